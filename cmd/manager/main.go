@@ -22,10 +22,11 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"time"
 
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/utils"
 	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/logger"
 	"github.com/Dynatrace/dynatrace-oneagent-operator/version"
-	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	"github.com/operator-framework/operator-sdk/pkg/leader"
 	"github.com/operator-framework/operator-sdk/pkg/log/zap"
 	sdkVersion "github.com/operator-framework/operator-sdk/version"
@@ -40,7 +41,7 @@ import (
 
 var log = logf.Log.WithName("cmd")
 
-var subcmdCallbacks = map[string]func(ns string, cfg *rest.Config) (manager.Manager, error){
+var subcmdCallbacks = map[string]func(namespaces []string, cfg *rest.Config) (manager.Manager, error){
 	"operator":             startOperator,
 	"webhook-bootstrapper": startWebhookBoostrapper,
 	"webhook-server":       startWebhookServer,
@@ -54,6 +55,16 @@ var (
 	keyFile  string
 )
 
+var (
+	maxConcurrentReconciles  int
+	instanceStatusWorkers    int
+	maxConcurrentAPIRequests int
+	dryRun                   bool
+	apiFailureThreshold      int
+	apiFailureCooldown       time.Duration
+	tokenExpiryWarning       time.Duration
+)
+
 func printVersion() {
 	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
 	log.Info(fmt.Sprintf("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH))
@@ -67,6 +78,16 @@ func main() {
 	webhookServerFlags.StringVar(&certFile, "cert", "tls.crt", "File name for the public certificate.")
 	webhookServerFlags.StringVar(&keyFile, "cert-key", "tls.key", "File name for the private key.")
 
+	operatorFlags := pflag.NewFlagSet("operator", pflag.ExitOnError)
+	operatorFlags.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1, "Number of OneAgent instances reconciled in parallel.")
+	operatorFlags.IntVar(&instanceStatusWorkers, "instance-status-workers", 4, "Number of pods whose OneAgent status is queried in parallel while reconciling an instance.")
+	operatorFlags.IntVar(&maxConcurrentAPIRequests, "max-concurrent-api-requests", 0, "Maximum number of requests in flight to the Dynatrace API, shared by all reconcilers. Unlimited if 0.")
+	operatorFlags.BoolVar(&dryRun, "dry-run", false, "Only log the changes the OneAgent controller would apply to the cluster.")
+	operatorFlags.IntVar(&apiFailureThreshold, "api-failure-threshold", 5, "Consecutive failed requests after which requests to a Dynatrace environment are stopped for the cooldown. Never stopped if 0.")
+	operatorFlags.DurationVar(&apiFailureCooldown, "api-failure-cooldown", 1*time.Minute, "Time requests to a Dynatrace environment are stopped for after repeated failures.")
+	operatorFlags.DurationVar(&tokenExpiryWarning, "token-expiry-warning", 14*24*time.Hour, "Time before the expiration of a Dynatrace token from which a warning condition is set on the OneAgent instances.")
+
+	pflag.CommandLine.AddFlagSet(operatorFlags)
 	pflag.CommandLine.AddFlagSet(webhookServerFlags)
 	pflag.CommandLine.AddFlagSet(zap.FlagSet())
 	err := pflag.Set("zap-time-encoding", "iso8601")
@@ -94,7 +115,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	namespace, err := k8sutil.GetWatchNamespace()
+	namespaces, err := utils.GetWatchNamespaces()
 	if err != nil {
 		log.Error(err, "Failed to get watch namespace")
 		os.Exit(1)
@@ -108,13 +129,17 @@ func main() {
 	}
 
 	// Become the leader before proceeding
-	err = leader.Become(context.Background(), fmt.Sprintf("dynatrace-oneagent-%s-lock", subcmd))
+	lock := fmt.Sprintf("dynatrace-oneagent-%s-lock", subcmd)
+	leadership := utils.NewLeadershipReporter(log, lock)
+	leadership.Report(false)
+	err = leader.Become(context.Background(), lock)
 	if err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
+	leadership.Report(true)
 
-	mgr, err := subcmdFn(namespace, cfg)
+	mgr, err := subcmdFn(namespaces, cfg)
 	if err != nil {
 		log.Error(err, "")
 		os.Exit(1)
@@ -22,17 +22,26 @@ import (
 	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/nodes"
 	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/oneagent"
 	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/oneagentapm"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/utils"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
-func startOperator(ns string, cfg *rest.Config) (manager.Manager, error) {
-	mgr, err := manager.New(cfg, manager.Options{Namespace: ns})
+func startOperator(namespaces []string, cfg *rest.Config) (manager.Manager, error) {
+	mgr, err := manager.New(cfg, managerOptions(namespaces))
 	if err != nil {
 		return nil, err
 	}
 
+	oneagent.MaxConcurrentReconciles = maxConcurrentReconciles
+	oneagent.InstanceStatusConcurrency = instanceStatusWorkers
+	oneagent.DryRun = dryRun
+	dtclient.DefaultRequestLimiter = dtclient.NewRequestLimiter(maxConcurrentAPIRequests)
+	dtclient.DefaultCircuitBreaker = dtclient.NewCircuitBreaker(apiFailureThreshold, apiFailureCooldown)
+	utils.TokenExpiryWarningWindow = tokenExpiryWarning
+
 	log.Info("Registering Components.")
 
 	if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
@@ -55,3 +64,13 @@ func startOperator(ns string, cfg *rest.Config) (manager.Manager, error) {
 
 	return mgr, nil
 }
+
+// managerOptions restricts the Manager's cache to the watched namespaces. A multi-namespace cache is only used when
+// more than one namespace is watched, still caching cluster-scoped objects for the whole cluster.
+func managerOptions(namespaces []string) manager.Options {
+	if len(namespaces) > 1 {
+		log.Info("Watching multiple namespaces", "namespaces", namespaces)
+		return manager.Options{NewCache: utils.NewMultiNamespaceCache(namespaces)}
+	}
+	return manager.Options{Namespace: namespaces[0]}
+}
@@ -24,9 +24,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
-func startWebhookBoostrapper(ns string, cfg *rest.Config) (manager.Manager, error) {
+func startWebhookBoostrapper(namespaces []string, cfg *rest.Config) (manager.Manager, error) {
 	mgr, err := manager.New(cfg, manager.Options{
-		Namespace:          ns,
+		Namespace:          namespaces[0],
 		MetricsBindAddress: "0.0.0.0:8484",
 	})
 	if err != nil {
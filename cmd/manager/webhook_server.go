@@ -28,9 +28,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
-func startWebhookServer(ns string, cfg *rest.Config) (manager.Manager, error) {
+func startWebhookServer(namespaces []string, cfg *rest.Config) (manager.Manager, error) {
 	mgr, err := manager.New(cfg, manager.Options{
-		Namespace:          ns,
+		Namespace:          namespaces[0],
 		MetricsBindAddress: "0.0.0.0:8383",
 		Port:               8443,
 	})
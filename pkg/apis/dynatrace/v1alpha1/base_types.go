@@ -43,13 +43,37 @@ type BaseOneAgentSpec struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:io.kubernetes:Secret"
 	Tokens string `json:"tokens,omitempty"`
 
+	// Optional: Namespace of the token secrets, e.g., for tokens managed centrally in a shared namespace - defaults to
+	// the namespace of the resource. The operator needs a Role and RoleBinding granting get on secrets in that
+	// namespace. The OneAgent pods can't reference secrets across namespaces, so the installer still takes the PaaS
+	// token from the secret with the same name in the namespace of the resource
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Tokens namespace"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	TokensNamespace string `json:"tokensNamespace,omitempty"`
+
+	// Optional: Name of a secret holding the PaaS token on the 'paasToken' field. Overrides the secret set on Tokens
+	// for the PaaS token
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="PaaS Token secret"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:io.kubernetes:Secret"
+	PaaSTokenSecret string `json:"paasTokenSecret,omitempty"`
+
+	// Optional: Name of a secret holding the API token on the 'apiToken' field. Overrides the secret set on Tokens
+	// for the API token
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="API Token secret"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:io.kubernetes:Secret"
+	APITokenSecret string `json:"apiTokenSecret,omitempty"`
+
 	// Disable certificate validation checks for installer download and API communication
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Skip Certificate Check"
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:booleanSwitch"
 	SkipCertCheck bool `json:"skipCertCheck,omitempty"`
 
-	// If enabled, Istio on the cluster will be configured automatically to allow access to the Dynatrace environment
+	// If enabled, Istio on the cluster will be configured automatically to allow access to the Dynatrace environment.
+	// If disabled, the Istio objects previously created by the operator for this resource are removed
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Enable Istio automatic management"
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:booleanSwitch"
@@ -100,6 +124,9 @@ type BaseOneAgentStatus struct {
 	// EnvironmentID contains the environment ID corresponding to the API URL
 	EnvironmentID string `json:"environmentID,omitempty"`
 
+	// APIURLHash contains the hash of the API URL the tokens were validated against, to detect changes of the tenant
+	APIURLHash string `json:"apiUrlHash,omitempty"`
+
 	// Credentials used for the OneAgent to connect back to Dynatrace.
 	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="API and PaaS Tokens"
@@ -117,6 +144,18 @@ type BaseOneAgentStatus struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Last cluster version probed"
 	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:text"
 	LastClusterVersionProbeTimestamp metav1.Time `json:"lastClusterVersionChecked,omitempty"`
+
+	// TokenScopes contains the scopes detected on the tokens on the last successful probe
+	TokenScopes TokenScopes `json:"tokenScopes,omitempty"`
+}
+
+// TokenScopes holds the scopes assigned to the PaaS and API tokens
+type TokenScopes struct {
+	// PaaSToken contains the scopes assigned to the PaaS token
+	PaaSToken []string `json:"paasToken,omitempty"`
+
+	// APIToken contains the scopes assigned to the API token
+	APIToken []string `json:"apiToken,omitempty"`
 }
 
 type OneAgentProxy struct {
@@ -130,6 +169,102 @@ const (
 
 	// PaaSTokenConditionType identifies the PaaS Token validity condition
 	PaaSTokenConditionType status.ConditionType = "PaaSToken"
+
+	// DeploymentEventConditionType identifies the condition for the last deployment event sent to Dynatrace
+	DeploymentEventConditionType status.ConditionType = "DeploymentEvent"
+
+	// DowngradeBlockedConditionType identifies the condition set when a OneAgent version downgrade has been prevented
+	DowngradeBlockedConditionType status.ConditionType = "DowngradeBlocked"
+
+	// MonitoringModeMismatchConditionType identifies the warning condition set when hosts report an unexpected monitoring mode
+	MonitoringModeMismatchConditionType status.ConditionType = "MonitoringModeMismatch"
+
+	// DNSConfigConditionType identifies the condition for the validity of the DNS config on the OneAgent pods
+	DNSConfigConditionType status.ConditionType = "DNSConfig"
+
+	// InsufficientPrivilegesConditionType identifies the warning condition set when the configured security context
+	// takes away privileges required by the OneAgent
+	InsufficientPrivilegesConditionType status.ConditionType = "InsufficientPrivileges"
+
+	// PausedConditionType identifies the condition set while reconciliation of the instance is paused
+	PausedConditionType status.ConditionType = "Paused"
+
+	// CustomVolumesConditionType identifies the condition for the validity of the custom volumes and volume mounts
+	CustomVolumesConditionType status.ConditionType = "CustomVolumes"
+
+	// UnmonitoredNodesConditionType identifies the warning condition set when selected nodes have no running OneAgent pod
+	UnmonitoredNodesConditionType status.ConditionType = "UnmonitoredNodes"
+
+	// UpdatingConditionType identifies the condition set while a new OneAgent version is rolled out
+	UpdatingConditionType status.ConditionType = "Updating"
+
+	// HostsNotCommunicatingConditionType identifies the warning condition set when too many hosts haven't been seen
+	// recently by Dynatrace
+	HostsNotCommunicatingConditionType status.ConditionType = "HostsNotCommunicating"
+
+	// FeatureFlagsConditionType identifies the warning condition set when unknown feature flags are configured
+	FeatureFlagsConditionType status.ConditionType = "FeatureFlags"
+
+	// ImmediateTerminationConditionType identifies the warning condition set when the OneAgent pods get no time to
+	// shut down
+	ImmediateTerminationConditionType status.ConditionType = "ImmediateTermination"
+
+	// UpdateDeferredConditionType identifies the condition set when OneAgent updates are restricted to update windows
+	UpdateDeferredConditionType status.ConditionType = "UpdateDeferred"
+
+	// UpdateSettingsConflictConditionType identifies the warning condition set when update settings on the spec
+	// contradict each other
+	UpdateSettingsConflictConditionType status.ConditionType = "UpdateSettingsConflict"
+
+	// CustomCommandConditionType identifies the warning condition set when the command of the OneAgent container is
+	// overridden
+	CustomCommandConditionType status.ConditionType = "CustomCommand"
+
+	// VersionSourceConditionType identifies the condition for the OneAgent version taken from .spec.versionSource
+	VersionSourceConditionType status.ConditionType = "VersionSource"
+
+	// LogMonitoringConditionType identifies the condition for the log monitoring configured on the OneAgent pods
+	LogMonitoringConditionType status.ConditionType = "LogMonitoring"
+
+	// APITokenExpiryConditionType identifies the warning condition set when the API token expires soon
+	APITokenExpiryConditionType status.ConditionType = "APITokenExpiry"
+
+	// PaaSTokenExpiryConditionType identifies the warning condition set when the PaaS token expires soon
+	PaaSTokenExpiryConditionType status.ConditionType = "PaaSTokenExpiry"
+
+	// SchedulingBlockedConditionType identifies the warning condition set when OneAgent pods can't be scheduled
+	SchedulingBlockedConditionType status.ConditionType = "SchedulingBlocked"
+
+	// InstallerSupportedConditionType identifies the condition reflecting whether the OneAgent version is available
+	// for the operating system and architecture of the selected nodes
+	InstallerSupportedConditionType status.ConditionType = "InstallerSupported"
+
+	// TenantChangedConditionType identifies the condition set when the API URL changes to a different tenant
+	TenantChangedConditionType status.ConditionType = "TenantChanged"
+
+	// IstioReadyConditionType identifies the condition reflecting whether the Istio objects are in sync
+	IstioReadyConditionType status.ConditionType = "IstioReady"
+
+	// AvailableConditionType identifies the condition summarizing the health reported by the other conditions
+	AvailableConditionType status.ConditionType = "Available"
+
+	// SecurityPresetConflictConditionType identifies the warning condition set when the OneAgent requires more
+	// privileges than allowed by the selected security preset
+	SecurityPresetConflictConditionType status.ConditionType = "SecurityPresetConflict"
+
+	// TenantMaintenanceConditionType identifies the informational condition set while the Dynatrace environment is
+	// under maintenance
+	TenantMaintenanceConditionType status.ConditionType = "TenantMaintenance"
+
+	// ImageDigestConditionType identifies the condition reflecting whether .spec.image pins the OneAgent image by a
+	// well-formed digest
+	ImageDigestConditionType status.ConditionType = "ImageDigest"
+
+	// PendingApprovalConditionType identifies the condition set while a OneAgent version update waits for approval
+	PendingApprovalConditionType status.ConditionType = "PendingApproval"
+
+	// VersionConsistencyConditionType identifies the condition reflecting whether all instances run .status.version
+	VersionConsistencyConditionType status.ConditionType = "VersionConsistency"
 )
 
 // Possible reasons for ApiToken and PaaSToken conditions
@@ -140,15 +275,247 @@ const (
 	// ReasonTokenSecretNotFound is set when the referenced secret can't be found
 	ReasonTokenSecretNotFound status.ConditionReason = "TokenSecretNotFound"
 
-	// ReasonTokenMissing is set when the field is missing on the secret
+	// ReasonTokenMissing is set when the field is empty on the secret
 	ReasonTokenMissing status.ConditionReason = "TokenMissing"
 
+	// ReasonTokenKeyMissing is set when the secret has no key for the token
+	ReasonTokenKeyMissing status.ConditionReason = "MissingKey"
+
 	// ReasonTokenUnauthorized is set when a token is unauthorized to query the Dynatrace API
 	ReasonTokenUnauthorized status.ConditionReason = "TokenUnauthorized"
 
 	// ReasonTokenScopeMissing is set when the token is missing the required scope for the Dynatrace API
 	ReasonTokenScopeMissing status.ConditionReason = "TokenScopeMissing"
 
+	// ReasonTokenScopeRevoked is set when a required scope, which the token had before, has been removed from it
+	ReasonTokenScopeRevoked status.ConditionReason = "TokenScopeRevoked"
+
 	// ReasonTokenError is set when an unknown error has been found when verifying the token
 	ReasonTokenError status.ConditionReason = "TokenError"
 )
+
+// Possible reasons for DeploymentEvent conditions
+const (
+	// ReasonDeploymentEventSent is set when the deployment event has been accepted by the Dynatrace API
+	ReasonDeploymentEventSent status.ConditionReason = "DeploymentEventSent"
+
+	// ReasonDeploymentEventFailed is set when the deployment event couldn't be sent to the Dynatrace API
+	ReasonDeploymentEventFailed status.ConditionReason = "DeploymentEventFailed"
+)
+
+// Possible reasons for DowngradeBlocked conditions
+const (
+	// ReasonDowngradeNotAllowed is set when the desired version is older than the deployed one and .spec.allowDowngrade is not set
+	ReasonDowngradeNotAllowed status.ConditionReason = "DowngradeNotAllowed"
+)
+
+// Possible reasons for MonitoringModeMismatch conditions
+const (
+	// ReasonMonitoringModeMatching is set when all hosts report the expected monitoring mode
+	ReasonMonitoringModeMatching status.ConditionReason = "MonitoringModeMatching"
+
+	// ReasonMonitoringModeMismatch is set when at least one host reports a different monitoring mode than the expected one
+	ReasonMonitoringModeMismatch status.ConditionReason = "MonitoringModeMismatch"
+)
+
+// Possible reasons for DNSConfig conditions
+const (
+	// ReasonDNSConfigValid is set when the DNS config can be applied with the DNS policy
+	ReasonDNSConfigValid status.ConditionReason = "DNSConfigValid"
+
+	// ReasonDNSConfigInvalid is set when the combination of DNS config and DNS policy would be rejected by Kubernetes
+	ReasonDNSConfigInvalid status.ConditionReason = "DNSConfigInvalid"
+)
+
+// Possible reasons for InsufficientPrivileges conditions
+const (
+	// ReasonPrivilegesSufficient is set when the custom security context grants the privileges required by the OneAgent
+	ReasonPrivilegesSufficient status.ConditionReason = "PrivilegesSufficient"
+
+	// ReasonPrivilegesRemoved is set when the custom security context removes privileges required by the OneAgent
+	ReasonPrivilegesRemoved status.ConditionReason = "PrivilegesRemoved"
+)
+
+// Possible reasons for Paused conditions
+const (
+	// ReasonReconcilePaused is set when the instance has the reconcile-paused annotation
+	ReasonReconcilePaused status.ConditionReason = "ReconcilePaused"
+)
+
+// Possible reasons for CustomVolumes conditions
+const (
+	// ReasonCustomVolumesApplied is set when all custom volumes and volume mounts have been added to the OneAgent pods
+	ReasonCustomVolumesApplied status.ConditionReason = "CustomVolumesApplied"
+
+	// ReasonCustomVolumesRejected is set when custom volumes or volume mounts have been left out from the OneAgent pods
+	ReasonCustomVolumesRejected status.ConditionReason = "CustomVolumesRejected"
+)
+
+// Possible reasons for UnmonitoredNodes conditions
+const (
+	// ReasonAllNodesMonitored is set when all selected nodes have a running OneAgent pod
+	ReasonAllNodesMonitored status.ConditionReason = "AllNodesMonitored"
+
+	// ReasonNodesUnmonitored is set when some of the selected nodes have no running OneAgent pod
+	ReasonNodesUnmonitored status.ConditionReason = "NodesUnmonitored"
+)
+
+// Possible reasons for Updating conditions
+const (
+	// ReasonUpdateInProgress is set while some of the OneAgent pods don't run the desired version yet
+	ReasonUpdateInProgress status.ConditionReason = "UpdateInProgress"
+
+	// ReasonUpdateCompleted is set when all OneAgent pods run the desired version
+	ReasonUpdateCompleted status.ConditionReason = "UpdateCompleted"
+)
+
+// Possible reasons for HostsNotCommunicating conditions
+const (
+	// ReasonHostsCommunicating is set when enough hosts have recently been seen by Dynatrace
+	ReasonHostsCommunicating status.ConditionReason = "HostsCommunicating"
+
+	// ReasonHostsNotCommunicating is set when too many hosts haven't recently been seen by Dynatrace
+	ReasonHostsNotCommunicating status.ConditionReason = "HostsNotCommunicating"
+)
+
+// Possible reasons for FeatureFlags conditions
+const (
+	// ReasonFeatureFlagsKnown is set when all configured feature flags are known to the operator
+	ReasonFeatureFlagsKnown status.ConditionReason = "FeatureFlagsKnown"
+
+	// ReasonUnknownFeatureFlags is set when some of the configured feature flags are unknown to the operator
+	ReasonUnknownFeatureFlags status.ConditionReason = "UnknownFeatureFlags"
+)
+
+// Possible reasons for ImmediateTermination conditions
+const (
+	// ReasonGracePeriodSet is set when the OneAgent pods get time to flush buffered data on shutdown
+	ReasonGracePeriodSet status.ConditionReason = "GracePeriodSet"
+
+	// ReasonNoGracePeriod is set when the OneAgent pods are killed immediately on shutdown
+	ReasonNoGracePeriod status.ConditionReason = "NoGracePeriod"
+)
+
+// Possible reasons for UpdateDeferred conditions
+const (
+	// ReasonInsideUpdateWindow is set when OneAgent updates are allowed within the current update window
+	ReasonInsideUpdateWindow status.ConditionReason = "InsideUpdateWindow"
+
+	// ReasonOutsideUpdateWindow is set when OneAgent updates are deferred until the next update window
+	ReasonOutsideUpdateWindow status.ConditionReason = "OutsideUpdateWindow"
+)
+
+// Possible reasons for UpdateSettingsConflict conditions
+const (
+	// ReasonUpdateSettingsConsistent is set when the update settings don't contradict each other
+	ReasonUpdateSettingsConsistent status.ConditionReason = "UpdateSettingsConsistent"
+
+	// ReasonConflictingUpdateSettings is set when some update settings are overridden by others
+	ReasonConflictingUpdateSettings status.ConditionReason = "ConflictingUpdateSettings"
+)
+
+// Possible reasons for CustomCommand conditions
+const (
+	// ReasonCommandOverridden is set when .spec.command replaces the command of the OneAgent container
+	ReasonCommandOverridden status.ConditionReason = "CommandOverridden"
+)
+
+// Possible reasons for VersionSource conditions
+const (
+	// ReasonVersionSourceApplied is set when the version of the referenced OneAgent is used
+	ReasonVersionSourceApplied status.ConditionReason = "VersionSourceApplied"
+	// ReasonVersionSourceNotFound is set when the referenced OneAgent doesn't exist
+	ReasonVersionSourceNotFound status.ConditionReason = "VersionSourceNotFound"
+	// ReasonVersionSourcePending is set when the referenced OneAgent has no version deployed yet
+	ReasonVersionSourcePending status.ConditionReason = "VersionSourcePending"
+)
+
+// Possible reasons for LogMonitoring conditions
+const (
+	// ReasonLogMonitoringEnabled is set when .spec.logMonitoring enables the ingestion of logs
+	ReasonLogMonitoringEnabled status.ConditionReason = "LogMonitoringEnabled"
+	// ReasonLogMonitoringDisabled is set when .spec.logMonitoring is set, but not enabled
+	ReasonLogMonitoringDisabled status.ConditionReason = "LogMonitoringDisabled"
+)
+
+// Possible reasons for APITokenExpiry and PaaSTokenExpiry conditions
+const (
+	// ReasonTokenExpiresSoon is set when the token expires within the warning window
+	ReasonTokenExpiresSoon status.ConditionReason = "TokenExpiresSoon"
+	// ReasonTokenExpired is set when the expiration date of the token has passed
+	ReasonTokenExpired status.ConditionReason = "TokenExpired"
+	// ReasonTokenNotExpiring is set when the token has no expiration date, or it's beyond the warning window
+	ReasonTokenNotExpiring status.ConditionReason = "TokenNotExpiring"
+)
+
+// Possible reasons for SchedulingBlocked conditions
+const (
+	// ReasonPodsUnschedulable is set when at least one OneAgent pod is pending as the scheduler can't place it
+	ReasonPodsUnschedulable status.ConditionReason = "PodsUnschedulable"
+	// ReasonPodsScheduled is set when no OneAgent pod is blocked from being scheduled
+	ReasonPodsScheduled status.ConditionReason = "PodsScheduled"
+)
+
+// Possible reasons for IstioReady conditions
+const (
+	// ReasonIstioObjectsSynced is set when the Istio objects for all Dynatrace endpoints are in sync
+	ReasonIstioObjectsSynced status.ConditionReason = "IstioObjectsSynced"
+	// ReasonIstioReconcileFailed is set when Istio is installed, but its objects couldn't be reconciled
+	ReasonIstioReconcileFailed status.ConditionReason = "IstioReconcileFailed"
+)
+
+// Possible reasons for TenantChanged conditions
+const (
+	// ReasonAPIURLChanged is set when .spec.apiUrl changed, and the tokens are validated against the new tenant
+	ReasonAPIURLChanged status.ConditionReason = "APIURLChanged"
+)
+
+// Possible reasons for InstallerSupported conditions
+const (
+	// ReasonInstallerAvailable is set when the OneAgent version is available for the selected nodes
+	ReasonInstallerAvailable status.ConditionReason = "InstallerAvailable"
+	// ReasonInstallerUnavailable is set when the OneAgent version isn't available for the selected nodes
+	ReasonInstallerUnavailable status.ConditionReason = "InstallerUnavailable"
+)
+
+// Possible reasons for Available conditions
+const (
+	// ReasonAllConditionsHealthy is set when the tokens, pods, version and host communication are all healthy
+	ReasonAllConditionsHealthy status.ConditionReason = "AllConditionsHealthy"
+	// ReasonConditionsUnhealthy is set when at least one of the summarized conditions reports a problem
+	ReasonConditionsUnhealthy status.ConditionReason = "ConditionsUnhealthy"
+)
+
+// Possible reasons for SecurityPresetConflict conditions
+const (
+	// ReasonPrivilegesRequired is set when the OneAgent pods need privileges forbidden by the restricted Pod Security Standard
+	ReasonPrivilegesRequired status.ConditionReason = "PrivilegesRequired"
+)
+
+// Possible reasons for TenantMaintenance conditions
+const (
+	// ReasonTenantInMaintenance is set when the Dynatrace environment reports to be under maintenance
+	ReasonTenantInMaintenance status.ConditionReason = "TenantInMaintenance"
+)
+
+// Possible reasons for ImageDigest conditions
+const (
+	// ReasonImageDigestPinned is set when .spec.image references the OneAgent image by a valid sha256 digest
+	ReasonImageDigestPinned status.ConditionReason = "ImageDigestPinned"
+	// ReasonImageDigestMalformed is set when the digest on .spec.image isn't a valid sha256 digest
+	ReasonImageDigestMalformed status.ConditionReason = "ImageDigestMalformed"
+)
+
+// Possible reasons for PendingApproval conditions
+const (
+	// ReasonApprovalRequired is set when a version update is held until the target version is approved
+	ReasonApprovalRequired status.ConditionReason = "ApprovalRequired"
+)
+
+// Possible reasons for VersionConsistency conditions
+const (
+	// ReasonVersionsConsistent is set when all instances with a known version run .status.version
+	ReasonVersionsConsistent status.ConditionReason = "VersionsConsistent"
+	// ReasonVersionsLagging is set when some instances run a version other than .status.version
+	ReasonVersionsLagging status.ConditionReason = "VersionsLagging"
+)
@@ -0,0 +1,58 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Defaults for the OneAgent spec. They're filled onto the objects by the defaulting webhook, and applied by the
+// operator to unset fields on installations without webhook.
+const (
+	// DefaultDNSPolicy is the DNS policy of the OneAgent pods
+	DefaultDNSPolicy = corev1.DNSClusterFirst
+
+	// DefaultWaitReadySeconds is the time to wait for a restarted OneAgent pod to become ready during updates
+	DefaultWaitReadySeconds uint16 = 300
+
+	// DefaultDeploymentType is the kind of workload running the OneAgent pods
+	DefaultDeploymentType = DeploymentTypeDaemonSet
+
+	// DefaultReplicas is the number of OneAgent pods run by a Deployment
+	DefaultReplicas int32 = 1
+
+	// DefaultCanaryPromotion is the promotion of canary rollouts
+	DefaultCanaryPromotion = CanaryPromotionManual
+)
+
+var _ admission.Defaulter = &OneAgent{}
+
+// Default fills the unset fields of the spec with their defaults, so that the effective spec gets visible on the object.
+func (oa *OneAgent) Default() {
+	oa.Spec.SetDefaults()
+}
+
+// SetDefaults fills the unset fields of the spec with their defaults. Replicas are only defaulted for the Deployment
+// deployment type, and the canary promotion only if a canary rollout is configured.
+func (spec *OneAgentSpec) SetDefaults() {
+	if spec.DNSPolicy == "" {
+		spec.DNSPolicy = DefaultDNSPolicy
+	}
+
+	if spec.WaitReadySeconds == nil {
+		secs := DefaultWaitReadySeconds
+		spec.WaitReadySeconds = &secs
+	}
+
+	if spec.DeploymentType == "" {
+		spec.DeploymentType = DefaultDeploymentType
+	}
+
+	if spec.DeploymentType == DeploymentTypeDeployment && spec.Replicas == nil {
+		replicas := DefaultReplicas
+		spec.Replicas = &replicas
+	}
+
+	if spec.CanaryRollout != nil && spec.CanaryRollout.Promotion == "" {
+		spec.CanaryRollout.Promotion = DefaultCanaryPromotion
+	}
+}
@@ -4,6 +4,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type BaseOneAgentDaemonSet interface {
@@ -39,6 +40,14 @@ type OneAgentSpec struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:number"
 	WaitReadySeconds *uint16 `json:"waitReadySeconds,omitempty"`
 
+	// Optional: Defines the time given to the OneAgent pods to flush buffered data on shutdown - default 60 sec. A
+	// value of 0 kills the pods immediately
+	// +kubebuilder:validation:Minimum=0
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Termination grace period seconds"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:number"
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
 	// Optional: the Dynatrace installer container image
 	// Defaults to docker.io/dynatrace/oneagent:latest for Kubernetes and to registry.connect.redhat.com/dynatrace/oneagent for OpenShift
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
@@ -46,14 +55,54 @@ type OneAgentSpec struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
 	Image string `json:"image,omitempty"`
 
+	// Optional: Repository of the OneAgent image without tag, e.g. of a mirror keeping Dynatrace's tag scheme. The image
+	// is composed from the repository and .spec.agentVersion as tag, or latest if unset. Ignored if .spec.image is set
+	// +kubebuilder:validation:Pattern=`^([a-zA-Z0-9.-]+(:[0-9]+)?/)?[a-z0-9]+([._-]+[a-z0-9]+)*(/[a-z0-9]+([._-]+[a-z0-9]+)*)*$`
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Image repository"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	ImageRepository string `json:"imageRepository,omitempty"`
+
 	// Optional: If specified, indicates the OneAgent version to use
-	// Defaults to latest
+	// Defaults to latest. If .spec.disableAgentUpdate is set, it's only used for the initial deployment
 	// Example: {major.minor.release} - 1.200.0
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="OneAgent version"
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
 	AgentVersion string `json:"agentVersion,omitempty"`
 
+	// Optional: Allows the Operator to move the OneAgent to an older version than the one currently deployed, e.g.
+	// when an older version is pinned or the latest version on the environment is rolled back. Defaults to false
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Allow downgrade"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:booleanSwitch"
+	AllowDowngrade bool `json:"allowDowngrade,omitempty"`
+
+	// Optional: Holds OneAgent version updates until they are approved by setting the annotation
+	// dynatrace.com/approve-version to the target version. Pending updates are shown on the PendingApproval condition.
+	// Defaults to false
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Require update approval"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:booleanSwitch"
+	RequireUpdateApproval bool `json:"requireUpdateApproval,omitempty"`
+
+	// Optional: Name of another OneAgent in the same namespace to take the version from, so that both stay on the
+	// same version. Replaces the latest version available on the environment. Only applies to updates through the
+	// installer, without immutable images
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Version source"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	VersionSource string `json:"versionSource,omitempty"`
+
+	// Optional: Restricts updates to the newest version available within the branch, e.g. 1.247 for 1.247.x, instead
+	// of the latest version on the environment. Ignored if .spec.versionSource is set. Only applies to updates through
+	// the installer, without immutable images
+	// +kubebuilder:validation:Pattern=`^\d+(\.\d+)*$`
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Version branch"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	VersionBranch string `json:"versionBranch,omitempty"`
+
 	// Optional: Pull secret for your private registry
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Custom PullSecret"
@@ -67,6 +116,55 @@ type OneAgentSpec struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
 	Args []string `json:"args,omitempty"`
 
+	// Optional: Replaces the command of the OneAgent container, e.g. for debugging or custom images
+	// If set, only .spec.args are passed as arguments, without the ones generated by the Operator. Overriding the
+	// command may break the OneAgent installation
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="OneAgent container command"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	Command []string `json:"command,omitempty"`
+
+	// Optional: Additional arguments to the OneAgent installer by the operating system of the nodes, appended to
+	// .spec.args. Supported keys are "linux" and "windows"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="OneAgent installer arguments by OS"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	InstallerArgs map[string][]string `json:"installerArgs,omitempty"`
+
+	// Optional: Tags to set on the monitored hosts, each passed to the OneAgent installer as --set-host-tag
+	// +listType=set
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Host tags"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	HostTags []string `json:"hostTags,omitempty"`
+
+	// Optional: Custom properties to set on the monitored hosts, each passed to the OneAgent installer as
+	// --set-host-property
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Host properties"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	HostProperties map[string]string `json:"hostProperties,omitempty"`
+
+	// Optional: Stable name of the Kubernetes cluster, set on the monitored hosts as the ClusterName host property and
+	// sent along with deployment events. Defaults to the UID of the kube-system namespace
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Cluster name"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:text"
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// Optional: OneAgent feature flags, each passed to the OneAgent installer as --set-<flag>=<value>. Unknown flags
+	// are passed as well, but reported on the FeatureFlags condition
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Feature flags"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	FeatureFlags map[string]string `json:"featureFlags,omitempty"`
+
+	// Optional: Enables the ingestion of logs by the OneAgent, including the log files on the given paths
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Log monitoring"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	LogMonitoring *LogMonitoring `json:"logMonitoring,omitempty"`
+
 	// Optional: List of environment variables to set for the installer
 	// +listType=set
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
@@ -80,6 +178,29 @@ type OneAgentSpec struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:resourceRequirements"
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 
+	// Optional: Node label whose values identify the node classes on .spec.resourcesByNodeClass, e.g.
+	// node.kubernetes.io/instance-type
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Node class label"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	NodeClassLabel string `json:"nodeClassLabel,omitempty"`
+
+	// Optional: Resources requests and limits per value of the .spec.nodeClassLabel node label. Applied if
+	// .spec.nodeSelector restricts the OneAgent pods to a single node class, e.g., with one OneAgent per node pool.
+	// Otherwise .spec.resources is used
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Resources by node class"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	ResourcesByNodeClass map[string]corev1.ResourceRequirements `json:"resourcesByNodeClass,omitempty"`
+
+	// Optional: Adjusts the arguments and resources of the OneAgent pods on the nodes matching a node selector, e.g.,
+	// for GPU nodes. Each override gets its own DaemonSet, and the nodes it selects are left out from the DaemonSet of
+	// the instance. Nodes matching several overrides get the first one
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Node overrides"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	NodeOverrides []NodeOverride `json:"nodeOverrides,omitempty"`
+
 	// Optional: If specified, indicates the pod's priority. Name must be defined by creating a PriorityClass object with that
 	// name. If not specified the setting will be removed from the DaemonSet.
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
@@ -87,31 +208,313 @@ type OneAgentSpec struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:io.kubernetes:PriorityClass"
 	PriorityClassName string `json:"priorityClassName,omitempty"`
 
+	// Optional: Whether the OneAgent pods preempt pods of lower priority, either Never or PreemptLowerPriority. If not
+	// specified the default of the priority class applies.
+	// +kubebuilder:validation:Enum=Never;PreemptLowerPriority
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Preemption policy"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:select:Never,urn:alm:descriptor:com.tectonic.ui:select:PreemptLowerPriority"
+	PreemptionPolicy *corev1.PreemptionPolicy `json:"preemptionPolicy,omitempty"`
+
 	// Disable automatic restarts of OneAgent pods in case a new version is available
+	// Takes precedence over .spec.agentVersion and .spec.allowDowngrade once the OneAgent is deployed
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Disable Agent update"
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:booleanSwitch"
 	DisableAgentUpdate bool `json:"disableAgentUpdate,omitempty"`
 
+	// Optional: Controls whether the OneAgent updates itself in place, independently of the operator. If disabled,
+	// the OneAgent only gets updated by the operator restarting its pods with a new version. Defaults to true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Agent auto-update"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:booleanSwitch"
+	AutoUpdate *bool `json:"autoUpdate,omitempty"`
+
+	// Optional: Restricts automatic updates of the OneAgent pods to the given maintenance windows. Outside the windows,
+	// pods keep running their current version. If not set, updates are applied at any time
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Update windows"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	UpdateWindows []UpdateWindow `json:"updateWindows,omitempty"`
+
+	// Optional: Number of deployed OneAgent versions kept on .status.versionHistory. Defaults to 10
+	// +kubebuilder:validation:Minimum=0
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Version history limit"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:number"
+	VersionHistoryLimit *int32 `json:"versionHistoryLimit,omitempty"`
+
+	// Optional: If enabled, nodes running a ready OneAgent pod are labeled with dynatrace.com/oneagent=ready, and the
+	// label is removed from selected nodes without one. Requires the operator to be allowed to patch nodes
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Label monitored nodes"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:booleanSwitch"
+	LabelMonitoredNodes bool `json:"labelMonitoredNodes,omitempty"`
+
+	// Optional: If enabled, nodes being removed by the cluster autoscaler, i.e., carrying its ToBeDeletedByClusterAutoscaler
+	// taint, aren't reported on .status.unmonitoredNodes, and their entries on .status.instances are kept unchanged
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Ignore scale-down nodes"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:booleanSwitch"
+	IgnoreScaleDownNodes bool `json:"ignoreScaleDownNodes,omitempty"`
+
+	// Optional: Node label to group the instances by on .status.instancesByZone, e.g. topology.kubernetes.io/zone.
+	// Instances on nodes without the label are grouped under <none>. Not grouped if not set
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Instances grouping label"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	InstancesGroupingLabel string `json:"instancesGroupingLabel,omitempty"`
+
 	// Optional: Sets DNS Policy for the OneAgent pods
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="DNS Policy"
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
 	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
 
+	// Optional: Sets custom DNS settings for the OneAgent pods, e.g. nameservers and search domains
+	// They are merged with the ones generated from the DNS policy, unless it is set to None
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="DNS Config"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
 	// Optional: set custom Service Account Name used with OneAgent pods
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Service Account name"
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:io.kubernetes:ServiceAccount"
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
 
-	// Optional: Adds additional labels for the OneAgent pods
+	// Optional: Adds additional labels for the OneAgent pods. They are not part of the DaemonSet selector, and cannot
+	// override the labels "dynatrace" and "oneagent" used by it
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Labels"
 	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
 	Labels map[string]string `json:"labels,omitempty"`
+
+	// Optional: Adds annotations to the OneAgent pods, e.g. for Prometheus to scrape the metrics of the OneAgent.
+	// Values are set literally, and cannot override the annotations managed by the operator
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Pod annotations"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+
+	// Optional: Overrides the security context of the OneAgent container, by default it runs privileged
+	// Only the fields set replace the defaults
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Security Context"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// Optional: Sets the security context of the OneAgent pods
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Pod Security Context"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// Optional: Preset for the security context of the OneAgent container, either Privileged or Restricted - default
+	// Privileged. Restricted only adds the capabilities required by the OneAgent instead of running privileged. Fields
+	// set on SecurityContext still override the preset
+	// +kubebuilder:validation:Enum=Privileged;Restricted
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Security Preset"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:select:Privileged,urn:alm:descriptor:com.tectonic.ui:select:Restricted"
+	SecurityPreset SecurityPreset `json:"securityPreset,omitempty"`
+
+	// Optional: Additional volumes for the OneAgent pods. Only hostPath, configMap and secret sources are supported
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Volumes"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// Optional: Additional volume mounts for the OneAgent container. Mounts colliding with the ones managed by the
+	// operator are rejected
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Volume Mounts"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// Optional: Absolute path of the directory on the host where the OneAgent stores its data, for node filesystem
+	// layouts where the default location in /var/opt/dynatrace can't be used
+	// +kubebuilder:validation:Pattern=`^/`
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Storage host path"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	StorageHostPath string `json:"storageHostPath,omitempty"`
+
+	// Optional: Back the OneAgent storage with an emptyDir volume instead of the host filesystem, for nodes with a
+	// read-only root filesystem. The data is lost when the pod is removed. Can't be combined with .spec.storageHostPath
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Use emptyDir cache"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:booleanSwitch"
+	UseEmptyDirCache bool `json:"useEmptyDirCache,omitempty"`
+
+	// Optional: Size limit of the emptyDir volume enabled by .spec.useEmptyDirCache, as a quantity like 2Gi. Unlimited
+	// if unset
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Cache size limit"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	CacheSizeLimit string `json:"cacheSizeLimit,omitempty"`
+
+	// Optional: Additional containers to run next to the OneAgent container, e.g., for logging or metrics. Sidecars can
+	// mount the volumes from .spec.volumes, and must not use the name of the OneAgent container
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Sidecars"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+
+	// Optional: Init containers to run before the OneAgent container starts, e.g., to prepare data on a shared volume.
+	// Init containers can mount the volumes from .spec.volumes, and must not use the name of another container
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Init containers"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// Optional: Readiness gates added to the OneAgent pods, so that external controllers, e.g. service meshes or rollout
+	// tools, take part in their readiness through the given pod conditions
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Readiness gates"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	ReadinessGates []corev1.PodReadinessGate `json:"readinessGates,omitempty"`
+
+	// Optional: Overrides the startup probe of the OneAgent container. Defaults to a check for the running OneAgent
+	// watchdog, which allows up to 10 minutes for the installation to finish
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Startup Probe"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
+	// Optional: Sets a liveness probe on the OneAgent container, which only runs after the startup probe succeeded
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Liveness Probe"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+
+	// Optional: Overrides the preStop hook of the OneAgent container. Defaults to stopping the OneAgent watchdog and
+	// waiting for it to exit, bounded by .spec.terminationGracePeriodSeconds, so that buffered data gets flushed
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="PreStop hook"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	PreStop *corev1.Handler `json:"preStop,omitempty"`
+
+	// Optional: Creates a PodDisruptionBudget for the OneAgent pods
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Create PodDisruptionBudget"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:booleanSwitch"
+	CreatePodDisruptionBudget bool `json:"createPodDisruptionBudget,omitempty"`
+
+	// Optional: Defines the number or percentage of OneAgent pods that can be unavailable on the PodDisruptionBudget - default 1
+	// For DaemonSets, the PodDisruptionBudget gets the number of pods to keep available instead, computed from the pods
+	// desired by the DaemonSets, as the disruption controller can't resolve the unavailable pods of DaemonSets
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="PodDisruptionBudget max unavailable"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	PodDisruptionBudgetMaxUnavailable *intstr.IntOrString `json:"podDisruptionBudgetMaxUnavailable,omitempty"`
+
+	// Optional: Kind of workload running the OneAgent pods, either DaemonSet or Deployment - default DaemonSet
+	// A Deployment doesn't monitor every node, and is only meant for specific use cases, e.g. virtual nodes
+	// +kubebuilder:validation:Enum=DaemonSet;Deployment
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Deployment type"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:select:DaemonSet,urn:alm:descriptor:com.tectonic.ui:select:Deployment"
+	DeploymentType DeploymentType `json:"deploymentType,omitempty"`
+
+	// Optional: Number of OneAgent pods if the deployment type is Deployment - default 1
+	// +kubebuilder:validation:Minimum=0
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Replicas"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:podCount"
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Optional: Rolls out new OneAgent versions to a percentage of the nodes first, and holds the update of the
+	// remaining nodes until the canary is promoted. Only applies to updates through the installer, without immutable
+	// images
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Canary rollout"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	CanaryRollout *CanaryRollout `json:"canaryRollout,omitempty"`
+
+	// Optional: Selects the namespaces in scope for application monitoring, resolved on .status.monitoredNamespaces.
+	// No injection happens yet, but the resolved list allows to verify the selector upfront
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="Namespace selector"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced"
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Optional: Name of the DaemonSet running the OneAgent pods. Defaults to the name of the OneAgent. The DaemonSet
+	// with the previous name is removed on change
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.displayName="DaemonSet name"
+	// +operator-sdk:gen-csv:customresourcedefinitions.specDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:advanced,urn:alm:descriptor:com.tectonic.ui:text"
+	DaemonSetName string `json:"daemonSetName,omitempty"`
 }
 
+// LogMonitoring configures the ingestion of logs by the OneAgent
+// +k8s:openapi-gen=true
+type LogMonitoring struct {
+	// Enabled turns on the access to system and application logs
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Paths are absolute paths of additional log files or directories on the host to ingest. Duplicates are ignored
+	Paths []string `json:"paths,omitempty"`
+}
+
+// CanaryRollout defines how new OneAgent versions are rolled out to a subset of the nodes first
+// +k8s:openapi-gen=true
+type CanaryRollout struct {
+	// Percentage of the nodes updated first, between 1 and 100. Rounded up to at least one node
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Percentage int32 `json:"percentage"`
+
+	// Promotion defines how the canary gets promoted to the remaining nodes, either Manual or Automatic - default
+	// Manual. Automatic promotion happens once all canary pods run the new version and are ready
+	// +kubebuilder:validation:Enum=Manual;Automatic
+	Promotion CanaryPromotion `json:"promotion,omitempty"`
+
+	// PromotedVersion promotes the canary with the given version to the remaining nodes if promotion is Manual, see
+	// .status.canary.version
+	PromotedVersion string `json:"promotedVersion,omitempty"`
+}
+
+// CanaryPromotion defines how a canary rollout gets promoted
+type CanaryPromotion string
+
+const (
+	CanaryPromotionManual    CanaryPromotion = "Manual"
+	CanaryPromotionAutomatic CanaryPromotion = "Automatic"
+)
+
+// SecurityPreset defines the strictness of the security context of the OneAgent container
+type SecurityPreset string
+
+const (
+	SecurityPresetPrivileged SecurityPreset = "Privileged"
+	SecurityPresetRestricted SecurityPreset = "Restricted"
+)
+
+// NodeOverride adjusts the OneAgent pods on the nodes matching its node selector
+type NodeOverride struct {
+	// Name of the override, appended to the name of the instance for the name of its DaemonSet
+	Name string `json:"name"`
+
+	// NodeSelector selects the nodes the override applies to, in addition to .spec.nodeSelector
+	NodeSelector map[string]string `json:"nodeSelector"`
+
+	// Args are appended to .spec.args for the OneAgent pods on the selected nodes
+	Args []string `json:"args,omitempty"`
+
+	// Resources replace .spec.resources for the OneAgent pods on the selected nodes if set
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// DeploymentType is the kind of workload running the OneAgent pods
+type DeploymentType string
+
+const (
+	DeploymentTypeDaemonSet  DeploymentType = "DaemonSet"
+	DeploymentTypeDeployment DeploymentType = "Deployment"
+)
+
 type OneAgentPhaseType string
 
 const (
@@ -131,16 +534,139 @@ type OneAgentStatus struct {
 	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:text"
 	Version string `json:"version,omitempty"`
 
+	// VersionHistory lists the most recent OneAgent versions deployed by the operator, oldest first
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Version History"
+	VersionHistory []OneAgentVersionHistoryEntry `json:"versionHistory,omitempty"`
+
+	// ObservedGeneration is the .metadata.generation of the OneAgent last processed by a successful reconciliation.
+	// A lower value than .metadata.generation means the latest spec changes are still pending
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Observed Generation"
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:text"
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ObservedReconcileNow is the value of the dynatrace.com/reconcile-now annotation last processed by a successful
+	// reconciliation
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Observed Reconcile Now"
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:text"
+	ObservedReconcileNow string `json:"observedReconcileNow,omitempty"`
+
 	Instances map[string]OneAgentInstance `json:"instances,omitempty"`
 
+	// InstancesByZone groups the nodes on .status.instances by the value of the node label set on
+	// .spec.instancesGroupingLabel
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Instances by zone"
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:text"
+	InstancesByZone map[string][]string `json:"instancesByZone,omitempty"`
+
 	// Defines the current state (Running, Updating, Error, ...)
 	Phase OneAgentPhaseType `json:"phase,omitempty"`
+
+	// DeploymentStatus summarizes the rollout state of the OneAgent DaemonSet
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Deployment Status"
+	DeploymentStatus OneAgentDeploymentStatus `json:"deploymentStatus,omitempty"`
+
+	// Deployed is true once all OneAgent pods are updated, ready and running the expected version
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Deployed"
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:com.tectonic.ui:booleanSwitch"
+	Deployed bool `json:"deployed,omitempty"`
+
+	// UnmonitoredNodes lists the nodes selected for the OneAgent that have no running OneAgent pod
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Unmonitored Nodes"
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:text"
+	UnmonitoredNodes []string `json:"unmonitoredNodes,omitempty"`
+
+	// Canary shows the progress of the canary rollout if .spec.canaryRollout is set
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Canary"
+	Canary *OneAgentCanaryStatus `json:"canary,omitempty"`
+
+	// ConfigHash is the hash of the installer arguments and environment variables the OneAgent pods are expected to
+	// run with, changing whenever the effective agent configuration changes
+	ConfigHash string `json:"configHash,omitempty"`
+
+	// MonitoredNamespaces lists the namespaces matching .spec.namespaceSelector, sorted by name
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors=true
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.displayName="Monitored Namespaces"
+	// +operator-sdk:gen-csv:customresourcedefinitions.statusDescriptors.x-descriptors="urn:alm:descriptor:text"
+	MonitoredNamespaces []string `json:"monitoredNamespaces,omitempty"`
+}
+
+// OneAgentCanaryStatus contains the progress of the canary rollout of a OneAgent version
+// +k8s:openapi-gen=true
+type OneAgentCanaryStatus struct {
+	// Version is the OneAgent version rolled out to the canary nodes
+	Version string `json:"version"`
+
+	// Nodes are the nodes selected for the canary
+	Nodes []string `json:"nodes,omitempty"`
+
+	// Updated is the number of canary nodes that have the OneAgent pod running the version and ready
+	Updated int32 `json:"updated"`
+
+	// Promoted is true once the version gets rolled out to the remaining nodes
+	Promoted bool `json:"promoted,omitempty"`
+}
+
+// OneAgentDeploymentStatus contains the pod counts reported by the OneAgent DaemonSet
+// +k8s:openapi-gen=true
+type OneAgentDeploymentStatus struct {
+	// Desired is the number of nodes that should be running the OneAgent pod
+	Desired int32 `json:"desired"`
+
+	// Ready is the number of nodes that have the OneAgent pod running and ready
+	Ready int32 `json:"ready"`
+
+	// Updated is the number of nodes that are running the latest OneAgent pod template
+	Updated int32 `json:"updated"`
+}
+
+// OneAgentVersionHistoryEntry records a OneAgent version deployed by the operator
+// +k8s:openapi-gen=true
+type OneAgentVersionHistoryEntry struct {
+	// Version is the OneAgent version
+	Version string `json:"version"`
+
+	// Timestamp indicates when the operator started deploying the version
+	Timestamp metav1.Time `json:"timestamp"`
+}
+
+// UpdateWindow defines a recurring maintenance window during which OneAgent updates are allowed
+// +k8s:openapi-gen=true
+type UpdateWindow struct {
+	// Days of the week the window starts on, e.g. "Monday" or "Mon". If empty, the window starts every day
+	Days []string `json:"days,omitempty"`
+
+	// StartTime is the time of day the window starts at, formatted as HH:MM
+	// +kubebuilder:validation:Pattern=`^([01]?[0-9]|2[0-3]):[0-5][0-9]$`
+	StartTime string `json:"startTime"`
+
+	// EndTime is the time of day the window ends at, formatted as HH:MM. Windows ending before they start last until
+	// the next day
+	// +kubebuilder:validation:Pattern=`^([01]?[0-9]|2[0-3]):[0-5][0-9]$`
+	EndTime string `json:"endTime"`
+
+	// Timezone is the IANA name of the time zone for StartTime and EndTime. Defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
 }
 
 type OneAgentInstance struct {
-	PodName   string `json:"podName,omitempty"`
-	Version   string `json:"version,omitempty"`
-	IPAddress string `json:"ipAddress,omitempty"`
+	PodName        string `json:"podName,omitempty"`
+	Version        string `json:"version,omitempty"`
+	IPAddress      string `json:"ipAddress,omitempty"`
+	MonitoringMode string `json:"monitoringMode,omitempty"`
+
+	// LastSeen is the last time Dynatrace received data from the host
+	LastSeen *metav1.Time `json:"lastSeen,omitempty"`
+
+	// CommunicationOk is true if Dynatrace has recently received data from the host
+	CommunicationOk bool `json:"communicationOk,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -23,7 +23,9 @@ package v1alpha1
 import (
 	status "github.com/operator-framework/operator-sdk/pkg/status"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -66,6 +68,8 @@ func (in *BaseOneAgentStatus) DeepCopyInto(out *BaseOneAgentStatus) {
 		in, out := &in.LastPaaSTokenProbeTimestamp, &out.LastPaaSTokenProbeTimestamp
 		*out = (*in).DeepCopy()
 	}
+	in.LastClusterVersionProbeTimestamp.DeepCopyInto(&out.LastClusterVersionProbeTimestamp)
+	in.TokenScopes.DeepCopyInto(&out.TokenScopes)
 	return
 }
 
@@ -79,6 +83,76 @@ func (in *BaseOneAgentStatus) DeepCopy() *BaseOneAgentStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryRollout) DeepCopyInto(out *CanaryRollout) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRollout.
+func (in *CanaryRollout) DeepCopy() *CanaryRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LogMonitoring) DeepCopyInto(out *LogMonitoring) {
+	*out = *in
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LogMonitoring.
+func (in *LogMonitoring) DeepCopy() *LogMonitoring {
+	if in == nil {
+		return nil
+	}
+	out := new(LogMonitoring)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeOverride) DeepCopyInto(out *NodeOverride) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeOverride.
+func (in *NodeOverride) DeepCopy() *NodeOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OneAgent) DeepCopyInto(out *OneAgent) {
 	*out = *in
@@ -202,6 +276,43 @@ func (in *OneAgentAPMStatus) DeepCopy() *OneAgentAPMStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OneAgentCanaryStatus) DeepCopyInto(out *OneAgentCanaryStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OneAgentCanaryStatus.
+func (in *OneAgentCanaryStatus) DeepCopy() *OneAgentCanaryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OneAgentCanaryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OneAgentDeploymentStatus) DeepCopyInto(out *OneAgentDeploymentStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OneAgentDeploymentStatus.
+func (in *OneAgentDeploymentStatus) DeepCopy() *OneAgentDeploymentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OneAgentDeploymentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OneAgentIM) DeepCopyInto(out *OneAgentIM) {
 	*out = *in
@@ -266,6 +377,10 @@ func (in *OneAgentIMList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OneAgentInstance) DeepCopyInto(out *OneAgentInstance) {
 	*out = *in
+	if in.LastSeen != nil {
+		in, out := &in.LastSeen, &out.LastSeen
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -351,11 +466,60 @@ func (in *OneAgentSpec) DeepCopyInto(out *OneAgentSpec) {
 		*out = new(uint16)
 		**out = **in
 	}
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
 	if in.Args != nil {
 		in, out := &in.Args, &out.Args
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstallerArgs != nil {
+		in, out := &in.InstallerArgs, &out.InstallerArgs
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.HostTags != nil {
+		in, out := &in.HostTags, &out.HostTags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HostProperties != nil {
+		in, out := &in.HostProperties, &out.HostProperties
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.FeatureFlags != nil {
+		in, out := &in.FeatureFlags, &out.FeatureFlags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LogMonitoring != nil {
+		in, out := &in.LogMonitoring, &out.LogMonitoring
+		*out = new(LogMonitoring)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Env != nil {
 		in, out := &in.Env, &out.Env
 		*out = make([]v1.EnvVar, len(*in))
@@ -364,6 +528,47 @@ func (in *OneAgentSpec) DeepCopyInto(out *OneAgentSpec) {
 		}
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.ResourcesByNodeClass != nil {
+		in, out := &in.ResourcesByNodeClass, &out.ResourcesByNodeClass
+		*out = make(map[string]v1.ResourceRequirements, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.NodeOverrides != nil {
+		in, out := &in.NodeOverrides, &out.NodeOverrides
+		*out = make([]NodeOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PreemptionPolicy != nil {
+		in, out := &in.PreemptionPolicy, &out.PreemptionPolicy
+		*out = new(v1.PreemptionPolicy)
+		**out = **in
+	}
+	if in.AutoUpdate != nil {
+		in, out := &in.AutoUpdate, &out.AutoUpdate
+		*out = new(bool)
+		**out = **in
+	}
+	if in.UpdateWindows != nil {
+		in, out := &in.UpdateWindows, &out.UpdateWindows
+		*out = make([]UpdateWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VersionHistoryLimit != nil {
+		in, out := &in.VersionHistoryLimit, &out.VersionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(v1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Labels != nil {
 		in, out := &in.Labels, &out.Labels
 		*out = make(map[string]string, len(*in))
@@ -371,6 +576,91 @@ func (in *OneAgentSpec) DeepCopyInto(out *OneAgentSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.PodAnnotations != nil {
+		in, out := &in.PodAnnotations, &out.PodAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodSecurityContext != nil {
+		in, out := &in.PodSecurityContext, &out.PodSecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]v1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Sidecars != nil {
+		in, out := &in.Sidecars, &out.Sidecars
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InitContainers != nil {
+		in, out := &in.InitContainers, &out.InitContainers
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ReadinessGates != nil {
+		in, out := &in.ReadinessGates, &out.ReadinessGates
+		*out = make([]v1.PodReadinessGate, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartupProbe != nil {
+		in, out := &in.StartupProbe, &out.StartupProbe
+		*out = new(v1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(v1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PreStop != nil {
+		in, out := &in.PreStop, &out.PreStop
+		*out = new(v1.Handler)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodDisruptionBudgetMaxUnavailable != nil {
+		in, out := &in.PodDisruptionBudgetMaxUnavailable, &out.PodDisruptionBudgetMaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.CanaryRollout != nil {
+		in, out := &in.CanaryRollout, &out.CanaryRollout
+		*out = new(CanaryRollout)
+		**out = **in
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -388,13 +678,51 @@ func (in *OneAgentSpec) DeepCopy() *OneAgentSpec {
 func (in *OneAgentStatus) DeepCopyInto(out *OneAgentStatus) {
 	*out = *in
 	in.BaseOneAgentStatus.DeepCopyInto(&out.BaseOneAgentStatus)
+	if in.VersionHistory != nil {
+		in, out := &in.VersionHistory, &out.VersionHistory
+		*out = make([]OneAgentVersionHistoryEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Instances != nil {
 		in, out := &in.Instances, &out.Instances
 		*out = make(map[string]OneAgentInstance, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.InstancesByZone != nil {
+		in, out := &in.InstancesByZone, &out.InstancesByZone
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
 		}
 	}
+	out.DeploymentStatus = in.DeploymentStatus
+	if in.UnmonitoredNodes != nil {
+		in, out := &in.UnmonitoredNodes, &out.UnmonitoredNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(OneAgentCanaryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MonitoredNamespaces != nil {
+		in, out := &in.MonitoredNamespaces, &out.MonitoredNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -407,3 +735,67 @@ func (in *OneAgentStatus) DeepCopy() *OneAgentStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OneAgentVersionHistoryEntry) DeepCopyInto(out *OneAgentVersionHistoryEntry) {
+	*out = *in
+	in.Timestamp.DeepCopyInto(&out.Timestamp)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OneAgentVersionHistoryEntry.
+func (in *OneAgentVersionHistoryEntry) DeepCopy() *OneAgentVersionHistoryEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(OneAgentVersionHistoryEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenScopes) DeepCopyInto(out *TokenScopes) {
+	*out = *in
+	if in.PaaSToken != nil {
+		in, out := &in.PaaSToken, &out.PaaSToken
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.APIToken != nil {
+		in, out := &in.APIToken, &out.APIToken
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenScopes.
+func (in *TokenScopes) DeepCopy() *TokenScopes {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenScopes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdateWindow) DeepCopyInto(out *UpdateWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateWindow.
+func (in *UpdateWindow) DeepCopy() *UpdateWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdateWindow)
+	in.DeepCopyInto(out)
+	return out
+}
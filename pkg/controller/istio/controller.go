@@ -2,6 +2,7 @@ package istio
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
@@ -30,6 +31,10 @@ const (
 	probeUnknown
 )
 
+// ErrNotInstalled is returned by ReconcileIstio if the Istio API isn't available on the cluster, in which case there is
+// nothing to reconcile.
+var ErrNotInstalled = errors.New("istio: not installed")
+
 // Controller - manager istioclientset and config
 type Controller struct {
 	istioClient istioclientset.Interface
@@ -65,7 +70,8 @@ func (c *Controller) initialiseIstioClient(config *rest.Config) (istioclientset.
 }
 
 // ReconcileIstio - runs the istio's reconcile workflow,
-// creating/deleting VS & SE for external communications
+// creating/deleting VS & SE for external communications,
+// returns ErrNotInstalled if Istio isn't installed
 func (c *Controller) ReconcileIstio(instance dynatracev1alpha1.BaseOneAgent,
 	dtc dtclient.Client) (updated bool, err error) {
 
@@ -76,7 +82,7 @@ func (c *Controller) ReconcileIstio(instance dynatracev1alpha1.BaseOneAgent,
 	c.logger.Info("istio: status", "enabled", enabled)
 
 	if !enabled {
-		return false, nil
+		return false, ErrNotInstalled
 	}
 
 	apiHost, err := dtc.GetCommunicationHostForClient()
@@ -91,12 +97,12 @@ func (c *Controller) ReconcileIstio(instance dynatracev1alpha1.BaseOneAgent,
 	}
 
 	// Fetch endpoints via Dynatrace client
-	ci, err := dtc.GetConnectionInfo()
+	comHosts, err := dtc.GetCommunicationHosts()
 	if err != nil {
 		return false, fmt.Errorf("istio: failed to get Dynatrace communication endpoints: %w", err)
 	}
 
-	if upd, err := c.reconcileIstioConfigurations(instance, ci.CommunicationHosts, "communication-endpoint"); err != nil {
+	if upd, err := c.reconcileIstioConfigurations(instance, comHosts, "communication-endpoint"); err != nil {
 		return false, fmt.Errorf("istio: error reconciling config for Dynatrace communication endpoints: %w", err)
 	} else if upd {
 		return true, nil
@@ -105,6 +111,27 @@ func (c *Controller) ReconcileIstio(instance dynatracev1alpha1.BaseOneAgent,
 	return false, nil
 }
 
+// RemoveIstio - deletes the VS & SE created for the instance,
+// used once Istio reconciliation is disabled on it
+func (c *Controller) RemoveIstio(instance dynatracev1alpha1.BaseOneAgent) (updated bool, err error) {
+	enabled, err := CheckIstioEnabled(c.config)
+	if err != nil {
+		return false, fmt.Errorf("istio: failed to verify Istio availability: %w", err)
+	}
+
+	if !enabled {
+		return false, nil
+	}
+
+	if upd, err := c.removeIstioConfigurations(instance); err != nil {
+		return false, fmt.Errorf("istio: error removing config: %w", err)
+	} else if upd {
+		return true, nil
+	}
+
+	return false, nil
+}
+
 func (c *Controller) reconcileIstioConfigurations(instance dynatracev1alpha1.BaseOneAgent,
 	comHosts []dtclient.CommunicationHost, role string) (bool, error) {
 
@@ -145,6 +172,24 @@ func (c *Controller) reconcileIstioRemoveConfigurations(instance dynatracev1alph
 	return vsUpd || seUpd, nil
 }
 
+func (c *Controller) removeIstioConfigurations(instance dynatracev1alpha1.BaseOneAgent) (bool, error) {
+	labels := labels.SelectorFromSet(buildIstioOwnerLabels(instance.GetName())).String()
+	listOps := &metav1.ListOptions{
+		LabelSelector: labels,
+	}
+
+	vsUpd, err := c.removeIstioConfigurationForVirtualService(listOps, map[string]bool{}, instance.GetNamespace())
+	if err != nil {
+		return false, err
+	}
+	seUpd, err := c.removeIstioConfigurationForServiceEntry(listOps, map[string]bool{}, instance.GetNamespace())
+	if err != nil {
+		return false, err
+	}
+
+	return vsUpd || seUpd, nil
+}
+
 func (c *Controller) removeIstioConfigurationForServiceEntry(listOps *metav1.ListOptions,
 	seen map[string]bool, namespace string) (bool, error) {
 
@@ -204,8 +249,7 @@ func (c *Controller) reconcileIstioCreateConfigurations(instance dynatracev1alph
 
 	crdProbe := c.verifyIstioCrdAvailability(instance)
 	if crdProbe != probeTypeFound {
-		c.logger.Info("istio: failed to lookup CRD for ServiceEntry/VirtualService: Did you install Istio recently? Please restart the Operator.")
-		return false, nil
+		return false, errors.New("failed to lookup CRD for ServiceEntry/VirtualService: Did you install Istio recently? Please restart the Operator")
 	}
 
 	configurationUpdated := false
@@ -254,7 +298,7 @@ func (c *Controller) handleIstioConfigurationForVirtualService(instance dynatrac
 		return false, err
 	}
 
-	virtualService := buildVirtualService(name, communicationHost.Host, communicationHost.Protocol,
+	virtualService := buildVirtualService(name, instance.GetNamespace(), communicationHost.Host, communicationHost.Protocol,
 		communicationHost.Port)
 	if virtualService == nil {
 		return false, nil
@@ -282,7 +326,7 @@ func (c *Controller) handleIstioConfigurationForServiceEntry(instance dynatracev
 		return false, err
 	}
 
-	serviceEntry := buildServiceEntry(name, communicationHost.Host, communicationHost.Protocol, communicationHost.Port)
+	serviceEntry := buildServiceEntry(name, instance.GetNamespace(), communicationHost.Host, communicationHost.Protocol, communicationHost.Port)
 	err = c.createIstioConfigurationForServiceEntry(instance, serviceEntry, role)
 	if err != nil {
 		c.logger.Error(err, "istio: failed to create ServiceEntry")
@@ -3,15 +3,18 @@ package istio
 import (
 	"bytes"
 	"encoding/json"
-	"os"
 	"testing"
 
-	_ "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis"
-	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis"
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	istiov1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
 	fakeistio "istio.io/client-go/pkg/clientset/versioned/fake"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 const (
@@ -37,12 +40,7 @@ func TestIstioClient_CreateIstioObjects(t *testing.T) {
 }
 
 func TestIstioClient_BuildDynatraceVirtualService(t *testing.T) {
-	err := os.Setenv(k8sutil.WatchNamespaceEnvVar, DefaultTestNamespace)
-	if err != nil {
-		t.Error("Failed to set environment variable")
-	}
-
-	vs := buildVirtualService("dt-vs", "ENVIRONMENTID.live.dynatrace.com", "https", 443)
+	vs := buildVirtualService("dt-vs", DefaultTestNamespace, "ENVIRONMENTID.live.dynatrace.com", "https", 443)
 	ic := fakeistio.NewSimpleClientset(vs)
 	vsList, err := ic.NetworkingV1alpha3().VirtualServices(DefaultTestNamespace).List(metav1.ListOptions{})
 	if err != nil {
@@ -53,3 +51,61 @@ func TestIstioClient_BuildDynatraceVirtualService(t *testing.T) {
 	}
 	t.Logf("list of istio object %v", vsList.Items)
 }
+
+func TestIstioController_RemoveIstioConfigurations(t *testing.T) {
+	apis.AddToScheme(scheme.Scheme)
+
+	newInstance := func(name string) *dynatracev1alpha1.OneAgent {
+		return &dynatracev1alpha1.OneAgent{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: DefaultTestNamespace, UID: types.UID(name)},
+		}
+	}
+	oa, other := newInstance("oneagent"), newInstance("other")
+
+	c := &Controller{
+		istioClient: fakeistio.NewSimpleClientset(),
+		scheme:      scheme.Scheme,
+		logger:      log.Log.WithName("istio.controller"),
+	}
+	networking := c.istioClient.NetworkingV1alpha3()
+
+	enable := func(instance *dynatracev1alpha1.OneAgent) {
+		name := buildNameForEndpoint(instance.GetName(), "https", "ENVIRONMENTID.live.dynatrace.com", 443)
+		se := buildServiceEntry(name, DefaultTestNamespace, "ENVIRONMENTID.live.dynatrace.com", "https", 443)
+		require.NoError(t, c.createIstioConfigurationForServiceEntry(instance, se, "api-url"))
+		vs := buildVirtualService(name, DefaultTestNamespace, "ENVIRONMENTID.live.dynatrace.com", "https", 443)
+		require.NoError(t, c.createIstioConfigurationForVirtualService(instance, vs, "api-url"))
+	}
+	count := func() (int, int) {
+		seList, err := networking.ServiceEntries(DefaultTestNamespace).List(metav1.ListOptions{})
+		require.NoError(t, err)
+		vsList, err := networking.VirtualServices(DefaultTestNamespace).List(metav1.ListOptions{})
+		require.NoError(t, err)
+		return len(seList.Items), len(vsList.Items)
+	}
+
+	enable(oa)
+	enable(other)
+
+	se, vs := count()
+	assert.Equal(t, 2, se)
+	assert.Equal(t, 2, vs)
+
+	upd, err := c.removeIstioConfigurations(oa)
+	require.NoError(t, err)
+	assert.True(t, upd)
+
+	se, vs = count()
+	assert.Equal(t, 1, se, "only the objects of the instance are removed")
+	assert.Equal(t, 1, vs, "only the objects of the instance are removed")
+
+	upd, err = c.removeIstioConfigurations(oa)
+	require.NoError(t, err)
+	assert.False(t, upd, "nothing left to remove")
+
+	enable(oa)
+
+	se, vs = count()
+	assert.Equal(t, 2, se, "objects are created again once re-enabled")
+	assert.Equal(t, 2, vs, "objects are created again once re-enabled")
+}
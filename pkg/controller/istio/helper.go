@@ -5,11 +5,9 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net"
-	"os"
 	"strconv"
 	"strings"
 
-	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	istio "istio.io/api/networking/v1alpha3"
 	istiov1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -58,33 +56,33 @@ func CheckIstioEnabled(cfg *rest.Config) (bool, error) {
 }
 
 // BuildServiceEntry returns an Istio ServiceEntry object for the given communication endpoint.
-func buildServiceEntry(name, host, protocol string, port uint32) *istiov1alpha3.ServiceEntry {
+func buildServiceEntry(name, namespace, host, protocol string, port uint32) *istiov1alpha3.ServiceEntry {
 	if net.ParseIP(host) != nil { // It's an IP.
-		return buildServiceEntryIP(name, host, port)
+		return buildServiceEntryIP(name, namespace, host, port)
 	}
 
-	return buildServiceEntryFQDN(name, host, protocol, port)
+	return buildServiceEntryFQDN(name, namespace, host, protocol, port)
 }
 
 // BuildVirtualService returns an Istio VirtualService object for the given communication endpoint.
-func buildVirtualService(name, host, protocol string, port uint32) *istiov1alpha3.VirtualService {
+func buildVirtualService(name, namespace, host, protocol string, port uint32) *istiov1alpha3.VirtualService {
 	if net.ParseIP(host) != nil { // It's an IP.
 		return nil
 	}
 
 	return &istiov1alpha3.VirtualService{
-		ObjectMeta: buildObjectMeta(name),
+		ObjectMeta: buildObjectMeta(name, namespace),
 		Spec:       buildVirtualServiceSpec(host, protocol, port),
 	}
 }
 
 // buildServiceEntryFQDN returns an Istio ServiceEntry object for the given communication endpoint with a FQDN host.
-func buildServiceEntryFQDN(name, host, protocol string, port uint32) *istiov1alpha3.ServiceEntry {
+func buildServiceEntryFQDN(name, namespace, host, protocol string, port uint32) *istiov1alpha3.ServiceEntry {
 	portStr := strconv.Itoa(int(port))
 	protocolStr := strings.ToUpper(protocol)
 
 	return &istiov1alpha3.ServiceEntry{
-		ObjectMeta: buildObjectMeta(name),
+		ObjectMeta: buildObjectMeta(name, namespace),
 		Spec: istio.ServiceEntry{
 			Hosts: []string{host},
 			Ports: []*istio.Port{{
@@ -99,11 +97,11 @@ func buildServiceEntryFQDN(name, host, protocol string, port uint32) *istiov1alp
 }
 
 // buildServiceEntryIP returns an Istio ServiceEntry object for the given communication endpoint with IP.
-func buildServiceEntryIP(name, host string, port uint32) *istiov1alpha3.ServiceEntry {
+func buildServiceEntryIP(name, namespace, host string, port uint32) *istiov1alpha3.ServiceEntry {
 	portStr := strconv.Itoa(int(port))
 
 	return &istiov1alpha3.ServiceEntry{
-		ObjectMeta: buildObjectMeta(name),
+		ObjectMeta: buildObjectMeta(name, namespace),
 		Spec: istio.ServiceEntry{
 			Hosts:     []string{"ignored.subdomain"},
 			Addresses: []string{host + "/32"},
@@ -170,10 +168,10 @@ func buildVirtualServiceHttpRoute(port uint32, host string) []*istio.HTTPRoute {
 	}}
 }
 
-func buildObjectMeta(name string) v1.ObjectMeta {
+func buildObjectMeta(name, namespace string) v1.ObjectMeta {
 	return v1.ObjectMeta{
 		Name:      name,
-		Namespace: os.Getenv(k8sutil.WatchNamespaceEnvVar),
+		Namespace: namespace,
 	}
 }
 
@@ -192,9 +190,15 @@ func mapErrorToObjectProbeResult(err error) (probeResult, error) {
 }
 
 func buildIstioLabels(name, role string) map[string]string {
+	labels := buildIstioOwnerLabels(name)
+	labels["dynatrace-istio-role"] = role
+	return labels
+}
+
+// buildIstioOwnerLabels returns the labels selecting the Istio objects created for the OneAgent, for all roles.
+func buildIstioOwnerLabels(name string) map[string]string {
 	return map[string]string{
-		"dynatrace":            "oneagent",
-		"oneagent":             name,
-		"dynatrace-istio-role": role,
+		"dynatrace": "oneagent",
+		"oneagent":  name,
 	}
 }
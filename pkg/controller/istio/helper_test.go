@@ -126,7 +126,7 @@ func TestServiceEntryGeneration(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	assert.ObjectsAreEqualValues(&se, buildServiceEntry("com1", "comtest.com", "https", 9999))
+	assert.ObjectsAreEqualValues(&se, buildServiceEntry("com1", "dynatrace", "comtest.com", "https", 9999))
 
 	seTest2 := bytes.NewBufferString(`{
 		    "apiVersion": "networking.istio.io/v1alpha3",
@@ -152,7 +152,7 @@ func TestServiceEntryGeneration(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	assert.ObjectsAreEqualValues(&se, buildServiceEntry("com1", "42.42.42.42", "https", 8888))
+	assert.ObjectsAreEqualValues(&se, buildServiceEntry("com1", "dynatrace", "42.42.42.42", "https", 8888))
 }
 
 func TestVirtualServiceGeneration(t *testing.T) {
@@ -185,7 +185,7 @@ func TestVirtualServiceGeneration(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	assert.ObjectsAreEqualValues(&vs, buildVirtualService("com1", "comtest.com", "https", 8888))
+	assert.ObjectsAreEqualValues(&vs, buildVirtualService("com1", "dynatrace", "comtest.com", "https", 8888))
 
 	vsTest2 := bytes.NewBufferString(`{
 		"apiVersion": "networking.istio.io/v1alpha3",
@@ -215,9 +215,9 @@ func TestVirtualServiceGeneration(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	assert.ObjectsAreEqualValues(&vs, buildVirtualService("com1", "comtest.com", "http", 7777))
+	assert.ObjectsAreEqualValues(&vs, buildVirtualService("com1", "dynatrace", "comtest.com", "http", 7777))
 
-	assert.Nil(t, buildVirtualService("com1", "42.42.42.42", "HTTP", 8888))
+	assert.Nil(t, buildVirtualService("com1", "dynatrace", "42.42.42.42", "HTTP", 8888))
 }
 
 func TestMapErrorToObjectProbeResult(t *testing.T) {
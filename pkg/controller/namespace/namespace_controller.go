@@ -12,7 +12,6 @@ import (
 	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/utils"
 	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/webhook"
 	"github.com/go-logr/logr"
-	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -25,7 +24,7 @@ import (
 )
 
 func Add(mgr manager.Manager) error {
-	ns, err := k8sutil.GetWatchNamespace()
+	ns, err := utils.GetOperatorNamespace()
 	if err != nil {
 		return err
 	}
@@ -110,7 +109,7 @@ func (r *ReconcileNamespaces) Reconcile(request reconcile.Request) (reconcile.Re
 	}
 
 	var tkns corev1.Secret
-	if err := r.client.Get(ctx, client.ObjectKey{Name: utils.GetTokensName(&apm), Namespace: r.namespace}, &tkns); err != nil {
+	if err := r.client.Get(ctx, client.ObjectKey{Name: utils.GetPaaSTokenSecretName(&apm), Namespace: utils.GetTokensNamespace(&apm)}, &tkns); err != nil {
 		return reconcile.Result{}, fmt.Errorf("failed to query tokens: %w", err)
 	}
 
@@ -184,9 +183,11 @@ func newScript(ctx context.Context, c client.Client, apm dynatracev1alpha1.OneAg
 		trustedCAs = []byte(cam.Data["certs"])
 	}
 
+	paasToken, _ := utils.LookupToken(&tkns, utils.DynatracePaasToken)
+
 	return &script{
 		OneAgent:   &apm,
-		PaaSToken:  string(tkns.Data[utils.DynatracePaasToken]),
+		PaaSToken:  string(paasToken),
 		Proxy:      proxy,
 		TrustedCAs: trustedCAs,
 		ClusterID:  string(kubeSystemNS.UID),
@@ -14,6 +14,7 @@ var ErrNotFound = errors.New("not found")
 // CacheEntry constains information about a Node.
 type CacheEntry struct {
 	Instance                 string    `json:"instance"`
+	Namespace                string    `json:"namespace,omitempty"`
 	IPAddress                string    `json:"ip"`
 	LastSeen                 time.Time `json:"seen"`
 	LastMarkedForTermination time.Time `json:"marked"`
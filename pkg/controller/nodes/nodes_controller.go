@@ -41,7 +41,7 @@ type ReconcileNodes struct {
 // Add creates a new Nodes Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	ns, err := k8sutil.GetWatchNamespace()
+	ns, err := utils.GetOperatorNamespace()
 	if err != nil {
 		return err
 	}
@@ -123,9 +123,9 @@ func (r *ReconcileNodes) onDeletion(node string) error {
 		return err
 	}
 
-	if err = r.removeNode(c, node, func(oaName string) (*dynatracev1alpha1.OneAgent, error) {
+	if err = r.removeNode(c, node, func(oaNamespace, oaName string) (*dynatracev1alpha1.OneAgent, error) {
 		var oa dynatracev1alpha1.OneAgent
-		if err := r.client.Get(context.TODO(), client.ObjectKey{Name: oaName, Namespace: r.namespace}, &oa); err != nil {
+		if err := r.client.Get(context.TODO(), client.ObjectKey{Name: oaName, Namespace: oaNamespace}, &oa); err != nil {
 			return nil, err
 		}
 		return &oa, nil
@@ -139,14 +139,14 @@ func (r *ReconcileNodes) onDeletion(node string) error {
 func (r *ReconcileNodes) reconcileAll() error {
 	r.logger.Info("reconciling nodes")
 
-	var oaLst dynatracev1alpha1.OneAgentList
-	if err := r.client.List(context.TODO(), &oaLst, client.InNamespace(r.namespace)); err != nil {
+	oaLst, err := r.getOneAgentList()
+	if err != nil {
 		return err
 	}
 
-	oas := make(map[string]*dynatracev1alpha1.OneAgent, len(oaLst.Items))
+	oas := make(map[client.ObjectKey]*dynatracev1alpha1.OneAgent, len(oaLst.Items))
 	for i := range oaLst.Items {
-		oas[oaLst.Items[i].Name] = &oaLst.Items[i]
+		oas[client.ObjectKey{Name: oaLst.Items[i].Name, Namespace: oaLst.Items[i].Namespace}] = &oaLst.Items[i]
 	}
 
 	c, err := r.getCache()
@@ -183,6 +183,7 @@ func (r *ReconcileNodes) reconcileAll() error {
 
 				info := CacheEntry{
 					Instance:  oa.Name,
+					Namespace: oa.Namespace,
 					IPAddress: info.IPAddress,
 					LastSeen:  time.Now().UTC(),
 				}
@@ -204,8 +205,8 @@ func (r *ReconcileNodes) reconcileAll() error {
 			continue
 		}
 
-		if err := r.removeNode(c, node, func(name string) (*dynatracev1alpha1.OneAgent, error) {
-			if oa, ok := oas[name]; ok {
+		if err := r.removeNode(c, node, func(namespace, name string) (*dynatracev1alpha1.OneAgent, error) {
+			if oa, ok := oas[client.ObjectKey{Name: name, Namespace: namespace}]; ok {
 				return oa, nil
 			}
 
@@ -269,7 +270,7 @@ func (r *ReconcileNodes) updateCache(c *Cache) error {
 	return r.client.Update(context.TODO(), c.Obj)
 }
 
-func (r *ReconcileNodes) removeNode(c *Cache, node string, oaFunc func(name string) (*dynatracev1alpha1.OneAgent, error)) error {
+func (r *ReconcileNodes) removeNode(c *Cache, node string, oaFunc func(namespace, name string) (*dynatracev1alpha1.OneAgent, error)) error {
 	logger := r.logger.WithValues("node", node)
 
 	nodeInfo, err := c.Get(node)
@@ -285,7 +286,13 @@ func (r *ReconcileNodes) removeNode(c *Cache, node string, oaFunc func(name stri
 	} else if nodeInfo.IPAddress == "" {
 		logger.Info("removing node with unknown IP")
 	} else {
-		oa, err := oaFunc(nodeInfo.Instance)
+		// Entries cached by older versions don't have a namespace, their OneAgent lives in the Operator's namespace.
+		oaNamespace := nodeInfo.Namespace
+		if oaNamespace == "" {
+			oaNamespace = r.namespace
+		}
+
+		oa, err := oaFunc(oaNamespace, nodeInfo.Instance)
 		if errors.IsNotFound(err) {
 			logger.Info("oneagent got already deleted")
 			c.Delete(node)
@@ -359,6 +366,7 @@ func (r *ReconcileNodes) reconcileUnschedulableNode(node *corev1.Node, c *Cache)
 			// If node not found in c add it
 			cachedNode := CacheEntry{
 				Instance:  oneAgent.Name,
+				Namespace: oneAgent.Namespace,
 				IPAddress: instance.IPAddress,
 				LastSeen:  time.Now().UTC(),
 			}
@@ -4,8 +4,6 @@ import (
 	"context"
 
 	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
-	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func (r *ReconcileNodes) determineOneAgentForNode(nodeName string) (*dynatracev1alpha1.OneAgent, error) {
@@ -17,14 +15,11 @@ func (r *ReconcileNodes) determineOneAgentForNode(nodeName string) (*dynatracev1
 	return r.filterOneAgentFromList(oneAgentList, nodeName), nil
 }
 
+// getOneAgentList returns the OneAgent objects from all watched namespaces. The Manager's cache is restricted to those
+// namespaces already, so no namespace is given on the query.
 func (r *ReconcileNodes) getOneAgentList() (*dynatracev1alpha1.OneAgentList, error) {
-	watchNamespace, err := k8sutil.GetWatchNamespace()
-	if err != nil {
-		return nil, err
-	}
-
 	var oneAgentList dynatracev1alpha1.OneAgentList
-	err = r.client.List(context.TODO(), &oneAgentList, client.InNamespace(watchNamespace))
+	err := r.client.List(context.TODO(), &oneAgentList)
 	if err != nil {
 		return nil, err
 	}
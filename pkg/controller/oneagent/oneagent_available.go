@@ -0,0 +1,76 @@
+package oneagent
+
+import (
+	"fmt"
+	"strings"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// availabilityCheck is a condition summarized on the Available condition, healthy while it has the given status.
+type availabilityCheck struct {
+	conditionType status.ConditionType
+	healthy       corev1.ConditionStatus
+
+	// required is true if the condition must be set to be healthy, otherwise a missing condition is healthy.
+	required bool
+}
+
+// availabilityChecks are the conditions summarized on the Available condition, in the order of the reported issues.
+var availabilityChecks = []availabilityCheck{
+	{conditionType: dynatracev1alpha1.APITokenConditionType, healthy: corev1.ConditionTrue, required: true},
+	{conditionType: dynatracev1alpha1.PaaSTokenConditionType, healthy: corev1.ConditionTrue, required: true},
+	{conditionType: dynatracev1alpha1.SchedulingBlockedConditionType, healthy: corev1.ConditionFalse},
+	{conditionType: dynatracev1alpha1.UnmonitoredNodesConditionType, healthy: corev1.ConditionFalse},
+	{conditionType: dynatracev1alpha1.UpdatingConditionType, healthy: corev1.ConditionFalse},
+	{conditionType: dynatracev1alpha1.DowngradeBlockedConditionType, healthy: corev1.ConditionFalse},
+	{conditionType: dynatracev1alpha1.InstallerSupportedConditionType, healthy: corev1.ConditionTrue},
+	{conditionType: dynatracev1alpha1.HostsNotCommunicatingConditionType, healthy: corev1.ConditionFalse},
+}
+
+// availabilityIssues returns the issues reported by the conditions summarized on the Available condition.
+func availabilityIssues(conditions status.Conditions) []string {
+	var issues []string
+	for _, check := range availabilityChecks {
+		cond := conditions.GetCondition(check.conditionType)
+		switch {
+		case cond == nil && check.required:
+			issues = append(issues, fmt.Sprintf("%s: not verified yet", check.conditionType))
+		case cond != nil && cond.Status != check.healthy:
+			issues = append(issues, fmt.Sprintf("%s: %s", check.conditionType, cond.Message))
+		}
+	}
+	return issues
+}
+
+// reconcileAvailable summarizes the tokens, pods, version and host communication conditions on the Available
+// condition, so that a single condition can be alerted on. It must run after the other conditions have been set.
+//
+// Returns true if the condition has changed.
+func reconcileAvailable(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	issues := availabilityIssues(*conditions)
+	if len(issues) == 0 {
+		return conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.AvailableConditionType,
+			Status:  corev1.ConditionTrue,
+			Reason:  dynatracev1alpha1.ReasonAllConditionsHealthy,
+			Message: "OneAgent is healthy",
+		})
+	}
+
+	if conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.AvailableConditionType,
+		Status:  corev1.ConditionFalse,
+		Reason:  dynatracev1alpha1.ReasonConditionsUnhealthy,
+		Message: strings.Join(issues, "; "),
+	}) {
+		logger.Info("OneAgent is unhealthy", "issues", issues)
+		return true
+	}
+	return false
+}
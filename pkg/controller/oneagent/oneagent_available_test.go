@@ -0,0 +1,47 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReconcileAvailable(t *testing.T) {
+	oa := newOneAgent()
+	conditions := &oa.Status.Conditions
+
+	assert.True(t, reconcileAvailable(consoleLogger, oa))
+	cond := conditions.GetCondition(dynatracev1alpha1.AvailableConditionType)
+	require.NotNil(t, cond)
+	assert.Equal(t, corev1.ConditionFalse, cond.Status)
+	assert.Equal(t, "APIToken: not verified yet; PaaSToken: not verified yet", cond.Message)
+
+	for _, c := range []status.Condition{
+		{Type: dynatracev1alpha1.APITokenConditionType, Status: corev1.ConditionTrue, Message: "Ready"},
+		{Type: dynatracev1alpha1.PaaSTokenConditionType, Status: corev1.ConditionFalse, Message: "Token unauthorized"},
+		{Type: dynatracev1alpha1.UnmonitoredNodesConditionType, Status: corev1.ConditionFalse},
+		{Type: dynatracev1alpha1.UpdatingConditionType, Status: corev1.ConditionTrue, Message: "2 of 3 pods updated"},
+		{Type: dynatracev1alpha1.HostsNotCommunicatingConditionType, Status: corev1.ConditionFalse},
+	} {
+		conditions.SetCondition(c)
+	}
+
+	assert.True(t, reconcileAvailable(consoleLogger, oa))
+	cond = conditions.GetCondition(dynatracev1alpha1.AvailableConditionType)
+	assert.Equal(t, corev1.ConditionFalse, cond.Status)
+	assert.Equal(t, dynatracev1alpha1.ReasonConditionsUnhealthy, cond.Reason)
+	assert.Equal(t, "PaaSToken: Token unauthorized; Updating: 2 of 3 pods updated", cond.Message)
+	assert.False(t, reconcileAvailable(consoleLogger, oa), "unchanged")
+
+	conditions.SetCondition(status.Condition{Type: dynatracev1alpha1.PaaSTokenConditionType, Status: corev1.ConditionTrue})
+	conditions.SetCondition(status.Condition{Type: dynatracev1alpha1.UpdatingConditionType, Status: corev1.ConditionFalse})
+
+	assert.True(t, reconcileAvailable(consoleLogger, oa))
+	cond = conditions.GetCondition(dynatracev1alpha1.AvailableConditionType)
+	assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	assert.Equal(t, dynatracev1alpha1.ReasonAllConditionsHealthy, cond.Reason)
+}
@@ -0,0 +1,137 @@
+package oneagent
+
+import (
+	"fmt"
+	"sort"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// validateCanaryRollout returns the issues found on .spec.canaryRollout
+func validateCanaryRollout(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	canary := spec.CanaryRollout
+	if canary == nil {
+		return nil
+	}
+
+	var msg []string
+	if canary.Percentage < 1 || canary.Percentage > 100 {
+		msg = append(msg, ".spec.canaryRollout.percentage must be between 1 and 100")
+	}
+
+	switch canary.Promotion {
+	case "", dynatracev1alpha1.CanaryPromotionManual, dynatracev1alpha1.CanaryPromotionAutomatic:
+	default:
+		msg = append(msg, fmt.Sprintf(".spec.canaryRollout.promotion has unknown value %q", canary.Promotion))
+	}
+
+	return msg
+}
+
+// reconcileCanary holds back the update of outdated pods to the canary nodes while a new version hasn't been promoted
+// yet. The canary nodes are selected once the rollout of a version starts, and kept on .status.canary together with
+// the number of them already running the version.
+//
+// Returns the pods to update, and true if the status has changed.
+func reconcileCanary(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dtc dtclient.Client, pods []corev1.Pod, outdated []corev1.Pod) ([]corev1.Pod, bool) {
+	spec := instance.GetOneAgentSpec().CanaryRollout
+	oaStatus := instance.GetOneAgentStatus()
+
+	if spec == nil {
+		if oaStatus.Canary != nil {
+			oaStatus.Canary = nil
+			return outdated, true
+		}
+		return outdated, false
+	}
+
+	updateCR := false
+	desired := oaStatus.Version
+	if oaStatus.Canary == nil || oaStatus.Canary.Version != desired {
+		if len(outdated) == 0 {
+			// Nothing to roll out.
+			return outdated, false
+		}
+
+		oaStatus.Canary = &dynatracev1alpha1.OneAgentCanaryStatus{
+			Version: desired,
+			Nodes:   selectCanaryNodes(pods, spec.Percentage),
+		}
+		logger.Info("Starting canary rollout", "version", desired, "nodes", oaStatus.Canary.Nodes)
+		updateCR = true
+	}
+
+	canary := oaStatus.Canary
+	isCanary := map[string]bool{}
+	for _, node := range canary.Nodes {
+		isCanary[node] = true
+	}
+
+	var updated int32
+	for i := range pods {
+		pod := &pods[i]
+		if !isCanary[pod.Spec.NodeName] || !getPodReadyState(pod) {
+			continue
+		}
+		if ver, err := dtc.GetAgentVersionForIP(pod.Status.HostIP); err == nil && ver == canary.Version {
+			updated++
+		}
+	}
+	if canary.Updated != updated {
+		canary.Updated = updated
+		updateCR = true
+	}
+
+	if !canary.Promoted && isCanaryPromoted(spec, canary) {
+		logger.Info("Promoting canary rollout to all nodes", "version", canary.Version)
+		canary.Promoted = true
+		updateCR = true
+	}
+
+	if canary.Promoted {
+		return outdated, updateCR
+	}
+
+	var toUpdate []corev1.Pod
+	for _, pod := range outdated {
+		if isCanary[pod.Spec.NodeName] {
+			toUpdate = append(toUpdate, pod)
+		}
+	}
+	if len(toUpdate) < len(outdated) {
+		logger.Info("Holding update of nodes until canary is promoted", "version", canary.Version, "held", len(outdated)-len(toUpdate))
+	}
+	return toUpdate, updateCR
+}
+
+// isCanaryPromoted returns true if the canary version can be rolled out to the remaining nodes, either once set on
+// .spec.canaryRollout.promotedVersion, or once all canary nodes are updated for automatic promotion.
+func isCanaryPromoted(spec *dynatracev1alpha1.CanaryRollout, canary *dynatracev1alpha1.OneAgentCanaryStatus) bool {
+	if spec.Promotion == dynatracev1alpha1.CanaryPromotionAutomatic {
+		return int(canary.Updated) >= len(canary.Nodes)
+	}
+	return spec.PromotedVersion != "" && spec.PromotedVersion == canary.Version
+}
+
+// selectCanaryNodes returns the given percentage of the nodes running the pods, rounded up to at least one node. Nodes
+// are sorted by name, so that the same nodes get selected on every rollout while the cluster doesn't change.
+func selectCanaryNodes(pods []corev1.Pod, percentage int32) []string {
+	seen := map[string]bool{}
+	var nodes []string
+	for _, pod := range pods {
+		if node := pod.Spec.NodeName; node != "" && !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+
+	n := (len(nodes)*int(percentage) + 99) / 100
+	if n > len(nodes) {
+		n = len(nodes)
+	}
+	return nodes[:n]
+}
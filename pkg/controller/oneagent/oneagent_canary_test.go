@@ -0,0 +1,140 @@
+package oneagent
+
+import (
+	"fmt"
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileCanary(t *testing.T) {
+	const (
+		previous = "1.202.0.20200808-120956"
+		desired  = "1.203.0.20200908-220956"
+	)
+
+	var pods []corev1.Pod
+	for i := 1; i <= 4; i++ {
+		pods = append(pods, corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("oneagent-%d", i), Namespace: "dynatrace"},
+			Spec:       corev1.PodSpec{NodeName: fmt.Sprintf("node-%d", i)},
+			Status: corev1.PodStatus{
+				HostIP:            fmt.Sprintf("10.0.0.%d", i),
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+			},
+		})
+	}
+
+	// versionsMock returns the mock reporting the desired version for the first updated pods, and the previous one for
+	// the others.
+	versionsMock := func(updated int) *dtclient.MockDynatraceClient {
+		dtc := &dtclient.MockDynatraceClient{}
+		for i, pod := range pods {
+			ver := previous
+			if i < updated {
+				ver = desired
+			}
+			dtc.On("GetAgentVersionForIP", pod.Status.HostIP).Return(ver, nil)
+		}
+		return dtc
+	}
+
+	newInstance := func(promotion dynatracev1alpha1.CanaryPromotion) *dynatracev1alpha1.OneAgent {
+		return &dynatracev1alpha1.OneAgent{
+			Spec: dynatracev1alpha1.OneAgentSpec{
+				CanaryRollout: &dynatracev1alpha1.CanaryRollout{Percentage: 25, Promotion: promotion},
+			},
+			Status: dynatracev1alpha1.OneAgentStatus{Version: desired},
+		}
+	}
+
+	t.Run("partitioned rollout with manual promotion", func(t *testing.T) {
+		oa := newInstance(dynatracev1alpha1.CanaryPromotionManual)
+
+		toUpdate, upd := reconcileCanary(consoleLogger, oa, versionsMock(0), pods, pods)
+		assert.True(t, upd)
+		require.Len(t, toUpdate, 1)
+		assert.Equal(t, "oneagent-1", toUpdate[0].Name)
+		assert.Equal(t, &dynatracev1alpha1.OneAgentCanaryStatus{Version: desired, Nodes: []string{"node-1"}}, oa.Status.Canary)
+
+		toUpdate, upd = reconcileCanary(consoleLogger, oa, versionsMock(1), pods, pods[1:])
+		assert.True(t, upd)
+		assert.Empty(t, toUpdate, "held until promoted")
+		assert.Equal(t, int32(1), oa.Status.Canary.Updated)
+		assert.False(t, oa.Status.Canary.Promoted)
+
+		oa.Spec.CanaryRollout.PromotedVersion = desired
+		toUpdate, upd = reconcileCanary(consoleLogger, oa, versionsMock(1), pods, pods[1:])
+		assert.True(t, upd)
+		assert.Equal(t, pods[1:], toUpdate)
+		assert.True(t, oa.Status.Canary.Promoted)
+	})
+
+	t.Run("automatic promotion once canary is updated", func(t *testing.T) {
+		oa := newInstance(dynatracev1alpha1.CanaryPromotionAutomatic)
+		oa.Spec.CanaryRollout.Percentage = 50
+
+		toUpdate, _ := reconcileCanary(consoleLogger, oa, versionsMock(0), pods, pods)
+		assert.Equal(t, pods[:2], toUpdate)
+
+		// Not ready yet.
+		notReady := append([]corev1.Pod{}, pods...)
+		notReady[1].Status.ContainerStatuses = []corev1.ContainerStatus{{Ready: false}}
+		toUpdate, _ = reconcileCanary(consoleLogger, oa, versionsMock(2), notReady, pods[2:])
+		assert.Empty(t, toUpdate)
+		assert.Equal(t, int32(1), oa.Status.Canary.Updated)
+
+		toUpdate, upd := reconcileCanary(consoleLogger, oa, versionsMock(2), pods, pods[2:])
+		assert.True(t, upd)
+		assert.Equal(t, pods[2:], toUpdate)
+		assert.True(t, oa.Status.Canary.Promoted)
+	})
+
+	t.Run("new version restarts canary", func(t *testing.T) {
+		oa := newInstance(dynatracev1alpha1.CanaryPromotionManual)
+		oa.Status.Canary = &dynatracev1alpha1.OneAgentCanaryStatus{Version: previous, Nodes: []string{"node-4"}, Updated: 1, Promoted: true}
+
+		toUpdate, upd := reconcileCanary(consoleLogger, oa, versionsMock(0), pods, pods)
+		assert.True(t, upd)
+		assert.Equal(t, pods[:1], toUpdate)
+		assert.Equal(t, &dynatracev1alpha1.OneAgentCanaryStatus{Version: desired, Nodes: []string{"node-1"}}, oa.Status.Canary)
+	})
+
+	t.Run("status removed without canary rollout", func(t *testing.T) {
+		oa := newInstance("")
+		oa.Spec.CanaryRollout = nil
+		oa.Status.Canary = &dynatracev1alpha1.OneAgentCanaryStatus{Version: desired}
+
+		toUpdate, upd := reconcileCanary(consoleLogger, oa, &dtclient.MockDynatraceClient{}, pods, pods)
+		assert.True(t, upd)
+		assert.Equal(t, pods, toUpdate)
+		assert.Nil(t, oa.Status.Canary)
+	})
+}
+
+func TestSelectCanaryNodes(t *testing.T) {
+	var pods []corev1.Pod
+	for _, node := range []string{"node-c", "node-a", "node-b"} {
+		pods = append(pods, corev1.Pod{Spec: corev1.PodSpec{NodeName: node}})
+	}
+
+	assert.Equal(t, []string{"node-a"}, selectCanaryNodes(pods, 1))
+	assert.Equal(t, []string{"node-a", "node-b"}, selectCanaryNodes(pods, 50))
+	assert.Equal(t, []string{"node-a", "node-b", "node-c"}, selectCanaryNodes(pods, 100))
+	assert.Empty(t, selectCanaryNodes(nil, 50))
+}
+
+func TestValidateCanaryRollout(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.CanaryRollout = &dynatracev1alpha1.CanaryRollout{Percentage: 10}
+	assert.NoError(t, validate(oa))
+
+	oa.Spec.CanaryRollout = &dynatracev1alpha1.CanaryRollout{Percentage: 0, Promotion: "OnSuccess"}
+	assert.EqualError(t, validate(oa), `.spec.canaryRollout.percentage must be between 1 and 100, .spec.canaryRollout.promotion has unknown value "OnSuccess"`)
+}
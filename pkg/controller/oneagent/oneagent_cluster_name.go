@@ -0,0 +1,45 @@
+package oneagent
+
+import (
+	"context"
+	"fmt"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getClusterName returns .spec.clusterName, or the UID of the kube-system namespace if unset. Returns an empty name
+// if neither is available.
+func (r *ReconcileOneAgent) getClusterName(instance dynatracev1alpha1.BaseOneAgentDaemonSet) (string, error) {
+	if name := instance.GetOneAgentSpec().ClusterName; name != "" {
+		return name, nil
+	}
+
+	var kubeSystemNS corev1.Namespace
+	if err := r.apiReader.Get(context.TODO(), client.ObjectKey{Name: "kube-system"}, &kubeSystemNS); k8serrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to query for cluster ID: %w", err)
+	}
+	return string(kubeSystemNS.UID), nil
+}
+
+// addClusterNameArg passes the cluster name to the OneAgent installer as the ClusterName host property. Custom
+// commands only get the arguments from the spec, so they're left unchanged.
+func (r *ReconcileOneAgent) addClusterNameArg(instance dynatracev1alpha1.BaseOneAgentDaemonSet, ds *appsv1.DaemonSet) error {
+	if len(instance.GetOneAgentSpec().Command) > 0 {
+		return nil
+	}
+
+	name, err := r.getClusterName(instance)
+	if err != nil || name == "" {
+		return err
+	}
+
+	container := &ds.Spec.Template.Spec.Containers[0]
+	container.Args = append(container.Args, fmt.Sprintf("--set-host-property=%s=%s", clusterNameHostProperty, name))
+	return updateTemplateHash(ds)
+}
@@ -0,0 +1,83 @@
+package oneagent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAddClusterNameArg(t *testing.T) {
+	kubeSystemNS := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: types.UID("01234-5678-9012-3456")},
+	}
+
+	newReconciler := func(objs ...runtime.Object) *ReconcileOneAgent {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+		return &ReconcileOneAgent{client: c, apiReader: c, scheme: scheme.Scheme, logger: consoleLogger}
+	}
+
+	t.Run("defaults to kube-system UID", func(t *testing.T) {
+		oa := newOneAgent()
+		ds, err := newDaemonSetForCR(consoleLogger, oa)
+		require.NoError(t, err)
+		hash := ds.Annotations[annotationTemplateHash]
+
+		require.NoError(t, newReconciler(kubeSystemNS).addClusterNameArg(oa, ds))
+		assert.Contains(t, ds.Spec.Template.Spec.Containers[0].Args, "--set-host-property=ClusterName=01234-5678-9012-3456")
+		assert.NotEqual(t, hash, ds.Annotations[annotationTemplateHash])
+	})
+
+	t.Run("uses configured cluster name", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.ClusterName = "production"
+		ds, err := newDaemonSetForCR(consoleLogger, oa)
+		require.NoError(t, err)
+
+		require.NoError(t, newReconciler(kubeSystemNS).addClusterNameArg(oa, ds))
+		args := ds.Spec.Template.Spec.Containers[0].Args
+		assert.Contains(t, args, "--set-host-property=ClusterName=production")
+		assert.NotContains(t, args, "--set-host-property=ClusterName=01234-5678-9012-3456")
+	})
+
+	t.Run("skipped without cluster UID", func(t *testing.T) {
+		oa := newOneAgent()
+		ds, err := newDaemonSetForCR(consoleLogger, oa)
+		require.NoError(t, err)
+		args := append([]string(nil), ds.Spec.Template.Spec.Containers[0].Args...)
+
+		require.NoError(t, newReconciler().addClusterNameArg(oa, ds))
+		assert.Equal(t, args, ds.Spec.Template.Spec.Containers[0].Args)
+	})
+
+	t.Run("skipped with custom command", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.ClusterName = "production"
+		oa.Spec.Command = []string{"/bin/sh"}
+		ds, err := newDaemonSetForCR(consoleLogger, oa)
+		require.NoError(t, err)
+
+		require.NoError(t, newReconciler(kubeSystemNS).addClusterNameArg(oa, ds))
+		assert.Empty(t, ds.Spec.Template.Spec.Containers[0].Args)
+	})
+}
+
+func TestValidateClusterName(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.ClusterName = "production-eu"
+	assert.NoError(t, validate(oa))
+
+	oa.Spec.ClusterName = "production eu"
+	assert.EqualError(t, validate(oa), `.spec.clusterName must not contain whitespace or quotes, got "production eu"`)
+
+	oa.Spec.ClusterName = ""
+	oa.Spec.HostProperties = map[string]string{clusterNameHostProperty: "production"}
+	assert.EqualError(t, validate(oa), `.spec.hostProperties must not set reserved key "ClusterName"`)
+}
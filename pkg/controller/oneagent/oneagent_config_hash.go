@@ -0,0 +1,35 @@
+package oneagent
+
+import (
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// generateConfigHash returns the hash of the installer arguments and environment variables of the OneAgent container
+// on the DaemonSet
+func generateConfigHash(ds *appsv1.DaemonSet) (string, error) {
+	container := ds.Spec.Template.Spec.Containers[0]
+	return generateHash(struct {
+		Args []string        `json:"args,omitempty"`
+		Env  []corev1.EnvVar `json:"env,omitempty"`
+	}{container.Args, container.Env})
+}
+
+// reconcileConfigHash sets .status.configHash to the hash of the agent configuration on the desired DaemonSet.
+//
+// Returns true if the hash has changed.
+func reconcileConfigHash(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, ds *appsv1.DaemonSet) (bool, error) {
+	hash, err := generateConfigHash(ds)
+	if err != nil {
+		return false, err
+	}
+
+	if instance.GetOneAgentStatus().ConfigHash == hash {
+		return false, nil
+	}
+	logger.Info("Agent configuration changed", "previous", instance.GetOneAgentStatus().ConfigHash, "configHash", hash)
+	instance.GetOneAgentStatus().ConfigHash = hash
+	return true, nil
+}
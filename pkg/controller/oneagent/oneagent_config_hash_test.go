@@ -0,0 +1,37 @@
+package oneagent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileConfigHash(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.Args = []string{"--set-host-group=default"}
+
+	ds, err := newDaemonSetForCR(consoleLogger, oa)
+	require.NoError(t, err)
+
+	upd, err := reconcileConfigHash(consoleLogger, oa, ds)
+	require.NoError(t, err)
+	assert.True(t, upd)
+	hash := oa.Status.ConfigHash
+	assert.NotEmpty(t, hash)
+
+	upd, err = reconcileConfigHash(consoleLogger, oa, ds)
+	require.NoError(t, err)
+	assert.False(t, upd, "unchanged config")
+	assert.Equal(t, hash, oa.Status.ConfigHash)
+
+	oa.Spec.Args = []string{"--set-host-group=production"}
+	ds, err = newDaemonSetForCR(consoleLogger, oa)
+	require.NoError(t, err)
+
+	upd, err = reconcileConfigHash(consoleLogger, oa, ds)
+	require.NoError(t, err)
+	assert.True(t, upd)
+	assert.NotEqual(t, hash, oa.Status.ConfigHash)
+}
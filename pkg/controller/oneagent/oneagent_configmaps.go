@@ -0,0 +1,139 @@
+package oneagent
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// referencedConfigMaps returns the sorted names of the ConfigMaps mounted on the OneAgent pods, i.e., the one in
+// .spec.trustedCAs and the ConfigMap sources of .spec.volumes.
+func referencedConfigMaps(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	seen := map[string]bool{}
+	var names []string
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	add(spec.TrustedCAs)
+	for _, v := range spec.Volumes {
+		if v.ConfigMap != nil {
+			add(v.ConfigMap.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// addConfigMapHash annotates the pod template of the DaemonSet with a hash of the content of the referenced ConfigMaps,
+// so that the OneAgent pods get rolled out whenever one of them changes. Missing ConfigMaps are hashed as empty.
+func (r *ReconcileOneAgent) addConfigMapHash(instance dynatracev1alpha1.BaseOneAgentDaemonSet, ds *appsv1.DaemonSet) error {
+	names := referencedConfigMaps(instance.GetOneAgentSpec())
+	if len(names) == 0 {
+		return nil
+	}
+
+	hasher := fnv.New32()
+	for _, name := range names {
+		var cm corev1.ConfigMap
+		if err := r.client.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: instance.GetNamespace()}, &cm); k8serrors.IsNotFound(err) {
+			cm = corev1.ConfigMap{}
+		} else if err != nil {
+			return fmt.Errorf("failed to get ConfigMap %s: %w", name, err)
+		}
+
+		if err := writeConfigMapContent(hasher, name, &cm); err != nil {
+			return err
+		}
+	}
+
+	if ds.Spec.Template.Annotations == nil {
+		ds.Spec.Template.Annotations = map[string]string{}
+	}
+	// Replaces a value from .spec.podAnnotations on collision.
+	ds.Spec.Template.Annotations[annotationConfigMapHash] = strconv.FormatUint(uint64(hasher.Sum32()), 10)
+
+	// The template hash needs to be recomputed to cover the new annotation.
+	return updateTemplateHash(ds)
+}
+
+// getConfigMapHash returns the ConfigMap hash on the pod template of the DaemonSet or Deployment, or an empty string if
+// there is none.
+func getConfigMapHash(obj runtime.Object) string {
+	switch w := obj.(type) {
+	case *appsv1.DaemonSet:
+		return w.Spec.Template.Annotations[annotationConfigMapHash]
+	case *appsv1.Deployment:
+		return w.Spec.Template.Annotations[annotationConfigMapHash]
+	}
+	return ""
+}
+
+// writeConfigMapContent writes the name and the entries of the ConfigMap in a stable order.
+func writeConfigMapContent(w io.Writer, name string, cm *corev1.ConfigMap) error {
+	entries := map[string][]byte{}
+	for k, v := range cm.Data {
+		entries["data/"+k] = []byte(v)
+	}
+	for k, v := range cm.BinaryData {
+		entries["binaryData/"+k] = v
+	}
+
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if _, err := w.Write([]byte(name + "\x00")); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := w.Write([]byte(k + "\x00")); err != nil {
+			return err
+		}
+		if _, err := w.Write(append(entries[k], 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapConfigMapToOneAgents returns the requests for the OneAgents in the namespace of the ConfigMap which reference it.
+func (r *ReconcileOneAgent) mapConfigMapToOneAgents(obj handler.MapObject) []reconcile.Request {
+	var list dynatracev1alpha1.OneAgentList
+	if err := r.client.List(context.TODO(), &list, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+		r.logger.Error(err, "failed to list OneAgents for ConfigMap", "name", obj.Meta.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range list.Items {
+		for _, name := range referencedConfigMaps(&list.Items[i].Spec) {
+			if name == obj.Meta.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: list.Items[i].Name, Namespace: list.Items[i].Namespace},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
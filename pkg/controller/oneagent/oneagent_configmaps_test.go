@@ -0,0 +1,159 @@
+package oneagent
+
+import (
+	"context"
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/utils"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestAddConfigMapHash(t *testing.T) {
+	const namespace = "dynatrace"
+
+	oa := newOneAgent()
+	oa.Namespace = namespace
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.TrustedCAs = "certs"
+	oa.Spec.Volumes = []corev1.Volume{{
+		Name:         "config",
+		VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "extra"}}},
+	}}
+
+	certs := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "certs", Namespace: namespace},
+		Data:       map[string]string{"certs": "-----BEGIN CERTIFICATE-----"},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, certs)
+	reconciler := &ReconcileOneAgent{client: c, apiReader: c, scheme: scheme.Scheme, logger: consoleLogger}
+
+	desired := func() *appsv1.DaemonSet {
+		ds, err := newDaemonSetForCR(consoleLogger, oa)
+		require.NoError(t, err)
+		require.NoError(t, reconciler.addConfigMapHash(oa, ds))
+		return ds
+	}
+
+	ds1 := desired()
+	assert.NotEmpty(t, ds1.Spec.Template.Annotations[annotationConfigMapHash])
+	assert.False(t, hasDaemonSetChanged(ds1, desired()))
+
+	certs.Data["certs"] = "-----BEGIN CERTIFICATE----- rotated"
+	require.NoError(t, c.Update(context.TODO(), certs))
+	ds2 := desired()
+	assert.NotEqual(t, ds1.Spec.Template.Annotations[annotationConfigMapHash], ds2.Spec.Template.Annotations[annotationConfigMapHash])
+	assert.True(t, hasDaemonSetChanged(ds1, ds2))
+
+	require.NoError(t, c.Create(context.TODO(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "extra", Namespace: namespace},
+		Data:       map[string]string{"key": "value"},
+	}))
+	assert.True(t, hasDaemonSetChanged(ds2, desired()), "ConfigMap from custom volume created")
+
+	t.Run("no annotation without referenced ConfigMaps", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.APIURL = "https://f.q.d.n/api"
+		ds, err := newDaemonSetForCR(consoleLogger, oa)
+		require.NoError(t, err)
+		require.NoError(t, reconciler.addConfigMapHash(oa, ds))
+		assert.NotContains(t, ds.Spec.Template.Annotations, annotationConfigMapHash)
+	})
+}
+
+func TestMapConfigMapToOneAgents(t *testing.T) {
+	const namespace = "dynatrace"
+
+	newInstance := func(name, trustedCAs string) *dynatracev1alpha1.OneAgent {
+		oa := &dynatracev1alpha1.OneAgent{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+		oa.Spec.TrustedCAs = trustedCAs
+		return oa
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, newInstance("a", "certs"), newInstance("b", "other"), newInstance("c", ""))
+	reconciler := &ReconcileOneAgent{client: c, apiReader: c, scheme: scheme.Scheme, logger: consoleLogger}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "certs", Namespace: namespace}}
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "a", Namespace: namespace}},
+	}, reconciler.mapConfigMapToOneAgents(handler.MapObject{Meta: cm, Object: cm}))
+
+	cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "certs", Namespace: "other-namespace"}}
+	assert.Empty(t, reconciler.mapConfigMapToOneAgents(handler.MapObject{Meta: cm, Object: cm}))
+}
+
+func TestReconcile_ConfigMapChange(t *testing.T) {
+	const namespace = "dynatrace"
+	const oaName = "oneagent"
+	key := types.NamespacedName{Name: oaName, Namespace: namespace}
+
+	certs := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "certs", Namespace: namespace},
+		Data:       map[string]string{"certs": "-----BEGIN CERTIFICATE-----"},
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, certs,
+		&dynatracev1alpha1.OneAgent{
+			ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace, Generation: 1},
+			Spec: dynatracev1alpha1.OneAgentSpec{
+				BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+					APIURL:     "https://ENVIRONMENTID.live.dynatrace.com/api",
+					Tokens:     oaName,
+					TrustedCAs: "certs",
+				},
+			},
+		},
+		NewSecret(oaName, namespace, map[string]string{utils.DynatracePaasToken: "42", utils.DynatraceApiToken: "84"}),
+	)
+
+	dtClient := &dtclient.MockDynatraceClient{}
+	dtClient.On("GetTenantStatus").Return(dtclient.TenantStatus{}, nil)
+	dtClient.On("GetLatestAgentVersion", "unix", "default").Return("42", nil)
+	dtClient.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+	dtClient.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+	dtClient.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+		dtcReconciler: &utils.DynatraceClientReconciler{
+			Client:              c,
+			DynatraceClientFunc: utils.StaticDynatraceClient(dtClient),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+		},
+		instance: &dynatracev1alpha1.OneAgent{},
+	}
+
+	reconcileAndGetHash := func(t *testing.T) string {
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		var ds appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), key, &ds))
+		return ds.Spec.Template.Annotations[annotationConfigMapHash]
+	}
+
+	hash := reconcileAndGetHash(t)
+	require.NotEmpty(t, hash)
+
+	var oa dynatracev1alpha1.OneAgent
+	require.NoError(t, c.Get(context.TODO(), key, &oa))
+	require.Equal(t, int64(1), oa.Status.ObservedGeneration, "generation observed, later reconciles take the fast path")
+	assert.Equal(t, hash, reconcileAndGetHash(t), "unchanged without ConfigMap change")
+
+	certs.Data["certs"] = "-----BEGIN CERTIFICATE----- rotated"
+	require.NoError(t, c.Update(context.TODO(), certs))
+	assert.NotEqual(t, hash, reconcileAndGetHash(t), "ConfigMap change rolled out")
+}
@@ -9,7 +9,11 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
@@ -18,14 +22,17 @@ import (
 	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
 	"github.com/Dynatrace/dynatrace-oneagent-operator/version"
 	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -40,17 +47,59 @@ import (
 const splayTimeSeconds = uint16(10)
 const annotationTemplateHash = "internal.oneagent.dynatrace.com/template-hash"
 
+// oneAgentContainerName is the name of the OneAgent container on the pods
+const oneAgentContainerName = "dynatrace-oneagent"
+
+// annotationReconcilePaused stops the reconciliation of an instance while set to "true"
+const annotationReconcilePaused = "dynatrace.com/reconcile-paused"
+
+// annotationReconcileNow requests a full reconciliation, including the rollout, whenever its value changes, e.g., set
+// to the current timestamp after changes on the Dynatrace side
+const annotationReconcileNow = "dynatrace.com/reconcile-now"
+
+// annotationProxyHash is set on the OneAgent pods to roll them out when the proxy secret changes
+const annotationProxyHash = "internal.oneagent.dynatrace.com/proxy-hash"
+
+// annotationConfigMapHash is set on the OneAgent pods to roll them out when the content of a referenced ConfigMap
+// changes
+const annotationConfigMapHash = "internal.oneagent.dynatrace.com/configmap-hash"
+
+// errDaemonSetDeleting is returned while a DaemonSet to recreate is still being deleted, e.g. with its orphan finalizer
+// not yet processed, so the new DaemonSet can't be created yet.
+var errDaemonSetDeleting = errors.New("previous daemonset still being deleted")
+
+// MaxConcurrentReconciles is the number of OneAgent instances reconciled in parallel by the controller.
+var MaxConcurrentReconciles = 1
+
+// InstanceStatusConcurrency is the number of pods whose OneAgent status is queried in parallel while reconciling a
+// OneAgent instance.
+var InstanceStatusConcurrency = 4
+
+// DryRun makes the controller only log the changes it would apply to the cluster, without creating, updating or
+// deleting objects, and without updating the status of the OneAgent instances.
+var DryRun = false
+
 // Add creates a new OneAgent Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	return add(mgr, NewOneAgentReconciler(
-		mgr.GetClient(),
+	logger := log.Log.WithName("oneagent.controller")
+
+	c := mgr.GetClient()
+	if DryRun {
+		logger.Info("Dry-run mode enabled, changes to the cluster are only logged")
+		c = utils.NewDryRunClient(c, logger)
+	}
+
+	r := NewOneAgentReconciler(
+		c,
 		mgr.GetAPIReader(),
 		mgr.GetScheme(),
 		mgr.GetConfig(),
-		log.Log.WithName("oneagent.controller"),
+		logger,
 		utils.BuildDynatraceClient,
-		&dynatracev1alpha1.OneAgent{}))
+		&dynatracev1alpha1.OneAgent{})
+	r.dryRun = DryRun
+	return add(mgr, r)
 }
 
 // NewOneAgentReconciler initializes a new ReconcileOneAgent instance
@@ -76,7 +125,10 @@ func NewOneAgentReconciler(client client.Client, apiReader client.Reader, scheme
 // add adds a new OneAgentController to mgr with r as the reconcile.Reconciler
 func add(mgr manager.Manager, r *ReconcileOneAgent) error {
 	// Create a new controller
-	c, err := controller.New("oneagent-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("oneagent-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
@@ -96,6 +148,32 @@ func add(mgr manager.Manager, r *ReconcileOneAgent) error {
 		return err
 	}
 
+	// Watch for changes to secondary resource Deployments and requeue the owner OneAgent
+	err = c.Watch(&source.Kind{Type: &appsv1.Deployment{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &dynatracev1alpha1.OneAgent{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource PodDisruptionBudgets and requeue the owner OneAgent
+	err = c.Watch(&source.Kind{Type: &policyv1beta1.PodDisruptionBudget{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &dynatracev1alpha1.OneAgent{},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to ConfigMaps and requeue the OneAgents referencing them
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(r.mapConfigMapToOneAgents),
+	})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -112,6 +190,19 @@ type ReconcileOneAgent struct {
 	dtcReconciler   *utils.DynatraceClientReconciler
 	istioController *istio.Controller
 	instance        dynatracev1alpha1.BaseOneAgentDaemonSet
+
+	// Skips the changes the client can't intercept in dry-run mode, e.g., Istio objects.
+	dryRun bool
+
+	// Set for testing purposes, leave nil to use the current time.
+	now func() time.Time
+}
+
+func (r *ReconcileOneAgent) currentTime() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
 }
 
 // Reconcile reads that state of the cluster for a OneAgent object and makes changes based on the state read
@@ -138,6 +229,7 @@ func (r *ReconcileOneAgent) Reconcile(request reconcile.Request) (reconcile.Resu
 
 	rec := reconciliation{log: logger, instance: instance, requeueAfter: 30 * time.Minute}
 	r.reconcileImpl(&rec)
+	rec.Update(reconcileAvailable(logger, instance), rec.requeueAfter, "Available condition updated")
 
 	if rec.err != nil {
 		if rec.update || instance.GetOneAgentStatus().SetPhaseOnError(rec.err) {
@@ -152,9 +244,25 @@ func (r *ReconcileOneAgent) Reconcile(request reconcile.Request) (reconcile.Resu
 			return reconcile.Result{RequeueAfter: 1 * time.Minute}, nil
 		}
 
+		var cerr dtclient.CircuitOpenError
+		if errors.As(rec.err, &cerr) {
+			logger.Info("Requests to Dynatrace API stopped after repeated failures", "retryAfter", cerr.RetryAfter.String())
+			return reconcile.Result{RequeueAfter: cerr.RetryAfter}, nil
+		}
+
 		return reconcile.Result{}, rec.err
 	}
 
+	if gen := instance.GetGeneration(); rec.rolledOut && instance.GetOneAgentStatus().ObservedGeneration != gen {
+		instance.GetOneAgentStatus().ObservedGeneration = gen
+		rec.update = true
+	}
+
+	if v := instance.GetAnnotations()[annotationReconcileNow]; rec.rolledOut && instance.GetOneAgentStatus().ObservedReconcileNow != v {
+		instance.GetOneAgentStatus().ObservedReconcileNow = v
+		rec.update = true
+	}
+
 	if rec.update {
 		if err := r.updateCR(instance); err != nil {
 			return reconcile.Result{}, err
@@ -171,12 +279,17 @@ type reconciliation struct {
 	// If update is true, then changes on instance will be sent to the Kubernetes API.
 	//
 	// Additionally, if err is not nil, then the Reconciliation will fail with its value. Unless it's a Too Many
-	// Requests HTTP error from the Dynatrace API, on which case, a reconciliation is requeued after one minute delay.
+	// Requests HTTP error from the Dynatrace API, on which case, a reconciliation is requeued after one minute delay,
+	// or a CircuitOpenError, on which case it's requeued once the circuit breaker lets requests through again.
 	//
 	// If err is nil, then a reconciliation is requeued after requeueAfter.
 	err          error
 	update       bool
 	requeueAfter time.Duration
+
+	// rolledOut is true if the DaemonSet is known to be rolled out for the current generation of the spec. On a
+	// successful reconciliation, the generation is then stored as observed on the status.
+	rolledOut bool
 }
 
 func (rec *reconciliation) Error(err error) bool {
@@ -198,18 +311,83 @@ func (rec *reconciliation) Update(upd bool, d time.Duration, cause string) bool
 }
 
 func (r *ReconcileOneAgent) reconcileImpl(rec *reconciliation) {
+	if rec.instance.GetAnnotations()[annotationReconcilePaused] == "true" {
+		rec.log.Info("Reconciliation is paused", "annotation", annotationReconcilePaused)
+		rec.requeueAfter = 1 * time.Hour
+		rec.Update(rec.instance.GetOneAgentStatus().Conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.PausedConditionType,
+			Status:  corev1.ConditionTrue,
+			Reason:  dynatracev1alpha1.ReasonReconcilePaused,
+			Message: fmt.Sprintf("Reconciliation paused by annotation %s", annotationReconcilePaused),
+		}), 1*time.Hour, "Reconciliation paused")
+		return
+	}
+
+	upd := rec.instance.GetOneAgentStatus().Conditions.RemoveCondition(dynatracev1alpha1.PausedConditionType)
+	rec.Update(upd, 5*time.Minute, "Reconciliation resumed")
+
 	if err := validate(rec.instance); rec.Error(err) {
 		return
 	}
 
+	upd, err := reconcileDNSConfig(rec.instance)
+	rec.Update(upd, 5*time.Minute, "DNS config condition updated")
+	if rec.Error(err) {
+		return
+	}
+
+	upd = reconcileTerminationGracePeriod(rec.log, rec.instance)
+	rec.Update(upd, 5*time.Minute, "Termination grace period condition updated")
+
+	upd = reconcileSecurityContext(rec.log, rec.instance)
+	rec.Update(upd, 5*time.Minute, "Security context condition updated")
+
+	upd = reconcileSecurityPreset(rec.log, rec.instance)
+	rec.Update(upd, 5*time.Minute, "Security preset condition updated")
+
+	upd = reconcileImageDigest(rec.log, rec.instance)
+	rec.Update(upd, 5*time.Minute, "Image digest condition updated")
+
+	upd = reconcileCustomVolumes(rec.log, rec.instance)
+	rec.Update(upd, 5*time.Minute, "Custom volumes condition updated")
+
+	upd = reconcileFeatureFlags(rec.log, rec.instance)
+	rec.Update(upd, 5*time.Minute, "Feature flags condition updated")
+
+	upd = reconcileUpdateSettings(rec.log, rec.instance)
+	rec.Update(upd, 5*time.Minute, "Update settings condition updated")
+
+	upd = reconcileCustomCommand(rec.log, rec.instance)
+	rec.Update(upd, 5*time.Minute, "Custom command condition updated")
+
+	upd = reconcileLogMonitoring(rec.log, rec.instance)
+	rec.Update(upd, 5*time.Minute, "Log monitoring condition updated")
+
+	upd, err = r.reconcileMonitoredNamespaces(rec.instance)
+	if rec.Error(err) {
+		return
+	}
+	rec.Update(upd, 5*time.Minute, "Monitored namespaces updated")
+
 	dtc, upd, err := r.dtcReconciler.Reconcile(context.Background(), rec.instance)
 	rec.Update(upd, 5*time.Minute, "Token conditions updated")
 	if rec.Error(err) {
 		return
 	}
 
-	if rec.instance.GetOneAgentSpec().EnableIstio {
-		if upd, err := r.istioController.ReconcileIstio(rec.instance, dtc); err != nil {
+	maintenance, upd := reconcileMaintenance(rec.log, rec.instance, dtc)
+	if maintenance {
+		rec.requeueAfter = maintenanceRequeueInterval
+		rec.Update(upd, maintenanceRequeueInterval, "Maintenance condition updated")
+		return
+	}
+	rec.Update(upd, 5*time.Minute, "Maintenance condition updated")
+
+	if r.dryRun {
+		rec.log.Info("dry-run: skipping Istio reconciliation")
+	} else if rec.instance.GetOneAgentSpec().EnableIstio {
+		upd, err := r.istioController.ReconcileIstio(rec.instance, dtc)
+		if err != nil && !errors.Is(err, istio.ErrNotInstalled) {
 			// If there are errors log them, but move on.
 			rec.log.Info("Istio: failed to reconcile objects", "error", err)
 		} else if upd {
@@ -217,9 +395,24 @@ func (r *ReconcileOneAgent) reconcileImpl(rec *reconciliation) {
 			rec.requeueAfter = 30 * time.Second
 			return
 		}
+		rec.Update(reconcileIstioReady(rec.log, rec.instance, err), 5*time.Minute, "Istio condition updated")
+	} else {
+		if r.istioController != nil {
+			if upd, err := r.istioController.RemoveIstio(rec.instance); err != nil {
+				rec.log.Info("Istio: failed to remove objects", "error", err)
+			} else if upd {
+				rec.log.Info("Istio: objects removed")
+			}
+		}
+		rec.Update(reconcileIstioReady(rec.log, rec.instance, nil), 5*time.Minute, "Istio condition updated")
 	}
 
+	useImmutableImage := rec.instance.GetOneAgentStatus().UseImmutableImage
 	upd = utils.SetUseImmutableImageStatus(r.logger, rec.instance, dtc)
+	if rec.instance.GetOneAgentStatus().UseImmutableImage != useImmutableImage {
+		// The pod spec depends on the image type, the DaemonSet has to be rolled out again.
+		rec.instance.GetOneAgentStatus().ObservedGeneration = 0
+	}
 	if rec.Update(upd, 5*time.Second, "checked cluster version") {
 		return
 	}
@@ -231,8 +424,31 @@ func (r *ReconcileOneAgent) reconcileImpl(rec *reconciliation) {
 		}
 	}
 
-	upd, err = r.reconcileRollout(rec.log, rec.instance, dtc)
-	if rec.Error(err) || rec.Update(upd, 5*time.Minute, "Rollout reconciled") {
+	if skip, err := r.canSkipRollout(rec.log, rec.instance); rec.Error(err) {
+		return
+	} else if skip {
+		rec.log.Info("Spec unchanged since last rollout, refreshing status only", "generation", rec.instance.GetGeneration())
+		rec.rolledOut = true
+	} else {
+		upd, err = r.reconcileRollout(rec.log, rec.instance, dtc)
+		if errors.Is(err, errDaemonSetDeleting) {
+			rec.log.Info("Waiting for the previous daemonset to be deleted before recreating it")
+			rec.requeueAfter = 5 * time.Second
+			return
+		} else if rec.Error(err) {
+			return
+		}
+		rec.rolledOut = true
+		if rec.Update(upd, 5*time.Minute, "Rollout reconciled") {
+			return
+		}
+	}
+
+	if err := r.reconcileOrphanedPods(rec.log, rec.instance); rec.Error(err) {
+		return
+	}
+
+	if err := r.reconcilePodDisruptionBudget(rec.log, rec.instance); rec.Error(err) {
 		return
 	}
 
@@ -246,8 +462,15 @@ func (r *ReconcileOneAgent) reconcileImpl(rec *reconciliation) {
 		return
 	}
 
-	upd, err = r.reconcileVersion(rec.log, rec.instance, dtc)
-	if rec.Error(err) || rec.Update(upd, 5*time.Minute, "Versions reconciled") {
+	upd, wait, err := r.reconcileScheduledVersion(rec.log, rec.instance, dtc)
+	if rec.Error(err) {
+		return
+	}
+	rec.Update(upd, 5*time.Minute, "Versions reconciled")
+	if wait > 0 && wait < rec.requeueAfter {
+		rec.requeueAfter = wait
+	}
+	if upd || wait > 0 {
 		return
 	}
 
@@ -261,31 +484,29 @@ func (r *ReconcileOneAgent) reconcileRollout(logger logr.Logger, instance dynatr
 	updateCR := false
 
 	// Define a new DaemonSet object
-	dsDesired, err := newDaemonSetForCR(logger, instance)
+	dsDesired, err := r.newDesiredDaemonSet(logger, instance)
 	if err != nil {
 		return false, err
 	}
 
+	if upd, err := reconcileConfigHash(logger, instance, dsDesired); err != nil {
+		return false, err
+	} else if upd {
+		updateCR = true
+	}
+
 	// Set OneAgent instance as the owner and controller
 	if err := controllerutil.SetControllerReference(instance, dsDesired, r.scheme); err != nil {
 		return false, err
 	}
 
-	// Check if this DaemonSet already exists
-	dsActual := &appsv1.DaemonSet{}
-	err = r.client.Get(context.TODO(), types.NamespacedName{Name: dsDesired.Name, Namespace: dsDesired.Namespace}, dsActual)
-	if err != nil && k8serrors.IsNotFound(err) {
-		logger.Info("Creating new daemonset")
-		if err = r.client.Create(context.TODO(), dsDesired); err != nil {
-			return false, err
-		}
-	} else if err != nil {
+	if isDeploymentMode(instance) {
+		err = r.reconcileDeployment(logger, instance, dsDesired)
+	} else if err = r.reconcileDaemonSet(logger, instance, dsDesired); err == nil {
+		err = r.reconcileNodeOverrides(logger, instance)
+	}
+	if err != nil {
 		return false, err
-	} else if hasDaemonSetChanged(dsDesired, dsActual) {
-		logger.Info("Updating existing daemonset")
-		if err = r.client.Update(context.TODO(), dsDesired); err != nil {
-			return false, err
-		}
 	}
 
 	if instance.GetOneAgentStatus().Version == "" {
@@ -300,7 +521,7 @@ func (r *ReconcileOneAgent) reconcileRollout(logger logr.Logger, instance dynatr
 				instance.GetOneAgentStatus().Version = instance.GetOneAgentSpec().AgentVersion
 			}
 		} else {
-			desired, err := dtc.GetLatestAgentVersion(dtclient.OsUnix, dtclient.InstallerTypeDefault)
+			desired, _, err := r.getDesiredVersion(logger, instance, dtc)
 			if err != nil {
 				return false, fmt.Errorf("failed to get desired version: %w", err)
 			}
@@ -321,9 +542,150 @@ func (r *ReconcileOneAgent) reconcileRollout(logger logr.Logger, instance dynatr
 	return updateCR, nil
 }
 
+// reconcileDaemonSet creates or updates the DaemonSet running the OneAgent pods, and removes the Deployment of the
+// instance if it was run as such before, as well as the DaemonSet under a previous .spec.daemonSetName.
+func (r *ReconcileOneAgent) reconcileDaemonSet(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dsDesired *appsv1.DaemonSet) error {
+	// Check if this DaemonSet already exists
+	dsActual := &appsv1.DaemonSet{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: dsDesired.Name, Namespace: dsDesired.Namespace}, dsActual)
+	if err != nil && k8serrors.IsNotFound(err) {
+		logger.Info("Creating new daemonset")
+		if err = r.client.Create(context.TODO(), dsDesired); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if dsActual.DeletionTimestamp != nil {
+		return errDaemonSetDeleting
+	} else if hasImmutableFieldChanged(dsDesired, dsActual) {
+		logger.Info("Immutable fields of existing daemonset changed, recreating it")
+		if err = r.recreateDaemonSet(dsDesired, dsActual); err != nil {
+			return err
+		}
+	} else if hasDaemonSetChanged(dsDesired, dsActual) {
+		logger.Info("Updating existing daemonset")
+		if err = r.client.Update(context.TODO(), dsDesired); err != nil {
+			return err
+		}
+	}
+
+	if err := r.deleteRenamedDaemonSets(logger, instance); err != nil {
+		return err
+	}
+	return r.deleteOwnedWorkload(logger, instance, &appsv1.Deployment{})
+}
+
+// newDesiredDaemonSet returns the DaemonSet for the instance, including the parts of the pod template that don't come
+// from the spec. In immutable-image mode, the image of a held back version update is kept.
+func (r *ReconcileOneAgent) newDesiredDaemonSet(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) (*appsv1.DaemonSet, error) {
+	ds, err := newDaemonSetForCR(logger, r.imageVersionInstance(logger, instance))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.addExternalConfig(instance, ds); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// addExternalConfig adds the inputs of the pod template read from other objects than the instance: the hashes of the
+// proxy secret and the referenced ConfigMaps, the key of the installer token and the cluster name.
+func (r *ReconcileOneAgent) addExternalConfig(instance dynatracev1alpha1.BaseOneAgentDaemonSet, ds *appsv1.DaemonSet) error {
+	if err := r.addProxySecretHash(instance, ds); err != nil {
+		return err
+	}
+	if err := r.addConfigMapHash(instance, ds); err != nil {
+		return err
+	}
+	if err := r.resolveInstallerTokenKey(instance, ds); err != nil {
+		return err
+	}
+	return r.addClusterNameArg(instance, ds)
+}
+
+// canSkipRollout returns true if the DaemonSet or Deployment has already been rolled out for the current generation of the spec, so
+// only the instance and version statuses need to be refreshed.
+//
+// The first reconciliation is always done fully, as well as reconciliations requested through a new value of the
+// dynatrace.com/reconcile-now annotation. Since the pod template also depends on objects other than the instance, e.g.,
+// the proxy secret, the referenced ConfigMaps or the kube-system namespace, the rollout is only skipped while the
+// template hashes of the desired DaemonSets match the ones of the live workloads, including the node overrides.
+func (r *ReconcileOneAgent) canSkipRollout(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) (bool, error) {
+	oaStatus := instance.GetOneAgentStatus()
+	if instance.GetGeneration() == 0 || oaStatus.ObservedGeneration != instance.GetGeneration() {
+		return false, nil
+	}
+
+	if instance.GetAnnotations()[annotationReconcileNow] != oaStatus.ObservedReconcileNow {
+		return false, nil
+	}
+
+	if oaStatus.Version == "" || oaStatus.Tokens != utils.GetTokensName(instance) {
+		return false, nil
+	}
+
+	ds, err := r.newDesiredDaemonSet(logger, instance)
+	if err != nil {
+		return false, err
+	}
+
+	desired := runtime.Object(ds)
+	if isDeploymentMode(instance) {
+		if desired, err = newDeploymentForCR(instance, ds); err != nil {
+			return false, err
+		}
+	}
+	if upToDate, err := r.isWorkloadUpToDate(instance, desired, newWorkload(instance)); err != nil || !upToDate {
+		return false, err
+	}
+
+	if isDeploymentMode(instance) {
+		return true, nil
+	}
+
+	overrides, err := newNodeOverrideDaemonSets(logger, r.imageVersionInstance(logger, instance))
+	if err != nil {
+		return false, err
+	}
+	for _, o := range overrides {
+		if err := r.addExternalConfig(instance, o); err != nil {
+			return false, err
+		}
+		if upToDate, err := r.isWorkloadUpToDate(instance, o, &appsv1.DaemonSet{}); err != nil || !upToDate {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// isWorkloadUpToDate returns true if the live object with the name of desired exists and has its template hash and
+// ConfigMap hash. actual must be an empty object of the same kind.
+//
+// The ConfigMap hash is compared on the pod templates as well, since that's where the pods pick it up from, so
+// ConfigMap changes get rolled out even if the template hash on the workload has been left unchanged.
+func (r *ReconcileOneAgent) isWorkloadUpToDate(instance dynatracev1alpha1.BaseOneAgentDaemonSet, desired, actual runtime.Object) (bool, error) {
+	desiredMeta, ok := desired.(metav1.Object)
+	if !ok {
+		return false, fmt.Errorf("unexpected type %T", desired)
+	}
+
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: desiredMeta.GetName(), Namespace: instance.GetNamespace()}, actual); k8serrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	actualMeta, ok := actual.(metav1.Object)
+	if !ok || getTemplateHash(actualMeta) != getTemplateHash(desiredMeta) {
+		return false, nil
+	}
+	return getConfigMapHash(actual) == getConfigMapHash(desired), nil
+}
+
 func (r *ReconcileOneAgent) reconcilePullSecret(instance dynatracev1alpha1.BaseOneAgent, log logr.Logger) error {
 	var tkns corev1.Secret
-	if err := r.client.Get(context.TODO(), client.ObjectKey{Name: utils.GetTokensName(instance), Namespace: instance.GetNamespace()}, &tkns); err != nil {
+	if err := r.client.Get(context.TODO(), client.ObjectKey{Name: utils.GetPaaSTokenSecretName(instance), Namespace: utils.GetTokensNamespace(instance)}, &tkns); err != nil {
 		return fmt.Errorf("failed to query tokens: %w", err)
 	}
 	pullSecretData, err := utils.GeneratePullSecretData(r.client, instance, &tkns)
@@ -348,21 +710,38 @@ func (r *ReconcileOneAgent) getPods(instance *dynatracev1alpha1.BaseOneAgentDaem
 	return podList.Items, listOps, err
 }
 
+// updateCR writes the status of the instance. On conflicts, the instance is read again and the status written onto
+// the latest version, so changes of the spec in the meantime are kept.
 func (r *ReconcileOneAgent) updateCR(instance dynatracev1alpha1.BaseOneAgentDaemonSet) error {
 	instance.GetOneAgentStatus().UpdatedTimestamp = metav1.Now()
-	return r.client.Status().Update(context.TODO(), instance)
+	desired := instance.GetOneAgentStatus().DeepCopy()
+	key := types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}
+
+	conflicted := false
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if conflicted {
+			if err := r.apiReader.Get(context.TODO(), key, instance); err != nil {
+				return err
+			}
+			desired.DeepCopyInto(instance.GetOneAgentStatus())
+		}
+
+		err := r.client.Status().Update(context.TODO(), instance)
+		conflicted = k8serrors.IsConflict(err)
+		return err
+	})
 }
 
 func newDaemonSetForCR(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) (*appsv1.DaemonSet, error) {
-	unprivileged := os.Getenv("ONEAGENT_OPERATOR_DEBUG_UNPRIVILEGED") == "true"
+	unprivileged := os.Getenv("ONEAGENT_OPERATOR_DEBUG_UNPRIVILEGED") == "true" || isRestrictedPreset(instance.GetOneAgentSpec())
 
 	podSpec := newPodSpecForCR(instance, unprivileged, logger)
 	selectorLabels := buildLabels(instance.GetName())
-	mergedLabels := mergeLabels(instance.GetOneAgentSpec().Labels, selectorLabels)
+	mergedLabels := buildPodLabels(instance)
 
 	ds := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        instance.GetName(),
+			Name:        daemonSetName(instance),
 			Namespace:   instance.GetNamespace(),
 			Labels:      mergedLabels,
 			Annotations: map[string]string{},
@@ -376,10 +755,12 @@ func newDaemonSetForCR(logger logr.Logger, instance dynatracev1alpha1.BaseOneAge
 		},
 	}
 
+	managedAnnotations := map[string]string{}
 	if unprivileged {
-		ds.Spec.Template.ObjectMeta.Annotations = map[string]string{
-			"container.apparmor.security.beta.kubernetes.io/dynatrace-oneagent": "unconfined",
-		}
+		managedAnnotations["container.apparmor.security.beta.kubernetes.io/"+oneAgentContainerName] = "unconfined"
+	}
+	if annotations := mergeLabels(instance.GetOneAgentSpec().PodAnnotations, managedAnnotations); len(annotations) > 0 {
+		ds.Spec.Template.ObjectMeta.Annotations = annotations
 	}
 
 	dsHash, err := generateDaemonSetHash(ds)
@@ -401,7 +782,7 @@ func newPodSpecForCR(instance dynatracev1alpha1.BaseOneAgentDaemonSet, unprivile
 		sa = "dynatrace-oneagent-unprivileged"
 	}
 
-	resources := instance.GetOneAgentSpec().Resources
+	resources := resourcesForNodeClass(instance.GetOneAgentSpec())
 	if resources.Requests == nil {
 		resources.Requests = corev1.ResourceList{}
 	}
@@ -410,7 +791,7 @@ func newPodSpecForCR(instance dynatracev1alpha1.BaseOneAgentDaemonSet, unprivile
 		resources.Requests[corev1.ResourceCPU] = *resource.NewScaledQuantity(1, -1)
 	}
 
-	args := instance.GetOneAgentSpec().Args
+	args := buildInstallerArgs(instance.GetOneAgentSpec(), targetOS(instance.GetOneAgentSpec()))
 	if instance.GetOneAgentSpec().Proxy != nil && (instance.GetOneAgentSpec().Proxy.ValueFrom != "" || instance.GetOneAgentSpec().Proxy.Value != "") {
 		args = append(args, "--set-proxy=$(https_proxy)")
 	}
@@ -423,75 +804,62 @@ func newPodSpecForCR(instance dynatracev1alpha1.BaseOneAgentDaemonSet, unprivile
 		args = append(args, "--set-infra-only=true")
 	}
 
-	args = append(args, "--set-host-property=OperatorVersion="+version.Version)
+	args = append(args, buildHostTagArgs(instance.GetOneAgentSpec(), args)...)
+	args = append(args, buildFeatureFlagArgs(instance.GetOneAgentSpec(), args)...)
+	args = append(args, buildLogMonitoringArgs(instance.GetOneAgentSpec(), args)...)
+	args = append(args, buildAutoUpdateArgs(instance.GetOneAgentSpec(), args)...)
+	args = append(args, fmt.Sprintf("--set-host-property=%s=%s", operatorVersionHostProperty, version.Version))
+
+	// A custom command may not understand the installer arguments, so it only gets the arguments from the spec.
+	command := instance.GetOneAgentSpec().Command
+	if len(command) > 0 {
+		args = append([]string(nil), instance.GetOneAgentSpec().Args...)
+	}
 
 	// K8s 1.18+ is expected to drop the "beta.kubernetes.io" labels in favor of "kubernetes.io" which was added on K8s 1.14.
 	// To support both older and newer K8s versions we use node affinity.
 
-	var secCtx *corev1.SecurityContext
-	if unprivileged {
-		secCtx = &corev1.SecurityContext{
-			Capabilities: &corev1.Capabilities{
-				Drop: []corev1.Capability{
-					"ALL",
-				},
-				Add: []corev1.Capability{
-					"CHOWN",
-					"DAC_OVERRIDE",
-					"DAC_READ_SEARCH",
-					"FOWNER",
-					"FSETID",
-					"KILL",
-					"NET_ADMIN",
-					"NET_RAW",
-					"SETFCAP",
-					"SETGID",
-					"SETUID",
-					"SYS_ADMIN",
-					"SYS_CHROOT",
-					"SYS_PTRACE",
-					"SYS_RESOURCE",
-				},
-			},
-		}
-	} else {
-		trueVar := true
-		secCtx = &corev1.SecurityContext{
-			Privileged: &trueVar,
-		}
-	}
+	secCtx := newSecurityContextForCR(instance, unprivileged)
+
+	volumes, volumeMounts := prepareVolumes(instance), prepareVolumeMounts(instance)
+	customVolumes, customVolumeMounts, _ := prepareCustomVolumes(instance, volumes, volumeMounts)
+	volumes = append(volumes, customVolumes...)
+	volumeMounts = append(volumeMounts, customVolumeMounts...)
 
 	p = corev1.PodSpec{
 		Containers: []corev1.Container{{
+			Command:         command,
 			Args:            args,
 			Env:             nil,
 			Image:           "",
 			ImagePullPolicy: corev1.PullAlways,
-			Name:            "dynatrace-oneagent",
+			Name:            oneAgentContainerName,
 			ReadinessProbe: &corev1.Probe{
-				Handler: corev1.Handler{
-					Exec: &corev1.ExecAction{
-						Command: []string{
-							"/bin/sh", "-c", "grep -q oneagentwatchdo /proc/[0-9]*/stat",
-						},
-					},
-				},
+				Handler:             watchdogProbeHandler(),
 				InitialDelaySeconds: 30,
 				PeriodSeconds:       30,
 				TimeoutSeconds:      1,
 			},
+			StartupProbe:    newStartupProbe(instance),
+			LivenessProbe:   instance.GetOneAgentSpec().LivenessProbe.DeepCopy(),
+			Lifecycle:       &corev1.Lifecycle{PreStop: newPreStopHandler(instance)},
 			Resources:       resources,
 			SecurityContext: secCtx,
-			VolumeMounts:    prepareVolumeMounts(instance),
+			VolumeMounts:    volumeMounts,
 		}},
 		HostNetwork:        true,
 		HostPID:            true,
 		HostIPC:            true,
+		SecurityContext:    instance.GetOneAgentSpec().PodSecurityContext,
 		NodeSelector:       instance.GetOneAgentSpec().NodeSelector,
 		PriorityClassName:  instance.GetOneAgentSpec().PriorityClassName,
+		PreemptionPolicy:   instance.GetOneAgentSpec().PreemptionPolicy,
 		ServiceAccountName: sa,
 		Tolerations:        instance.GetOneAgentSpec().Tolerations,
 		DNSPolicy:          instance.GetOneAgentSpec().DNSPolicy,
+		DNSConfig:          instance.GetOneAgentSpec().DNSConfig,
+
+		TerminationGracePeriodSeconds: terminationGracePeriodSeconds(instance),
 		Affinity: &corev1.Affinity{
 			NodeAffinity: &corev1.NodeAffinity{
 				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
@@ -528,7 +896,13 @@ func newPodSpecForCR(instance dynatracev1alpha1.BaseOneAgentDaemonSet, unprivile
 				},
 			},
 		},
-		Volumes: prepareVolumes(instance),
+		Volumes: volumes,
+	}
+
+	if overrides := instance.GetOneAgentSpec().NodeOverrides; len(overrides) > 0 {
+		// Nodes matching a node override are left to the override's DaemonSet.
+		terms := &p.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		*terms = excludeNodeOverrides(*terms, overrides)
 	}
 
 	if instance.GetOneAgentStatus().UseImmutableImage {
@@ -543,15 +917,83 @@ func newPodSpecForCR(instance dynatracev1alpha1.BaseOneAgentDaemonSet, unprivile
 		}
 	}
 
+	for _, sidecar := range instance.GetOneAgentSpec().Sidecars {
+		p.Containers = append(p.Containers, *sidecar.DeepCopy())
+	}
+
+	for _, initContainer := range instance.GetOneAgentSpec().InitContainers {
+		p.InitContainers = append(p.InitContainers, *initContainer.DeepCopy())
+	}
+
+	p.ReadinessGates = append(p.ReadinessGates, instance.GetOneAgentSpec().ReadinessGates...)
+
 	return p
 }
 
+// watchdogProbeHandler checks whether the OneAgent watchdog process is running
+func watchdogProbeHandler() corev1.Handler {
+	return corev1.Handler{
+		Exec: &corev1.ExecAction{
+			Command: []string{
+				"/bin/sh", "-c", "grep -q oneagentwatchdo /proc/[0-9]*/stat",
+			},
+		},
+	}
+}
+
+// defaultTerminationGracePeriodSeconds gives the OneAgent time to flush buffered data on shutdown
+const defaultTerminationGracePeriodSeconds = int64(60)
+
+// terminationGracePeriodSeconds returns the grace period from .spec.terminationGracePeriodSeconds, or the default.
+func terminationGracePeriodSeconds(instance dynatracev1alpha1.BaseOneAgentDaemonSet) *int64 {
+	seconds := defaultTerminationGracePeriodSeconds
+	if s := instance.GetOneAgentSpec().TerminationGracePeriodSeconds; s != nil {
+		seconds = *s
+	}
+	return &seconds
+}
+
+// newStartupProbe returns the probe from .spec.startupProbe, or a default one that waits up to 10 minutes for the
+// OneAgent installation to finish. Readiness and liveness probes only start after it succeeded.
+func newStartupProbe(instance dynatracev1alpha1.BaseOneAgentDaemonSet) *corev1.Probe {
+	if p := instance.GetOneAgentSpec().StartupProbe; p != nil {
+		return p.DeepCopy()
+	}
+
+	return &corev1.Probe{
+		Handler:          watchdogProbeHandler(),
+		PeriodSeconds:    10,
+		TimeoutSeconds:   1,
+		FailureThreshold: 60,
+	}
+}
+
+// newPreStopHandler returns the hook from .spec.preStop, or a default one that stops the OneAgent watchdog and waits
+// for it to exit. The kubelet bounds the wait by the termination grace period.
+func newPreStopHandler(instance dynatracev1alpha1.BaseOneAgentDaemonSet) *corev1.Handler {
+	if h := instance.GetOneAgentSpec().PreStop; h != nil {
+		return h.DeepCopy()
+	}
+
+	return &corev1.Handler{
+		Exec: &corev1.ExecAction{
+			Command: []string{
+				"/bin/sh", "-c",
+				"for p in /proc/[0-9]*; do grep -q oneagentwatchdo $p/stat 2>/dev/null && kill -TERM ${p#/proc/}; done; " +
+					"while grep -q oneagentwatchdo /proc/[0-9]*/stat 2>/dev/null; do sleep 1; done",
+			},
+		},
+	}
+}
+
 func preparePodSpecInstaller(p *corev1.PodSpec, instance dynatracev1alpha1.BaseOneAgentDaemonSet) error {
 	img := "docker.io/dynatrace/oneagent:latest"
 	envVarImg := os.Getenv("RELATED_IMAGE_DYNATRACE_ONEAGENT")
 
 	if instance.GetOneAgentSpec().Image != "" {
 		img = instance.GetOneAgentSpec().Image
+	} else if instance.GetOneAgentSpec().ImageRepository != "" {
+		img = imageFromRepository(instance.GetOneAgentSpec())
 	} else if envVarImg != "" {
 		img = envVarImg
 	}
@@ -571,15 +1013,42 @@ func preparePodSpecImmutableImage(p *corev1.PodSpec, instance dynatracev1alpha1.
 		Name: pullSecretName,
 	})
 
-	i, err := utils.BuildOneAgentImage(instance.GetSpec().APIURL, instance.GetOneAgentSpec().AgentVersion)
-	if err != nil {
-		return err
+	if isImagePinned(instance.GetOneAgentSpec()) {
+		p.Containers[0].Image = instance.GetOneAgentSpec().Image
+	} else if instance.GetOneAgentSpec().Image == "" && instance.GetOneAgentSpec().ImageRepository != "" {
+		p.Containers[0].Image = imageFromRepository(instance.GetOneAgentSpec())
+	} else {
+		i, err := utils.BuildOneAgentImage(instance.GetSpec().APIURL, instance.GetOneAgentSpec().AgentVersion)
+		if err != nil {
+			return err
+		}
+		p.Containers[0].Image = i
+	}
+
+	// The proxy is referenced by the --set-proxy argument, but the remaining installer variables aren't needed.
+	for _, env := range instance.GetOneAgentSpec().Env {
+		if env.Name == "https_proxy" {
+			p.Containers[0].Env = append(p.Containers[0].Env, env)
+			return nil
+		}
+	}
+	if proxy := newProxyEnvVar(instance); proxy != nil {
+		p.Containers[0].Env = append(p.Containers[0].Env, *proxy)
 	}
-	p.Containers[0].Image = i
 
 	return nil
 }
 
+const (
+	// storageVolumeName is the volume for .spec.storageHostPath or .spec.useEmptyDirCache
+	storageVolumeName = "oneagent-storage"
+
+	// storageMountPath is where the OneAgent image looks for its storage if volume storage is enabled
+	storageMountPath = "/mnt/volume_storage_mount"
+)
+
+var hostPathDirectoryOrCreate = corev1.HostPathDirectoryOrCreate
+
 func prepareVolumes(instance dynatracev1alpha1.BaseOneAgentDaemonSet) []corev1.Volume {
 	volumes := []corev1.Volume{
 		{
@@ -592,6 +1061,27 @@ func prepareVolumes(instance dynatracev1alpha1.BaseOneAgentDaemonSet) []corev1.V
 		},
 	}
 
+	if p := instance.GetOneAgentSpec().StorageHostPath; p != "" {
+		volumes = append(volumes, corev1.Volume{
+			Name: storageVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: p,
+					Type: &hostPathDirectoryOrCreate,
+				},
+			},
+		})
+	} else if instance.GetOneAgentSpec().UseEmptyDirCache {
+		volumes = append(volumes, corev1.Volume{
+			Name: storageVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{
+					SizeLimit: cacheSizeLimit(instance.GetOneAgentSpec()),
+				},
+			},
+		})
+	}
+
 	if instance.GetOneAgentSpec().TrustedCAs != "" {
 		volumes = append(volumes, corev1.Volume{
 			Name: "certs",
@@ -622,6 +1112,13 @@ func prepareVolumeMounts(instance dynatracev1alpha1.BaseOneAgentDaemonSet) []cor
 		},
 	}
 
+	if usesVolumeStorage(instance.GetOneAgentSpec()) {
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      storageVolumeName,
+			MountPath: storageMountPath,
+		})
+	}
+
 	if instance.GetOneAgentSpec().TrustedCAs != "" {
 		volumeMounts = append(volumeMounts, corev1.VolumeMount{
 			Name:      "certs",
@@ -657,7 +1154,7 @@ func prepareEnvVars(instance dynatracev1alpha1.BaseOneAgentDaemonSet) []corev1.E
 			Name: "ONEAGENT_INSTALLER_TOKEN",
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{Name: utils.GetTokensName(instance)},
+					LocalObjectReference: corev1.LocalObjectReference{Name: utils.GetPaaSTokenSecretName(instance)},
 					Key:                  utils.DynatracePaasToken,
 				},
 			},
@@ -666,8 +1163,9 @@ func prepareEnvVars(instance dynatracev1alpha1.BaseOneAgentDaemonSet) []corev1.E
 
 	if installerURL == nil {
 		installerURL = &corev1.EnvVar{
-			Name:  "ONEAGENT_INSTALLER_SCRIPT_URL",
-			Value: fmt.Sprintf("%s/v1/deployment/installer/agent/unix/default/latest?Api-Token=$(ONEAGENT_INSTALLER_TOKEN)&arch=x86&flavor=default", instance.GetOneAgentSpec().APIURL),
+			Name: "ONEAGENT_INSTALLER_SCRIPT_URL",
+			Value: fmt.Sprintf("%s/v1/deployment/installer/agent/unix/default/latest?Api-Token=$(ONEAGENT_INSTALLER_TOKEN)&arch=%s&flavor=default",
+				instance.GetOneAgentSpec().APIURL, installerArch(instance.GetOneAgentSpec())),
 		}
 	}
 
@@ -680,38 +1178,150 @@ func prepareEnvVars(instance dynatracev1alpha1.BaseOneAgentDaemonSet) []corev1.E
 
 	env := []corev1.EnvVar{*token, *installerURL, *skipCert}
 
+	if usesVolumeStorage(instance.GetOneAgentSpec()) {
+		env = append(env, corev1.EnvVar{Name: "ONEAGENT_ENABLE_VOLUME_STORAGE", Value: "true"})
+	}
+
+	if paths := logMonitoringPaths(instance.GetOneAgentSpec()); len(paths) > 0 {
+		env = append(env, corev1.EnvVar{Name: logPathsEnvVar, Value: strings.Join(paths, ",")})
+	}
+
 	if proxy == nil {
-		if instance.GetOneAgentSpec().Proxy != nil {
-			if instance.GetOneAgentSpec().Proxy.ValueFrom != "" {
-				env = append(env, corev1.EnvVar{
-					Name: "https_proxy",
-					ValueFrom: &corev1.EnvVarSource{
-						SecretKeyRef: &corev1.SecretKeySelector{
-							LocalObjectReference: corev1.LocalObjectReference{Name: instance.GetOneAgentSpec().Proxy.ValueFrom},
-							Key:                  "proxy",
-						},
-					},
-				})
-			} else if instance.GetOneAgentSpec().Proxy.Value != "" {
-				env = append(env, corev1.EnvVar{
-					Name:  "https_proxy",
-					Value: instance.GetOneAgentSpec().Proxy.Value,
-				})
-			}
-		}
-	} else {
+		proxy = newProxyEnvVar(instance)
+	}
+	if proxy != nil {
 		env = append(env, *proxy)
 	}
 
 	return append(env, envVars...)
 }
 
+// newProxyEnvVar returns the https_proxy environment variable referenced by the --set-proxy argument of the OneAgent,
+// read from the secret in .spec.proxy.valueFrom or set to .spec.proxy.value. Returns nil if no proxy is configured.
+func newProxyEnvVar(instance dynatracev1alpha1.BaseOneAgentDaemonSet) *corev1.EnvVar {
+	p := instance.GetOneAgentSpec().Proxy
+	if p == nil {
+		return nil
+	}
+
+	if p.ValueFrom != "" {
+		return &corev1.EnvVar{
+			Name: "https_proxy",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: p.ValueFrom},
+					Key:                  "proxy",
+				},
+			},
+		}
+	} else if p.Value != "" {
+		return &corev1.EnvVar{
+			Name:  "https_proxy",
+			Value: p.Value,
+		}
+	}
+	return nil
+}
+
+// addProxySecretHash annotates the pod template of the DaemonSet with a hash of the proxy secret in
+// .spec.proxy.valueFrom, so that the OneAgent pods get rolled out whenever the proxy in the secret changes.
+func (r *ReconcileOneAgent) addProxySecretHash(instance dynatracev1alpha1.BaseOneAgentDaemonSet, ds *appsv1.DaemonSet) error {
+	p := instance.GetOneAgentSpec().Proxy
+	if p == nil || p.ValueFrom == "" {
+		return nil
+	}
+
+	var secret corev1.Secret
+	if err := r.client.Get(context.TODO(), client.ObjectKey{Name: p.ValueFrom, Namespace: instance.GetNamespace()}, &secret); err != nil {
+		return fmt.Errorf("failed to get proxy secret: %w", err)
+	}
+
+	hasher := fnv.New32()
+	if _, err := hasher.Write(secret.Data["proxy"]); err != nil {
+		return err
+	}
+
+	if ds.Spec.Template.Annotations == nil {
+		ds.Spec.Template.Annotations = map[string]string{}
+	}
+	// Replaces a value from .spec.podAnnotations on collision.
+	ds.Spec.Template.Annotations[annotationProxyHash] = strconv.FormatUint(uint64(hasher.Sum32()), 10)
+
+	// The template hash needs to be recomputed to cover the new annotation.
+	return updateTemplateHash(ds)
+}
+
+// resolveInstallerTokenKey points the installer token on the DaemonSet to the legacy key for the PaaS token if the
+// secret doesn't have the current key.
+func (r *ReconcileOneAgent) resolveInstallerTokenKey(instance dynatracev1alpha1.BaseOneAgentDaemonSet, ds *appsv1.DaemonSet) error {
+	for i := range ds.Spec.Template.Spec.Containers[0].Env {
+		env := &ds.Spec.Template.Spec.Containers[0].Env[i]
+		if env.Name != "ONEAGENT_INSTALLER_TOKEN" || env.ValueFrom == nil || env.ValueFrom.SecretKeyRef == nil {
+			continue
+		}
+
+		ref := env.ValueFrom.SecretKeyRef
+		if ref.Name != utils.GetPaaSTokenSecretName(instance) || ref.Key != utils.DynatracePaasToken {
+			return nil
+		}
+
+		var secret corev1.Secret
+		if err := r.client.Get(context.TODO(), client.ObjectKey{Name: ref.Name, Namespace: instance.GetNamespace()}, &secret); k8serrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to get token secret: %w", err)
+		}
+
+		if key := utils.ResolveTokenKey(&secret, ref.Key); key != ref.Key {
+			ref.Key = key
+			return updateTemplateHash(ds)
+		}
+		return nil
+	}
+	return nil
+}
+
+// updateTemplateHash recomputes the template hash annotation after the DaemonSet has been modified.
+func updateTemplateHash(ds *appsv1.DaemonSet) error {
+	delete(ds.Annotations, annotationTemplateHash)
+	dsHash, err := generateDaemonSetHash(ds)
+	if err != nil {
+		return err
+	}
+	ds.Annotations[annotationTemplateHash] = dsHash
+	return nil
+}
+
+// recreateDaemonSet replaces the existing DaemonSet with the desired one. The existing DaemonSet is deleted with orphan
+// cascade, so the OneAgent pods keep running until they get replaced by the new DaemonSet. Returns errDaemonSetDeleting
+// if the existing DaemonSet isn't gone yet, the new one is then created on a later reconciliation.
+func (r *ReconcileOneAgent) recreateDaemonSet(dsDesired, dsActual *appsv1.DaemonSet) error {
+	if err := r.client.Delete(context.TODO(), dsActual, client.PropagationPolicy(metav1.DeletePropagationOrphan)); err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	if err := r.client.Create(context.TODO(), dsDesired); k8serrors.IsAlreadyExists(err) {
+		return errDaemonSetDeleting
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// hasImmutableFieldChanged returns true if the DaemonSets differ in a field that cannot be updated
+func hasImmutableFieldChanged(a, b *appsv1.DaemonSet) bool {
+	return !reflect.DeepEqual(a.Spec.Selector, b.Spec.Selector)
+}
+
 func hasDaemonSetChanged(a, b *appsv1.DaemonSet) bool {
 	return getTemplateHash(a) != getTemplateHash(b)
 }
 
 func generateDaemonSetHash(ds *appsv1.DaemonSet) (string, error) {
-	data, err := json.Marshal(ds)
+	return generateHash(ds)
+}
+
+func generateHash(obj interface{}) (string, error) {
+	data, err := json.Marshal(obj)
 	if err != nil {
 		return "", err
 	}
@@ -734,42 +1344,376 @@ func getTemplateHash(a metav1.Object) string {
 
 func (r *ReconcileOneAgent) reconcileInstanceStatuses(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dtc dtclient.Client) (bool, error) {
 	pods, listOpts, err := r.getPods(&instance)
+	podsListed := err == nil
 	if err != nil {
 		handlePodListError(logger, err, listOpts)
 	}
 
-	instanceStatuses, err := getInstanceStatuses(pods, dtc, instance)
+	scaleDownNodes, err := r.findScaleDownNodes(instance)
+	if err != nil {
+		return false, err
+	}
+
+	// Pods on nodes being scaled down aren't queried, their last known status is kept until the node is gone.
+	queried := pods
+	if len(scaleDownNodes) > 0 {
+		queried = nil
+		for _, pod := range pods {
+			if !scaleDownNodes[pod.Spec.NodeName] {
+				queried = append(queried, pod)
+			}
+		}
+	}
+
+	instanceStatuses, err := getInstanceStatuses(queried, dtc, instance)
 	if err != nil {
 		if instanceStatuses == nil || len(instanceStatuses) <= 0 {
 			return false, err
 		}
 	}
 
-	if instance.GetOneAgentStatus().Instances == nil || !reflect.DeepEqual(instance.GetOneAgentStatus().Instances, instanceStatuses) {
-		instance.GetOneAgentStatus().Instances = instanceStatuses
-		return true, err
+	for _, pod := range pods {
+		if i, ok := instance.GetOneAgentStatus().Instances[pod.Spec.NodeName]; ok && scaleDownNodes[pod.Spec.NodeName] {
+			instanceStatuses[pod.Spec.NodeName] = i
+		}
 	}
 
-	return false, err
+	updateCR := false
+	if instance.GetOneAgentStatus().Instances == nil || haveInstanceStatusesChanged(instance.GetOneAgentStatus().Instances, instanceStatuses) {
+		updateCR = true
+	}
+	instance.GetOneAgentStatus().Instances = instanceStatuses
+
+	if upd, zonesErr := r.reconcileInstancesByZone(instance); zonesErr != nil {
+		return updateCR, zonesErr
+	} else if upd {
+		updateCR = true
+	}
+
+	if classesErr := r.checkNodeClasses(logger, instance); classesErr != nil {
+		return updateCR, classesErr
+	}
+
+	if reconcileMonitoringModeCondition(logger, instance) {
+		updateCR = true
+	}
+
+	if reconcileCommunicationCondition(logger, instance) {
+		updateCR = true
+	}
+
+	if reconcileVersionConsistency(logger, instance) {
+		updateCR = true
+	}
+
+	upd, dsErr := r.reconcileDeploymentStatus(instance)
+	if dsErr != nil {
+		return updateCR, dsErr
+	}
+	if upd {
+		updateCR = true
+	}
+
+	if podsListed {
+		if reconcileSchedulingBlocked(logger, instance, pods) {
+			updateCR = true
+		}
+
+		upd, nodesErr := r.reconcileUnmonitoredNodes(logger, instance, pods)
+		if nodesErr != nil {
+			return updateCR, nodesErr
+		}
+		if upd {
+			updateCR = true
+		}
+
+		if instance.GetOneAgentSpec().LabelMonitoredNodes {
+			if labelsErr := r.reconcileMonitoredNodeLabels(logger, instance, pods); labelsErr != nil {
+				return updateCR, labelsErr
+			}
+		}
+	}
+
+	return updateCR, err
 }
 
-func getInstanceStatuses(pods []corev1.Pod, dtc dtclient.Client, instance dynatracev1alpha1.BaseOneAgentDaemonSet) (map[string]dynatracev1alpha1.OneAgentInstance, error) {
-	instanceStatuses := make(map[string]dynatracev1alpha1.OneAgentInstance)
+// reconcileDeploymentStatus updates the DeploymentStatus and Deployed fields from the live status of the DaemonSet
+// together with the DaemonSets of node overrides, or the Deployment if the instance is deployed as such.
+// The instance counts as deployed once all desired pods are updated and ready, and all instances run the version
+// recorded on the status.
+//
+// Returns true if the status has changed.
+func (r *ReconcileOneAgent) reconcileDeploymentStatus(instance dynatracev1alpha1.BaseOneAgentDaemonSet) (bool, error) {
+	workload := newWorkload(instance)
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: workloadName(instance), Namespace: instance.GetNamespace()}, workload)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, err
+	}
 
-	for _, pod := range pods {
-		instanceStatus := dynatracev1alpha1.OneAgentInstance{
-			PodName:   pod.Name,
-			IPAddress: pod.Status.HostIP,
+	var deploymentStatus dynatracev1alpha1.OneAgentDeploymentStatus
+	var observed bool
+	switch w := workload.(type) {
+	case *appsv1.DaemonSet:
+		deploymentStatus = dynatracev1alpha1.OneAgentDeploymentStatus{
+			Desired: w.Status.DesiredNumberScheduled,
+			Ready:   w.Status.NumberReady,
+			Updated: w.Status.UpdatedNumberScheduled,
 		}
-		ver, err := dtc.GetAgentVersionForIP(pod.Status.HostIP)
+		observed = w.Status.ObservedGeneration >= w.Generation
+
+		overrides, err := r.listNodeOverrideDaemonSets(instance)
 		if err != nil {
-			if err = handleAgentVersionForIPError(err, instance, pod, &instanceStatus); err != nil {
-				return instanceStatuses, err
+			return false, err
+		}
+		for _, ds := range overrides {
+			deploymentStatus.Desired += ds.Status.DesiredNumberScheduled
+			deploymentStatus.Ready += ds.Status.NumberReady
+			deploymentStatus.Updated += ds.Status.UpdatedNumberScheduled
+			observed = observed && ds.Status.ObservedGeneration >= ds.Generation
+		}
+	case *appsv1.Deployment:
+		deploymentStatus = dynatracev1alpha1.OneAgentDeploymentStatus{
+			Ready:   w.Status.ReadyReplicas,
+			Updated: w.Status.UpdatedReplicas,
+		}
+		if w.Spec.Replicas != nil {
+			deploymentStatus.Desired = *w.Spec.Replicas
+		}
+		observed = w.Status.ObservedGeneration >= w.Generation
+	}
+
+	deployed := err == nil &&
+		observed &&
+		deploymentStatus.Updated == deploymentStatus.Desired &&
+		deploymentStatus.Ready == deploymentStatus.Desired &&
+		isVersionDeployed(instance)
+
+	oaStatus := instance.GetOneAgentStatus()
+	if oaStatus.DeploymentStatus == deploymentStatus && oaStatus.Deployed == deployed {
+		return false, nil
+	}
+
+	oaStatus.DeploymentStatus = deploymentStatus
+	oaStatus.Deployed = deployed
+	return true, nil
+}
+
+// isVersionDeployed returns true if all instances run the version recorded on the status
+func isVersionDeployed(instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	oaStatus := instance.GetOneAgentStatus()
+	if oaStatus.Version == "" {
+		return false
+	}
+
+	for _, i := range oaStatus.Instances {
+		if i.Version != oaStatus.Version {
+			return false
+		}
+	}
+	return true
+}
+
+// reconcileMonitoringModeCondition sets the MonitoringModeMismatch condition, listing the nodes where Dynatrace
+// reports a different monitoring mode than the one the instance is configured for.
+//
+// Returns true if the condition has changed.
+func reconcileMonitoringModeCondition(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	expected := expectedMonitoringMode(instance)
+
+	var mismatched []string
+	for node, i := range instance.GetOneAgentStatus().Instances {
+		if i.MonitoringMode != "" && i.MonitoringMode != expected {
+			mismatched = append(mismatched, fmt.Sprintf("%s (%s)", node, i.MonitoringMode))
+		}
+	}
+
+	if len(mismatched) == 0 {
+		return instance.GetOneAgentStatus().Conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.MonitoringModeMismatchConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonMonitoringModeMatching,
+			Message: fmt.Sprintf("All hosts are monitored in %s mode", expected),
+		})
+	}
+
+	sort.Strings(mismatched)
+	msg := fmt.Sprintf("Hosts not monitored in %s mode: %s", expected, strings.Join(mismatched, ", "))
+	if instance.GetOneAgentStatus().Conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.MonitoringModeMismatchConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonMonitoringModeMismatch,
+		Message: msg,
+	}) {
+		logger.Info("monitoring mode mismatch detected", "expected", expected, "hosts", mismatched)
+		return true
+	}
+	return false
+}
+
+// notCommunicatingThresholdPercent is the share of hosts that may not communicate with Dynatrace before the
+// HostsNotCommunicating condition is set
+const notCommunicatingThresholdPercent = 10
+
+// reconcileCommunicationCondition sets the HostsNotCommunicating condition, listing the nodes whose hosts haven't
+// recently been seen by Dynatrace if they exceed notCommunicatingThresholdPercent of the instances.
+//
+// Returns true if the condition has changed.
+func reconcileCommunicationCondition(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	instances := instance.GetOneAgentStatus().Instances
+
+	var notCommunicating []string
+	for node, i := range instances {
+		if !i.CommunicationOk {
+			notCommunicating = append(notCommunicating, node)
+		}
+	}
+
+	if len(notCommunicating)*100 <= len(instances)*notCommunicatingThresholdPercent {
+		return instance.GetOneAgentStatus().Conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.HostsNotCommunicatingConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonHostsCommunicating,
+			Message: fmt.Sprintf("%d of %d hosts are communicating with Dynatrace", len(instances)-len(notCommunicating), len(instances)),
+		})
+	}
+
+	sort.Strings(notCommunicating)
+	msg := fmt.Sprintf("Hosts not communicating with Dynatrace: %s", strings.Join(notCommunicating, ", "))
+	if instance.GetOneAgentStatus().Conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.HostsNotCommunicatingConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonHostsNotCommunicating,
+		Message: msg,
+	}) {
+		logger.Info("hosts not communicating with Dynatrace", "hosts", notCommunicating)
+		return true
+	}
+	return false
+}
+
+// expectedMonitoringMode returns the monitoring mode the OneAgent is configured to run in.
+func expectedMonitoringMode(instance dynatracev1alpha1.BaseOneAgentDaemonSet) string {
+	if _, ok := instance.(*dynatracev1alpha1.OneAgentIM); ok {
+		return dtclient.MonitoringModeInfrastructure
+	}
+
+	spec := instance.GetOneAgentSpec()
+	for _, arg := range buildInstallerArgs(spec, targetOS(spec)) {
+		if arg == "--set-infra-only=true" {
+			return dtclient.MonitoringModeInfrastructure
+		}
+	}
+
+	return dtclient.MonitoringModeFullStack
+}
+
+// getInstanceStatuses queries the status of the OneAgent running on each pod, with up to InstanceStatusConcurrency
+// queries in parallel. On failure, the statuses of the pods before the failed one are returned together with the error,
+// which matches querying the pods one after another.
+func getInstanceStatuses(pods []corev1.Pod, dtc dtclient.Client, instance dynatracev1alpha1.BaseOneAgentDaemonSet) (map[string]dynatracev1alpha1.OneAgentInstance, error) {
+	return getInstanceStatusesConcurrently(pods, dtc, instance, InstanceStatusConcurrency)
+}
+
+func getInstanceStatusesConcurrently(pods []corev1.Pod, dtc dtclient.Client, instance dynatracev1alpha1.BaseOneAgentDaemonSet, workers int) (map[string]dynatracev1alpha1.OneAgentInstance, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(pods) {
+		workers = len(pods)
+	}
+
+	results := make([]dynatracev1alpha1.OneAgentInstance, len(pods))
+	errs := make([]error, len(pods))
+
+	// firstFailed is the lowest index of a pod whose query failed. Later pods aren't queried anymore, as their status
+	// isn't returned.
+	firstFailed := int64(len(pods))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if int64(i) > atomic.LoadInt64(&firstFailed) {
+					continue
+				}
+				if results[i], errs[i] = getInstanceStatus(pods[i], dtc, instance); errs[i] != nil {
+					for {
+						f := atomic.LoadInt64(&firstFailed)
+						if int64(i) >= f || atomic.CompareAndSwapInt64(&firstFailed, f, int64(i)) {
+							break
+						}
+					}
+				}
 			}
-		} else {
-			instanceStatus.Version = ver
+		}()
+	}
+	for i := range pods {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	instanceStatuses := make(map[string]dynatracev1alpha1.OneAgentInstance)
+	for i, pod := range pods {
+		if errs[i] != nil {
+			return instanceStatuses, errs[i]
 		}
-		instanceStatuses[pod.Spec.NodeName] = instanceStatus
+		instanceStatuses[pod.Spec.NodeName] = results[i]
 	}
 	return instanceStatuses, nil
 }
+
+// haveInstanceStatusesChanged compares instance statuses apart from LastSeen, which changes with almost every query of
+// the hosts and so is only persisted along with other changes.
+func haveInstanceStatusesChanged(a, b map[string]dynatracev1alpha1.OneAgentInstance) bool {
+	if len(a) != len(b) {
+		return true
+	}
+	for node, i := range a {
+		j, ok := b[node]
+		if !ok {
+			return true
+		}
+		i.LastSeen, j.LastSeen = nil, nil
+		if !reflect.DeepEqual(i, j) {
+			return true
+		}
+	}
+	return false
+}
+
+func getInstanceStatus(pod corev1.Pod, dtc dtclient.Client, instance dynatracev1alpha1.BaseOneAgentDaemonSet) (dynatracev1alpha1.OneAgentInstance, error) {
+	instanceStatus := dynatracev1alpha1.OneAgentInstance{
+		PodName:   pod.Name,
+		IPAddress: pod.Status.HostIP,
+	}
+	ver, err := dtc.GetAgentVersionForIP(pod.Status.HostIP)
+	if err != nil {
+		if err = handleAgentVersionForIPError(err, instance, pod, &instanceStatus); err != nil {
+			return instanceStatus, err
+		}
+	} else {
+		instanceStatus.Version = ver
+	}
+
+	if mode, err := dtc.GetMonitoringModeForIP(pod.Status.HostIP); err == nil {
+		instanceStatus.MonitoringMode = mode
+	} else if i, ok := instance.GetOneAgentStatus().Instances[pod.Spec.NodeName]; ok {
+		// use last known monitoring mode if available
+		instanceStatus.MonitoringMode = i.MonitoringMode
+	}
+
+	if lastSeen, err := dtc.GetLastSeenForIP(pod.Status.HostIP); err == nil {
+		t := metav1.NewTime(lastSeen)
+		instanceStatus.LastSeen = &t
+		instanceStatus.CommunicationOk = true
+	} else if i, ok := instance.GetOneAgentStatus().Instances[pod.Spec.NodeName]; ok {
+		// use last known state if available, unless the host hasn't been seen recently
+		instanceStatus.LastSeen = i.LastSeen
+		instanceStatus.CommunicationOk = i.CommunicationOk && !errors.Is(err, dtclient.ErrHostNotFound)
+	}
+
+	return instanceStatus, nil
+}
@@ -3,7 +3,10 @@ package oneagent
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,11 +18,15 @@ import (
 	"github.com/operator-framework/operator-sdk/pkg/status"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -56,9 +63,10 @@ func TestReconcileOneAgent_ReconcileOnEmptyEnvironmentAndDNSPolicy(t *testing.T)
 	)
 
 	dtClient := &dtclient.MockDynatraceClient{}
+	dtClient.On("GetTenantStatus").Return(dtclient.TenantStatus{}, nil)
 	dtClient.On("GetLatestAgentVersion", "unix", "default").Return("42", nil)
-	dtClient.On("GetTokenScopes", "42").Return(dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}, nil)
-	dtClient.On("GetTokenScopes", "84").Return(dtclient.TokenScopes{dtclient.TokenScopeDataExport}, nil)
+	dtClient.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+	dtClient.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
 	dtClient.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
 
 	reconciler := &ReconcileOneAgent{
@@ -87,6 +95,72 @@ func TestReconcileOneAgent_ReconcileOnEmptyEnvironmentAndDNSPolicy(t *testing.T)
 	mock.AssertExpectationsForObjects(t, dtClient)
 }
 
+func TestReconcile_MultipleNamespaces(t *testing.T) {
+	oaName := "oneagent"
+	tokens := map[string][2]string{
+		"team-a": {"paas-a", "api-a"},
+		"team-b": {"paas-b", "api-b"},
+	}
+
+	var objs []runtime.Object
+	dtClient := &dtclient.MockDynatraceClient{}
+	dtClient.On("GetTenantStatus").Return(dtclient.TenantStatus{}, nil)
+	dtClient.On("GetLatestAgentVersion", "unix", "default").Return("42", nil)
+	dtClient.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+
+	for ns, tkns := range tokens {
+		objs = append(objs,
+			&dynatracev1alpha1.OneAgent{
+				ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: ns},
+				Spec: dynatracev1alpha1.OneAgentSpec{
+					BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+						APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+						Tokens: oaName,
+					},
+				},
+			},
+			NewSecret(oaName, ns, map[string]string{utils.DynatracePaasToken: tkns[0], utils.DynatraceApiToken: tkns[1]}))
+
+		dtClient.On("GetTokenMetadata", tkns[0]).Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+		dtClient.On("GetTokenMetadata", tkns[1]).Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+	reconciler := &ReconcileOneAgent{
+		client:    fakeClient,
+		apiReader: fakeClient,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+		dtcReconciler: &utils.DynatraceClientReconciler{
+			Client:              fakeClient,
+			DynatraceClientFunc: utils.StaticDynatraceClient(dtClient),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+		},
+		instance: &dynatracev1alpha1.OneAgent{},
+	}
+
+	for ns := range tokens {
+		key := types.NamespacedName{Name: oaName, Namespace: ns}
+
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		var ds appsv1.DaemonSet
+		require.NoError(t, fakeClient.Get(context.TODO(), key, &ds), "DaemonSet missing in namespace %s", ns)
+		if assert.Len(t, ds.OwnerReferences, 1) {
+			assert.Equal(t, oaName, ds.OwnerReferences[0].Name)
+		}
+
+		var oa dynatracev1alpha1.OneAgent
+		require.NoError(t, fakeClient.Get(context.TODO(), key, &oa))
+		assert.True(t, oa.Status.Conditions.IsTrueFor(dynatracev1alpha1.PaaSTokenConditionType), "PaaS token not ready in namespace %s", ns)
+		assert.True(t, oa.Status.Conditions.IsTrueFor(dynatracev1alpha1.APITokenConditionType), "API token not ready in namespace %s", ns)
+	}
+
+	mock.AssertExpectationsForObjects(t, dtClient)
+}
+
 func TestReconcile_PhaseSetCorrectly(t *testing.T) {
 	namespace := "dynatrace"
 	oaName := "oneagent"
@@ -166,6 +240,9 @@ func TestReconcile_PhaseSetCorrectly(t *testing.T) {
 		oa := base.DeepCopy()
 		oa.Status.Version = version
 		oa.Status.Tokens = utils.GetTokensName(oa)
+		_, err := reconciler.reconcileRollout(consoleLogger, oa, dtcMock)
+		require.NoError(t, err)
+		require.NotEmpty(t, oa.Status.ConfigHash)
 
 		// act
 		updateCR, err := reconciler.reconcileRollout(consoleLogger, oa, dtcMock)
@@ -283,13 +360,16 @@ func TestReconcile_InstancesSet(t *testing.T) {
 	// arrange
 	c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, map[string]string{utils.DynatracePaasToken: "42", utils.DynatraceApiToken: "84"}))
 	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetTenantStatus").Return(dtclient.TenantStatus{}, nil)
 	version := "1.187"
 	oldVersion := "1.186"
 	hostIP := "1.2.3.4"
 	dtcMock.On("GetLatestAgentVersion", dtclient.OsUnix, dtclient.InstallerTypeDefault).Return(version, nil)
 	dtcMock.On("GetAgentVersionForIP", hostIP).Return(version, nil)
-	dtcMock.On("GetTokenScopes", "42").Return(dtclient.TokenScopes{utils.DynatracePaasToken}, nil)
-	dtcMock.On("GetTokenScopes", "84").Return(dtclient.TokenScopes{utils.DynatraceApiToken}, nil)
+	dtcMock.On("GetMonitoringModeForIP", hostIP).Return(dtclient.MonitoringModeFullStack, nil)
+	dtcMock.On("GetLastSeenForIP", hostIP).Return(time.Now(), nil)
+	dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{utils.DynatracePaasToken}}, nil)
+	dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{utils.DynatraceApiToken}}, nil)
 
 	reconciler := &ReconcileOneAgent{
 		client:    c,
@@ -319,9 +399,11 @@ func TestReconcile_InstancesSet(t *testing.T) {
 		pod.Spec = newPodSpecForCR(oa, false, consoleLogger)
 		pod.Status.HostIP = hostIP
 		oa.Status.Tokens = utils.GetTokensName(oa)
+		_, err := reconciler.reconcileRollout(consoleLogger, oa, dtcMock)
+		require.NoError(t, err)
 
 		rec := reconciliation{log: consoleLogger, instance: oa, requeueAfter: 30 * time.Minute}
-		err := reconciler.client.Create(context.TODO(), pod)
+		err = reconciler.client.Create(context.TODO(), pod)
 
 		assert.NoError(t, err)
 
@@ -346,9 +428,11 @@ func TestReconcile_InstancesSet(t *testing.T) {
 		pod.Spec = newPodSpecForCR(oa, false, consoleLogger)
 		pod.Status.HostIP = hostIP
 		oa.Status.Tokens = utils.GetTokensName(oa)
+		_, err := reconciler.reconcileRollout(consoleLogger, oa, dtcMock)
+		require.NoError(t, err)
 
 		rec := reconciliation{log: consoleLogger, instance: oa, requeueAfter: 30 * time.Minute}
-		err := reconciler.client.Create(context.TODO(), pod)
+		err = reconciler.client.Create(context.TODO(), pod)
 
 		assert.NoError(t, err)
 
@@ -359,10 +443,1281 @@ func TestReconcile_InstancesSet(t *testing.T) {
 	})
 }
 
-func NewSecret(name, namespace string, kv map[string]string) *corev1.Secret {
-	data := make(map[string][]byte)
-	for k, v := range kv {
-		data[k] = []byte(v)
+func TestReconcile_MonitoringModeMismatch(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+		},
 	}
-	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}, Data: data}
+
+	newPod := func(name, node, hostIP string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: buildLabels(oaName)},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{HostIP: hostIP},
+		}
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme,
+		newPod("oneagent-1", "node-1", "1.2.3.4"),
+		newPod("oneagent-2", "node-2", "5.6.7.8"))
+
+	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetAgentVersionForIP", mock.Anything).Return("1.203.0.20200908-220956", nil)
+	dtcMock.On("GetMonitoringModeForIP", "1.2.3.4").Return(dtclient.MonitoringModeFullStack, nil)
+	dtcMock.On("GetMonitoringModeForIP", "5.6.7.8").Return(dtclient.MonitoringModeDiscovery, nil)
+	dtcMock.On("GetLastSeenForIP", mock.Anything).Return(time.Unix(1521540000, 0), nil)
+
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	updateCR, err := reconciler.reconcileInstanceStatuses(consoleLogger, oa, dtcMock)
+	assert.NoError(t, err)
+	assert.True(t, updateCR)
+
+	assert.Equal(t, dtclient.MonitoringModeFullStack, oa.Status.Instances["node-1"].MonitoringMode)
+	assert.Equal(t, dtclient.MonitoringModeDiscovery, oa.Status.Instances["node-2"].MonitoringMode)
+
+	cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.MonitoringModeMismatchConditionType)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonMonitoringModeMismatch, cond.Reason)
+		assert.Equal(t, "Hosts not monitored in FULL_STACK mode: node-2 (DISCOVERY)", cond.Message)
+	}
+
+	// No changes on subsequent reconciliations
+	updateCR, err = reconciler.reconcileInstanceStatuses(consoleLogger, oa, dtcMock)
+	assert.NoError(t, err)
+	assert.False(t, updateCR)
+}
+
+func TestReconcile_HostCommunication(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+		},
+	}
+
+	newPod := func(name, node, hostIP string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: buildLabels(oaName)},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{HostIP: hostIP},
+		}
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme,
+		newPod("oneagent-1", "node-1", "1.2.3.4"),
+		newPod("oneagent-2", "node-2", "5.6.7.8"))
+
+	lastSeen := time.Unix(1521540000, 0)
+
+	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetAgentVersionForIP", mock.Anything).Return("1.203.0.20200908-220956", nil)
+	dtcMock.On("GetMonitoringModeForIP", mock.Anything).Return(dtclient.MonitoringModeFullStack, nil)
+	dtcMock.On("GetLastSeenForIP", "1.2.3.4").Return(lastSeen, nil)
+	dtcMock.On("GetLastSeenForIP", "5.6.7.8").Return(time.Time{}, dtclient.ErrHostNotFound)
+
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	updateCR, err := reconciler.reconcileInstanceStatuses(consoleLogger, oa, dtcMock)
+	assert.NoError(t, err)
+	assert.True(t, updateCR)
+
+	if i := oa.Status.Instances["node-1"]; assert.NotNil(t, i.LastSeen) {
+		assert.True(t, i.CommunicationOk)
+		assert.True(t, lastSeen.Equal(i.LastSeen.Time))
+	}
+	assert.False(t, oa.Status.Instances["node-2"].CommunicationOk)
+	assert.Nil(t, oa.Status.Instances["node-2"].LastSeen)
+
+	cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.HostsNotCommunicatingConditionType)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonHostsNotCommunicating, cond.Reason)
+		assert.Equal(t, "Hosts not communicating with Dynatrace: node-2", cond.Message)
+	}
+
+	t.Run("newer last seen timestamp alone doesn't update the status", func(t *testing.T) {
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("GetAgentVersionForIP", mock.Anything).Return("1.203.0.20200908-220956", nil)
+		dtcMock.On("GetMonitoringModeForIP", mock.Anything).Return(dtclient.MonitoringModeFullStack, nil)
+		dtcMock.On("GetLastSeenForIP", "1.2.3.4").Return(lastSeen.Add(time.Minute), nil)
+		dtcMock.On("GetLastSeenForIP", "5.6.7.8").Return(time.Time{}, dtclient.ErrHostNotFound)
+
+		updateCR, err := reconciler.reconcileInstanceStatuses(consoleLogger, oa, dtcMock)
+		assert.NoError(t, err)
+		assert.False(t, updateCR)
+		assert.True(t, lastSeen.Add(time.Minute).Equal(oa.Status.Instances["node-1"].LastSeen.Time))
+	})
+}
+
+func TestGetInstanceStatuses_Concurrency(t *testing.T) {
+	oa := &dynatracev1alpha1.OneAgent{}
+
+	var pods []corev1.Pod
+	for i := 1; i <= 12; i++ {
+		pods = append(pods, corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("oneagent-%d", i)},
+			Spec:       corev1.PodSpec{NodeName: fmt.Sprintf("node-%d", i)},
+			Status:     corev1.PodStatus{HostIP: fmt.Sprintf("10.0.0.%d", i)},
+		})
+	}
+
+	var inFlight, maxInFlight int32
+	newMock := func(failingIP string) *dtclient.MockDynatraceClient {
+		dtcMock := &dtclient.MockDynatraceClient{}
+		if failingIP != "" {
+			dtcMock.On("GetAgentVersionForIP", failingIP).Return("", dtclient.ServerError{Code: http.StatusTooManyRequests})
+		}
+		dtcMock.On("GetAgentVersionForIP", mock.Anything).Run(func(mock.Arguments) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}).Return("1.203.0.20200908-220956", nil)
+		dtcMock.On("GetMonitoringModeForIP", mock.Anything).Return(dtclient.MonitoringModeFullStack, nil)
+		dtcMock.On("GetLastSeenForIP", mock.Anything).Return(time.Unix(1521540000, 0), nil)
+		return dtcMock
+	}
+
+	serial, err := getInstanceStatusesConcurrently(pods, newMock(""), oa, 1)
+	require.NoError(t, err)
+	require.Len(t, serial, len(pods))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxInFlight))
+
+	t.Run("same results as serial", func(t *testing.T) {
+		maxInFlight = 0
+		parallel, err := getInstanceStatusesConcurrently(pods, newMock(""), oa, 3)
+		require.NoError(t, err)
+		assert.Equal(t, serial, parallel)
+		assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(3))
+		assert.Greater(t, atomic.LoadInt32(&maxInFlight), int32(1))
+	})
+
+	t.Run("first error", func(t *testing.T) {
+		serialPartial, serialErr := getInstanceStatusesConcurrently(pods, newMock("10.0.0.5"), oa, 1)
+		require.Error(t, serialErr)
+		assert.Len(t, serialPartial, 4)
+
+		parallelPartial, parallelErr := getInstanceStatusesConcurrently(pods, newMock("10.0.0.5"), oa, 4)
+		assert.Equal(t, serialErr, parallelErr)
+		assert.Equal(t, serialPartial, parallelPartial)
+	})
+}
+
+func TestReconcileRollout_RecreateDaemonSetOnSelectorChange(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{Version: "1.203.0.20200908-220956"},
+	}
+	oa.Status.Tokens = utils.GetTokensName(oa)
+
+	outdatedLabels := map[string]string{"app": "oneagent-legacy"}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace, UID: "legacy-uid"},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: outdatedLabels},
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: outdatedLabels}},
+		},
+	})
+
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	_, err := reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+	assert.NoError(t, err)
+
+	var ds appsv1.DaemonSet
+	assert.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: oaName, Namespace: namespace}, &ds))
+	assert.NotEqual(t, types.UID("legacy-uid"), ds.UID)
+	assert.Equal(t, buildLabels(oaName), ds.Spec.Selector.MatchLabels)
+	assert.True(t, metav1.IsControlledBy(&ds, oa))
+}
+
+// pendingDeleteClient keeps deleted objects in place, like a DaemonSet whose orphan finalizer isn't processed yet.
+type pendingDeleteClient struct {
+	client.Client
+}
+
+func (c pendingDeleteClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	return nil
+}
+
+func TestReconcileRollout_RecreateDaemonSetPendingDeletion(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{Version: "1.203.0.20200908-220956"},
+	}
+	oa.Status.Tokens = utils.GetTokensName(oa)
+
+	outdatedLabels := map[string]string{"app": "oneagent-legacy"}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace, UID: "legacy-uid"},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: outdatedLabels},
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: outdatedLabels}},
+		},
+	})
+
+	reconciler := &ReconcileOneAgent{
+		client:    pendingDeleteClient{c},
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	t.Run("create postponed while previous daemonset exists", func(t *testing.T) {
+		_, err := reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+		assert.True(t, errors.Is(err, errDaemonSetDeleting), "unexpected error: %v", err)
+
+		var ds appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: oaName, Namespace: namespace}, &ds))
+		assert.Equal(t, types.UID("legacy-uid"), ds.UID)
+	})
+
+	t.Run("waits while previous daemonset is being deleted", func(t *testing.T) {
+		var ds appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: oaName, Namespace: namespace}, &ds))
+		now := metav1.Now()
+		ds.DeletionTimestamp = &now
+		require.NoError(t, c.Update(context.TODO(), &ds))
+
+		_, err := reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+		assert.True(t, errors.Is(err, errDaemonSetDeleting), "unexpected error: %v", err)
+	})
+
+	t.Run("created once previous daemonset is gone", func(t *testing.T) {
+		require.NoError(t, c.Delete(context.TODO(), &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace}}))
+
+		_, err := reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+		require.NoError(t, err)
+
+		var ds appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: oaName, Namespace: namespace}, &ds))
+		assert.Equal(t, buildLabels(oaName), ds.Spec.Selector.MatchLabels)
+	})
+}
+
+func TestReconcileRollout_CustomLabelsKeepSelector(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+			Labels: map[string]string{"team": "a", "oneagent": "overridden"},
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{Version: "1.203.0.20200908-220956"},
+	}
+	oa.Status.Tokens = utils.GetTokensName(oa)
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	_, err := reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+	require.NoError(t, err)
+
+	var ds appsv1.DaemonSet
+	require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: oaName, Namespace: namespace}, &ds))
+	assert.Equal(t, buildLabels(oaName), ds.Spec.Selector.MatchLabels)
+	assert.Equal(t, "a", ds.Spec.Template.Labels["team"])
+	assert.Equal(t, oaName, ds.Spec.Template.Labels["oneagent"], "selector labels not overridden")
+
+	oa.Spec.Labels = map[string]string{"team": "b"}
+
+	_, err = reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+	require.NoError(t, err)
+
+	var updated appsv1.DaemonSet
+	require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: oaName, Namespace: namespace}, &updated))
+	assert.Equal(t, ds.Spec.Selector, updated.Spec.Selector, "selector stays stable")
+	assert.Equal(t, "b", updated.Spec.Template.Labels["team"], "pod labels updated")
+	assert.Equal(t, oaName, updated.Spec.Template.Labels["oneagent"])
+}
+
+func TestReconcile_SkipRolloutOnObservedGeneration(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	key := types.NamespacedName{Name: oaName, Namespace: namespace}
+
+	newReconciler := func(observedGeneration int64, modify ...func(*dynatracev1alpha1.OneAgent)) (*ReconcileOneAgent, client.Client) {
+		oa := &dynatracev1alpha1.OneAgent{
+			ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace, Generation: 3},
+			Spec: dynatracev1alpha1.OneAgentSpec{
+				BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+					APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+					Tokens: oaName,
+				},
+			},
+			Status: dynatracev1alpha1.OneAgentStatus{Version: "42", ObservedGeneration: observedGeneration},
+		}
+		oa.Status.Tokens = utils.GetTokensName(oa)
+		for _, m := range modify {
+			m(oa)
+		}
+
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, oa,
+			NewSecret(oaName, namespace, map[string]string{utils.DynatracePaasToken: "42", utils.DynatraceApiToken: "84"}))
+
+		dtClient := &dtclient.MockDynatraceClient{}
+		dtClient.On("GetTenantStatus").Return(dtclient.TenantStatus{}, nil)
+		dtClient.On("GetLatestAgentVersion", "unix", "default").Return("42", nil)
+		dtClient.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+		dtClient.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+		dtClient.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+
+		reconciler := &ReconcileOneAgent{
+			client:    c,
+			apiReader: c,
+			scheme:    scheme.Scheme,
+			logger:    consoleLogger,
+			dtcReconciler: &utils.DynatraceClientReconciler{
+				Client:              c,
+				DynatraceClientFunc: utils.StaticDynatraceClient(dtClient),
+				UpdatePaaSToken:     true,
+				UpdateAPIToken:      true,
+			},
+			instance: &dynatracev1alpha1.OneAgent{},
+		}
+
+		// The live DaemonSet has the template hash of the desired one, but otherwise outdated content, which is only
+		// replaced if the rollout is done.
+		desired, err := reconciler.newDesiredDaemonSet(consoleLogger, oa)
+		require.NoError(t, err)
+		outdatedLabels := map[string]string{"app": "oneagent-legacy"}
+		require.NoError(t, c.Create(context.TODO(), &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: oaName, Namespace: namespace, UID: "legacy-uid",
+				Annotations: map[string]string{annotationTemplateHash: getTemplateHash(desired)},
+			},
+			Spec: appsv1.DaemonSetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: outdatedLabels},
+				Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: outdatedLabels}},
+			},
+		}))
+
+		return reconciler, c
+	}
+
+	t.Run("rollout skipped if generation was already observed", func(t *testing.T) {
+		reconciler, c := newReconciler(3)
+
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		var ds appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), key, &ds))
+		assert.Equal(t, types.UID("legacy-uid"), ds.UID)
+	})
+
+	t.Run("rollout done if an input from outside the spec changed", func(t *testing.T) {
+		reconciler, c := newReconciler(3)
+		require.NoError(t, c.Create(context.TODO(), &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "01234-5678-9012-3456"},
+		}))
+
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		var ds appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), key, &ds))
+		assert.NotEqual(t, types.UID("legacy-uid"), ds.UID, "outdated DaemonSet replaced")
+		assert.Contains(t, ds.Spec.Template.Spec.Containers[0].Args, "--set-host-property=ClusterName=01234-5678-9012-3456")
+	})
+
+	t.Run("rollout done if the DaemonSet drifted", func(t *testing.T) {
+		reconciler, c := newReconciler(3)
+
+		var ds appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), key, &ds))
+		ds.Annotations[annotationTemplateHash] = "edited"
+		require.NoError(t, c.Update(context.TODO(), &ds))
+
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		var recreated appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), key, &recreated))
+		assert.NotEqual(t, types.UID("legacy-uid"), recreated.UID)
+		assert.Equal(t, buildLabels(oaName), recreated.Spec.Selector.MatchLabels)
+	})
+
+	t.Run("rollout done for new generation", func(t *testing.T) {
+		reconciler, c := newReconciler(2)
+
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		var ds appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), key, &ds))
+		assert.NotEqual(t, types.UID("legacy-uid"), ds.UID)
+		assert.Equal(t, buildLabels(oaName), ds.Spec.Selector.MatchLabels)
+
+		var oa dynatracev1alpha1.OneAgent
+		require.NoError(t, c.Get(context.TODO(), key, &oa))
+		assert.Equal(t, int64(3), oa.Status.ObservedGeneration)
+	})
+
+	reconcileNow := func(value, observed string) func(*dynatracev1alpha1.OneAgent) {
+		return func(oa *dynatracev1alpha1.OneAgent) {
+			oa.Annotations = map[string]string{annotationReconcileNow: value}
+			oa.Status.ObservedReconcileNow = observed
+		}
+	}
+
+	t.Run("rollout done for changed reconcile-now annotation", func(t *testing.T) {
+		reconciler, c := newReconciler(3, reconcileNow("2020-10-14T12:00:00Z", "2020-10-13T08:00:00Z"))
+
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		var ds appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), key, &ds))
+		assert.NotEqual(t, types.UID("legacy-uid"), ds.UID)
+
+		var oa dynatracev1alpha1.OneAgent
+		require.NoError(t, c.Get(context.TODO(), key, &oa))
+		assert.Equal(t, "2020-10-14T12:00:00Z", oa.Status.ObservedReconcileNow, "processed value recorded")
+	})
+
+	t.Run("rollout skipped for processed reconcile-now annotation", func(t *testing.T) {
+		reconciler, c := newReconciler(3, reconcileNow("2020-10-14T12:00:00Z", "2020-10-14T12:00:00Z"))
+
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		var ds appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), key, &ds))
+		assert.Equal(t, types.UID("legacy-uid"), ds.UID)
+	})
+
+	t.Run("reconcile requeued while the DaemonSet to recreate is deleted", func(t *testing.T) {
+		reconciler, c := newReconciler(2)
+		reconciler.client = pendingDeleteClient{c}
+
+		result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Second, result.RequeueAfter)
+
+		var oa dynatracev1alpha1.OneAgent
+		require.NoError(t, c.Get(context.TODO(), key, &oa))
+		assert.Equal(t, int64(2), oa.Status.ObservedGeneration, "generation not observed before the rollout")
+	})
+}
+
+func TestReconcile_ObservedGeneration(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	key := types.NamespacedName{Name: oaName, Namespace: namespace}
+
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme,
+		&dynatracev1alpha1.OneAgent{
+			ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace, Generation: 1},
+			Spec: dynatracev1alpha1.OneAgentSpec{
+				BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+					APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+					Tokens: oaName,
+				},
+			},
+		},
+		NewSecret(oaName, namespace, map[string]string{utils.DynatracePaasToken: "42", utils.DynatraceApiToken: "84"}),
+	)
+
+	dtClient := &dtclient.MockDynatraceClient{}
+	dtClient.On("GetTenantStatus").Return(dtclient.TenantStatus{}, nil)
+	dtClient.On("GetLatestAgentVersion", "unix", "default").Return("42", nil)
+	dtClient.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+	dtClient.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+	dtClient.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+
+	reconciler := &ReconcileOneAgent{
+		client:    fakeClient,
+		apiReader: fakeClient,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+		dtcReconciler: &utils.DynatraceClientReconciler{
+			Client:              fakeClient,
+			DynatraceClientFunc: utils.StaticDynatraceClient(dtClient),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+		},
+		instance: &dynatracev1alpha1.OneAgent{},
+	}
+
+	reconcileAndGet := func(t *testing.T) (*dynatracev1alpha1.OneAgent, error) {
+		_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+
+		var oa dynatracev1alpha1.OneAgent
+		require.NoError(t, fakeClient.Get(context.TODO(), key, &oa))
+		return &oa, err
+	}
+
+	t.Run("observed generation set after successful reconcile", func(t *testing.T) {
+		oa, err := reconcileAndGet(t)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), oa.Status.ObservedGeneration)
+	})
+
+	t.Run("observed generation follows spec changes", func(t *testing.T) {
+		var oa dynatracev1alpha1.OneAgent
+		require.NoError(t, fakeClient.Get(context.TODO(), key, &oa))
+		oa.Spec.Args = []string{"--set-host-group=group"}
+		oa.Generation = 2
+		require.NoError(t, fakeClient.Update(context.TODO(), &oa))
+
+		updated, err := reconcileAndGet(t)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), updated.Status.ObservedGeneration)
+	})
+
+	t.Run("observed generation kept on failed reconcile", func(t *testing.T) {
+		var oa dynatracev1alpha1.OneAgent
+		require.NoError(t, fakeClient.Get(context.TODO(), key, &oa))
+		oa.Spec.Tokens = "missing-secret"
+		oa.Generation = 3
+		require.NoError(t, fakeClient.Update(context.TODO(), &oa))
+
+		updated, err := reconcileAndGet(t)
+		assert.Error(t, err)
+		assert.Equal(t, int64(2), updated.Status.ObservedGeneration)
+	})
+}
+
+// writeCountingClient counts the writes sent through it.
+type writeCountingClient struct {
+	client.Client
+	writes int
+}
+
+func (c *writeCountingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	c.writes++
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *writeCountingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	c.writes++
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *writeCountingClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	c.writes++
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *writeCountingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.writes++
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *writeCountingClient) Status() client.StatusWriter {
+	c.writes++ // Only used for writes
+	return c.Client.Status()
+}
+
+// conflictingStatusClient fails the first status updates sent through it with a conflict.
+type conflictingStatusClient struct {
+	client.Client
+	conflicts int
+	updates   int
+}
+
+func (c *conflictingStatusClient) Status() client.StatusWriter {
+	return &conflictingStatusWriter{StatusWriter: c.Client.Status(), c: c}
+}
+
+type conflictingStatusWriter struct {
+	client.StatusWriter
+	c *conflictingStatusClient
+}
+
+func (w *conflictingStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	w.c.updates++
+	if w.c.conflicts > 0 {
+		w.c.conflicts--
+		return k8serrors.NewConflict(dynatracev1alpha1.SchemeGroupVersion.WithResource("oneagents").GroupResource(), "oneagent", errors.New("object has been modified"))
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func TestUpdateCR_RetryOnConflict(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	key := types.NamespacedName{Name: oaName, Namespace: namespace}
+
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+		},
+	})
+	c := &conflictingStatusClient{Client: fakeClient, conflicts: 1}
+	reconciler := &ReconcileOneAgent{client: c, apiReader: fakeClient, scheme: scheme.Scheme, logger: consoleLogger}
+
+	var oa dynatracev1alpha1.OneAgent
+	require.NoError(t, fakeClient.Get(context.TODO(), key, &oa))
+
+	// Modified in the meantime, e.g., by a user editing the spec.
+	var latest dynatracev1alpha1.OneAgent
+	require.NoError(t, fakeClient.Get(context.TODO(), key, &latest))
+	latest.Spec.AgentVersion = "1.203.0"
+	require.NoError(t, fakeClient.Update(context.TODO(), &latest))
+
+	oa.Status.Version = "1.202.0.20200808-120956"
+	require.NoError(t, reconciler.updateCR(&oa))
+	assert.Equal(t, 2, c.updates, "retried after conflict")
+
+	var result dynatracev1alpha1.OneAgent
+	require.NoError(t, fakeClient.Get(context.TODO(), key, &result))
+	assert.Equal(t, "1.202.0.20200808-120956", result.Status.Version, "status written")
+	assert.Equal(t, "1.203.0", result.Spec.AgentVersion, "spec changes kept")
+}
+
+func TestReconcile_DryRun(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	key := types.NamespacedName{Name: oaName, Namespace: namespace}
+
+	fakeClient := &writeCountingClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+		&dynatracev1alpha1.OneAgent{
+			ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace, Generation: 1},
+			Spec: dynatracev1alpha1.OneAgentSpec{
+				BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+					APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+					Tokens: oaName,
+				},
+			},
+		},
+		NewSecret(oaName, namespace, map[string]string{utils.DynatracePaasToken: "42", utils.DynatraceApiToken: "84"}),
+	)}
+
+	dtClient := &dtclient.MockDynatraceClient{}
+	dtClient.On("GetTenantStatus").Return(dtclient.TenantStatus{}, nil)
+	dtClient.On("GetLatestAgentVersion", "unix", "default").Return("42", nil)
+	dtClient.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+	dtClient.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+	dtClient.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+
+	dryRunClient := utils.NewDryRunClient(fakeClient, consoleLogger)
+	reconciler := &ReconcileOneAgent{
+		client:    dryRunClient,
+		apiReader: fakeClient,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+		dtcReconciler: &utils.DynatraceClientReconciler{
+			Client:              dryRunClient,
+			DynatraceClientFunc: utils.StaticDynatraceClient(dtClient),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+		},
+		instance: &dynatracev1alpha1.OneAgent{},
+		dryRun:   true,
+	}
+
+	_, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Zero(t, fakeClient.writes, "no writes sent to the cluster")
+
+	var ds appsv1.DaemonSet
+	assert.True(t, k8serrors.IsNotFound(fakeClient.Get(context.TODO(), key, &ds)), "DaemonSet not created")
+
+	var oa dynatracev1alpha1.OneAgent
+	require.NoError(t, fakeClient.Get(context.TODO(), key, &oa))
+	assert.Empty(t, oa.Status.Tokens, "status not updated")
+	assert.Zero(t, oa.Status.ObservedGeneration, "status not updated")
+}
+
+func TestReconcileDeploymentStatus(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	version := "1.203.0.20200908-220956"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Status: dynatracev1alpha1.OneAgentStatus{
+			Version: version,
+			Instances: map[string]dynatracev1alpha1.OneAgentInstance{
+				"node-1": {Version: version},
+				"node-2": {Version: version},
+			},
+		},
+	}
+
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Status: appsv1.DaemonSetStatus{
+			DesiredNumberScheduled: 2,
+			NumberReady:            2,
+			UpdatedNumberScheduled: 1,
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, ds)
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	// Rollout in progress
+	updateCR, err := reconciler.reconcileDeploymentStatus(oa)
+	assert.NoError(t, err)
+	assert.True(t, updateCR)
+	assert.Equal(t, dynatracev1alpha1.OneAgentDeploymentStatus{Desired: 2, Ready: 2, Updated: 1}, oa.Status.DeploymentStatus)
+	assert.False(t, oa.Status.Deployed)
+
+	// All pods updated, but one instance still reports an older version
+	ds.Status.UpdatedNumberScheduled = 2
+	assert.NoError(t, c.Update(context.TODO(), ds))
+	oa.Status.Instances["node-2"] = dynatracev1alpha1.OneAgentInstance{Version: "1.202.0.20200801-120000"}
+
+	updateCR, err = reconciler.reconcileDeploymentStatus(oa)
+	assert.NoError(t, err)
+	assert.True(t, updateCR)
+	assert.False(t, oa.Status.Deployed)
+
+	// All counts and versions aligned
+	oa.Status.Instances["node-2"] = dynatracev1alpha1.OneAgentInstance{Version: version}
+
+	updateCR, err = reconciler.reconcileDeploymentStatus(oa)
+	assert.NoError(t, err)
+	assert.True(t, updateCR)
+	assert.True(t, oa.Status.Deployed)
+
+	// No changes on subsequent reconciliations
+	updateCR, err = reconciler.reconcileDeploymentStatus(oa)
+	assert.NoError(t, err)
+	assert.False(t, updateCR)
+
+	// Pod becomes unready
+	ds.Status.NumberReady = 1
+	assert.NoError(t, c.Update(context.TODO(), ds))
+
+	updateCR, err = reconciler.reconcileDeploymentStatus(oa)
+	assert.NoError(t, err)
+	assert.True(t, updateCR)
+	assert.False(t, oa.Status.Deployed)
+}
+
+func TestReconcile_Paused(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        oaName,
+			Namespace:   namespace,
+			Annotations: map[string]string{annotationReconcilePaused: "true"},
+		},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+		},
+	}
+
+	outdatedDS := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: buildLabels(oaName)},
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: buildLabels(oaName)}},
+		},
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, oa, outdatedDS,
+		NewSecret(oaName, namespace, map[string]string{utils.DynatracePaasToken: "42", utils.DynatraceApiToken: "84"}))
+
+	dtClient := &dtclient.MockDynatraceClient{}
+	dtClient.On("GetLatestAgentVersion", "unix", "default").Return("42", nil)
+	dtClient.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+	dtClient.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+	dtClient.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+	dtClient.On("GetTenantStatus").Return(dtclient.TenantStatus{}, nil)
+
+	reconciler := &ReconcileOneAgent{
+		client:    fakeClient,
+		apiReader: fakeClient,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+		dtcReconciler: &utils.DynatraceClientReconciler{
+			Client:              fakeClient,
+			DynatraceClientFunc: utils.StaticDynatraceClient(dtClient),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+		},
+		instance: &dynatracev1alpha1.OneAgent{},
+	}
+
+	key := types.NamespacedName{Name: oaName, Namespace: namespace}
+	daemonSetVersion := func() string {
+		var ds appsv1.DaemonSet
+		assert.NoError(t, fakeClient.Get(context.TODO(), key, &ds))
+		return ds.ResourceVersion
+	}
+	initialVersion := daemonSetVersion()
+
+	result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+	assert.NoError(t, err)
+	assert.Equal(t, 1*time.Hour, result.RequeueAfter)
+	assert.Equal(t, initialVersion, daemonSetVersion(), "DaemonSet has been modified while paused")
+	dtClient.AssertNotCalled(t, "GetTokenMetadata", mock.Anything)
+
+	var actual dynatracev1alpha1.OneAgent
+	assert.NoError(t, fakeClient.Get(context.TODO(), key, &actual))
+	cond := actual.Status.Conditions.GetCondition(dynatracev1alpha1.PausedConditionType)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonReconcilePaused, cond.Reason)
+	}
+
+	// Removing the annotation resumes the reconciliation
+	actual.Annotations = nil
+	assert.NoError(t, fakeClient.Update(context.TODO(), &actual))
+
+	_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+	assert.NoError(t, err)
+	assert.NotEqual(t, initialVersion, daemonSetVersion())
+
+	assert.NoError(t, fakeClient.Get(context.TODO(), key, &actual))
+	assert.Nil(t, actual.Status.Conditions.GetCondition(dynatracev1alpha1.PausedConditionType))
+}
+
+func TestReconcile_TenantMaintenance(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+		},
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, oa,
+		NewSecret(oaName, namespace, map[string]string{utils.DynatracePaasToken: "42", utils.DynatraceApiToken: "84"}))
+
+	dtClient := &dtclient.MockDynatraceClient{}
+	dtClient.On("GetLatestAgentVersion", "unix", "default").Return("42", nil)
+	dtClient.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+	dtClient.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+	dtClient.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+	dtClient.On("GetTenantStatus").Return(dtclient.TenantStatus{Maintenance: true, Message: "Cluster upgrade in progress"}, nil).Once()
+	dtClient.On("GetTenantStatus").Return(dtclient.TenantStatus{}, nil)
+
+	reconciler := &ReconcileOneAgent{
+		client:    fakeClient,
+		apiReader: fakeClient,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+		dtcReconciler: &utils.DynatraceClientReconciler{
+			Client:              fakeClient,
+			DynatraceClientFunc: utils.StaticDynatraceClient(dtClient),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+		},
+		instance: &dynatracev1alpha1.OneAgent{},
+	}
+
+	key := types.NamespacedName{Name: oaName, Namespace: namespace}
+
+	result, err := reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+	assert.NoError(t, err)
+	assert.Equal(t, maintenanceRequeueInterval, result.RequeueAfter)
+	dtClient.AssertNotCalled(t, "GetLatestAgentVersion", mock.Anything, mock.Anything)
+	assert.True(t, k8serrors.IsNotFound(fakeClient.Get(context.TODO(), key, &appsv1.DaemonSet{})), "DaemonSet created during maintenance")
+
+	var actual dynatracev1alpha1.OneAgent
+	assert.NoError(t, fakeClient.Get(context.TODO(), key, &actual))
+	cond := actual.Status.Conditions.GetCondition(dynatracev1alpha1.TenantMaintenanceConditionType)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonTenantInMaintenance, cond.Reason)
+	}
+
+	// Reconciliation resumes once the maintenance has ended
+	_, err = reconciler.Reconcile(reconcile.Request{NamespacedName: key})
+	assert.NoError(t, err)
+	assert.NoError(t, fakeClient.Get(context.TODO(), key, &appsv1.DaemonSet{}))
+
+	assert.NoError(t, fakeClient.Get(context.TODO(), key, &actual))
+	assert.Nil(t, actual.Status.Conditions.GetCondition(dynatracev1alpha1.TenantMaintenanceConditionType))
+}
+
+func TestProxyPassthrough(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+
+	newInstance := func(proxy *dynatracev1alpha1.OneAgentProxy) *dynatracev1alpha1.OneAgent {
+		return &dynatracev1alpha1.OneAgent{
+			ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+			Spec: dynatracev1alpha1.OneAgentSpec{
+				BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+					APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+					Tokens: oaName,
+					Proxy:  proxy,
+				},
+			},
+		}
+	}
+
+	findEnv := func(envVars []corev1.EnvVar, name string) *corev1.EnvVar {
+		for i := range envVars {
+			if envVars[i].Name == name {
+				return &envVars[i]
+			}
+		}
+		return nil
+	}
+
+	t.Run("inline proxy", func(t *testing.T) {
+		for _, immutable := range []bool{false, true} {
+			oa := newInstance(&dynatracev1alpha1.OneAgentProxy{Value: "http://proxy:3128"})
+			oa.Status.UseImmutableImage = immutable
+
+			podSpec := newPodSpecForCR(oa, false, consoleLogger)
+			assert.Contains(t, podSpec.Containers[0].Args, "--set-proxy=$(https_proxy)")
+			env := findEnv(podSpec.Containers[0].Env, "https_proxy")
+			if assert.NotNil(t, env, "immutable image: %v", immutable) {
+				assert.Equal(t, "http://proxy:3128", env.Value)
+			}
+		}
+	})
+
+	t.Run("secret-sourced proxy", func(t *testing.T) {
+		for _, immutable := range []bool{false, true} {
+			oa := newInstance(&dynatracev1alpha1.OneAgentProxy{ValueFrom: "proxy-secret"})
+			oa.Status.UseImmutableImage = immutable
+
+			podSpec := newPodSpecForCR(oa, false, consoleLogger)
+			assert.Contains(t, podSpec.Containers[0].Args, "--set-proxy=$(https_proxy)")
+			env := findEnv(podSpec.Containers[0].Env, "https_proxy")
+			if assert.NotNil(t, env, "immutable image: %v", immutable) {
+				assert.Empty(t, env.Value)
+				assert.Equal(t, &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "proxy-secret"},
+					Key:                  "proxy",
+				}, env.ValueFrom.SecretKeyRef)
+			}
+		}
+	})
+
+	t.Run("inline proxy change rolls pods", func(t *testing.T) {
+		ds1, err := newDaemonSetForCR(consoleLogger, newInstance(&dynatracev1alpha1.OneAgentProxy{Value: "http://proxy:3128"}))
+		assert.NoError(t, err)
+		ds2, err := newDaemonSetForCR(consoleLogger, newInstance(&dynatracev1alpha1.OneAgentProxy{Value: "http://other-proxy:3128"}))
+		assert.NoError(t, err)
+		assert.True(t, hasDaemonSetChanged(ds1, ds2))
+	})
+
+	t.Run("secret-sourced proxy change rolls pods", func(t *testing.T) {
+		oa := newInstance(&dynatracev1alpha1.OneAgentProxy{ValueFrom: "proxy-secret"})
+		secret := NewSecret("proxy-secret", namespace, map[string]string{"proxy": "http://proxy:3128"})
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, secret)
+		reconciler := &ReconcileOneAgent{
+			client:    c,
+			apiReader: c,
+			scheme:    scheme.Scheme,
+			logger:    consoleLogger,
+		}
+
+		desired := func() *appsv1.DaemonSet {
+			ds, err := newDaemonSetForCR(consoleLogger, oa)
+			assert.NoError(t, err)
+			assert.NoError(t, reconciler.addProxySecretHash(oa, ds))
+			return ds
+		}
+
+		ds1 := desired()
+		assert.NotEmpty(t, ds1.Spec.Template.Annotations[annotationProxyHash])
+		assert.False(t, hasDaemonSetChanged(ds1, desired()))
+
+		secret.Data["proxy"] = []byte("http://other-proxy:3128")
+		assert.NoError(t, c.Update(context.TODO(), secret))
+		assert.True(t, hasDaemonSetChanged(ds1, desired()))
+	})
+}
+
+func TestNewDaemonSetForCR_PodAnnotations(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.Proxy = &dynatracev1alpha1.OneAgentProxy{ValueFrom: "proxy-secret"}
+	oa.Spec.PodAnnotations = map[string]string{
+		"prometheus.io/scrape": "true",
+		"prometheus.io/port":   "{{ .port }}",
+		annotationProxyHash:    "user-value",
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret("proxy-secret", oa.Namespace, map[string]string{"proxy": "http://proxy:3128"}))
+	reconciler := &ReconcileOneAgent{client: c, apiReader: c, scheme: scheme.Scheme, logger: consoleLogger}
+
+	ds, err := newDaemonSetForCR(consoleLogger, oa)
+	require.NoError(t, err)
+	require.NoError(t, reconciler.addProxySecretHash(oa, ds))
+
+	annotations := ds.Spec.Template.Annotations
+	assert.Equal(t, "true", annotations["prometheus.io/scrape"])
+	assert.Equal(t, "{{ .port }}", annotations["prometheus.io/port"], "kept literally")
+	assert.NotEmpty(t, annotations[annotationProxyHash])
+	assert.NotEqual(t, "user-value", annotations[annotationProxyHash], "managed annotation preserved")
+	assert.Equal(t, "user-value", oa.Spec.PodAnnotations[annotationProxyHash], "spec not modified")
+
+	ds2, err := newDaemonSetForCR(consoleLogger, newOneAgent())
+	require.NoError(t, err)
+	assert.Nil(t, ds2.Spec.Template.Annotations)
+}
+
+func TestNewPodSpecForCR_Probes(t *testing.T) {
+	t.Run("default startup probe", func(t *testing.T) {
+		oa := newOneAgent()
+
+		container := newPodSpecForCR(oa, false, consoleLogger).Containers[0]
+		if assert.NotNil(t, container.StartupProbe) {
+			assert.Equal(t, watchdogProbeHandler(), container.StartupProbe.Handler)
+			assert.Equal(t, int32(60), container.StartupProbe.FailureThreshold)
+			// Allows at least 10 minutes for the installation before other probes kick in
+			assert.GreaterOrEqual(t, container.StartupProbe.PeriodSeconds*container.StartupProbe.FailureThreshold, int32(600))
+		}
+		assert.NotNil(t, container.ReadinessProbe)
+		assert.Nil(t, container.LivenessProbe)
+	})
+
+	t.Run("custom startup and liveness probes", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.StartupProbe = &corev1.Probe{Handler: watchdogProbeHandler(), PeriodSeconds: 30, FailureThreshold: 40}
+		oa.Spec.LivenessProbe = &corev1.Probe{Handler: watchdogProbeHandler(), PeriodSeconds: 60, FailureThreshold: 3}
+
+		container := newPodSpecForCR(oa, false, consoleLogger).Containers[0]
+		assert.Equal(t, oa.Spec.StartupProbe, container.StartupProbe)
+		assert.Equal(t, oa.Spec.LivenessProbe, container.LivenessProbe)
+
+		// The liveness probe only gets activated by the kubelet once the startup probe succeeded, so it needs no
+		// initial delay to cover the installation.
+		assert.Zero(t, container.LivenessProbe.InitialDelaySeconds)
+		assert.Greater(t, container.StartupProbe.PeriodSeconds*container.StartupProbe.FailureThreshold,
+			container.LivenessProbe.PeriodSeconds*container.LivenessProbe.FailureThreshold)
+	})
+}
+
+func TestNewPodSpecForCR_PreStop(t *testing.T) {
+	t.Run("default hook", func(t *testing.T) {
+		oa := newOneAgent()
+
+		container := newPodSpecForCR(oa, false, consoleLogger).Containers[0]
+		require.NotNil(t, container.Lifecycle)
+		require.NotNil(t, container.Lifecycle.PreStop)
+		assert.Equal(t, newPreStopHandler(oa), container.Lifecycle.PreStop)
+		assert.Contains(t, container.Lifecycle.PreStop.Exec.Command[2], "kill -TERM")
+	})
+
+	t.Run("custom hook", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.PreStop = &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"/opt/dynatrace/stop.sh"}}}
+
+		container := newPodSpecForCR(oa, false, consoleLogger).Containers[0]
+		assert.Equal(t, oa.Spec.PreStop, container.Lifecycle.PreStop)
+	})
+
+	t.Run("malformed hook", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.APIURL = "https://f.q.d.n/api"
+		oa.Spec.PreStop = &corev1.Handler{Exec: &corev1.ExecAction{}, HTTPGet: &corev1.HTTPGetAction{Path: "/stop"}}
+		assert.EqualError(t, validate(oa), ".spec.preStop.exec.command must not be empty, .spec.preStop.httpGet.port must be set, "+
+			".spec.preStop must set exactly one of exec, httpGet or tcpSocket")
+
+		oa.Spec.PreStop = &corev1.Handler{}
+		assert.EqualError(t, validate(oa), ".spec.preStop must set exactly one of exec, httpGet or tcpSocket")
+	})
+}
+
+func NewSecret(name, namespace string, kv map[string]string) *corev1.Secret {
+	data := make(map[string][]byte)
+	for k, v := range kv {
+		data[k] = []byte(v)
+	}
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}, Data: data}
+}
+
+func TestNewPodSpecForCR_Sidecars(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.Volumes = []corev1.Volume{{
+		Name:         "logs",
+		VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/log/oneagent"}},
+	}}
+	oa.Spec.Sidecars = []corev1.Container{{
+		Name:         "log-shipper",
+		Image:        "fluent/fluent-bit",
+		VolumeMounts: []corev1.VolumeMount{{Name: "logs", MountPath: "/logs", ReadOnly: true}},
+	}}
+	require.NoError(t, validate(oa))
+
+	ps := newPodSpecForCR(oa, false, consoleLogger)
+	if assert.Len(t, ps.Containers, 2) {
+		assert.Equal(t, oneAgentContainerName, ps.Containers[0].Name)
+		assert.Equal(t, oa.Spec.Sidecars[0], ps.Containers[1])
+	}
+	assert.Contains(t, ps.Volumes, oa.Spec.Volumes[0])
+
+	oa.Spec.Sidecars = append(oa.Spec.Sidecars, corev1.Container{
+		Name:         oneAgentContainerName,
+		VolumeMounts: []corev1.VolumeMount{{Name: "unknown", MountPath: "/data"}},
+	})
+	assert.EqualError(t, validate(oa),
+		`.spec.sidecars contains duplicate container name "dynatrace-oneagent", .spec.sidecars[1] mounts unknown volume "unknown"`)
+}
+
+func TestNewPodSpecForCR_InitContainers(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.Volumes = []corev1.Volume{{
+		Name:         "shared",
+		VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/lib/oneagent-data"}},
+	}}
+	oa.Spec.VolumeMounts = []corev1.VolumeMount{{Name: "shared", MountPath: "/mnt/shared"}}
+	oa.Spec.InitContainers = []corev1.Container{
+		{Name: "fetch", Image: "busybox", VolumeMounts: []corev1.VolumeMount{{Name: "shared", MountPath: "/data"}}},
+		{Name: "prepare", Image: "busybox", VolumeMounts: []corev1.VolumeMount{{Name: "shared", MountPath: "/data"}}},
+	}
+	require.NoError(t, validate(oa))
+
+	ps := newPodSpecForCR(oa, false, consoleLogger)
+	if assert.Len(t, ps.InitContainers, 2) {
+		assert.Equal(t, "fetch", ps.InitContainers[0].Name)
+		assert.Equal(t, "prepare", ps.InitContainers[1].Name)
+	}
+	assert.Equal(t, oneAgentContainerName, ps.Containers[0].Name)
+	assert.Contains(t, ps.Volumes, oa.Spec.Volumes[0])
+	assert.Contains(t, ps.Containers[0].VolumeMounts, oa.Spec.VolumeMounts[0], "volume shared with the OneAgent container")
+
+	oa.Spec.Sidecars = []corev1.Container{{Name: "fetch"}}
+	oa.Spec.InitContainers = append(oa.Spec.InitContainers, corev1.Container{
+		Name:         oneAgentContainerName,
+		VolumeMounts: []corev1.VolumeMount{{Name: "unknown", MountPath: "/data"}},
+	})
+	assert.EqualError(t, validate(oa),
+		`.spec.initContainers contains duplicate container name "fetch", .spec.initContainers contains duplicate container name "dynatrace-oneagent", .spec.initContainers[2] mounts unknown volume "unknown"`)
+}
+
+func TestNewPodSpecForCR_ReadinessGates(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.ReadinessGates = []corev1.PodReadinessGate{
+		{ConditionType: "www.example.com/mesh-ready"},
+		{ConditionType: "rollouts.example.com/approved"},
+	}
+	require.NoError(t, validate(oa))
+
+	ps := newPodSpecForCR(oa, false, consoleLogger)
+	assert.Equal(t, oa.Spec.ReadinessGates, ps.ReadinessGates)
+
+	assert.Empty(t, newPodSpecForCR(newOneAgent(), false, consoleLogger).ReadinessGates)
+
+	oa.Spec.ReadinessGates = append(oa.Spec.ReadinessGates, corev1.PodReadinessGate{ConditionType: " "})
+	assert.EqualError(t, validate(oa), ".spec.readinessGates[2] has no condition type")
+}
+
+func TestNewPodSpecForCR_TerminationGracePeriod(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+
+	ps := newPodSpecForCR(oa, false, consoleLogger)
+	if assert.NotNil(t, ps.TerminationGracePeriodSeconds) {
+		assert.Equal(t, defaultTerminationGracePeriodSeconds, *ps.TerminationGracePeriodSeconds)
+	}
+	assert.False(t, reconcileTerminationGracePeriod(consoleLogger, oa))
+
+	seconds := int64(120)
+	oa.Spec.TerminationGracePeriodSeconds = &seconds
+	ps = newPodSpecForCR(oa, false, consoleLogger)
+	assert.Equal(t, &seconds, ps.TerminationGracePeriodSeconds)
+
+	seconds = 0
+	assert.True(t, reconcileTerminationGracePeriod(consoleLogger, oa))
+	cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.ImmediateTerminationConditionType)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonNoGracePeriod, cond.Reason)
+	}
+
+	seconds = -1
+	assert.EqualError(t, validate(oa), ".spec.terminationGracePeriodSeconds must not be negative")
+}
+
+func TestNewPodSpecForCR_PreemptionPolicy(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+
+	ps := newPodSpecForCR(oa, false, consoleLogger)
+	assert.Nil(t, ps.PreemptionPolicy)
+
+	policy := corev1.PreemptNever
+	oa.Spec.PriorityClassName = "monitoring"
+	oa.Spec.PreemptionPolicy = &policy
+	ps = newPodSpecForCR(oa, false, consoleLogger)
+	assert.Equal(t, "monitoring", ps.PriorityClassName)
+	if assert.NotNil(t, ps.PreemptionPolicy) {
+		assert.Equal(t, corev1.PreemptNever, *ps.PreemptionPolicy)
+	}
+	assert.NoError(t, validate(oa))
+
+	policy = "Always"
+	assert.EqualError(t, validate(oa), `.spec.preemptionPolicy must be Never or PreemptLowerPriority, got "Always"`)
 }
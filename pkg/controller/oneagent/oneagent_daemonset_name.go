@@ -0,0 +1,74 @@
+package oneagent
+
+import (
+	"context"
+	"fmt"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// validateDaemonSetName returns the issues found on .spec.daemonSetName
+func validateDaemonSetName(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	if spec.DaemonSetName == "" {
+		return nil
+	}
+
+	var msg []string
+	if errs := validation.IsDNS1123Subdomain(spec.DaemonSetName); len(errs) > 0 {
+		msg = append(msg, fmt.Sprintf(".spec.daemonSetName must be a valid object name, got %q", spec.DaemonSetName))
+	}
+	if spec.DeploymentType == dynatracev1alpha1.DeploymentTypeDeployment {
+		msg = append(msg, ".spec.daemonSetName is not supported with deployment type Deployment")
+	}
+	return msg
+}
+
+// daemonSetName returns the name of the DaemonSet running the OneAgent pods: .spec.daemonSetName, or the name of the
+// instance if unset.
+func daemonSetName(instance dynatracev1alpha1.BaseOneAgentDaemonSet) string {
+	if name := instance.GetOneAgentSpec().DaemonSetName; name != "" {
+		return name
+	}
+	return instance.GetName()
+}
+
+// workloadName returns the name of the object returned by newWorkload. Deployments always take the name of the
+// instance.
+func workloadName(instance dynatracev1alpha1.BaseOneAgentDaemonSet) string {
+	if isDeploymentMode(instance) {
+		return instance.GetName()
+	}
+	return daemonSetName(instance)
+}
+
+// deleteRenamedDaemonSets deletes the DaemonSets controlled by the instance under a name other than the one from
+// daemonSetName, left behind after .spec.daemonSetName has changed. They run pods with the same selector labels, so
+// they'd otherwise compete for the nodes. The DaemonSets of node overrides are kept.
+func (r *ReconcileOneAgent) deleteRenamedDaemonSets(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) error {
+	var list appsv1.DaemonSetList
+	if err := r.client.List(context.TODO(), &list,
+		client.InNamespace(instance.GetNamespace()),
+		client.MatchingLabels(buildLabels(instance.GetName())),
+	); err != nil {
+		return err
+	}
+
+	name := daemonSetName(instance)
+	for i := range list.Items {
+		ds := &list.Items[i]
+		if _, ok := ds.Labels[labelNodeOverride]; ok || ds.Name == name || !metav1.IsControlledBy(ds, instance) {
+			continue
+		}
+		logger.Info("Deleting daemonset of previous name", "name", ds.Name)
+		if err := r.client.Delete(context.TODO(), ds); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package oneagent
+
+import (
+	"context"
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/utils"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileRollout_DaemonSetName(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace, UID: "69e98f18-805a-42de-84b5-3eae66534f75"},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{Version: "1.203.0.20200908-220956"},
+	}
+	oa.Status.Tokens = utils.GetTokensName(oa)
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	t.Run("defaults to the name of the instance", func(t *testing.T) {
+		_, err := reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+		require.NoError(t, err)
+
+		assert.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: oaName, Namespace: namespace}, &appsv1.DaemonSet{}))
+	})
+
+	t.Run("created under custom name", func(t *testing.T) {
+		oa.Spec.DaemonSetName = "dynatrace-oneagent-ds"
+
+		_, err := reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+		require.NoError(t, err)
+
+		var ds appsv1.DaemonSet
+		require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: "dynatrace-oneagent-ds", Namespace: namespace}, &ds))
+		assert.True(t, metav1.IsControlledBy(&ds, oa))
+		assert.Equal(t, buildLabels(oaName), ds.Spec.Selector.MatchLabels)
+
+		err = c.Get(context.TODO(), types.NamespacedName{Name: oaName, Namespace: namespace}, &appsv1.DaemonSet{})
+		assert.True(t, k8serrors.IsNotFound(err), "DaemonSet under previous name removed")
+	})
+}
+
+func TestValidateDaemonSetName(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.DaemonSetName = "dynatrace-oneagent-ds"
+	assert.NoError(t, validate(oa))
+
+	oa.Spec.DaemonSetName = "OneAgent_DS"
+	assert.EqualError(t, validate(oa), `.spec.daemonSetName must be a valid object name, got "OneAgent_DS"`)
+
+	oa.Spec.DaemonSetName = "dynatrace-oneagent-ds"
+	oa.Spec.DeploymentType = dynatracev1alpha1.DeploymentTypeDeployment
+	assert.EqualError(t, validate(oa), ".spec.daemonSetName is not supported with deployment type Deployment")
+}
@@ -0,0 +1,128 @@
+package oneagent
+
+import (
+	"context"
+	"fmt"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// defaultReplicas is the number of OneAgent pods run by a Deployment if .spec.replicas is not set
+const defaultReplicas = dynatracev1alpha1.DefaultReplicas
+
+// isDeploymentMode returns true if the OneAgent pods are run by a Deployment instead of a DaemonSet
+func isDeploymentMode(instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	return instance.GetOneAgentSpec().DeploymentType == dynatracev1alpha1.DeploymentTypeDeployment
+}
+
+// newWorkload returns an empty object of the kind running the OneAgent pods of the instance
+func newWorkload(instance dynatracev1alpha1.BaseOneAgentDaemonSet) runtime.Object {
+	if isDeploymentMode(instance) {
+		return &appsv1.Deployment{}
+	}
+	return &appsv1.DaemonSet{}
+}
+
+// validateDeploymentType returns the issues found on .spec.deploymentType and .spec.replicas
+func validateDeploymentType(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	var msg []string
+
+	switch spec.DeploymentType {
+	case "", dynatracev1alpha1.DeploymentTypeDaemonSet, dynatracev1alpha1.DeploymentTypeDeployment:
+	default:
+		msg = append(msg, fmt.Sprintf(".spec.deploymentType has unknown value %q", spec.DeploymentType))
+	}
+
+	if spec.Replicas != nil && *spec.Replicas < 0 {
+		msg = append(msg, ".spec.replicas must not be negative")
+	}
+
+	return msg
+}
+
+// newDeploymentForCR returns the Deployment running the pods of the desired DaemonSet, with the configured number of
+// replicas. The template hash is recalculated, so that changes to the replicas get rolled out.
+func newDeploymentForCR(instance dynatracev1alpha1.BaseOneAgentDaemonSet, ds *appsv1.DaemonSet) (*appsv1.Deployment, error) {
+	replicas := defaultReplicas
+	if r := instance.GetOneAgentSpec().Replicas; r != nil {
+		replicas = *r
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: *ds.ObjectMeta.DeepCopy(),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: ds.Spec.Selector.DeepCopy(),
+			Template: *ds.Spec.Template.DeepCopy(),
+		},
+	}
+	deployment.Name = instance.GetName()
+
+	delete(deployment.Annotations, annotationTemplateHash)
+	hash, err := generateHash(deployment)
+	if err != nil {
+		return nil, err
+	}
+	deployment.Annotations[annotationTemplateHash] = hash
+
+	return deployment, nil
+}
+
+// reconcileDeployment creates or updates the Deployment running the OneAgent pods, and removes the DaemonSet of the
+// instance if it was run as such before.
+func (r *ReconcileOneAgent) reconcileDeployment(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dsDesired *appsv1.DaemonSet) error {
+	desired, err := newDeploymentForCR(instance, dsDesired)
+	if err != nil {
+		return err
+	}
+
+	actual := &appsv1.Deployment{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, actual)
+	if err != nil && k8serrors.IsNotFound(err) {
+		logger.Info("Creating new deployment")
+		if err = r.client.Create(context.TODO(), desired); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if getTemplateHash(desired) != getTemplateHash(actual) {
+		logger.Info("Updating existing deployment")
+		if err = r.client.Update(context.TODO(), desired); err != nil {
+			return err
+		}
+	}
+
+	return r.deleteOwnedWorkload(logger, instance, &appsv1.DaemonSet{})
+}
+
+// deleteOwnedWorkload deletes the object of the given kind with the name of the instance, or .spec.daemonSetName for
+// DaemonSets, if it's controlled by the instance. Used to clean up after the deployment type has been switched.
+func (r *ReconcileOneAgent) deleteOwnedWorkload(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, obj runtime.Object) error {
+	name := instance.GetName()
+	if _, ok := obj.(*appsv1.DaemonSet); ok {
+		name = daemonSetName(instance)
+	}
+
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.GetNamespace()}, obj); k8serrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	m, ok := obj.(metav1.Object)
+	if !ok || !metav1.IsControlledBy(m, instance) {
+		return nil
+	}
+
+	logger.Info("Deleting workload of previous deployment type", "type", fmt.Sprintf("%T", obj))
+	if err := r.client.Delete(context.TODO(), obj); err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package oneagent
+
+import (
+	"context"
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/utils"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileRollout_DeploymentType(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	key := types.NamespacedName{Name: oaName, Namespace: namespace}
+
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace, UID: "69e98f18-805a-42de-84b5-3eae66534f75"},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{Version: "1.203.0.20200908-220956"},
+	}
+	oa.Status.Tokens = utils.GetTokensName(oa)
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	t.Run("DaemonSet by default", func(t *testing.T) {
+		_, err := reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+		require.NoError(t, err)
+
+		var ds appsv1.DaemonSet
+		assert.NoError(t, c.Get(context.TODO(), key, &ds))
+		assert.True(t, k8serrors.IsNotFound(c.Get(context.TODO(), key, &appsv1.Deployment{})))
+	})
+
+	t.Run("Deployment replaces DaemonSet", func(t *testing.T) {
+		replicas := int32(3)
+		oa.Spec.DeploymentType = dynatracev1alpha1.DeploymentTypeDeployment
+		oa.Spec.Replicas = &replicas
+
+		_, err := reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+		require.NoError(t, err)
+
+		var deployment appsv1.Deployment
+		require.NoError(t, c.Get(context.TODO(), key, &deployment))
+		assert.Equal(t, replicas, *deployment.Spec.Replicas)
+		assert.Equal(t, buildLabels(oaName), deployment.Spec.Selector.MatchLabels)
+		assert.True(t, metav1.IsControlledBy(&deployment, oa))
+		assert.True(t, k8serrors.IsNotFound(c.Get(context.TODO(), key, &appsv1.DaemonSet{})))
+	})
+
+	t.Run("replicas updated", func(t *testing.T) {
+		replicas := int32(1)
+		oa.Spec.Replicas = &replicas
+
+		_, err := reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+		require.NoError(t, err)
+
+		var deployment appsv1.Deployment
+		require.NoError(t, c.Get(context.TODO(), key, &deployment))
+		assert.Equal(t, replicas, *deployment.Spec.Replicas)
+	})
+
+	t.Run("deployment status from Deployment", func(t *testing.T) {
+		var deployment appsv1.Deployment
+		require.NoError(t, c.Get(context.TODO(), key, &deployment))
+		deployment.Status = appsv1.DeploymentStatus{ReadyReplicas: 1, UpdatedReplicas: 1}
+		require.NoError(t, c.Status().Update(context.TODO(), &deployment))
+
+		_, err := reconciler.reconcileDeploymentStatus(oa)
+		require.NoError(t, err)
+		assert.Equal(t, dynatracev1alpha1.OneAgentDeploymentStatus{Desired: 1, Ready: 1, Updated: 1}, oa.Status.DeploymentStatus)
+	})
+}
+
+func TestValidateDeploymentType(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.DeploymentType = dynatracev1alpha1.DeploymentTypeDeployment
+	assert.NoError(t, validate(oa))
+
+	oa.Spec.DeploymentType = "StatefulSet"
+	assert.EqualError(t, validate(oa), `.spec.deploymentType has unknown value "StatefulSet"`)
+}
@@ -0,0 +1,67 @@
+package oneagent
+
+import (
+	"context"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// sendDeploymentEvent reports the rollout of the given OneAgent version to Dynatrace, attached to the hosts where
+// the OneAgent pods are running.
+//
+// Sending the event is best-effort: failures are logged and reflected on the DeploymentEvent condition, but are never
+// returned, so they don't block the reconciliation.
+func (r *ReconcileOneAgent) sendDeploymentEvent(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dtc dtclient.Client, version string) {
+	var hostIPs []string
+
+	pods, err := r.findPods(instance)
+	if err != nil {
+		logger.Info("failed to list pods for deployment event", "error", err.Error())
+	}
+
+	for _, pod := range pods {
+		if pod.Status.HostIP != "" {
+			hostIPs = append(hostIPs, pod.Status.HostIP)
+		}
+	}
+
+	var kubeSystemNS corev1.Namespace
+	if err := r.apiReader.Get(context.TODO(), client.ObjectKey{Name: "kube-system"}, &kubeSystemNS); err != nil {
+		logger.Info("failed to query for cluster ID for deployment event", "error", err.Error())
+	}
+
+	clusterName := instance.GetOneAgentSpec().ClusterName
+	if clusterName == "" {
+		clusterName = string(kubeSystemNS.UID)
+	}
+
+	err = dtc.SendDeploymentEvent(&dtclient.DeploymentEvent{
+		Version:     version,
+		ClusterID:   string(kubeSystemNS.UID),
+		ClusterName: clusterName,
+		HostIPs:     hostIPs,
+	})
+
+	if err != nil {
+		logger.Info("failed to send deployment event", "version", version, "error", err.Error())
+		instance.GetOneAgentStatus().Conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.DeploymentEventConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonDeploymentEventFailed,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	instance.GetOneAgentStatus().Conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.DeploymentEventConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonDeploymentEventSent,
+		Message: "Deployment event sent for version " + version,
+	})
+}
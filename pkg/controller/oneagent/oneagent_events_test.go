@@ -0,0 +1,97 @@
+package oneagent
+
+import (
+	"errors"
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSendDeploymentEvent(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	version := "1.203.0.20200908-220956"
+
+	base := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent-abcde", Namespace: namespace, Labels: buildLabels(oaName)},
+		Status:     corev1.PodStatus{HostIP: "1.2.3.4"},
+	}
+
+	kubeSystemNS := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: types.UID("01234-5678-9012-3456")},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, pod, kubeSystemNS)
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	expectedEvent := &dtclient.DeploymentEvent{
+		Version:     version,
+		ClusterID:   "01234-5678-9012-3456",
+		ClusterName: "01234-5678-9012-3456",
+		HostIPs:     []string{"1.2.3.4"},
+	}
+
+	t.Run("sendDeploymentEvent sets condition on success", func(t *testing.T) {
+		oa := base.DeepCopy()
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("SendDeploymentEvent", expectedEvent).Return(nil)
+
+		reconciler.sendDeploymentEvent(consoleLogger, oa, dtcMock, version)
+
+		dtcMock.AssertExpectations(t)
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.DeploymentEventConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonDeploymentEventSent, cond.Reason)
+	})
+
+	t.Run("sendDeploymentEvent sets condition on failure without blocking", func(t *testing.T) {
+		oa := base.DeepCopy()
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("SendDeploymentEvent", expectedEvent).Return(errors.New("events API unavailable"))
+
+		reconciler.sendDeploymentEvent(consoleLogger, oa, dtcMock, version)
+
+		dtcMock.AssertExpectations(t)
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.DeploymentEventConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionFalse, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonDeploymentEventFailed, cond.Reason)
+		assert.Equal(t, "events API unavailable", cond.Message)
+	})
+
+	t.Run("sendDeploymentEvent includes configured cluster name", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Spec.ClusterName = "production"
+		event := *expectedEvent
+		event.ClusterName = "production"
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("SendDeploymentEvent", &event).Return(nil)
+
+		reconciler.sendDeploymentEvent(consoleLogger, oa, dtcMock, version)
+
+		dtcMock.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,102 @@
+package oneagent
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// knownFeatureFlags are the feature flags supported by the OneAgent installer at the time of release. Other flags are
+// still passed to the installer, so new ones can be used without an operator update.
+var knownFeatureFlags = map[string]bool{
+	"app-log-content-access":     true,
+	"auto-injection-enabled":     true,
+	"system-logs-access-enabled": true,
+}
+
+var featureFlagKeyRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// validateFeatureFlags returns the issues found on .spec.featureFlags
+func validateFeatureFlags(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	var msg []string
+
+	for _, key := range sortedKeys(spec.FeatureFlags) {
+		if !featureFlagKeyRegexp.MatchString(key) {
+			msg = append(msg, fmt.Sprintf(".spec.featureFlags contains invalid flag %q", key))
+		} else if !hostTagValueRegexp.MatchString(spec.FeatureFlags[key]) {
+			msg = append(msg, fmt.Sprintf(".spec.featureFlags contains invalid value for flag %q", key))
+		}
+	}
+
+	return msg
+}
+
+// buildFeatureFlagArgs returns the installer arguments for .spec.featureFlags, sorted by flag. Flags already set on
+// existingArgs are skipped, so .spec.args takes precedence.
+func buildFeatureFlagArgs(spec *dynatracev1alpha1.OneAgentSpec, existingArgs []string) []string {
+	var args []string
+
+	for _, key := range sortedKeys(spec.FeatureFlags) {
+		prefix := fmt.Sprintf("--set-%s=", key)
+		if !hasArgWithPrefix(existingArgs, prefix) {
+			args = append(args, prefix+spec.FeatureFlags[key])
+		}
+	}
+
+	return args
+}
+
+func hasArgWithPrefix(args []string, prefix string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileFeatureFlags sets the FeatureFlags condition, listing the configured flags unknown to the operator. The
+// condition is only set if feature flags are used.
+//
+// Returns true if the condition has changed.
+func reconcileFeatureFlags(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	flags := instance.GetOneAgentSpec().FeatureFlags
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if len(flags) == 0 {
+		return conditions.RemoveCondition(dynatracev1alpha1.FeatureFlagsConditionType)
+	}
+
+	var unknown []string
+	for _, key := range sortedKeys(flags) {
+		if !knownFeatureFlags[key] {
+			unknown = append(unknown, key)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.FeatureFlagsConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonFeatureFlagsKnown,
+			Message: "All feature flags are known",
+		})
+	}
+
+	msg := fmt.Sprintf("Unknown feature flags passed to the OneAgent: %s", strings.Join(unknown, ", "))
+	if conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.FeatureFlagsConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonUnknownFeatureFlags,
+		Message: msg,
+	}) {
+		logger.Info("unknown feature flags configured", "flags", unknown)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestBuildFeatureFlagArgs(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.Args = []string{"--set-auto-injection-enabled=false"}
+	oa.Spec.FeatureFlags = map[string]string{
+		"system-logs-access-enabled": "true",
+		"auto-injection-enabled":     "true",
+		"app-log-content-access":     "false",
+	}
+
+	assert.Equal(t, []string{
+		"--set-app-log-content-access=false",
+		"--set-system-logs-access-enabled=true",
+	}, buildFeatureFlagArgs(&oa.Spec, oa.Spec.Args))
+
+	// Flags already passed through .spec.args are kept as they are
+	args := newPodSpecForCR(oa, false, consoleLogger).Containers[0].Args
+	assert.Contains(t, args, "--set-auto-injection-enabled=false")
+	assert.NotContains(t, args, "--set-auto-injection-enabled=true")
+	assert.Contains(t, args, "--set-app-log-content-access=false")
+}
+
+func TestValidateFeatureFlags(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.FeatureFlags = map[string]string{"new-experimental-flag": "on"}
+	assert.NoError(t, validate(oa))
+
+	oa.Spec.FeatureFlags = map[string]string{"--set-flag": "on"}
+	assert.EqualError(t, validate(oa), `.spec.featureFlags contains invalid flag "--set-flag"`)
+
+	oa.Spec.FeatureFlags = map[string]string{"flag": "with space"}
+	assert.EqualError(t, validate(oa), `.spec.featureFlags contains invalid value for flag "flag"`)
+}
+
+func TestReconcileFeatureFlags(t *testing.T) {
+	t.Run("no condition without feature flags", func(t *testing.T) {
+		oa := newOneAgent()
+
+		assert.False(t, reconcileFeatureFlags(consoleLogger, oa))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.FeatureFlagsConditionType))
+	})
+
+	t.Run("warning for unknown feature flags", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.FeatureFlags = map[string]string{
+			"app-log-content-access": "true",
+			"new-experimental-flag":  "on",
+		}
+
+		assert.True(t, reconcileFeatureFlags(consoleLogger, oa))
+		assert.False(t, reconcileFeatureFlags(consoleLogger, oa))
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.FeatureFlagsConditionType)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionTrue, cond.Status)
+			assert.Equal(t, dynatracev1alpha1.ReasonUnknownFeatureFlags, cond.Reason)
+			assert.Equal(t, "Unknown feature flags passed to the OneAgent: new-experimental-flag", cond.Message)
+		}
+
+		// Unknown flags are passed to the OneAgent nevertheless
+		assert.Contains(t, newPodSpecForCR(oa, false, consoleLogger).Containers[0].Args, "--set-new-experimental-flag=on")
+
+		delete(oa.Spec.FeatureFlags, "new-experimental-flag")
+		assert.True(t, reconcileFeatureFlags(consoleLogger, oa))
+		assert.Equal(t, corev1.ConditionFalse,
+			oa.Status.Conditions.GetCondition(dynatracev1alpha1.FeatureFlagsConditionType).Status)
+	})
+}
@@ -0,0 +1,59 @@
+package oneagent
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// imageDigestRegexp matches an image reference pinned by a sha256 digest, with or without tag
+var imageDigestRegexp = regexp.MustCompile(`^[^@\s]+@sha256:[a-f0-9]{64}$`)
+
+// hasImageDigest returns true if .spec.image references the image by a digest, whether well-formed or not
+func hasImageDigest(spec *dynatracev1alpha1.OneAgentSpec) bool {
+	return strings.Contains(spec.Image, "@")
+}
+
+// isImagePinned returns true if .spec.image pins the image by a well-formed sha256 digest. Pinned images are used
+// verbatim, without resolving the image from the OneAgent version, and are never updated by the operator.
+func isImagePinned(spec *dynatracev1alpha1.OneAgentSpec) bool {
+	return imageDigestRegexp.MatchString(spec.Image)
+}
+
+// reconcileImageDigest reflects the digest on .spec.image on the ImageDigest condition. The condition is only set if
+// the image references a digest.
+//
+// Returns true if the condition has changed.
+func reconcileImageDigest(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	spec := instance.GetOneAgentSpec()
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if !hasImageDigest(spec) {
+		return conditions.RemoveCondition(dynatracev1alpha1.ImageDigestConditionType)
+	}
+
+	if !isImagePinned(spec) {
+		if conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.ImageDigestConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonImageDigestMalformed,
+			Message: fmt.Sprintf(".spec.image %q must reference a digest like image@sha256:<64 hex characters>, resolving the image normally", spec.Image),
+		}) {
+			logger.Info("Malformed image digest", "image", spec.Image)
+			return true
+		}
+		return false
+	}
+
+	return conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.ImageDigestConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonImageDigestPinned,
+		Message: fmt.Sprintf("OneAgent image pinned to %s", spec.Image),
+	})
+}
@@ -0,0 +1,65 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const testDigestImage = "registry.example.com/dynatrace/oneagent@sha256:4d2d4e1b2c3f8a9e0b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b9c8d7e6f5a"
+
+func TestImageDigest(t *testing.T) {
+	t.Run("digest image used unchanged", func(t *testing.T) {
+		for _, immutable := range []bool{false, true} {
+			oa := newOneAgent()
+			oa.Spec.APIURL = "https://f.q.d.n/api"
+			oa.Spec.AgentVersion = "1.203.0.20200908-220956"
+			oa.Spec.Image = testDigestImage
+			oa.Status.UseImmutableImage = immutable
+
+			ds, err := newDaemonSetForCR(consoleLogger, oa)
+			require.NoError(t, err)
+			assert.Equal(t, testDigestImage, ds.Spec.Template.Spec.Containers[0].Image, "immutable image: %v", immutable)
+		}
+	})
+
+	t.Run("image resolved without digest", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.APIURL = "https://f.q.d.n/api"
+		oa.Spec.Image = "registry.example.com/dynatrace/oneagent:latest"
+		oa.Status.UseImmutableImage = true
+
+		ds, err := newDaemonSetForCR(consoleLogger, oa)
+		require.NoError(t, err)
+		assert.Equal(t, "f.q.d.n/linux/oneagent", ds.Spec.Template.Spec.Containers[0].Image)
+	})
+
+	t.Run("condition", func(t *testing.T) {
+		oa := newOneAgent()
+		assert.False(t, reconcileImageDigest(consoleLogger, oa))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.ImageDigestConditionType))
+
+		oa.Spec.Image = testDigestImage
+		assert.True(t, reconcileImageDigest(consoleLogger, oa))
+		assert.False(t, reconcileImageDigest(consoleLogger, oa))
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.ImageDigestConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonImageDigestPinned, cond.Reason)
+
+		oa.Spec.Image = "registry.example.com/dynatrace/oneagent@sha256:1234"
+		assert.False(t, isImagePinned(&oa.Spec))
+		assert.True(t, reconcileImageDigest(consoleLogger, oa))
+		cond = oa.Status.Conditions.GetCondition(dynatracev1alpha1.ImageDigestConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionFalse, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonImageDigestMalformed, cond.Reason)
+
+		oa.Spec.Image = ""
+		assert.True(t, reconcileImageDigest(consoleLogger, oa))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.ImageDigestConditionType))
+	})
+}
@@ -0,0 +1,20 @@
+package oneagent
+
+import (
+	"regexp"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+)
+
+// imageRepositoryRegexp matches image repositories with optional registry host and port, but without tag or digest
+var imageRepositoryRegexp = regexp.MustCompile(`^([a-zA-Z0-9.-]+(:[0-9]+)?/)?[a-z0-9]+([._-]+[a-z0-9]+)*(/[a-z0-9]+([._-]+[a-z0-9]+)*)*$`)
+
+// imageFromRepository returns the OneAgent image composed from .spec.imageRepository and .spec.agentVersion as tag,
+// or latest if no version is set.
+func imageFromRepository(spec *dynatracev1alpha1.OneAgentSpec) string {
+	tag := spec.AgentVersion
+	if tag == "" {
+		tag = "latest"
+	}
+	return spec.ImageRepository + ":" + tag
+}
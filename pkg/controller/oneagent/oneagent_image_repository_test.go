@@ -0,0 +1,61 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRepository(t *testing.T) {
+	for _, immutable := range []bool{false, true} {
+		newInstance := func() *dynatracev1alpha1.OneAgent {
+			oa := newOneAgent()
+			oa.Spec.APIURL = "https://f.q.d.n/api"
+			oa.Spec.ImageRepository = "mirror.example.com:5000/dynatrace/oneagent"
+			oa.Status.UseImmutableImage = immutable
+			return oa
+		}
+
+		t.Run("composed from repository and version", func(t *testing.T) {
+			oa := newInstance()
+			oa.Spec.AgentVersion = "1.203.0.20200908-220956"
+
+			ds, err := newDaemonSetForCR(consoleLogger, oa)
+			require.NoError(t, err)
+			assert.Equal(t, "mirror.example.com:5000/dynatrace/oneagent:1.203.0.20200908-220956", ds.Spec.Template.Spec.Containers[0].Image, "immutable image: %v", immutable)
+		})
+
+		t.Run("latest without version", func(t *testing.T) {
+			ds, err := newDaemonSetForCR(consoleLogger, newInstance())
+			require.NoError(t, err)
+			assert.Equal(t, "mirror.example.com:5000/dynatrace/oneagent:latest", ds.Spec.Template.Spec.Containers[0].Image, "immutable image: %v", immutable)
+		})
+
+		t.Run("full image takes precedence", func(t *testing.T) {
+			oa := newInstance()
+			oa.Spec.AgentVersion = "1.203.0.20200908-220956"
+			oa.Spec.Image = "registry.example.com/dynatrace/oneagent@sha256:4d2d4e1b2c3f8a9e0b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b9c8d7e6f5a"
+
+			ds, err := newDaemonSetForCR(consoleLogger, oa)
+			require.NoError(t, err)
+			assert.Equal(t, oa.Spec.Image, ds.Spec.Template.Spec.Containers[0].Image, "immutable image: %v", immutable)
+		})
+	}
+}
+
+func TestValidateImageRepository(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+
+	for _, repo := range []string{"oneagent", "dynatrace/oneagent", "docker.io/dynatrace/oneagent", "mirror.example.com:5000/dynatrace/one-agent"} {
+		oa.Spec.ImageRepository = repo
+		assert.NoError(t, validate(oa), "repository: %s", repo)
+	}
+
+	for _, repo := range []string{"dynatrace/oneagent:latest", "dynatrace/oneagent@sha256:1234", "Dynatrace/OneAgent", "dynatrace/oneagent/"} {
+		oa.Spec.ImageRepository = repo
+		assert.Error(t, validate(oa), "repository: %s", repo)
+	}
+}
@@ -0,0 +1,85 @@
+package oneagent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	osLinux   = "linux"
+	osWindows = "windows"
+)
+
+// knownOperatingSystems are the keys supported on .spec.installerArgs
+var knownOperatingSystems = map[string]bool{
+	osLinux:   true,
+	osWindows: true,
+}
+
+// validateInstallerArgs returns the issues found on .spec.installerArgs
+func validateInstallerArgs(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	var msg []string
+
+	keys := make([]string, 0, len(spec.InstallerArgs))
+	for k := range spec.InstallerArgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !knownOperatingSystems[k] {
+			msg = append(msg, fmt.Sprintf(".spec.installerArgs contains unknown operating system %q", k))
+		}
+	}
+
+	return msg
+}
+
+// targetOS returns the operating system of the nodes the OneAgent pods are scheduled on, as selected on
+// .spec.nodeSelector. Defaults to Linux.
+func targetOS(spec *dynatracev1alpha1.OneAgentSpec) string {
+	for _, label := range []string{"kubernetes.io/os", "beta.kubernetes.io/os"} {
+		if os, ok := spec.NodeSelector[label]; ok && os != "" {
+			return os
+		}
+	}
+	return osLinux
+}
+
+// buildInstallerArgs returns a copy of .spec.args followed by the arguments on .spec.installerArgs for the given
+// operating system.
+func buildInstallerArgs(spec *dynatracev1alpha1.OneAgentSpec, os string) []string {
+	args := make([]string, 0, len(spec.Args)+len(spec.InstallerArgs[os]))
+	args = append(args, spec.Args...)
+	return append(args, spec.InstallerArgs[os]...)
+}
+
+// reconcileCustomCommand sets the CustomCommand condition as a warning while .spec.command overrides the command of
+// the OneAgent container, since the installer arguments generated by the operator are dropped then.
+//
+// Returns true if the condition has changed.
+func reconcileCustomCommand(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	command := instance.GetOneAgentSpec().Command
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if len(command) == 0 {
+		return conditions.RemoveCondition(dynatracev1alpha1.CustomCommandConditionType)
+	}
+
+	if conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.CustomCommandConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonCommandOverridden,
+		Message: fmt.Sprintf("OneAgent container command overridden with %q, only .spec.args are passed, which may break the OneAgent installation", strings.Join(command, " ")),
+	}) {
+		logger.Info("OneAgent container command is overridden", "command", command)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestBuildInstallerArgs(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.Args = []string{"--set-app-log-content-access=true"}
+	oa.Spec.InstallerArgs = map[string][]string{
+		"linux":   {"INSTALL_PATH=/opt/dynatrace"},
+		"windows": {"INSTALL_PATH=C:\\dynatrace"},
+	}
+
+	t.Run("linux by default", func(t *testing.T) {
+		assert.Equal(t, "linux", targetOS(&oa.Spec))
+		assert.Equal(t, []string{"--set-app-log-content-access=true", "INSTALL_PATH=/opt/dynatrace"},
+			buildInstallerArgs(&oa.Spec, targetOS(&oa.Spec)))
+
+		ps := newPodSpecForCR(oa, false, consoleLogger)
+		assert.Contains(t, ps.Containers[0].Args, "INSTALL_PATH=/opt/dynatrace")
+		assert.NotContains(t, ps.Containers[0].Args, "INSTALL_PATH=C:\\dynatrace")
+	})
+
+	t.Run("selected by node selector", func(t *testing.T) {
+		windows := oa.DeepCopy()
+		windows.Spec.NodeSelector = map[string]string{"kubernetes.io/os": "windows"}
+
+		ps := newPodSpecForCR(windows, false, consoleLogger)
+		assert.Contains(t, ps.Containers[0].Args, "INSTALL_PATH=C:\\dynatrace")
+		assert.NotContains(t, ps.Containers[0].Args, "INSTALL_PATH=/opt/dynatrace")
+	})
+
+	t.Run(".spec.args not modified", func(t *testing.T) {
+		_ = newPodSpecForCR(oa, false, consoleLogger)
+		assert.Equal(t, []string{"--set-app-log-content-access=true"}, oa.Spec.Args)
+	})
+}
+
+func TestValidateInstallerArgs(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.InstallerArgs = map[string][]string{"linux": {"--set-host-group=a"}, "windows": nil}
+	assert.NoError(t, validate(oa))
+
+	oa.Spec.InstallerArgs["darwin"] = []string{"--set-host-group=b"}
+	assert.EqualError(t, validate(oa), `.spec.installerArgs contains unknown operating system "darwin"`)
+}
+
+func TestCustomCommand(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.Args = []string{"--set-app-log-content-access=true"}
+	oa.Spec.HostTags = []string{"team=a"}
+
+	t.Run("default command", func(t *testing.T) {
+		ps := newPodSpecForCR(oa, false, consoleLogger)
+		assert.Nil(t, ps.Containers[0].Command)
+		assert.Contains(t, ps.Containers[0].Args, "--set-app-log-content-access=true")
+		assert.Contains(t, ps.Containers[0].Args, "--set-host-tag=team=a", "generated args merged")
+
+		assert.False(t, reconcileCustomCommand(consoleLogger, oa))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.CustomCommandConditionType))
+	})
+
+	t.Run("command overridden", func(t *testing.T) {
+		custom := oa.DeepCopy()
+		custom.Spec.Command = []string{"/bin/sh", "-c"}
+
+		ps := newPodSpecForCR(custom, false, consoleLogger)
+		assert.Equal(t, []string{"/bin/sh", "-c"}, ps.Containers[0].Command)
+		assert.Equal(t, []string{"--set-app-log-content-access=true"}, ps.Containers[0].Args, "only args from the spec")
+
+		assert.True(t, reconcileCustomCommand(consoleLogger, custom))
+		cond := custom.Status.Conditions.GetCondition(dynatracev1alpha1.CustomCommandConditionType)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionTrue, cond.Status)
+			assert.Equal(t, dynatracev1alpha1.ReasonCommandOverridden, cond.Reason)
+		}
+		assert.False(t, reconcileCustomCommand(consoleLogger, custom))
+
+		custom.Spec.Command = nil
+		assert.True(t, reconcileCustomCommand(consoleLogger, custom), "condition removed")
+	})
+}
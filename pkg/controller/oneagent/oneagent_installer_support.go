@@ -0,0 +1,109 @@
+package oneagent
+
+import (
+	"fmt"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// installerArchs maps the node architectures to the ones of the OneAgent installers
+var installerArchs = map[string]string{
+	"amd64":   dtclient.ArchX86,
+	"arm64":   dtclient.ArchARM,
+	"ppc64le": dtclient.ArchPPCLE,
+	"s390x":   dtclient.ArchS390,
+}
+
+// installerOperatingSystems maps the node operating systems to the ones of the OneAgent installers
+var installerOperatingSystems = map[string]string{
+	osLinux:   dtclient.OsUnix,
+	osWindows: dtclient.OsWindows,
+}
+
+// targetArch returns the architecture of the nodes the OneAgent pods are scheduled on, as selected on
+// .spec.nodeSelector, or an empty string if not selected.
+func targetArch(spec *dynatracev1alpha1.OneAgentSpec) string {
+	for _, label := range []string{"kubernetes.io/arch", "beta.kubernetes.io/arch"} {
+		if arch, ok := spec.NodeSelector[label]; ok && arch != "" {
+			return arch
+		}
+	}
+	return ""
+}
+
+// installerArch returns the architecture of the installer downloaded by the OneAgent pods. Pods can only get the
+// installer matching their nodes if .spec.nodeSelector selects the architecture, e.g., with one OneAgent per
+// architecture on mixed amd64 and arm64 clusters. Defaults to x86 otherwise.
+func installerArch(spec *dynatracev1alpha1.OneAgentSpec) string {
+	if arch, ok := installerArchs[targetArch(spec)]; ok {
+		return arch
+	}
+	return dtclient.ArchX86
+}
+
+// reconcileInstallerSupport sets the InstallerSupported condition, verifying that the version on the status is
+// available for the operating system and architecture selected on .spec.nodeSelector before it gets rolled out. The
+// condition is only set if an architecture is selected, and each combination is only looked up once.
+//
+// Returns true if the condition has changed.
+func reconcileInstallerSupport(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dtc dtclient.Client) bool {
+	spec := instance.GetOneAgentSpec()
+	oaStatus := instance.GetOneAgentStatus()
+
+	nodeArch, nodeOS, ver := targetArch(spec), targetOS(spec), oaStatus.Version
+	if nodeArch == "" {
+		return oaStatus.Conditions.RemoveCondition(dynatracev1alpha1.InstallerSupportedConditionType)
+	}
+	if ver == "" {
+		return false
+	}
+
+	supportedMsg := fmt.Sprintf("OneAgent version %s is available for %s/%s", ver, nodeOS, nodeArch)
+	unsupportedMsg := fmt.Sprintf("OneAgent version %s is not available for %s/%s", ver, nodeOS, nodeArch)
+	if cond := oaStatus.Conditions.GetCondition(dynatracev1alpha1.InstallerSupportedConditionType); cond != nil &&
+		(cond.Message == supportedMsg || cond.Message == unsupportedMsg) {
+		return false
+	}
+
+	installer := dtclient.AgentInstaller{
+		OS:            installerOperatingSystems[nodeOS],
+		InstallerType: dtclient.InstallerTypeDefault,
+		Arch:          installerArchs[nodeArch],
+	}
+
+	supported := false
+	if installer.OS != "" && installer.Arch != "" {
+		available, err := dtc.GetAvailableInstallers(ver)
+		if err != nil {
+			logger.Info("Failed to query available installers", "version", ver, "error", err.Error())
+			return false
+		}
+		for _, i := range available {
+			if i == installer {
+				supported = true
+				break
+			}
+		}
+	}
+
+	if supported {
+		return oaStatus.Conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.InstallerSupportedConditionType,
+			Status:  corev1.ConditionTrue,
+			Reason:  dynatracev1alpha1.ReasonInstallerAvailable,
+			Message: supportedMsg,
+		})
+	}
+
+	logger.Info("OneAgent version not available for selected nodes", "version", ver, "os", nodeOS, "arch", nodeArch)
+	return oaStatus.Conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.InstallerSupportedConditionType,
+		Status:  corev1.ConditionFalse,
+		Reason:  dynatracev1alpha1.ReasonInstallerUnavailable,
+		Message: unsupportedMsg,
+	})
+}
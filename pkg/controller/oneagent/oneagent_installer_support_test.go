@@ -0,0 +1,91 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReconcileInstallerSupport(t *testing.T) {
+	const version = "1.203.0.20200908-220956"
+
+	available := []dtclient.AgentInstaller{
+		{OS: dtclient.OsUnix, InstallerType: dtclient.InstallerTypeDefault, Arch: dtclient.ArchX86},
+		{OS: dtclient.OsUnix, InstallerType: dtclient.InstallerTypeDefault, Arch: dtclient.ArchARM},
+	}
+
+	newInstance := func(nodeSelector map[string]string) *dynatracev1alpha1.OneAgent {
+		oa := newOneAgent()
+		oa.Spec.NodeSelector = nodeSelector
+		oa.Status.Version = version
+		return oa
+	}
+
+	t.Run("supported combination", func(t *testing.T) {
+		oa := newInstance(map[string]string{"kubernetes.io/arch": "arm64"})
+		dtc := &dtclient.MockDynatraceClient{}
+		dtc.On("GetAvailableInstallers", version).Return(available, nil).Once()
+
+		assert.True(t, reconcileInstallerSupport(consoleLogger, oa, dtc))
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.InstallerSupportedConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, "OneAgent version "+version+" is available for linux/arm64", cond.Message)
+
+		assert.False(t, reconcileInstallerSupport(consoleLogger, oa, dtc), "not looked up again")
+		dtc.AssertExpectations(t)
+	})
+
+	t.Run("unsupported combination", func(t *testing.T) {
+		oa := newInstance(map[string]string{"kubernetes.io/os": "linux", "kubernetes.io/arch": "s390x"})
+		dtc := &dtclient.MockDynatraceClient{}
+		dtc.On("GetAvailableInstallers", version).Return(available, nil)
+
+		assert.True(t, reconcileInstallerSupport(consoleLogger, oa, dtc))
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.InstallerSupportedConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionFalse, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonInstallerUnavailable, cond.Reason)
+		assert.Equal(t, "OneAgent version "+version+" is not available for linux/s390x", cond.Message)
+	})
+
+	t.Run("unknown architecture", func(t *testing.T) {
+		oa := newInstance(map[string]string{"kubernetes.io/arch": "riscv64"})
+		dtc := &dtclient.MockDynatraceClient{}
+
+		assert.True(t, reconcileInstallerSupport(consoleLogger, oa, dtc))
+		assert.Equal(t, corev1.ConditionFalse, oa.Status.Conditions.GetCondition(dynatracev1alpha1.InstallerSupportedConditionType).Status)
+		dtc.AssertNotCalled(t, "GetAvailableInstallers", mock.Anything)
+	})
+
+	t.Run("no condition without selected architecture", func(t *testing.T) {
+		oa := newInstance(nil)
+		assert.False(t, reconcileInstallerSupport(consoleLogger, oa, &dtclient.MockDynatraceClient{}))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.InstallerSupportedConditionType))
+	})
+}
+
+func TestPrepareEnvVars_InstallerArch(t *testing.T) {
+	installerURL := func(nodeSelector map[string]string) string {
+		oa := newOneAgent()
+		oa.Spec.APIURL = "https://f.q.d.n/api"
+		oa.Spec.NodeSelector = nodeSelector
+		for _, env := range prepareEnvVars(oa) {
+			if env.Name == "ONEAGENT_INSTALLER_SCRIPT_URL" {
+				return env.Value
+			}
+		}
+		return ""
+	}
+
+	assert.Contains(t, installerURL(nil), "&arch=x86&")
+	assert.Contains(t, installerURL(map[string]string{"kubernetes.io/arch": "amd64"}), "&arch=x86&")
+	assert.Contains(t, installerURL(map[string]string{"kubernetes.io/arch": "arm64"}), "&arch=arm&")
+	assert.Contains(t, installerURL(map[string]string{"beta.kubernetes.io/arch": "arm64"}), "&arch=arm&")
+	assert.Contains(t, installerURL(map[string]string{"kubernetes.io/arch": "riscv64"}), "&arch=x86&", "unknown architectures reported on condition")
+}
@@ -0,0 +1,43 @@
+package oneagent
+
+import (
+	"errors"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/istio"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// reconcileIstioReady reflects the result of the Istio reconciliation, given by err, on the IstioReady condition. The
+// condition is only set if .spec.enableIstio is set and Istio is installed on the cluster.
+//
+// Returns true if the condition has changed.
+func reconcileIstioReady(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, err error) bool {
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if !instance.GetOneAgentSpec().EnableIstio || errors.Is(err, istio.ErrNotInstalled) {
+		return conditions.RemoveCondition(dynatracev1alpha1.IstioReadyConditionType)
+	}
+
+	if err != nil {
+		if conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.IstioReadyConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonIstioReconcileFailed,
+			Message: err.Error(),
+		}) {
+			logger.Info("Istio objects are out of sync", "error", err)
+			return true
+		}
+		return false
+	}
+
+	return conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.IstioReadyConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonIstioObjectsSynced,
+		Message: "Istio objects are in sync",
+	})
+}
@@ -0,0 +1,63 @@
+package oneagent
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/istio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReconcileIstioReady(t *testing.T) {
+	t.Run("not installed is skipped", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.EnableIstio = true
+		assert.False(t, reconcileIstioReady(consoleLogger, oa, istio.ErrNotInstalled))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.IstioReadyConditionType))
+	})
+
+	t.Run("missing CRD", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.EnableIstio = true
+		err := fmt.Errorf("istio: error reconciling config for Dynatrace API URL: %w",
+			errors.New("failed to lookup CRD for ServiceEntry/VirtualService"))
+
+		assert.True(t, reconcileIstioReady(consoleLogger, oa, err))
+		assert.False(t, reconcileIstioReady(consoleLogger, oa, err))
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.IstioReadyConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionFalse, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonIstioReconcileFailed, cond.Reason)
+		assert.Equal(t, err.Error(), cond.Message)
+	})
+
+	t.Run("failed apply, then in sync", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.EnableIstio = true
+		err := fmt.Errorf("istio: error reconciling config for Dynatrace communication endpoints: %w",
+			errors.New(`serviceentries.networking.istio.io "oneagent-https-endpoint" is forbidden`))
+
+		assert.True(t, reconcileIstioReady(consoleLogger, oa, err))
+		assert.Equal(t, err.Error(), oa.Status.Conditions.GetCondition(dynatracev1alpha1.IstioReadyConditionType).Message)
+
+		assert.True(t, reconcileIstioReady(consoleLogger, oa, nil))
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.IstioReadyConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonIstioObjectsSynced, cond.Reason)
+	})
+
+	t.Run("removed once disabled", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.EnableIstio = true
+		require.True(t, reconcileIstioReady(consoleLogger, oa, nil))
+
+		oa.Spec.EnableIstio = false
+		assert.True(t, reconcileIstioReady(consoleLogger, oa, nil))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.IstioReadyConditionType))
+	})
+}
@@ -0,0 +1,113 @@
+package oneagent
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// logPathsEnvVar passes the paths from .spec.logMonitoring.paths to the OneAgent, separated by commas
+const logPathsEnvVar = "ONEAGENT_LOG_PATHS"
+
+// logMonitoringFeatureFlags are the installer feature flags giving the OneAgent access to logs
+var logMonitoringFeatureFlags = []string{"app-log-content-access", "system-logs-access-enabled"}
+
+// validateLogMonitoring returns the issues found on .spec.logMonitoring
+func validateLogMonitoring(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	if spec.LogMonitoring == nil {
+		return nil
+	}
+
+	var msg []string
+	for _, p := range spec.LogMonitoring.Paths {
+		if !path.IsAbs(p) {
+			msg = append(msg, fmt.Sprintf(".spec.logMonitoring.paths contains relative path %q", p))
+		}
+	}
+	return msg
+}
+
+// isLogMonitoringEnabled returns true if .spec.logMonitoring enables the ingestion of logs
+func isLogMonitoringEnabled(spec *dynatracev1alpha1.OneAgentSpec) bool {
+	return spec.LogMonitoring != nil && spec.LogMonitoring.Enabled
+}
+
+// logMonitoringPaths returns the cleaned paths from .spec.logMonitoring.paths in their original order, without
+// duplicates.
+func logMonitoringPaths(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	if !isLogMonitoringEnabled(spec) {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var paths []string
+	for _, p := range spec.LogMonitoring.Paths {
+		p = path.Clean(p)
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// buildLogMonitoringArgs returns the installer arguments enabling log access if .spec.logMonitoring is enabled. Flags
+// already set on existingArgs are skipped, so .spec.args and .spec.featureFlags take precedence.
+func buildLogMonitoringArgs(spec *dynatracev1alpha1.OneAgentSpec, existingArgs []string) []string {
+	if !isLogMonitoringEnabled(spec) {
+		return nil
+	}
+
+	var args []string
+	for _, flag := range logMonitoringFeatureFlags {
+		prefix := fmt.Sprintf("--set-%s=", flag)
+		if !hasArgWithPrefix(existingArgs, prefix) {
+			args = append(args, prefix+"true")
+		}
+	}
+	return args
+}
+
+// reconcileLogMonitoring reflects the log monitoring configured on the OneAgent pods on the LogMonitoring condition.
+// The condition is only set if .spec.logMonitoring is set.
+//
+// Returns true if the condition has changed.
+func reconcileLogMonitoring(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	spec := instance.GetOneAgentSpec()
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if spec.LogMonitoring == nil {
+		return conditions.RemoveCondition(dynatracev1alpha1.LogMonitoringConditionType)
+	}
+
+	if !spec.LogMonitoring.Enabled {
+		return conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.LogMonitoringConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonLogMonitoringDisabled,
+			Message: "Log monitoring is disabled",
+		})
+	}
+
+	msg := "Log monitoring is enabled"
+	paths := logMonitoringPaths(spec)
+	if len(paths) > 0 {
+		msg = fmt.Sprintf("Log monitoring is enabled, including paths %s", strings.Join(paths, ", "))
+	}
+
+	if conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.LogMonitoringConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonLogMonitoringEnabled,
+		Message: msg,
+	}) {
+		logger.Info("Log monitoring configuration changed", "paths", paths)
+		return true
+	}
+	return false
+}
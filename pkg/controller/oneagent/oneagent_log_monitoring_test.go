@@ -0,0 +1,68 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestLogMonitoring(t *testing.T) {
+	t.Run("args and env on the pod spec", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.Args = []string{"--set-app-log-content-access=false"}
+		oa.Spec.LogMonitoring = &dynatracev1alpha1.LogMonitoring{
+			Enabled: true,
+			Paths:   []string{"/var/log/app", "/opt/logs/", "/var/log/app"},
+		}
+
+		podSpec := newPodSpecForCR(oa, false, consoleLogger)
+		args := podSpec.Containers[0].Args
+		assert.Contains(t, args, "--set-system-logs-access-enabled=true")
+		assert.Contains(t, args, "--set-app-log-content-access=false", "spec args take precedence")
+		assert.NotContains(t, args, "--set-app-log-content-access=true")
+
+		env := prepareEnvVars(oa)
+		assert.Contains(t, env, corev1.EnvVar{Name: logPathsEnvVar, Value: "/var/log/app,/opt/logs"})
+	})
+
+	t.Run("nothing added if disabled", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.LogMonitoring = &dynatracev1alpha1.LogMonitoring{Paths: []string{"/var/log/app"}}
+
+		assert.Empty(t, buildLogMonitoringArgs(&oa.Spec, nil))
+		for _, e := range prepareEnvVars(oa) {
+			assert.NotEqual(t, logPathsEnvVar, e.Name)
+		}
+	})
+
+	t.Run("relative paths are rejected", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.APIURL = "https://f.q.d.n/api"
+		oa.Spec.LogMonitoring = &dynatracev1alpha1.LogMonitoring{Enabled: true, Paths: []string{"/var/log", "logs/app"}}
+		assert.EqualError(t, validate(oa), `.spec.logMonitoring.paths contains relative path "logs/app"`)
+	})
+
+	t.Run("condition follows config", func(t *testing.T) {
+		oa := newOneAgent()
+		assert.False(t, reconcileLogMonitoring(consoleLogger, oa))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.LogMonitoringConditionType))
+
+		oa.Spec.LogMonitoring = &dynatracev1alpha1.LogMonitoring{Enabled: true, Paths: []string{"/var/log/app"}}
+		assert.True(t, reconcileLogMonitoring(consoleLogger, oa))
+		assert.False(t, reconcileLogMonitoring(consoleLogger, oa))
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.LogMonitoringConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, "Log monitoring is enabled, including paths /var/log/app", cond.Message)
+
+		oa.Spec.LogMonitoring.Paths = append(oa.Spec.LogMonitoring.Paths, "/opt/logs")
+		assert.True(t, reconcileLogMonitoring(consoleLogger, oa), "path change reflected")
+
+		oa.Spec.LogMonitoring.Enabled = false
+		assert.True(t, reconcileLogMonitoring(consoleLogger, oa))
+		assert.Equal(t, dynatracev1alpha1.ReasonLogMonitoringDisabled, oa.Status.Conditions.GetCondition(dynatracev1alpha1.LogMonitoringConditionType).Reason)
+	})
+}
@@ -0,0 +1,54 @@
+package oneagent
+
+import (
+	"time"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// maintenanceRequeueInterval is the delay for the next reconciliation while the Dynatrace environment is under
+// maintenance
+const maintenanceRequeueInterval = 15 * time.Minute
+
+// reconcileMaintenance sets the TenantMaintenance condition while the Dynatrace environment is under maintenance, and
+// removes it once the maintenance has ended. If the tenant status can't be queried, the environment is assumed to be
+// available, so that the actual API requests report the error.
+//
+// Returns true if the environment is under maintenance, and true if the condition has changed.
+func reconcileMaintenance(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dtc dtclient.Client) (bool, bool) {
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	tenant, err := dtc.GetTenantStatus()
+	if err != nil {
+		logger.Info("Failed to query tenant status", "error", err.Error())
+		return false, false
+	}
+
+	if !tenant.Maintenance {
+		if conditions.RemoveCondition(dynatracev1alpha1.TenantMaintenanceConditionType) {
+			logger.Info("Dynatrace environment maintenance ended, resuming reconciliation")
+			return false, true
+		}
+		return false, false
+	}
+
+	msg := "Dynatrace environment is under maintenance, skipping reconciliation"
+	if tenant.Message != "" {
+		msg += ": " + tenant.Message
+	}
+
+	if conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.TenantMaintenanceConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonTenantInMaintenance,
+		Message: msg,
+	}) {
+		logger.Info("Dynatrace environment is under maintenance, pausing reconciliation", "message", tenant.Message)
+		return true, true
+	}
+	return true, false
+}
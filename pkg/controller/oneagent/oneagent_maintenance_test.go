@@ -0,0 +1,60 @@
+package oneagent
+
+import (
+	"errors"
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReconcileMaintenance(t *testing.T) {
+	newMock := func(tenant dtclient.TenantStatus, err error) *dtclient.MockDynatraceClient {
+		dtc := &dtclient.MockDynatraceClient{}
+		dtc.On("GetTenantStatus").Return(tenant, err)
+		return dtc
+	}
+
+	oa := newOneAgent()
+
+	t.Run("available tenant", func(t *testing.T) {
+		maintenance, upd := reconcileMaintenance(consoleLogger, oa, newMock(dtclient.TenantStatus{}, nil))
+		assert.False(t, maintenance)
+		assert.False(t, upd)
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.TenantMaintenanceConditionType))
+	})
+
+	t.Run("tenant under maintenance", func(t *testing.T) {
+		dtc := newMock(dtclient.TenantStatus{Maintenance: true, Message: "Cluster upgrade in progress"}, nil)
+
+		maintenance, upd := reconcileMaintenance(consoleLogger, oa, dtc)
+		assert.True(t, maintenance)
+		assert.True(t, upd)
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.TenantMaintenanceConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonTenantInMaintenance, cond.Reason)
+		assert.Equal(t, "Dynatrace environment is under maintenance, skipping reconciliation: Cluster upgrade in progress", cond.Message)
+
+		maintenance, upd = reconcileMaintenance(consoleLogger, oa, dtc)
+		assert.True(t, maintenance)
+		assert.False(t, upd, "condition unchanged")
+	})
+
+	t.Run("failed query keeps condition", func(t *testing.T) {
+		maintenance, upd := reconcileMaintenance(consoleLogger, oa, newMock(dtclient.TenantStatus{}, errors.New("connection refused")))
+		assert.False(t, maintenance)
+		assert.False(t, upd)
+		assert.NotNil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.TenantMaintenanceConditionType))
+	})
+
+	t.Run("maintenance ended", func(t *testing.T) {
+		maintenance, upd := reconcileMaintenance(consoleLogger, oa, newMock(dtclient.TenantStatus{}, nil))
+		assert.False(t, maintenance)
+		assert.True(t, upd)
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.TenantMaintenanceConditionType))
+	})
+}
@@ -0,0 +1,55 @@
+package oneagent
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// validateNamespaceSelector returns the issues found on .spec.namespaceSelector
+func validateNamespaceSelector(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	if spec.NamespaceSelector == nil {
+		return nil
+	}
+	if _, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector); err != nil {
+		return []string{fmt.Sprintf(".spec.namespaceSelector is invalid: %s", err.Error())}
+	}
+	return nil
+}
+
+// reconcileMonitoredNamespaces resolves .spec.namespaceSelector into .status.monitoredNamespaces. The list is cleared
+// if no selector is set.
+//
+// Returns true if the list has changed.
+func (r *ReconcileOneAgent) reconcileMonitoredNamespaces(instance dynatracev1alpha1.BaseOneAgentDaemonSet) (bool, error) {
+	var names []string
+	if sel := instance.GetOneAgentSpec().NamespaceSelector; sel != nil {
+		selector, err := metav1.LabelSelectorAsSelector(sel)
+		if err != nil {
+			return false, err
+		}
+
+		var list corev1.NamespaceList
+		if err := r.client.List(context.TODO(), &list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return false, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+
+		for _, ns := range list.Items {
+			names = append(names, ns.Name)
+		}
+		sort.Strings(names)
+	}
+
+	sts := instance.GetOneAgentStatus()
+	if reflect.DeepEqual(sts.MonitoredNamespaces, names) {
+		return false, nil
+	}
+	sts.MonitoredNamespaces = names
+	return true, nil
+}
@@ -0,0 +1,69 @@
+package oneagent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileMonitoredNamespaces(t *testing.T) {
+	newNamespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme,
+		newNamespace("shop", map[string]string{"monitoring": "enabled", "team": "web"}),
+		newNamespace("billing", map[string]string{"monitoring": "enabled"}),
+		newNamespace("sandbox", map[string]string{"monitoring": "disabled"}),
+		newNamespace("kube-system", nil),
+	)
+	r := &ReconcileOneAgent{client: c, apiReader: c, scheme: scheme.Scheme, logger: consoleLogger}
+
+	oa := newOneAgent()
+	upd, err := r.reconcileMonitoredNamespaces(oa)
+	require.NoError(t, err)
+	assert.False(t, upd)
+	assert.Empty(t, oa.Status.MonitoredNamespaces)
+
+	oa.Spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"monitoring": "enabled"}}
+	upd, err = r.reconcileMonitoredNamespaces(oa)
+	require.NoError(t, err)
+	assert.True(t, upd)
+	assert.Equal(t, []string{"billing", "shop"}, oa.Status.MonitoredNamespaces)
+
+	upd, err = r.reconcileMonitoredNamespaces(oa)
+	require.NoError(t, err)
+	assert.False(t, upd)
+
+	oa.Spec.NamespaceSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "monitoring", Operator: metav1.LabelSelectorOpIn, Values: []string{"enabled"}},
+		{Key: "team", Operator: metav1.LabelSelectorOpDoesNotExist},
+	}}
+	upd, err = r.reconcileMonitoredNamespaces(oa)
+	require.NoError(t, err)
+	assert.True(t, upd)
+	assert.Equal(t, []string{"billing"}, oa.Status.MonitoredNamespaces)
+
+	oa.Spec.NamespaceSelector = nil
+	upd, err = r.reconcileMonitoredNamespaces(oa)
+	require.NoError(t, err)
+	assert.True(t, upd)
+	assert.Empty(t, oa.Status.MonitoredNamespaces)
+}
+
+func TestValidateNamespaceSelector(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"monitoring": "enabled"}}
+	assert.NoError(t, validate(oa))
+
+	oa.Spec.NamespaceSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "monitoring", Operator: "Matches"},
+	}}
+	assert.Error(t, validate(oa))
+}
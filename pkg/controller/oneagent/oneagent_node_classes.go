@@ -0,0 +1,77 @@
+package oneagent
+
+import (
+	"context"
+	"sort"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// validateNodeClasses returns the issues found on .spec.resourcesByNodeClass
+func validateNodeClasses(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	if len(spec.ResourcesByNodeClass) > 0 && spec.NodeClassLabel == "" {
+		return []string{".spec.resourcesByNodeClass requires .spec.nodeClassLabel to be set"}
+	}
+	return nil
+}
+
+// nodeClass returns the node class the OneAgent pods are restricted to by .spec.nodeSelector on the .spec.nodeClassLabel
+// label, i.e., when running a DaemonSet per node pool. Returns an empty string if the pods span node classes.
+func nodeClass(spec *dynatracev1alpha1.OneAgentSpec) string {
+	if spec.NodeClassLabel == "" {
+		return ""
+	}
+	return spec.NodeSelector[spec.NodeClassLabel]
+}
+
+// resourcesForNodeClass returns a copy of the resources on .spec.resourcesByNodeClass for the node class of the
+// OneAgent pods, falling back to .spec.resources if there is no matching class.
+func resourcesForNodeClass(spec *dynatracev1alpha1.OneAgentSpec) corev1.ResourceRequirements {
+	if class := nodeClass(spec); class != "" {
+		if resources, ok := spec.ResourcesByNodeClass[class]; ok {
+			return *resources.DeepCopy()
+		}
+	}
+	return *spec.Resources.DeepCopy()
+}
+
+// missingNodeClasses returns the node classes on .spec.resourcesByNodeClass without any node labeled with them, sorted
+// by name.
+func missingNodeClasses(spec *dynatracev1alpha1.OneAgentSpec, nodes []corev1.Node) []string {
+	found := map[string]bool{}
+	for _, node := range nodes {
+		if class, ok := node.Labels[spec.NodeClassLabel]; ok {
+			found[class] = true
+		}
+	}
+
+	var missing []string
+	for class := range spec.ResourcesByNodeClass {
+		if !found[class] {
+			missing = append(missing, class)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// checkNodeClasses warns about the node classes on .spec.resourcesByNodeClass no node belongs to, most likely because
+// of a typo in the class or in .spec.nodeClassLabel.
+func (r *ReconcileOneAgent) checkNodeClasses(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) error {
+	spec := instance.GetOneAgentSpec()
+	if len(spec.ResourcesByNodeClass) == 0 || spec.NodeClassLabel == "" {
+		return nil
+	}
+
+	var nodeList corev1.NodeList
+	if err := r.client.List(context.TODO(), &nodeList); err != nil {
+		return err
+	}
+
+	if missing := missingNodeClasses(spec, nodeList.Items); len(missing) > 0 {
+		logger.Info("No nodes found for classes on .spec.resourcesByNodeClass", "label", spec.NodeClassLabel, "classes", missing)
+	}
+	return nil
+}
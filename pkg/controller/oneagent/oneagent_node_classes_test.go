@@ -0,0 +1,73 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResourcesForNodeClass(t *testing.T) {
+	const label = "node.kubernetes.io/instance-type"
+
+	newSpec := func(nodeSelector map[string]string) *dynatracev1alpha1.OneAgentSpec {
+		return &dynatracev1alpha1.OneAgentSpec{
+			NodeSelector:   nodeSelector,
+			NodeClassLabel: label,
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			},
+			ResourcesByNodeClass: map[string]corev1.ResourceRequirements{
+				"m5.8xlarge": {Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")}},
+			},
+		}
+	}
+
+	t.Run("matching class", func(t *testing.T) {
+		spec := newSpec(map[string]string{label: "m5.8xlarge"})
+		assert.Equal(t, resource.MustParse("2Gi"), resourcesForNodeClass(spec).Limits[corev1.ResourceMemory])
+
+		oa := newOneAgent()
+		oa.Spec = *spec
+		podSpec := newPodSpecForCR(oa, false, consoleLogger)
+		assert.Equal(t, resource.MustParse("2Gi"), podSpec.Containers[0].Resources.Limits[corev1.ResourceMemory])
+		assert.NotContains(t, spec.ResourcesByNodeClass["m5.8xlarge"].Requests, corev1.ResourceCPU, "spec not modified")
+	})
+
+	t.Run("default for unknown class", func(t *testing.T) {
+		spec := newSpec(map[string]string{label: "m5.large"})
+		assert.Equal(t, resource.MustParse("512Mi"), resourcesForNodeClass(spec).Limits[corev1.ResourceMemory])
+	})
+
+	t.Run("default for single DaemonSet", func(t *testing.T) {
+		spec := newSpec(map[string]string{"kubernetes.io/os": "linux"})
+		assert.Equal(t, resource.MustParse("512Mi"), resourcesForNodeClass(spec).Limits[corev1.ResourceMemory])
+
+		spec = newSpec(nil)
+		assert.Equal(t, resource.MustParse("512Mi"), resourcesForNodeClass(spec).Limits[corev1.ResourceMemory])
+	})
+}
+
+func TestNodeClassesValidation(t *testing.T) {
+	spec := &dynatracev1alpha1.OneAgentSpec{
+		NodeClassLabel: "pool",
+		ResourcesByNodeClass: map[string]corev1.ResourceRequirements{
+			"large":  {},
+			"xlarge": {},
+			"small":  {},
+		},
+	}
+	nodes := []corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"pool": "large"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node-2", Labels: map[string]string{"other": "small"}}},
+	}
+	assert.Equal(t, []string{"small", "xlarge"}, missingNodeClasses(spec, nodes))
+
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.ResourcesByNodeClass = spec.ResourcesByNodeClass
+	assert.EqualError(t, validate(oa), ".spec.resourcesByNodeClass requires .spec.nodeClassLabel to be set")
+}
@@ -0,0 +1,183 @@
+package oneagent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// labelNodeOverride is set on the DaemonSets and pods of a node override, with the name of the override
+const labelNodeOverride = "oneagent.dynatrace.com/node-override"
+
+// validateNodeOverrides returns the issues found on .spec.nodeOverrides
+func validateNodeOverrides(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	if len(spec.NodeOverrides) == 0 {
+		return nil
+	}
+
+	var msg []string
+	if spec.DeploymentType == dynatracev1alpha1.DeploymentTypeDeployment {
+		msg = append(msg, ".spec.nodeOverrides is not supported with deployment type Deployment")
+	}
+
+	seen := map[string]bool{}
+	for i, o := range spec.NodeOverrides {
+		if o.Name == "" {
+			msg = append(msg, fmt.Sprintf(".spec.nodeOverrides[%d] has no name", i))
+		} else if errs := validation.IsDNS1123Label(o.Name); len(errs) > 0 {
+			msg = append(msg, fmt.Sprintf(".spec.nodeOverrides[%d] has invalid name %q", i, o.Name))
+		} else if seen[o.Name] {
+			msg = append(msg, fmt.Sprintf(".spec.nodeOverrides[%d] has duplicate name %q", i, o.Name))
+		}
+		seen[o.Name] = true
+
+		if len(o.NodeSelector) == 0 {
+			msg = append(msg, fmt.Sprintf(".spec.nodeOverrides[%d] has no node selector", i))
+		}
+	}
+	return msg
+}
+
+// excludeNodeOverrides returns the node selector terms restricted to the nodes not matching any of the overrides. A
+// node is left out if it matches all labels of an override's selector, so every term is split into one term per label,
+// requiring the node to differ on that label.
+func excludeNodeOverrides(terms []corev1.NodeSelectorTerm, overrides []dynatracev1alpha1.NodeOverride) []corev1.NodeSelectorTerm {
+	for _, o := range overrides {
+		keys := make([]string, 0, len(o.NodeSelector))
+		for k := range o.NodeSelector {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var expanded []corev1.NodeSelectorTerm
+		for _, term := range terms {
+			for _, k := range keys {
+				t := *term.DeepCopy()
+				t.MatchExpressions = append(t.MatchExpressions, corev1.NodeSelectorRequirement{
+					Key:      k,
+					Operator: corev1.NodeSelectorOpNotIn,
+					Values:   []string{o.NodeSelector[k]},
+				})
+				expanded = append(expanded, t)
+			}
+		}
+		terms = expanded
+	}
+	return terms
+}
+
+// nodeOverrideName returns the name of the DaemonSet of the override
+func nodeOverrideName(instance metav1.Object, o dynatracev1alpha1.NodeOverride) string {
+	return fmt.Sprintf("%s-%s", instance.GetName(), o.Name)
+}
+
+// newNodeOverrideDaemonSets returns the DaemonSets for .spec.nodeOverrides. Each of them runs the pods of the instance
+// with the arguments and resources of the override, on the nodes matching its selector but none of the overrides
+// before it.
+//
+// The pods keep the labels of the instance, so they're listed together with the pods of the main DaemonSet. They're
+// still not adopted by it, since they're controlled by their own DaemonSet.
+func newNodeOverrideDaemonSets(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) ([]*appsv1.DaemonSet, error) {
+	overrides := instance.GetOneAgentSpec().NodeOverrides
+
+	var dss []*appsv1.DaemonSet
+	for i, o := range overrides {
+		scoped, ok := instance.DeepCopyObject().(dynatracev1alpha1.BaseOneAgentDaemonSet)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %T", instance)
+		}
+
+		spec := scoped.GetOneAgentSpec()
+		spec.Args = append(spec.Args, o.Args...)
+		if o.Resources != nil {
+			spec.Resources = *o.Resources.DeepCopy()
+			spec.ResourcesByNodeClass = nil
+		}
+		spec.NodeSelector = mergeLabels(spec.NodeSelector, o.NodeSelector)
+		spec.NodeOverrides = overrides[:i]
+
+		ds, err := newDaemonSetForCR(logger, scoped)
+		if err != nil {
+			return nil, err
+		}
+
+		ds.Name = nodeOverrideName(instance, o)
+		ds.Labels[labelNodeOverride] = o.Name
+		ds.Spec.Selector.MatchLabels[labelNodeOverride] = o.Name
+		ds.Spec.Template.Labels = mergeLabels(ds.Spec.Template.Labels, map[string]string{labelNodeOverride: o.Name})
+		if err := updateTemplateHash(ds); err != nil {
+			return nil, err
+		}
+		dss = append(dss, ds)
+	}
+	return dss, nil
+}
+
+// reconcileNodeOverrides creates or updates the DaemonSets for .spec.nodeOverrides, and deletes the ones of overrides
+// that have been removed.
+func (r *ReconcileOneAgent) reconcileNodeOverrides(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) error {
+	dss, err := newNodeOverrideDaemonSets(logger, r.imageVersionInstance(logger, instance))
+	if err != nil {
+		return err
+	}
+
+	desired := map[string]bool{}
+	for _, ds := range dss {
+		desired[ds.Name] = true
+
+		if err := r.addExternalConfig(instance, ds); err != nil {
+			return err
+		}
+		if err := controllerutil.SetControllerReference(instance, ds, r.scheme); err != nil {
+			return err
+		}
+		if err := r.reconcileDaemonSet(logger.WithValues("override", ds.Labels[labelNodeOverride]), instance, ds); err != nil {
+			return err
+		}
+	}
+
+	stale, err := r.listNodeOverrideDaemonSets(instance)
+	if err != nil {
+		return err
+	}
+	for i := range stale {
+		ds := &stale[i]
+		if desired[ds.Name] {
+			continue
+		}
+		logger.Info("Deleting daemonset of removed node override", "override", ds.Labels[labelNodeOverride])
+		if err := r.client.Delete(context.TODO(), ds); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// listNodeOverrideDaemonSets returns the node override DaemonSets controlled by the instance
+func (r *ReconcileOneAgent) listNodeOverrideDaemonSets(instance dynatracev1alpha1.BaseOneAgentDaemonSet) ([]appsv1.DaemonSet, error) {
+	var list appsv1.DaemonSetList
+	if err := r.client.List(context.TODO(), &list,
+		client.InNamespace(instance.GetNamespace()),
+		client.MatchingLabels(buildLabels(instance.GetName())),
+	); err != nil {
+		return nil, err
+	}
+
+	var dss []appsv1.DaemonSet
+	for _, ds := range list.Items {
+		if _, ok := ds.Labels[labelNodeOverride]; ok && metav1.IsControlledBy(&ds, instance) {
+			dss = append(dss, ds)
+		}
+	}
+	return dss, nil
+}
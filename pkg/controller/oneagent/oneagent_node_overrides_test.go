@@ -0,0 +1,111 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewNodeOverrideDaemonSets(t *testing.T) {
+	gpuResources := corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+	}
+
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.Args = []string{"--set-host-group=default"}
+	oa.Spec.NodeSelector = map[string]string{"pool": "workers"}
+	oa.Spec.NodeOverrides = []dynatracev1alpha1.NodeOverride{
+		{
+			Name:         "gpu",
+			NodeSelector: map[string]string{"accelerator": "nvidia"},
+			Args:         []string{"--set-host-property=gpu=true"},
+			Resources:    &gpuResources,
+		},
+		{
+			Name:         "large",
+			NodeSelector: map[string]string{"size": "large"},
+		},
+	}
+
+	dss, err := newNodeOverrideDaemonSets(consoleLogger, oa)
+	require.NoError(t, err)
+	require.Len(t, dss, 2)
+
+	t.Run("override arguments and resources", func(t *testing.T) {
+		ds := dss[0]
+		assert.Equal(t, "my-oneagent-gpu", ds.Name)
+		assert.Equal(t, "my-namespace", ds.Namespace)
+		assert.Equal(t, map[string]string{"dynatrace": "oneagent", "oneagent": "my-oneagent", labelNodeOverride: "gpu"}, ds.Spec.Selector.MatchLabels)
+		assert.Equal(t, "gpu", ds.Spec.Template.Labels[labelNodeOverride])
+
+		podSpec := ds.Spec.Template.Spec
+		assert.Equal(t, map[string]string{"pool": "workers", "accelerator": "nvidia"}, podSpec.NodeSelector)
+		assert.Contains(t, podSpec.Containers[0].Args, "--set-host-group=default")
+		assert.Contains(t, podSpec.Containers[0].Args, "--set-host-property=gpu=true")
+		assert.Equal(t, resource.MustParse("2Gi"), podSpec.Containers[0].Resources.Limits[corev1.ResourceMemory])
+
+		for _, term := range podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+			for _, expr := range term.MatchExpressions {
+				assert.NotEqual(t, corev1.NodeSelectorOpNotIn, expr.Operator, "first override excludes no nodes")
+			}
+		}
+	})
+
+	t.Run("later overrides exclude the nodes of earlier ones", func(t *testing.T) {
+		ds := dss[1]
+		assert.Equal(t, "my-oneagent-large", ds.Name)
+		assert.NotContains(t, ds.Spec.Template.Spec.Containers[0].Args, "--set-host-property=gpu=true")
+
+		gpuNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+			"pool": "workers", "size": "large", "accelerator": "nvidia", "kubernetes.io/arch": "amd64", "kubernetes.io/os": "linux",
+		}}}
+		assert.False(t, isNodeSelected(&ds.Spec.Template.Spec, gpuNode))
+
+		delete(gpuNode.Labels, "accelerator")
+		assert.True(t, isNodeSelected(&ds.Spec.Template.Spec, gpuNode))
+	})
+
+	t.Run("main daemonset excludes override nodes", func(t *testing.T) {
+		ds, err := newDaemonSetForCR(consoleLogger, oa)
+		require.NoError(t, err)
+		assert.Equal(t, "my-oneagent", ds.Name)
+		assert.Equal(t, buildLabels("my-oneagent"), ds.Spec.Selector.MatchLabels)
+
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+			"pool": "workers", "kubernetes.io/arch": "amd64", "kubernetes.io/os": "linux",
+		}}}
+		assert.True(t, isNodeSelected(&ds.Spec.Template.Spec, node))
+
+		node.Labels["accelerator"] = "nvidia"
+		assert.False(t, isNodeSelected(&ds.Spec.Template.Spec, node))
+
+		node.Labels["accelerator"] = "amd"
+		node.Labels["size"] = "large"
+		assert.False(t, isNodeSelected(&ds.Spec.Template.Spec, node))
+	})
+
+	assert.Equal(t, []string{"--set-host-group=default"}, oa.Spec.Args, "instance left unchanged")
+}
+
+func TestValidateNodeOverrides(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.NodeOverrides = []dynatracev1alpha1.NodeOverride{{Name: "gpu", NodeSelector: map[string]string{"accelerator": "nvidia"}}}
+	assert.NoError(t, validate(oa))
+
+	oa.Spec.NodeOverrides = append(oa.Spec.NodeOverrides,
+		dynatracev1alpha1.NodeOverride{Name: "gpu", NodeSelector: map[string]string{"size": "large"}},
+		dynatracev1alpha1.NodeOverride{Name: "Large_Nodes"},
+	)
+	assert.EqualError(t, validate(oa), `.spec.nodeOverrides[1] has duplicate name "gpu", .spec.nodeOverrides[2] has invalid name "Large_Nodes", .spec.nodeOverrides[2] has no node selector`)
+
+	oa.Spec.NodeOverrides = oa.Spec.NodeOverrides[:1]
+	oa.Spec.DeploymentType = dynatracev1alpha1.DeploymentTypeDeployment
+	assert.EqualError(t, validate(oa), ".spec.nodeOverrides is not supported with deployment type Deployment")
+}
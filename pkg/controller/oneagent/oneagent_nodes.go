@@ -0,0 +1,290 @@
+package oneagent
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// nodeSelectorOperators maps the operators of node selector requirements to label selector operators
+var nodeSelectorOperators = map[corev1.NodeSelectorOperator]selection.Operator{
+	corev1.NodeSelectorOpIn:           selection.In,
+	corev1.NodeSelectorOpNotIn:        selection.NotIn,
+	corev1.NodeSelectorOpExists:       selection.Exists,
+	corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	corev1.NodeSelectorOpGt:           selection.GreaterThan,
+	corev1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+// scaleDownTaint is set by the cluster autoscaler on nodes it's about to remove
+const scaleDownTaint = "ToBeDeletedByClusterAutoscaler"
+
+// reconcileUnmonitoredNodes records on the status the nodes which are selected by the OneAgent DaemonSet, but have
+// no running OneAgent pod, e.g. because they are cordoned or tainted. The UnmonitoredNodes condition is set as a
+// warning while the list is not empty. Nodes being scaled down are left out if .spec.ignoreScaleDownNodes is set.
+//
+// Returns true if the status has changed.
+func (r *ReconcileOneAgent) reconcileUnmonitoredNodes(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, pods []corev1.Pod) (bool, error) {
+	ds := &appsv1.DaemonSet{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: daemonSetName(instance), Namespace: instance.GetNamespace()}, ds); k8serrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	var nodeList corev1.NodeList
+	if err := r.client.List(context.TODO(), &nodeList); err != nil {
+		return false, err
+	}
+
+	monitored := map[string]bool{}
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodRunning {
+			monitored[pod.Spec.NodeName] = true
+		}
+	}
+
+	ignoreScaleDown := instance.GetOneAgentSpec().IgnoreScaleDownNodes
+
+	var unmonitored []string
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		if ignoreScaleDown && isScalingDown(node) {
+			continue
+		}
+		if !monitored[node.Name] && isNodeSelected(&ds.Spec.Template.Spec, node) {
+			unmonitored = append(unmonitored, node.Name)
+		}
+	}
+	sort.Strings(unmonitored)
+
+	oaStatus := instance.GetOneAgentStatus()
+	updateCR := false
+	if !reflect.DeepEqual(oaStatus.UnmonitoredNodes, unmonitored) {
+		oaStatus.UnmonitoredNodes = unmonitored
+		updateCR = true
+	}
+
+	if len(unmonitored) == 0 {
+		if oaStatus.Conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.UnmonitoredNodesConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonAllNodesMonitored,
+			Message: "All selected nodes have a running OneAgent pod",
+		}) {
+			updateCR = true
+		}
+		return updateCR, nil
+	}
+
+	if oaStatus.Conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.UnmonitoredNodesConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonNodesUnmonitored,
+		Message: fmt.Sprintf("Nodes without running OneAgent pod: %s", strings.Join(unmonitored, ", ")),
+	}) {
+		logger.Info("nodes without running OneAgent pod found", "nodes", unmonitored)
+		updateCR = true
+	}
+	return updateCR, nil
+}
+
+const (
+	// monitoredNodeLabel is the label set on nodes running a ready OneAgent pod if .spec.labelMonitoredNodes is enabled
+	monitoredNodeLabel      = "dynatrace.com/oneagent"
+	monitoredNodeLabelValue = "ready"
+)
+
+// reconcileMonitoredNodeLabels sets the monitoredNodeLabel on the nodes with a ready OneAgent pod, and removes it from
+// the nodes selected by the OneAgent DaemonSet which don't have one.
+//
+// Patching the nodes requires the "patch" verb on "nodes", which the operator's ClusterRole and the OLM bundles grant
+// in addition to the "get", "list" and "watch" verbs needed to read them.
+func (r *ReconcileOneAgent) reconcileMonitoredNodeLabels(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, pods []corev1.Pod) error {
+	ds := &appsv1.DaemonSet{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: daemonSetName(instance), Namespace: instance.GetNamespace()}, ds); k8serrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var nodeList corev1.NodeList
+	if err := r.client.List(context.TODO(), &nodeList); err != nil {
+		return err
+	}
+
+	ready := map[string]bool{}
+	for i := range pods {
+		if pods[i].Status.Phase == corev1.PodRunning && getPodReadyState(&pods[i]) {
+			ready[pods[i].Spec.NodeName] = true
+		}
+	}
+
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		_, labeled := node.Labels[monitoredNodeLabel]
+
+		var patch client.Patch
+		if ready[node.Name] && node.Labels[monitoredNodeLabel] != monitoredNodeLabelValue {
+			patch = client.MergeFrom(node.DeepCopy())
+			if node.Labels == nil {
+				node.Labels = map[string]string{}
+			}
+			node.Labels[monitoredNodeLabel] = monitoredNodeLabelValue
+		} else if !ready[node.Name] && labeled && isNodeSelected(&ds.Spec.Template.Spec, node) {
+			patch = client.MergeFrom(node.DeepCopy())
+			delete(node.Labels, monitoredNodeLabel)
+		} else {
+			continue
+		}
+
+		if err := r.client.Patch(context.TODO(), node, patch); err != nil {
+			return fmt.Errorf("failed to update label on node %s: %w", node.Name, err)
+		}
+		logger.Info("updated label on node", "node", node.Name, "label", monitoredNodeLabel, "ready", ready[node.Name])
+	}
+
+	return nil
+}
+
+// findScaleDownNodes returns the names of the nodes being scaled down if .spec.ignoreScaleDownNodes is set, or nil
+// otherwise.
+func (r *ReconcileOneAgent) findScaleDownNodes(instance dynatracev1alpha1.BaseOneAgentDaemonSet) (map[string]bool, error) {
+	if !instance.GetOneAgentSpec().IgnoreScaleDownNodes {
+		return nil, nil
+	}
+
+	var nodeList corev1.NodeList
+	if err := r.client.List(context.TODO(), &nodeList); err != nil {
+		return nil, err
+	}
+
+	nodes := map[string]bool{}
+	for i := range nodeList.Items {
+		if isScalingDown(&nodeList.Items[i]) {
+			nodes[nodeList.Items[i].Name] = true
+		}
+	}
+	return nodes, nil
+}
+
+// isScalingDown returns true if the node carries the taint of the cluster autoscaler for nodes about to be removed.
+func isScalingDown(node *corev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == scaleDownTaint {
+			return true
+		}
+	}
+	return false
+}
+
+// ungroupedZone is the group on .status.instancesByZone for the instances on nodes without the grouping label. It's
+// not a valid label value, so it can't collide with the value of a zone.
+const ungroupedZone = "<none>"
+
+// reconcileInstancesByZone groups the nodes on .status.instances by the value of their .spec.instancesGroupingLabel
+// label into .status.instancesByZone, sorted by node name. The grouping is removed if no label is set.
+//
+// Returns true if the status has changed.
+func (r *ReconcileOneAgent) reconcileInstancesByZone(instance dynatracev1alpha1.BaseOneAgentDaemonSet) (bool, error) {
+	oaStatus := instance.GetOneAgentStatus()
+
+	label := instance.GetOneAgentSpec().InstancesGroupingLabel
+	if label == "" {
+		if oaStatus.InstancesByZone == nil {
+			return false, nil
+		}
+		oaStatus.InstancesByZone = nil
+		return true, nil
+	}
+
+	var nodeList corev1.NodeList
+	if err := r.client.List(context.TODO(), &nodeList); err != nil {
+		return false, err
+	}
+
+	zones := map[string]string{}
+	for _, node := range nodeList.Items {
+		if zone, ok := node.Labels[label]; ok && zone != "" {
+			zones[node.Name] = zone
+		}
+	}
+
+	var byZone map[string][]string
+	for nodeName := range oaStatus.Instances {
+		zone, ok := zones[nodeName]
+		if !ok {
+			zone = ungroupedZone
+		}
+		if byZone == nil {
+			byZone = map[string][]string{}
+		}
+		byZone[zone] = append(byZone[zone], nodeName)
+	}
+	for _, nodeNames := range byZone {
+		sort.Strings(nodeNames)
+	}
+
+	if reflect.DeepEqual(oaStatus.InstancesByZone, byZone) {
+		return false, nil
+	}
+	oaStatus.InstancesByZone = byZone
+	return true, nil
+}
+
+// isNodeSelected returns true if the node matches the node selector and the required node affinity of the pod spec.
+func isNodeSelected(podSpec *corev1.PodSpec, node *corev1.Node) bool {
+	nodeLabels := labels.Set(node.Labels)
+
+	if !labels.SelectorFromSet(podSpec.NodeSelector).Matches(nodeLabels) {
+		return false
+	}
+
+	if podSpec.Affinity == nil || podSpec.Affinity.NodeAffinity == nil ||
+		podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true
+	}
+
+	// Terms are ORed, the requirements of a term are ANDed.
+	for _, term := range podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if matchesNodeSelectorTerm(term, nodeLabels) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesNodeSelectorTerm(term corev1.NodeSelectorTerm, nodeLabels labels.Set) bool {
+	if len(term.MatchExpressions) == 0 {
+		return false
+	}
+
+	selector := labels.NewSelector()
+	for _, expr := range term.MatchExpressions {
+		op, ok := nodeSelectorOperators[expr.Operator]
+		if !ok {
+			return false
+		}
+
+		req, err := labels.NewRequirement(expr.Key, op, expr.Values)
+		if err != nil {
+			return false
+		}
+		selector = selector.Add(*req)
+	}
+
+	return selector.Matches(nodeLabels)
+}
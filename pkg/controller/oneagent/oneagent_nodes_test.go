@@ -0,0 +1,309 @@
+package oneagent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileUnmonitoredNodes(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+			NodeSelector: map[string]string{"monitoring": "enabled"},
+		},
+	}
+
+	ds, err := newDaemonSetForCR(consoleLogger, oa)
+	require.NoError(t, err)
+
+	newNode := func(name, os string, extraLabels map[string]string) *corev1.Node {
+		nodeLabels := map[string]string{"kubernetes.io/os": os, "kubernetes.io/arch": "amd64"}
+		for k, v := range extraLabels {
+			nodeLabels[k] = v
+		}
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nodeLabels}}
+	}
+
+	selected := map[string]string{"monitoring": "enabled"}
+	cordoned := newNode("node-2", "linux", selected)
+	cordoned.Spec.Unschedulable = true
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme,
+		ds,
+		newNode("node-1", "linux", selected),
+		cordoned,
+		newNode("node-3", "windows", selected),
+		newNode("node-4", "linux", nil))
+
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	pods := []corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent-1", Namespace: namespace, Labels: buildLabels(oaName)},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}}
+
+	updateCR, err := reconciler.reconcileUnmonitoredNodes(consoleLogger, oa, pods)
+	assert.NoError(t, err)
+	assert.True(t, updateCR)
+	assert.Equal(t, []string{"node-2"}, oa.Status.UnmonitoredNodes)
+
+	cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.UnmonitoredNodesConditionType)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonNodesUnmonitored, cond.Reason)
+		assert.Equal(t, "Nodes without running OneAgent pod: node-2", cond.Message)
+	}
+
+	// No changes on subsequent reconciliations
+	updateCR, err = reconciler.reconcileUnmonitoredNodes(consoleLogger, oa, pods)
+	assert.NoError(t, err)
+	assert.False(t, updateCR)
+
+	// Pod scheduled after the node got uncordoned
+	pods = append(pods, corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent-2", Namespace: namespace, Labels: buildLabels(oaName)},
+		Spec:       corev1.PodSpec{NodeName: "node-2"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	updateCR, err = reconciler.reconcileUnmonitoredNodes(consoleLogger, oa, pods)
+	assert.NoError(t, err)
+	assert.True(t, updateCR)
+	assert.Empty(t, oa.Status.UnmonitoredNodes)
+
+	cond = oa.Status.Conditions.GetCondition(dynatracev1alpha1.UnmonitoredNodesConditionType)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, corev1.ConditionFalse, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonAllNodesMonitored, cond.Reason)
+	}
+}
+
+func TestReconcileInstanceStatuses_IgnoreScaleDownNodes(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+			IgnoreScaleDownNodes: true,
+		},
+	}
+	lastSeen := metav1.NewTime(time.Unix(1521540000, 0))
+	oa.Status.Instances = map[string]dynatracev1alpha1.OneAgentInstance{
+		"node-2": {PodName: "oneagent-2", Version: "1.202.0.20200808-120956", LastSeen: &lastSeen, CommunicationOk: true},
+	}
+
+	ds, err := newDaemonSetForCR(consoleLogger, oa)
+	require.NoError(t, err)
+
+	newNode := func(name string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"kubernetes.io/os": "linux", "kubernetes.io/arch": "amd64"}}}
+	}
+	scalingDown := newNode("node-2")
+	scalingDown.Spec.Taints = []corev1.Taint{{Key: scaleDownTaint, Effect: corev1.TaintEffectNoSchedule}}
+	scalingDownNoPod := newNode("node-3")
+	scalingDownNoPod.Spec.Taints = scalingDown.Spec.Taints
+
+	newPod := func(name, node, hostIP string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: buildLabels(oaName)},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Phase: phase, HostIP: hostIP},
+		}
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme,
+		ds,
+		newNode("node-1"),
+		scalingDown,
+		scalingDownNoPod,
+		newPod("oneagent-1", "node-1", "10.0.0.1", corev1.PodRunning),
+		newPod("oneagent-2", "node-2", "10.0.0.2", corev1.PodPending))
+
+	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetAgentVersionForIP", "10.0.0.1").Return("1.203.0.20200908-220956", nil)
+	dtcMock.On("GetMonitoringModeForIP", "10.0.0.1").Return(dtclient.MonitoringModeFullStack, nil)
+	dtcMock.On("GetLastSeenForIP", "10.0.0.1").Return(time.Unix(1521540000, 0), nil)
+
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	_, err = reconciler.reconcileInstanceStatuses(consoleLogger, oa, dtcMock)
+	require.NoError(t, err)
+	mock.AssertExpectationsForObjects(t, dtcMock)
+
+	assert.Len(t, oa.Status.Instances, 2)
+	assert.Equal(t, "1.203.0.20200908-220956", oa.Status.Instances["node-1"].Version)
+	assert.Equal(t, dynatracev1alpha1.OneAgentInstance{
+		PodName: "oneagent-2", Version: "1.202.0.20200808-120956", LastSeen: &lastSeen, CommunicationOk: true,
+	}, oa.Status.Instances["node-2"], "last known status kept")
+
+	assert.Empty(t, oa.Status.UnmonitoredNodes)
+	assert.True(t, oa.Status.Conditions.IsFalseFor(dynatracev1alpha1.UnmonitoredNodesConditionType))
+
+	t.Run("reported if not ignored", func(t *testing.T) {
+		oa.Spec.IgnoreScaleDownNodes = false
+		dtcMock.On("GetAgentVersionForIP", "10.0.0.2").Return("", errors.New("host not found"))
+		dtcMock.On("GetMonitoringModeForIP", "10.0.0.2").Return("", errors.New("host not found"))
+		dtcMock.On("GetLastSeenForIP", "10.0.0.2").Return(time.Time{}, dtclient.ErrHostNotFound)
+
+		_, err = reconciler.reconcileInstanceStatuses(consoleLogger, oa, dtcMock)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"node-2", "node-3"}, oa.Status.UnmonitoredNodes)
+		assert.False(t, oa.Status.Instances["node-2"].CommunicationOk)
+	})
+}
+
+func TestReconcileMonitoredNodeLabels(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+			LabelMonitoredNodes: true,
+		},
+	}
+
+	ds, err := newDaemonSetForCR(consoleLogger, oa)
+	require.NoError(t, err)
+
+	newNode := func(name string, extraLabels map[string]string) *corev1.Node {
+		nodeLabels := map[string]string{"kubernetes.io/os": "linux", "kubernetes.io/arch": "amd64"}
+		for k, v := range extraLabels {
+			nodeLabels[k] = v
+		}
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nodeLabels}}
+	}
+
+	labeled := map[string]string{monitoredNodeLabel: monitoredNodeLabelValue}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, ds, newNode("node-1", nil), newNode("node-2", labeled))
+
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	newPod := func(node string, ready bool) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "oneagent-" + node, Namespace: namespace, Labels: buildLabels(oaName)},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Name: oneAgentContainerName, Ready: ready}},
+			},
+		}
+	}
+
+	nodeLabel := func(name string) (string, bool) {
+		var node corev1.Node
+		require.NoError(t, c.Get(context.TODO(), client.ObjectKey{Name: name}, &node))
+		v, ok := node.Labels[monitoredNodeLabel]
+		return v, ok
+	}
+
+	require.NoError(t, reconciler.reconcileMonitoredNodeLabels(consoleLogger, oa, []corev1.Pod{newPod("node-1", true), newPod("node-2", false)}))
+
+	v, ok := nodeLabel("node-1")
+	assert.True(t, ok, "label set on node with ready pod")
+	assert.Equal(t, monitoredNodeLabelValue, v)
+	_, ok = nodeLabel("node-2")
+	assert.False(t, ok, "label removed from node without ready pod")
+
+	require.NoError(t, reconciler.reconcileMonitoredNodeLabels(consoleLogger, oa, []corev1.Pod{newPod("node-2", true)}))
+
+	_, ok = nodeLabel("node-1")
+	assert.False(t, ok, "label removed once the pod is gone")
+	_, ok = nodeLabel("node-2")
+	assert.True(t, ok, "label set once the pod is ready")
+}
+
+func TestReconcileInstancesByZone(t *testing.T) {
+	const zoneLabel = "topology.kubernetes.io/zone"
+
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent", Namespace: "dynatrace"},
+		Spec:       dynatracev1alpha1.OneAgentSpec{InstancesGroupingLabel: zoneLabel},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme,
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a1", Labels: map[string]string{zoneLabel: "zone-a"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a2", Labels: map[string]string{zoneLabel: "zone-a"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b1", Labels: map[string]string{zoneLabel: "zone-b"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-other"}},
+	)
+	reconciler := &ReconcileOneAgent{client: c, apiReader: c, scheme: scheme.Scheme, logger: consoleLogger}
+
+	var pods []corev1.Pod
+	for i, node := range []string{"node-a2", "node-b1", "node-a1", "node-other"} {
+		pods = append(pods, corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "oneagent-" + node},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{HostIP: fmt.Sprintf("10.0.0.%d", i+1)},
+		})
+	}
+
+	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetAgentVersionForIP", mock.Anything).Return("1.203.0.20200908-220956", nil)
+	dtcMock.On("GetMonitoringModeForIP", mock.Anything).Return("fullstack", nil)
+	dtcMock.On("GetLastSeenForIP", mock.Anything).Return(time.Now(), nil)
+
+	instances, err := getInstanceStatuses(pods, dtcMock, oa)
+	require.NoError(t, err)
+	oa.Status.Instances = instances
+
+	upd, err := reconciler.reconcileInstancesByZone(oa)
+	require.NoError(t, err)
+	assert.True(t, upd)
+	assert.Equal(t, map[string][]string{
+		"zone-a":      {"node-a1", "node-a2"},
+		"zone-b":      {"node-b1"},
+		ungroupedZone: {"node-other"},
+	}, oa.Status.InstancesByZone)
+	assert.Len(t, oa.Status.Instances, 4, "flat instances kept")
+
+	upd, err = reconciler.reconcileInstancesByZone(oa)
+	require.NoError(t, err)
+	assert.False(t, upd)
+
+	oa.Spec.InstancesGroupingLabel = ""
+	upd, err = reconciler.reconcileInstancesByZone(oa)
+	require.NoError(t, err)
+	assert.True(t, upd)
+	assert.Nil(t, oa.Status.InstancesByZone)
+}
@@ -0,0 +1,79 @@
+package oneagent
+
+import (
+	"context"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// legacyPodLabels returns the labels shared by the OneAgent pods of the current and all previous DaemonSets of an
+// instance, regardless of their selector.
+func legacyPodLabels(name string) map[string]string {
+	return map[string]string{"oneagent": name}
+}
+
+// reconcileOrphanedPods deletes OneAgent pods left behind by a previous DaemonSet of the instance, e.g. after it got
+// recreated with orphan cascade. An orphaned pod is only deleted once the current DaemonSet has a running and ready
+// replacement on the same node, so that nodes don't go unmonitored in the meantime.
+func (r *ReconcileOneAgent) reconcileOrphanedPods(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) error {
+	ds := &appsv1.DaemonSet{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: daemonSetName(instance), Namespace: instance.GetNamespace()}, ds); k8serrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	podList := &corev1.PodList{}
+	listOps := []client.ListOption{
+		client.InNamespace(instance.GetNamespace()),
+		client.MatchingLabels(legacyPodLabels(instance.GetName())),
+	}
+	if err := r.client.List(context.TODO(), podList, listOps...); err != nil {
+		handlePodListError(logger, err, listOps)
+		return err
+	}
+
+	replaced := map[string]bool{}
+	var orphans []corev1.Pod
+	for _, pod := range podList.Items {
+		if owner := metav1.GetControllerOf(&pod); owner != nil && owner.UID == ds.UID {
+			if pod.Status.Phase == corev1.PodRunning && getPodReadyState(&pod) {
+				replaced[pod.Spec.NodeName] = true
+			}
+		} else if isOrphanedPod(&pod, ds) {
+			orphans = append(orphans, pod)
+		}
+	}
+
+	for i := range orphans {
+		pod := &orphans[i]
+		if !replaced[pod.Spec.NodeName] {
+			logger.Info("keeping orphaned pod until its replacement is ready", "pod", pod.Name, "node", pod.Spec.NodeName)
+			continue
+		}
+
+		logger.Info("deleting orphaned pod", "pod", pod.Name, "node", pod.Spec.NodeName)
+		if err := r.client.Delete(context.TODO(), pod); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isOrphanedPod returns true if the pod has no controller, or is controlled by an older DaemonSet with the same name
+// as the given one.
+func isOrphanedPod(pod *corev1.Pod, ds *appsv1.DaemonSet) bool {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return true
+	}
+	return owner.Kind == "DaemonSet" && owner.Name == ds.Name && owner.UID != ds.UID
+}
@@ -0,0 +1,78 @@
+package oneagent
+
+import (
+	"context"
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileOrphanedPods(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+	}
+
+	trueVar := true
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace, UID: "current-uid"}}
+	ownedBy := func(uid types.UID) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "DaemonSet", Name: oaName, UID: uid, Controller: &trueVar}}
+	}
+
+	newPod := func(name, node string, podLabels map[string]string, owners []metav1.OwnerReference, ready bool) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: podLabels, OwnerReferences: owners},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: ready}},
+			},
+		}
+	}
+
+	legacyLabels := map[string]string{"oneagent": oaName, "app": "oneagent-legacy"}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme,
+		ds,
+		// Orphaned pod with a healthy replacement on node-1
+		newPod("legacy-1", "node-1", legacyLabels, nil, true),
+		newPod("oneagent-1", "node-1", buildLabels(oaName), ownedBy("current-uid"), true),
+		// Orphaned pod whose replacement on node-2 isn't ready yet
+		newPod("legacy-2", "node-2", legacyLabels, ownedBy("legacy-uid"), true),
+		newPod("oneagent-2", "node-2", buildLabels(oaName), ownedBy("current-uid"), false),
+		// Orphaned pod without replacement on node-3
+		newPod("legacy-3", "node-3", legacyLabels, nil, true))
+
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	assert.NoError(t, reconciler.reconcileOrphanedPods(consoleLogger, oa))
+
+	podExists := func(name string) bool {
+		err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, &corev1.Pod{})
+		if k8serrors.IsNotFound(err) {
+			return false
+		}
+		assert.NoError(t, err)
+		return true
+	}
+
+	assert.False(t, podExists("legacy-1"), "orphaned pod with healthy replacement should be deleted")
+	assert.True(t, podExists("legacy-2"), "orphaned pod with unready replacement should be kept")
+	assert.True(t, podExists("legacy-3"), "orphaned pod without replacement should be kept")
+	assert.True(t, podExists("oneagent-1"))
+	assert.True(t, podExists("oneagent-2"))
+}
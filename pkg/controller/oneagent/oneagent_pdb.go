@@ -0,0 +1,121 @@
+package oneagent
+
+import (
+	"context"
+	"reflect"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// reconcilePodDisruptionBudget creates, updates or removes the PodDisruptionBudget protecting the OneAgent pods,
+// depending on whether .spec.createPodDisruptionBudget is set.
+//
+// The PodDisruptionBudget is owned by the OneAgent object, so it gets garbage collected when the latter is deleted.
+func (r *ReconcileOneAgent) reconcilePodDisruptionBudget(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) error {
+	pdbActual := &policyv1beta1.PodDisruptionBudget{}
+	err := r.client.Get(context.TODO(), client.ObjectKey{Name: instance.GetName(), Namespace: instance.GetNamespace()}, pdbActual)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	if !instance.GetOneAgentSpec().CreatePodDisruptionBudget {
+		if exists && metav1.IsControlledBy(pdbActual, instance) {
+			logger.Info("Deleting PodDisruptionBudget")
+			if err := r.client.Delete(context.TODO(), pdbActual); err != nil && !k8serrors.IsNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	desiredPods, err := r.desiredDaemonSetPods(instance)
+	if err != nil {
+		return err
+	}
+
+	pdbDesired := newPodDisruptionBudgetForCR(instance, desiredPods)
+	if err := controllerutil.SetControllerReference(instance, pdbDesired, r.scheme); err != nil {
+		return err
+	}
+
+	if !exists {
+		logger.Info("Creating new PodDisruptionBudget")
+		return r.client.Create(context.TODO(), pdbDesired)
+	}
+
+	if !reflect.DeepEqual(pdbActual.Spec, pdbDesired.Spec) {
+		logger.Info("Updating existing PodDisruptionBudget")
+		pdbActual.Spec = pdbDesired.Spec
+		return r.client.Update(context.TODO(), pdbActual)
+	}
+
+	return nil
+}
+
+// desiredDaemonSetPods returns the number of OneAgent pods the DaemonSets of the instance should run, including the
+// ones of node overrides.
+func (r *ReconcileOneAgent) desiredDaemonSetPods(instance dynatracev1alpha1.BaseOneAgentDaemonSet) (int32, error) {
+	if isDeploymentMode(instance) {
+		return 0, nil
+	}
+
+	var dsList appsv1.DaemonSetList
+	if err := r.client.List(context.TODO(), &dsList,
+		client.InNamespace(instance.GetNamespace()),
+		client.MatchingLabels(buildLabels(instance.GetName()))); err != nil {
+		return 0, err
+	}
+
+	var desired int32
+	for i := range dsList.Items {
+		if metav1.IsControlledBy(&dsList.Items[i], instance) {
+			desired += dsList.Items[i].Status.DesiredNumberScheduled
+		}
+	}
+	return desired, nil
+}
+
+// newPodDisruptionBudgetForCR returns the PodDisruptionBudget for the OneAgent pods. The disruption controller can
+// only resolve maxUnavailable for pods of workloads with a scale subresource, which DaemonSets don't have, so for
+// DaemonSets the budget gets the number of pods to keep available instead, computed from the desired pods.
+func newPodDisruptionBudgetForCR(instance dynatracev1alpha1.BaseOneAgentDaemonSet, desiredPods int32) *policyv1beta1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+	if mu := instance.GetOneAgentSpec().PodDisruptionBudgetMaxUnavailable; mu != nil {
+		maxUnavailable = *mu
+	}
+
+	spec := policyv1beta1.PodDisruptionBudgetSpec{
+		Selector: &metav1.LabelSelector{MatchLabels: buildLabels(instance.GetName())},
+	}
+
+	if isDeploymentMode(instance) {
+		spec.MaxUnavailable = &maxUnavailable
+	} else {
+		// Errors only occur for malformed percentages, which are then treated as no pod being allowed to be unavailable.
+		unavailable, _ := intstr.GetValueFromIntOrPercent(&maxUnavailable, int(desiredPods), true)
+		minAvailable := int(desiredPods) - unavailable
+		if minAvailable < 0 {
+			minAvailable = 0
+		}
+		ma := intstr.FromInt(minAvailable)
+		spec.MinAvailable = &ma
+	}
+
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.GetName(),
+			Namespace: instance.GetNamespace(),
+			Labels:    buildLabels(instance.GetName()),
+		},
+		Spec: spec,
+	}
+}
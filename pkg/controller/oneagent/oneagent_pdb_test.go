@@ -0,0 +1,95 @@
+package oneagent
+
+import (
+	"context"
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestReconcilePodDisruptionBudget(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	key := types.NamespacedName{Name: oaName, Namespace: namespace}
+
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+			CreatePodDisruptionBudget: true,
+		},
+	}
+
+	// The pods desired by the DaemonSet and the one of a node override make up the pods to keep available.
+	newDaemonSet := func(name string, desired int32) *appsv1.DaemonSet {
+		ds := &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: buildLabels(oaName)},
+			Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: desired},
+		}
+		require.NoError(t, controllerutil.SetControllerReference(oa, ds, scheme.Scheme))
+		return ds
+	}
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, newDaemonSet(oaName, 15), newDaemonSet(oaName+"-gpu", 5))
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	t.Run("PodDisruptionBudget created with default maxUnavailable", func(t *testing.T) {
+		require.NoError(t, reconciler.reconcilePodDisruptionBudget(consoleLogger, oa))
+
+		var pdb policyv1beta1.PodDisruptionBudget
+		require.NoError(t, c.Get(context.TODO(), key, &pdb))
+		assert.Equal(t, buildLabels(oaName), pdb.Spec.Selector.MatchLabels)
+		assert.Nil(t, pdb.Spec.MaxUnavailable)
+		assert.Equal(t, intstr.FromInt(19), *pdb.Spec.MinAvailable)
+		assert.True(t, metav1.IsControlledBy(&pdb, oa))
+	})
+
+	t.Run("PodDisruptionBudget updated with custom maxUnavailable", func(t *testing.T) {
+		maxUnavailable := intstr.FromString("12%")
+		oa.Spec.PodDisruptionBudgetMaxUnavailable = &maxUnavailable
+
+		require.NoError(t, reconciler.reconcilePodDisruptionBudget(consoleLogger, oa))
+
+		var pdb policyv1beta1.PodDisruptionBudget
+		require.NoError(t, c.Get(context.TODO(), key, &pdb))
+		assert.Equal(t, intstr.FromInt(17), *pdb.Spec.MinAvailable, "12% of 20 pods rounded up")
+	})
+
+	t.Run("PodDisruptionBudget keeps maxUnavailable for Deployments", func(t *testing.T) {
+		oa.Spec.DeploymentType = dynatracev1alpha1.DeploymentTypeDeployment
+		defer func() { oa.Spec.DeploymentType = "" }()
+
+		require.NoError(t, reconciler.reconcilePodDisruptionBudget(consoleLogger, oa))
+
+		var pdb policyv1beta1.PodDisruptionBudget
+		require.NoError(t, c.Get(context.TODO(), key, &pdb))
+		assert.Nil(t, pdb.Spec.MinAvailable)
+		assert.Equal(t, *oa.Spec.PodDisruptionBudgetMaxUnavailable, *pdb.Spec.MaxUnavailable)
+	})
+
+	t.Run("PodDisruptionBudget removed when disabled", func(t *testing.T) {
+		oa.Spec.CreatePodDisruptionBudget = false
+
+		require.NoError(t, reconciler.reconcilePodDisruptionBudget(consoleLogger, oa))
+
+		var pdb policyv1beta1.PodDisruptionBudget
+		assert.True(t, k8serrors.IsNotFound(c.Get(context.TODO(), key, &pdb)))
+	})
+}
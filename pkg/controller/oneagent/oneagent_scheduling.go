@@ -0,0 +1,85 @@
+package oneagent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// reconcileSchedulingBlocked sets the SchedulingBlocked condition as a warning while OneAgent pods are pending because
+// the scheduler can't place them, e.g. due to insufficient resources on their nodes. The message lists the affected
+// nodes together with the reason given by the scheduler.
+//
+// Returns true if the condition has changed.
+func reconcileSchedulingBlocked(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, pods []corev1.Pod) bool {
+	blocked := map[string]string{}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != corev1.PodPending {
+			continue
+		}
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable {
+				blocked[podTargetNode(pod)] = c.Message
+			}
+		}
+	}
+
+	conditions := &instance.GetOneAgentStatus().Conditions
+	if len(blocked) == 0 {
+		return conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.SchedulingBlockedConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonPodsScheduled,
+			Message: "No OneAgent pod is blocked from being scheduled",
+		})
+	}
+
+	nodes := make([]string, 0, len(blocked))
+	for node := range blocked {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	details := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		details = append(details, fmt.Sprintf("%s (%s)", node, blocked[node]))
+	}
+
+	if conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.SchedulingBlockedConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonPodsUnschedulable,
+		Message: fmt.Sprintf("OneAgent pods can't be scheduled on nodes: %s", strings.Join(details, ", ")),
+	}) {
+		logger.Info("OneAgent pods can't be scheduled", "nodes", nodes)
+		return true
+	}
+	return false
+}
+
+// podTargetNode returns the node the pod is meant to run on. Pending DaemonSet pods aren't bound to a node yet, but
+// target it through the node affinity on metadata.name set by the DaemonSet controller. Returns the pod name if the
+// node can't be determined.
+func podTargetNode(pod *corev1.Pod) string {
+	if pod.Spec.NodeName != "" {
+		return pod.Spec.NodeName
+	}
+
+	if a := pod.Spec.Affinity; a != nil && a.NodeAffinity != nil && a.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		for _, term := range a.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+			for _, f := range term.MatchFields {
+				if f.Key == "metadata.name" && f.Operator == corev1.NodeSelectorOpIn && len(f.Values) == 1 {
+					return f.Values[0]
+				}
+			}
+		}
+	}
+
+	return pod.Name
+}
@@ -0,0 +1,64 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileSchedulingBlocked(t *testing.T) {
+	oa := &dynatracev1alpha1.OneAgent{}
+
+	running := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent-1"},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	pending := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent-2"},
+		Spec: corev1.PodSpec{
+			Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchFields: []corev1.NodeSelectorRequirement{{
+							Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: []string{"node-2"},
+						}},
+					}},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{{
+				Type:    corev1.PodScheduled,
+				Status:  corev1.ConditionFalse,
+				Reason:  corev1.PodReasonUnschedulable,
+				Message: "0/3 nodes are available: 1 Insufficient memory, 2 node(s) didn't match node selector.",
+			}},
+		},
+	}
+
+	assert.True(t, reconcileSchedulingBlocked(consoleLogger, oa, []corev1.Pod{running, pending}))
+
+	cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.SchedulingBlockedConditionType)
+	require.NotNil(t, cond)
+	assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	assert.Equal(t, dynatracev1alpha1.ReasonPodsUnschedulable, cond.Reason)
+	assert.Equal(t, "OneAgent pods can't be scheduled on nodes: node-2 (0/3 nodes are available: 1 Insufficient memory, 2 node(s) didn't match node selector.)", cond.Message)
+
+	assert.False(t, reconcileSchedulingBlocked(consoleLogger, oa, []corev1.Pod{running, pending}), "no changes")
+
+	// Scheduled once resources got freed.
+	pending.Spec.NodeName = "node-2"
+	pending.Status = corev1.PodStatus{Phase: corev1.PodRunning}
+	assert.True(t, reconcileSchedulingBlocked(consoleLogger, oa, []corev1.Pod{running, pending}))
+
+	cond = oa.Status.Conditions.GetCondition(dynatracev1alpha1.SchedulingBlockedConditionType)
+	require.NotNil(t, cond)
+	assert.Equal(t, corev1.ConditionFalse, cond.Status)
+	assert.Equal(t, dynatracev1alpha1.ReasonPodsScheduled, cond.Reason)
+}
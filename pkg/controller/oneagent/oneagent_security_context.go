@@ -0,0 +1,195 @@
+package oneagent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// requiredCapabilities are the capabilities the OneAgent needs when not running as a privileged container
+var requiredCapabilities = []corev1.Capability{
+	"CHOWN",
+	"DAC_OVERRIDE",
+	"DAC_READ_SEARCH",
+	"FOWNER",
+	"FSETID",
+	"KILL",
+	"NET_ADMIN",
+	"NET_RAW",
+	"SETFCAP",
+	"SETGID",
+	"SETUID",
+	"SYS_ADMIN",
+	"SYS_CHROOT",
+	"SYS_PTRACE",
+	"SYS_RESOURCE",
+}
+
+// newSecurityContextForCR returns the security context for the OneAgent container. By default, the container runs
+// privileged, or with the required capabilities only in unprivileged mode or with the Restricted preset. Fields set on
+// .spec.securityContext override the defaults.
+func newSecurityContextForCR(instance dynatracev1alpha1.BaseOneAgentDaemonSet, unprivileged bool) *corev1.SecurityContext {
+	var secCtx *corev1.SecurityContext
+	if isRestrictedPreset(instance.GetOneAgentSpec()) {
+		falseVar := false
+		secCtx = &corev1.SecurityContext{
+			Privileged: &falseVar,
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{
+					"ALL",
+				},
+				Add: append([]corev1.Capability{}, requiredCapabilities...),
+			},
+		}
+	} else if unprivileged {
+		secCtx = &corev1.SecurityContext{
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{
+					"ALL",
+				},
+				Add: append([]corev1.Capability{}, requiredCapabilities...),
+			},
+		}
+	} else {
+		trueVar := true
+		secCtx = &corev1.SecurityContext{
+			Privileged: &trueVar,
+		}
+	}
+
+	return mergeSecurityContext(secCtx, instance.GetOneAgentSpec().SecurityContext)
+}
+
+// mergeSecurityContext returns a copy of base with the fields set on override replacing the ones from base.
+func mergeSecurityContext(base, override *corev1.SecurityContext) *corev1.SecurityContext {
+	if override == nil {
+		return base
+	}
+
+	result := base.DeepCopy()
+	o := override.DeepCopy()
+
+	if o.Capabilities != nil {
+		result.Capabilities = o.Capabilities
+	}
+	if o.Privileged != nil {
+		result.Privileged = o.Privileged
+	}
+	if o.SELinuxOptions != nil {
+		result.SELinuxOptions = o.SELinuxOptions
+	}
+	if o.WindowsOptions != nil {
+		result.WindowsOptions = o.WindowsOptions
+	}
+	if o.RunAsUser != nil {
+		result.RunAsUser = o.RunAsUser
+	}
+	if o.RunAsGroup != nil {
+		result.RunAsGroup = o.RunAsGroup
+	}
+	if o.RunAsNonRoot != nil {
+		result.RunAsNonRoot = o.RunAsNonRoot
+	}
+	if o.ReadOnlyRootFilesystem != nil {
+		result.ReadOnlyRootFilesystem = o.ReadOnlyRootFilesystem
+	}
+	if o.AllowPrivilegeEscalation != nil {
+		result.AllowPrivilegeEscalation = o.AllowPrivilegeEscalation
+	}
+	if o.ProcMount != nil {
+		result.ProcMount = o.ProcMount
+	}
+
+	return result
+}
+
+// reconcileSecurityContext sets the InsufficientPrivileges condition if the security contexts configured on the
+// instance take away privileges the OneAgent requires. The condition is only set if custom security contexts are used.
+//
+// Returns true if the condition has changed.
+func reconcileSecurityContext(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	spec := instance.GetOneAgentSpec()
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if spec.SecurityContext == nil && spec.PodSecurityContext == nil {
+		return conditions.RemoveCondition(dynatracev1alpha1.InsufficientPrivilegesConditionType)
+	}
+
+	unprivileged := os.Getenv("ONEAGENT_OPERATOR_DEBUG_UNPRIVILEGED") == "true" || isRestrictedPreset(spec)
+	issues := findMissingPrivileges(newSecurityContextForCR(instance, unprivileged), spec.PodSecurityContext)
+
+	if len(issues) == 0 {
+		return conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.InsufficientPrivilegesConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonPrivilegesSufficient,
+			Message: "Security context grants the privileges required by the OneAgent",
+		})
+	}
+
+	msg := fmt.Sprintf("Security context removes privileges required by the OneAgent: %s", strings.Join(issues, ", "))
+	if conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.InsufficientPrivilegesConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonPrivilegesRemoved,
+		Message: msg,
+	}) {
+		logger.Info("OneAgent may not work properly with the configured security context", "issues", issues)
+		return true
+	}
+	return false
+}
+
+// findMissingPrivileges returns the privileges required by the OneAgent that the given security contexts don't grant.
+func findMissingPrivileges(secCtx *corev1.SecurityContext, podSecCtx *corev1.PodSecurityContext) []string {
+	var issues []string
+
+	if podSecCtx != nil {
+		if podSecCtx.RunAsNonRoot != nil && *podSecCtx.RunAsNonRoot {
+			issues = append(issues, "pod must not set runAsNonRoot")
+		}
+		if podSecCtx.RunAsUser != nil && *podSecCtx.RunAsUser != 0 {
+			issues = append(issues, "pod must run as root user")
+		}
+	}
+
+	if secCtx == nil {
+		return issues
+	}
+
+	if secCtx.RunAsNonRoot != nil && *secCtx.RunAsNonRoot {
+		issues = append(issues, "container must not set runAsNonRoot")
+	}
+	if secCtx.RunAsUser != nil && *secCtx.RunAsUser != 0 {
+		issues = append(issues, "container must run as root user")
+	}
+
+	if secCtx.Privileged != nil && *secCtx.Privileged {
+		return issues
+	}
+
+	granted := map[corev1.Capability]bool{}
+	if secCtx.Capabilities != nil {
+		for _, c := range secCtx.Capabilities.Add {
+			granted[c] = true
+		}
+	}
+
+	var missing []string
+	for _, c := range requiredCapabilities {
+		if !granted[c] {
+			missing = append(missing, string(c))
+		}
+	}
+
+	if len(missing) > 0 {
+		issues = append(issues, fmt.Sprintf("container must be privileged or add capabilities %s", strings.Join(missing, ", ")))
+	}
+
+	return issues
+}
@@ -0,0 +1,99 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNewSecurityContextForCR(t *testing.T) {
+	t.Run("privileged by default", func(t *testing.T) {
+		oa := newOneAgent()
+
+		secCtx := newSecurityContextForCR(oa, false)
+		if assert.NotNil(t, secCtx.Privileged) {
+			assert.True(t, *secCtx.Privileged)
+		}
+	})
+
+	t.Run("override replaces set fields only", func(t *testing.T) {
+		readOnly := true
+		oa := newOneAgent()
+		oa.Spec.SecurityContext = &corev1.SecurityContext{ReadOnlyRootFilesystem: &readOnly}
+		oa.Spec.PodSecurityContext = &corev1.PodSecurityContext{
+			SELinuxOptions: &corev1.SELinuxOptions{Type: "spc_t"},
+		}
+
+		ps := newPodSpecForCR(oa, false, consoleLogger)
+		secCtx := ps.Containers[0].SecurityContext
+		if assert.NotNil(t, secCtx.Privileged) {
+			assert.True(t, *secCtx.Privileged)
+		}
+		assert.Equal(t, &readOnly, secCtx.ReadOnlyRootFilesystem)
+		assert.Equal(t, oa.Spec.PodSecurityContext, ps.SecurityContext)
+	})
+
+	t.Run("override drops privileged mode", func(t *testing.T) {
+		privileged := false
+		oa := newOneAgent()
+		oa.Spec.SecurityContext = &corev1.SecurityContext{
+			Privileged:   &privileged,
+			Capabilities: &corev1.Capabilities{Add: requiredCapabilities},
+		}
+
+		secCtx := newSecurityContextForCR(oa, false)
+		assert.Equal(t, &privileged, secCtx.Privileged)
+		assert.Equal(t, requiredCapabilities, secCtx.Capabilities.Add)
+	})
+}
+
+func TestReconcileSecurityContext(t *testing.T) {
+	t.Run("no condition without custom security context", func(t *testing.T) {
+		oa := newOneAgent()
+
+		assert.False(t, reconcileSecurityContext(consoleLogger, oa))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.InsufficientPrivilegesConditionType))
+	})
+
+	t.Run("no warning if required privileges are kept", func(t *testing.T) {
+		privileged := false
+		oa := newOneAgent()
+		oa.Spec.SecurityContext = &corev1.SecurityContext{
+			Privileged:   &privileged,
+			Capabilities: &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}, Add: requiredCapabilities},
+		}
+
+		assert.True(t, reconcileSecurityContext(consoleLogger, oa))
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.InsufficientPrivilegesConditionType)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionFalse, cond.Status)
+			assert.Equal(t, dynatracev1alpha1.ReasonPrivilegesSufficient, cond.Reason)
+		}
+	})
+
+	t.Run("warning if required privileges are removed", func(t *testing.T) {
+		privileged := false
+		runAsNonRoot := true
+		oa := newOneAgent()
+		oa.Spec.SecurityContext = &corev1.SecurityContext{
+			Privileged:   &privileged,
+			Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"SYS_ADMIN"}},
+		}
+		oa.Spec.PodSecurityContext = &corev1.PodSecurityContext{RunAsNonRoot: &runAsNonRoot}
+
+		assert.True(t, reconcileSecurityContext(consoleLogger, oa))
+		assert.False(t, reconcileSecurityContext(consoleLogger, oa))
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.InsufficientPrivilegesConditionType)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionTrue, cond.Status)
+			assert.Equal(t, dynatracev1alpha1.ReasonPrivilegesRemoved, cond.Reason)
+			assert.Contains(t, cond.Message, "pod must not set runAsNonRoot")
+			assert.Contains(t, cond.Message, "NET_ADMIN")
+			assert.NotContains(t, cond.Message, "SYS_ADMIN")
+		}
+	})
+}
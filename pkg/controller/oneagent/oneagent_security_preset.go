@@ -0,0 +1,68 @@
+package oneagent
+
+import (
+	"fmt"
+	"strings"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// restrictedPresetConflicts are the privileges the OneAgent can't do without, even with the Restricted preset, but which
+// the restricted Pod Security Standard forbids.
+var restrictedPresetConflicts = []string{
+	"host network, PID and IPC namespaces",
+	"hostPath volume for the host root filesystem",
+	"running as root user",
+	"unconfined AppArmor profile",
+}
+
+// validateSecurityPreset returns the issues found on .spec.securityPreset
+func validateSecurityPreset(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	switch spec.SecurityPreset {
+	case "", dynatracev1alpha1.SecurityPresetPrivileged, dynatracev1alpha1.SecurityPresetRestricted:
+		return nil
+	}
+	return []string{fmt.Sprintf(".spec.securityPreset has unknown value %q", spec.SecurityPreset)}
+}
+
+// isRestrictedPreset returns true if .spec.securityPreset selects the Restricted preset
+func isRestrictedPreset(spec *dynatracev1alpha1.OneAgentSpec) bool {
+	return spec.SecurityPreset == dynatracev1alpha1.SecurityPresetRestricted
+}
+
+// reconcileSecurityPreset sets the SecurityPresetConflict condition while the Restricted preset is selected, explaining
+// which privileges the OneAgent pods still need beyond the restricted Pod Security Standard.
+//
+// Returns true if the condition has changed.
+func reconcileSecurityPreset(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if !isRestrictedPreset(instance.GetOneAgentSpec()) {
+		return conditions.RemoveCondition(dynatracev1alpha1.SecurityPresetConflictConditionType)
+	}
+
+	conflicts := append([]string{}, restrictedPresetConflicts...)
+	conflicts = append(conflicts, fmt.Sprintf("capabilities %s", joinCapabilities(requiredCapabilities)))
+
+	if conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.SecurityPresetConflictConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonPrivilegesRequired,
+		Message: fmt.Sprintf("OneAgent runs unprivileged, but still requires %s", strings.Join(conflicts, ", ")),
+	}) {
+		logger.Info("OneAgent pods can't comply with the restricted Pod Security Standard", "conflicts", conflicts)
+		return true
+	}
+	return false
+}
+
+func joinCapabilities(capabilities []corev1.Capability) string {
+	names := make([]string, len(capabilities))
+	for i, c := range capabilities {
+		names[i] = string(c)
+	}
+	return strings.Join(names, ", ")
+}
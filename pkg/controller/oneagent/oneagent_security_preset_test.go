@@ -0,0 +1,80 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNewSecurityContextForCR_Preset(t *testing.T) {
+	t.Run("privileged preset", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.SecurityPreset = dynatracev1alpha1.SecurityPresetPrivileged
+
+		secCtx := newSecurityContextForCR(oa, false)
+		if assert.NotNil(t, secCtx.Privileged) {
+			assert.True(t, *secCtx.Privileged)
+		}
+		assert.Nil(t, secCtx.Capabilities)
+	})
+
+	t.Run("restricted preset", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.APIURL = "https://f.q.d.n/api"
+		oa.Spec.SecurityPreset = dynatracev1alpha1.SecurityPresetRestricted
+
+		ds, err := newDaemonSetForCR(consoleLogger, oa)
+		require.NoError(t, err)
+		secCtx := ds.Spec.Template.Spec.Containers[0].SecurityContext
+		if assert.NotNil(t, secCtx.Privileged) {
+			assert.False(t, *secCtx.Privileged)
+		}
+		assert.Equal(t, &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}, Add: requiredCapabilities}, secCtx.Capabilities)
+		assert.Equal(t, "dynatrace-oneagent-unprivileged", ds.Spec.Template.Spec.ServiceAccountName)
+		assert.Equal(t, "unconfined", ds.Spec.Template.Annotations["container.apparmor.security.beta.kubernetes.io/"+oneAgentContainerName])
+	})
+
+	t.Run("security context overrides restricted preset", func(t *testing.T) {
+		readOnly := true
+		oa := newOneAgent()
+		oa.Spec.SecurityPreset = dynatracev1alpha1.SecurityPresetRestricted
+		oa.Spec.SecurityContext = &corev1.SecurityContext{ReadOnlyRootFilesystem: &readOnly}
+
+		secCtx := newSecurityContextForCR(oa, false)
+		assert.Equal(t, &readOnly, secCtx.ReadOnlyRootFilesystem)
+		assert.Equal(t, requiredCapabilities, secCtx.Capabilities.Add)
+	})
+}
+
+func TestReconcileSecurityPreset(t *testing.T) {
+	oa := newOneAgent()
+	assert.False(t, reconcileSecurityPreset(consoleLogger, oa))
+	assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.SecurityPresetConflictConditionType))
+
+	oa.Spec.SecurityPreset = dynatracev1alpha1.SecurityPresetRestricted
+	assert.True(t, reconcileSecurityPreset(consoleLogger, oa))
+	cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.SecurityPresetConflictConditionType)
+	require.NotNil(t, cond)
+	assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	assert.Equal(t, dynatracev1alpha1.ReasonPrivilegesRequired, cond.Reason)
+	assert.Contains(t, cond.Message, "hostPath volume for the host root filesystem")
+	assert.Contains(t, cond.Message, "capabilities CHOWN, DAC_OVERRIDE")
+	assert.False(t, reconcileSecurityPreset(consoleLogger, oa))
+
+	oa.Spec.SecurityPreset = dynatracev1alpha1.SecurityPresetPrivileged
+	assert.True(t, reconcileSecurityPreset(consoleLogger, oa))
+	assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.SecurityPresetConflictConditionType))
+}
+
+func TestValidateSecurityPreset(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.SecurityPreset = dynatracev1alpha1.SecurityPresetRestricted
+	assert.NoError(t, validate(oa))
+
+	oa.Spec.SecurityPreset = "Baseline"
+	assert.EqualError(t, validate(oa), `.spec.securityPreset has unknown value "Baseline"`)
+}
@@ -4,36 +4,159 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
 	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/utils"
 	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/version"
 	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// reconcileScheduledVersion reconciles the OneAgent version if the current time is within the update windows set on
+// the instance, or if none are set.
+//
+// Outside the update windows, the version is kept and the duration until the next window starts is returned.
+func (r *ReconcileOneAgent) reconcileScheduledVersion(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dtc dtclient.Client) (bool, time.Duration, error) {
+	upd, wait := reconcileUpdateWindows(logger, instance, r.currentTime())
+	if wait > 0 {
+		return upd, wait, nil
+	}
+
+	updVersion, err := r.reconcileVersion(logger, instance, dtc)
+	return upd || updVersion, 0, err
+}
+
 func (r *ReconcileOneAgent) reconcileVersion(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dtc dtclient.Client) (bool, error) {
+	var updateCR bool
+	var err error
+
 	if instance.GetOneAgentStatus().UseImmutableImage {
-		return r.reconcileVersionImmutableImage(instance, dtc)
+		updateCR, err = r.reconcileVersionImmutableImage(instance, dtc)
 	} else {
-		return r.reconcileVersionInstaller(logger, instance, dtc)
+		updateCR, err = r.reconcileVersionInstaller(logger, instance, dtc)
+	}
+
+	if recordVersionHistory(instance, r.currentTime()) {
+		updateCR = true
+	}
+	if reconcileUpdatingCondition(logger, instance) {
+		updateCR = true
 	}
+	if reconcileInstallerSupport(logger, instance, dtc) {
+		updateCR = true
+	}
+	return updateCR, err
 }
 
-func (r *ReconcileOneAgent) reconcileVersionInstaller(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dtc dtclient.Client) (bool, error) {
-	updateCR := false
+// defaultVersionHistoryLimit is the number of versions kept on .status.versionHistory if not set on the spec
+const defaultVersionHistoryLimit = 10
+
+// recordVersionHistory appends the version on the status to .status.versionHistory, unless it is already the last
+// recorded one. The oldest entries are dropped beyond .spec.versionHistoryLimit.
+//
+// Returns true if the history has changed.
+func recordVersionHistory(instance dynatracev1alpha1.BaseOneAgentDaemonSet, now time.Time) bool {
+	oaStatus := instance.GetOneAgentStatus()
+	updated := false
+
+	if v := oaStatus.Version; v != "" {
+		if n := len(oaStatus.VersionHistory); n == 0 || oaStatus.VersionHistory[n-1].Version != v {
+			oaStatus.VersionHistory = append(oaStatus.VersionHistory, dynatracev1alpha1.OneAgentVersionHistoryEntry{
+				Version:   v,
+				Timestamp: metav1.NewTime(now),
+			})
+			updated = true
+		}
+	}
+
+	limit := defaultVersionHistoryLimit
+	if l := instance.GetOneAgentSpec().VersionHistoryLimit; l != nil {
+		limit = int(*l)
+		if limit < 0 {
+			limit = 0
+		}
+	}
+
+	if n := len(oaStatus.VersionHistory); n > limit {
+		oaStatus.VersionHistory = append([]dynatracev1alpha1.OneAgentVersionHistoryEntry{}, oaStatus.VersionHistory[n-limit:]...)
+		updated = true
+	}
+
+	return updated
+}
+
+// reconcileUpdatingCondition sets the Updating condition to True while some instances don't run the version on the
+// status yet, and to False once all of them do.
+//
+// Returns true if the condition has changed.
+func reconcileUpdatingCondition(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	oaStatus := instance.GetOneAgentStatus()
+	desired := oaStatus.Version
+	if desired == "" {
+		return oaStatus.Conditions.RemoveCondition(dynatracev1alpha1.UpdatingConditionType)
+	}
+
+	if isVersionDeployed(instance) {
+		return oaStatus.Conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.UpdatingConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonUpdateCompleted,
+			Message: fmt.Sprintf("OneAgent version %s is deployed on all pods", desired),
+		})
+	}
+
+	seen := map[string]bool{}
+	var previous []string
+	for _, i := range oaStatus.Instances {
+		if i.Version != "" && i.Version != desired && !seen[i.Version] {
+			seen[i.Version] = true
+			previous = append(previous, i.Version)
+		}
+	}
+	sort.Strings(previous)
+
+	msg := fmt.Sprintf("Updating OneAgent to version %s", desired)
+	if len(previous) > 0 {
+		msg = fmt.Sprintf("Updating OneAgent from version %s to %s", strings.Join(previous, ", "), desired)
+	}
+
+	if oaStatus.Conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.UpdatingConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonUpdateInProgress,
+		Message: msg,
+	}) {
+		logger.Info("OneAgent update in progress", "previous", previous, "desired", desired)
+		return true
+	}
+	return false
+}
 
-	desired, err := dtc.GetLatestAgentVersion(dtclient.OsUnix, dtclient.InstallerTypeDefault)
+func (r *ReconcileOneAgent) reconcileVersionInstaller(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dtc dtclient.Client) (bool, error) {
+	desired, updateCR, err := r.getDesiredVersion(logger, instance, dtc)
 	if err != nil {
-		return false, fmt.Errorf("failed to get desired version: %w", err)
-	} else if desired != "" && isDesiredNewer(instance.GetOneAgentStatus().Version, desired, logger) {
-		logger.Info("new version available", "actual", instance.GetOneAgentStatus().Version, "desired", desired)
-		instance.GetOneAgentStatus().Version = desired
-		updateCR = true
+		return updateCR, fmt.Errorf("failed to get desired version: %w", err)
+	} else if desired != "" && desired != instance.GetOneAgentStatus().Version {
+		allowed, upd := reconcileDowngrade(logger, instance, desired)
+		updateCR = updateCR || upd
+		if allowed {
+			allowed, upd = reconcileUpdateApproval(logger, instance, desired)
+			updateCR = updateCR || upd
+		}
+		if allowed {
+			logger.Info("new version available", "actual", instance.GetOneAgentStatus().Version, "desired", desired)
+			instance.GetOneAgentStatus().Version = desired
+			updateCR = true
+		}
 	}
 
 	podList, err := r.findPods(instance)
@@ -47,7 +170,10 @@ func (r *ReconcileOneAgent) reconcileVersionInstaller(logger logr.Logger, instan
 		return updateCR, err
 	}
 
-	var waitSecs uint16 = 300
+	podsToDelete, updCanary := reconcileCanary(logger, instance, dtc, podList, podsToDelete)
+	updateCR = updateCR || updCanary
+
+	waitSecs := dynatracev1alpha1.DefaultWaitReadySeconds
 	if instance.GetOneAgentSpec().WaitReadySeconds != nil {
 		waitSecs = *instance.GetOneAgentSpec().WaitReadySeconds
 	}
@@ -68,17 +194,155 @@ func (r *ReconcileOneAgent) reconcileVersionInstaller(logger logr.Logger, instan
 		return updateCR, err
 	}
 
+	if len(podsToDelete) > 0 {
+		r.sendDeploymentEvent(logger, instance, dtc, instance.GetOneAgentStatus().Version)
+		updateCR = true
+	}
+
 	return updateCR, nil
 }
 
+// getDesiredVersion returns the OneAgent version to roll out through the installer: the latest version on the
+// environment, the newest one within .spec.versionBranch, or the version on the status of the OneAgent referenced by .spec.versionSource. The outcome of the
+// latter is reflected on the VersionSource condition, and the current version is kept, i.e., an empty version is
+// returned, while the referenced OneAgent is absent or has no version.
+//
+// Returns the version, and true if the condition has changed.
+func (r *ReconcileOneAgent) getDesiredVersion(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, dtc dtclient.Client) (string, bool, error) {
+	conditions := &instance.GetOneAgentStatus().Conditions
+	name := instance.GetOneAgentSpec().VersionSource
+	if name == "" {
+		upd := conditions.RemoveCondition(dynatracev1alpha1.VersionSourceConditionType)
+		if branch := instance.GetOneAgentSpec().VersionBranch; branch != "" {
+			desired, err := dtc.GetLatestAgentVersionForBranch(dtclient.OsUnix, dtclient.InstallerTypeDefault, branch)
+			return desired, upd, err
+		}
+		desired, err := dtc.GetLatestAgentVersion(dtclient.OsUnix, dtclient.InstallerTypeDefault)
+		return desired, upd, err
+	}
+
+	var source dynatracev1alpha1.OneAgent
+	if err := r.client.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: instance.GetNamespace()}, &source); k8serrors.IsNotFound(err) {
+		if conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.VersionSourceConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonVersionSourceNotFound,
+			Message: fmt.Sprintf("OneAgent %s referenced on .spec.versionSource not found, keeping the current version", name),
+		}) {
+			logger.Info("OneAgent referenced as version source not found", "versionSource", name)
+			return "", true, nil
+		}
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	desired := source.Status.Version
+	if desired == "" {
+		return "", conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.VersionSourceConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonVersionSourcePending,
+			Message: fmt.Sprintf("OneAgent %s referenced on .spec.versionSource has no version yet, keeping the current version", name),
+		}), nil
+	}
+
+	return desired, conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.VersionSourceConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonVersionSourceApplied,
+		Message: fmt.Sprintf("Using version %s of OneAgent %s", desired, name),
+	}), nil
+}
+
+// heldImageVersion returns the version on the status, and true, if the image tag of the OneAgent pods must not be
+// moved to .spec.agentVersion yet in immutable-image mode, i.e., while the downgrade to it is blocked, the update to it
+// waits for approval, or the given time is outside the update windows. reconcileScheduledVersion reports the reason on
+// the conditions.
+func heldImageVersion(instance dynatracev1alpha1.BaseOneAgentDaemonSet, now time.Time) (string, bool) {
+	spec := instance.GetOneAgentSpec()
+	current := instance.GetOneAgentStatus().Version
+	if !instance.GetOneAgentStatus().UseImmutableImage || isImagePinned(spec) || spec.DisableAgentUpdate {
+		return "", false
+	}
+	if desired := spec.AgentVersion; desired == "" || current == "" || desired == current {
+		return "", false
+	}
+
+	if isDowngrade(current, spec.AgentVersion) && !spec.AllowDowngrade {
+		return current, true
+	}
+	if spec.RequireUpdateApproval && instance.GetAnnotations()[annotationApproveVersion] != spec.AgentVersion {
+		return current, true
+	}
+	if isUpdateDeferred(spec, now) {
+		return current, true
+	}
+	return "", false
+}
+
+// imageVersionInstance returns the instance to build the workloads from. In immutable-image mode, the image tag comes
+// from .spec.agentVersion, so changing it would roll out the new version through the pod template right away. While
+// heldImageVersion holds the update back, a copy of the instance with the version on the status is returned instead.
+func (r *ReconcileOneAgent) imageVersionInstance(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) dynatracev1alpha1.BaseOneAgentDaemonSet {
+	v, held := heldImageVersion(instance, r.currentTime())
+	if !held {
+		return instance
+	}
+
+	scoped, ok := instance.DeepCopyObject().(dynatracev1alpha1.BaseOneAgentDaemonSet)
+	if !ok {
+		return instance
+	}
+	logger.V(1).Info("Keeping the image of the current OneAgent version", "actual", v, "desired", instance.GetOneAgentSpec().AgentVersion)
+	scoped.GetOneAgentSpec().AgentVersion = v
+	return scoped
+}
+
 func (r *ReconcileOneAgent) reconcileVersionImmutableImage(instance dynatracev1alpha1.BaseOneAgentDaemonSet, dtc dtclient.Client) (bool, error) {
 	updateCR := false
-	var waitSecs uint16 = 300
+	waitSecs := dynatracev1alpha1.DefaultWaitReadySeconds
 	if instance.GetOneAgentSpec().WaitReadySeconds != nil {
 		waitSecs = *instance.GetOneAgentSpec().WaitReadySeconds
 	}
 
-	if !instance.GetOneAgentSpec().DisableAgentUpdate {
+	if isImagePinned(instance.GetOneAgentSpec()) {
+		r.logger.Info("Skipping updating pods because the image is pinned by digest", "image", instance.GetOneAgentSpec().Image)
+	} else if !instance.GetOneAgentSpec().DisableAgentUpdate {
+		if desired := instance.GetOneAgentSpec().AgentVersion; desired != "" {
+			allowed, upd := reconcileDowngrade(r.logger, instance, desired)
+			updateCR = upd
+			if !allowed {
+				r.logger.Info("Skipping updating pods because of blocked downgrade", "actual", instance.GetOneAgentStatus().Version, "desired", desired)
+				return updateCR, nil
+			}
+		}
+
+		if instance.GetOneAgentSpec().RequireUpdateApproval {
+			desired := instance.GetOneAgentSpec().AgentVersion
+			if desired == "" {
+				latest, err := dtc.GetLatestAgentVersion(dtclient.OsUnix, dtclient.InstallerTypeDefault)
+				if err != nil {
+					return updateCR, fmt.Errorf("failed to get desired version: %w", err)
+				}
+				desired = latest
+			}
+
+			allowed, upd := reconcileUpdateApproval(r.logger, instance, desired)
+			updateCR = updateCR || upd
+			if !allowed {
+				r.logger.Info("Skipping updating pods because the update isn't approved", "actual", instance.GetOneAgentStatus().Version, "desired", desired)
+				return updateCR, nil
+			}
+		}
+
+		// The allowed version is recorded, so that heldImageVersion compares further changes against it.
+		if desired := instance.GetOneAgentSpec().AgentVersion; desired != "" && desired != instance.GetOneAgentStatus().Version {
+			r.logger.Info("new version allowed", "actual", instance.GetOneAgentStatus().Version, "desired", desired)
+			instance.GetOneAgentStatus().Version = desired
+			updateCR = true
+		}
+
 		r.logger.Info("checking for outdated pods")
 		// Check if pods have latest agent version
 		outdatedPods, err := r.findOutdatedPodsImmutableImage(r.logger, instance, isLatest)
@@ -99,6 +363,8 @@ func (r *ReconcileOneAgent) reconcileVersionImmutableImage(instance dynatracev1a
 				r.logger.Error(err, err.Error())
 				return updateCR, err
 			}
+
+			r.sendDeploymentEvent(r.logger, instance, dtc, instance.GetOneAgentStatus().Version)
 		}
 	} else if instance.GetOneAgentSpec().DisableAgentUpdate {
 		r.logger.Info("Skipping updating pods because of configuration", "disableOneAgentUpdate", true)
@@ -118,10 +384,10 @@ func findOutdatedPodsInstaller(pods []corev1.Pod, dtc dtclient.Client, instance
 			if err != nil {
 				return doomedPods, err
 			}
-		} else {
-			if isDesiredNewer(ver, instance.GetOneAgentStatus().Version, logger) {
-				doomedPods = append(doomedPods, pod)
-			}
+		} else if isDesiredNewer(ver, instance.GetOneAgentStatus().Version, logger) {
+			doomedPods = append(doomedPods, pod)
+		} else if instance.GetOneAgentSpec().AllowDowngrade && isDowngrade(ver, instance.GetOneAgentStatus().Version) {
+			doomedPods = append(doomedPods, pod)
 		}
 	}
 
@@ -211,6 +477,110 @@ func (r *ReconcileOneAgent) setVersionByIP(instance dynatracev1alpha1.BaseOneAge
 	return nil
 }
 
+// reconcileDowngrade checks whether moving from the version on the status to the desired one would downgrade the
+// OneAgent, which is only permitted if .spec.allowDowngrade is set. Blocked downgrades are recorded on the
+// DowngradeBlocked condition, which gets removed again once the desired version can be applied.
+//
+// Returns whether the desired version can be applied, and whether the instance has been modified.
+func reconcileDowngrade(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, desired string) (bool, bool) {
+	sts := instance.GetOneAgentStatus()
+	if sts.Version == "" || sts.Version == desired {
+		return true, sts.Conditions.RemoveCondition(dynatracev1alpha1.DowngradeBlockedConditionType)
+	}
+
+	result, err := version.CompareAgentVersions(sts.Version, desired)
+	if err != nil {
+		// Not in Dynatrace's version format, fall back to the plain comparison that never allows downgrades
+		logger.Info("failed to parse versions", "actual", sts.Version, "desired", desired, "error", err.Error())
+		return isDesiredNewer(sts.Version, desired, logger), false
+	}
+
+	if result <= 0 || instance.GetOneAgentSpec().AllowDowngrade {
+		if result > 0 {
+			logger.Info("downgrading OneAgent version", "actual", sts.Version, "desired", desired)
+		}
+		return result != 0, sts.Conditions.RemoveCondition(dynatracev1alpha1.DowngradeBlockedConditionType)
+	}
+
+	logger.Info("downgrade detected, set .spec.allowDowngrade to allow it", "actual", sts.Version, "desired", desired)
+	return false, sts.Conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.DowngradeBlockedConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonDowngradeNotAllowed,
+		Message: fmt.Sprintf("Downgrade from version %s to %s blocked, set .spec.allowDowngrade to allow it", sts.Version, desired),
+	})
+}
+
+// autoUpdateArg is the installer argument controlling whether the OneAgent updates itself in place
+const autoUpdateArg = "--set-auto-update-enabled="
+
+// isAutoUpdateEnabled returns true unless .spec.autoUpdate disables the in-place updates of the OneAgent
+func isAutoUpdateEnabled(spec *dynatracev1alpha1.OneAgentSpec) bool {
+	return spec.AutoUpdate == nil || *spec.AutoUpdate
+}
+
+// buildAutoUpdateArgs returns the installer argument disabling in-place updates of the OneAgent if .spec.autoUpdate is
+// false, so that it only gets updated by the operator. Skipped if already set on existingArgs.
+func buildAutoUpdateArgs(spec *dynatracev1alpha1.OneAgentSpec, existingArgs []string) []string {
+	if isAutoUpdateEnabled(spec) || hasArgWithPrefix(existingArgs, autoUpdateArg) {
+		return nil
+	}
+	return []string{autoUpdateArg + "false"}
+}
+
+// reconcileUpdateSettings sets the UpdateSettingsConflict condition, listing the update settings without effect
+// because of others. .spec.disableAgentUpdate takes precedence: once a version is deployed, neither a different
+// .spec.agentVersion nor .spec.allowDowngrade get applied. As it only stops the operator from rolling out new versions,
+// the OneAgent keeps updating itself unless .spec.autoUpdate is disabled too. The condition is only set if agent
+// updates are disabled.
+//
+// Returns true if the condition has changed.
+func reconcileUpdateSettings(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	spec := instance.GetOneAgentSpec()
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if !spec.DisableAgentUpdate {
+		return conditions.RemoveCondition(dynatracev1alpha1.UpdateSettingsConflictConditionType)
+	}
+
+	var conflicts []string
+	if deployed := instance.GetOneAgentStatus().Version; spec.AgentVersion != "" && deployed != "" && spec.AgentVersion != deployed {
+		conflicts = append(conflicts, fmt.Sprintf(".spec.agentVersion %s is not applied, version %s is kept as .spec.disableAgentUpdate is set", spec.AgentVersion, deployed))
+	}
+	if spec.AllowDowngrade {
+		conflicts = append(conflicts, ".spec.allowDowngrade has no effect as .spec.disableAgentUpdate is set")
+	}
+	if spec.AutoUpdate != nil && *spec.AutoUpdate {
+		conflicts = append(conflicts, ".spec.disableAgentUpdate doesn't stop the OneAgent from updating itself as .spec.autoUpdate is enabled")
+	}
+
+	if len(conflicts) == 0 {
+		return conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.UpdateSettingsConflictConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonUpdateSettingsConsistent,
+			Message: "Update settings are consistent",
+		})
+	}
+
+	if conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.UpdateSettingsConflictConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonConflictingUpdateSettings,
+		Message: strings.Join(conflicts, ", "),
+	}) {
+		logger.Info("Conflicting update settings", "conflicts", conflicts)
+		return true
+	}
+	return false
+}
+
+// isDowngrade returns true if the desired version is older than the actual one
+func isDowngrade(actual string, desired string) bool {
+	result, err := version.CompareAgentVersions(actual, desired)
+	return err == nil && result > 0
+}
+
 func isDesiredNewer(actual string, desired string, logger logr.Logger) bool {
 	aa := strings.Split(actual, ".")
 	da := strings.Split(desired, ".")
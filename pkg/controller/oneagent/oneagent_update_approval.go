@@ -0,0 +1,42 @@
+package oneagent
+
+import (
+	"fmt"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// annotationApproveVersion approves the update to the given OneAgent version if .spec.requireUpdateApproval is set
+const annotationApproveVersion = "dynatrace.com/approve-version"
+
+// reconcileUpdateApproval checks whether moving from the version on the status to the desired one has been approved,
+// which is only required if .spec.requireUpdateApproval is set. The initial deployment needs no approval. Updates
+// waiting for approval are recorded on the PendingApproval condition, which gets removed again once the desired
+// version can be applied.
+//
+// Returns whether the desired version can be applied, and whether the instance has been modified.
+func reconcileUpdateApproval(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, desired string) (bool, bool) {
+	sts := instance.GetOneAgentStatus()
+	if !instance.GetOneAgentSpec().RequireUpdateApproval || sts.Version == "" || sts.Version == desired {
+		return true, sts.Conditions.RemoveCondition(dynatracev1alpha1.PendingApprovalConditionType)
+	}
+
+	if instance.GetAnnotations()[annotationApproveVersion] == desired {
+		logger.Info("update approved", "actual", sts.Version, "desired", desired)
+		return true, sts.Conditions.RemoveCondition(dynatracev1alpha1.PendingApprovalConditionType)
+	}
+
+	if sts.Conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.PendingApprovalConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonApprovalRequired,
+		Message: fmt.Sprintf("Update from version %s to %s pending, set annotation %s=%s to approve it", sts.Version, desired, annotationApproveVersion, desired),
+	}) {
+		logger.Info("update waiting for approval", "actual", sts.Version, "desired", desired, "annotation", annotationApproveVersion)
+		return false, true
+	}
+	return false, false
+}
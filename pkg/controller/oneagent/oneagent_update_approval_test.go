@@ -0,0 +1,156 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/utils"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileVersion_UpdateApproval(t *testing.T) {
+	actual := "1.202.0.20200808-120956"
+	desired := "1.203.0.20200908-220956"
+
+	base := dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent", Namespace: "dynatrace"},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+			RequireUpdateApproval: true,
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{Version: actual},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetLatestAgentVersion", dtclient.OsUnix, dtclient.InstallerTypeDefault).Return(desired, nil)
+
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	t.Run("update waits for approval", func(t *testing.T) {
+		oa := base.DeepCopy()
+
+		updateCR, err := reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+
+		assert.NoError(t, err)
+		assert.True(t, updateCR)
+		assert.Equal(t, actual, oa.Status.Version)
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.PendingApprovalConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonApprovalRequired, cond.Reason)
+		assert.Contains(t, cond.Message, desired)
+	})
+
+	t.Run("approval of another version keeps waiting", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Annotations = map[string]string{annotationApproveVersion: "1.204.0.20201008-120956"}
+
+		_, err := reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+
+		assert.NoError(t, err)
+		assert.Equal(t, actual, oa.Status.Version)
+		assert.NotNil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.PendingApprovalConditionType))
+	})
+
+	t.Run("approved update is rolled out", func(t *testing.T) {
+		oa := base.DeepCopy()
+		reconcileUpdateApproval(consoleLogger, oa, desired)
+		require.NotNil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.PendingApprovalConditionType))
+		oa.Annotations = map[string]string{annotationApproveVersion: desired}
+
+		updateCR, err := reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+
+		assert.NoError(t, err)
+		assert.True(t, updateCR)
+		assert.Equal(t, desired, oa.Status.Version)
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.PendingApprovalConditionType))
+	})
+
+	t.Run("initial deployment needs no approval", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Status.Version = ""
+
+		allowed, updateCR := reconcileUpdateApproval(consoleLogger, oa, desired)
+
+		assert.True(t, allowed)
+		assert.False(t, updateCR)
+	})
+
+	t.Run("no approval required by default", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Spec.RequireUpdateApproval = false
+
+		allowed, _ := reconcileUpdateApproval(consoleLogger, oa, desired)
+
+		assert.True(t, allowed)
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.PendingApprovalConditionType))
+	})
+}
+
+func TestReconcileVersion_UpdateApprovalImmutableImage(t *testing.T) {
+	actual := "1.202.0.20200808-120956"
+	desired := "1.203.0.20200908-220956"
+
+	base := dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent", Namespace: "dynatrace"},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+			AgentVersion:          desired,
+			RequireUpdateApproval: true,
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{
+			BaseOneAgentStatus: dynatracev1alpha1.BaseOneAgentStatus{UseImmutableImage: true},
+			Version:            actual,
+		},
+	}
+
+	actualImage, err := utils.BuildOneAgentImage(base.Spec.APIURL, actual)
+	require.NoError(t, err)
+	desiredImage, err := utils.BuildOneAgentImage(base.Spec.APIURL, desired)
+	require.NoError(t, err)
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	reconciler := &ReconcileOneAgent{client: c, apiReader: c, scheme: scheme.Scheme, logger: consoleLogger}
+
+	t.Run("image of approved version kept until the update is approved", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Annotations = map[string]string{annotationApproveVersion: "1.201.0.20200708-120956"}
+		assert.Equal(t, actualImage, rolloutImage(t, oa))
+
+		_, err := reconciler.reconcileVersionImmutableImage(oa, &dtclient.MockDynatraceClient{})
+		require.NoError(t, err)
+		assert.Equal(t, actual, oa.Status.Version)
+		require.NotNil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.PendingApprovalConditionType))
+	})
+
+	t.Run("image of desired version rolled out once approved", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Annotations = map[string]string{annotationApproveVersion: desired}
+		assert.Equal(t, desiredImage, rolloutImage(t, oa))
+
+		_, err := reconciler.reconcileVersionImmutableImage(oa, &dtclient.MockDynatraceClient{})
+		require.NoError(t, err)
+		assert.Equal(t, desired, oa.Status.Version)
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.PendingApprovalConditionType))
+
+		oa.Annotations = nil
+		assert.Equal(t, desiredImage, rolloutImage(t, oa), "approved version kept after the annotation is removed")
+	})
+}
@@ -1,9 +1,22 @@
 package oneagent
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/utils"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestNewerVersion(t *testing.T) {
@@ -26,3 +39,448 @@ func TestSameVersion(t *testing.T) {
 	assert.False(t, isDesiredNewer("1.202.2.12345", "1.202.2.12345", consoleLogger))
 	assert.False(t, isDesiredNewer("1.202.1.1", "1.202.1.1", consoleLogger))
 }
+
+func TestReconcileVersion_Downgrade(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	actual := "1.203.0.20200908-220956"
+	desired := "1.202.0.20200808-120956"
+
+	base := dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{Version: actual},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetLatestAgentVersion", dtclient.OsUnix, dtclient.InstallerTypeDefault).Return(desired, nil)
+
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	t.Run("reconcileVersion blocks downgrade by default", func(t *testing.T) {
+		oa := base.DeepCopy()
+
+		updateCR, err := reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+
+		assert.NoError(t, err)
+		assert.True(t, updateCR)
+		assert.Equal(t, actual, oa.Status.Version)
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.DowngradeBlockedConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonDowngradeNotAllowed, cond.Reason)
+	})
+
+	t.Run("reconcileVersion downgrades if allowed", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Spec.AllowDowngrade = true
+
+		updateCR, err := reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+
+		assert.NoError(t, err)
+		assert.True(t, updateCR)
+		assert.Equal(t, desired, oa.Status.Version)
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.DowngradeBlockedConditionType))
+	})
+
+	t.Run("reconcileDowngrade clears condition on upgrade", func(t *testing.T) {
+		oa := base.DeepCopy()
+		reconcileDowngrade(consoleLogger, oa, desired)
+		require.NotNil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.DowngradeBlockedConditionType))
+
+		allowed, updateCR := reconcileDowngrade(consoleLogger, oa, "1.204.0.20201008-120956")
+
+		assert.True(t, allowed)
+		assert.True(t, updateCR)
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.DowngradeBlockedConditionType))
+	})
+}
+
+func TestReconcileVersion_UpdatingCondition(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	actual := "1.202.0.20200808-120956"
+	desired := "1.203.0.20200908-220956"
+
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{
+			Version: actual,
+			Instances: map[string]dynatracev1alpha1.OneAgentInstance{
+				"node-1": {PodName: "oneagent-1", Version: actual},
+				"node-2": {PodName: "oneagent-2", Version: actual},
+			},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetLatestAgentVersion", dtclient.OsUnix, dtclient.InstallerTypeDefault).Return(desired, nil)
+
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+	}
+
+	updateCR, err := reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+	require.NoError(t, err)
+	assert.True(t, updateCR)
+
+	cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdatingConditionType)
+	require.NotNil(t, cond)
+	assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	assert.Equal(t, dynatracev1alpha1.ReasonUpdateInProgress, cond.Reason)
+	assert.Equal(t, "Updating OneAgent from version "+actual+" to "+desired, cond.Message)
+
+	oa.Status.Instances["node-1"] = dynatracev1alpha1.OneAgentInstance{PodName: "oneagent-1", Version: desired}
+	oa.Status.Instances["node-2"] = dynatracev1alpha1.OneAgentInstance{PodName: "oneagent-2", Version: desired}
+
+	updateCR, err = reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+	require.NoError(t, err)
+	assert.True(t, updateCR)
+
+	cond = oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdatingConditionType)
+	require.NotNil(t, cond)
+	assert.Equal(t, corev1.ConditionFalse, cond.Status)
+	assert.Equal(t, dynatracev1alpha1.ReasonUpdateCompleted, cond.Reason)
+
+	updateCR, err = reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+	require.NoError(t, err)
+	assert.False(t, updateCR)
+}
+
+func TestReconcileVersion_VersionHistory(t *testing.T) {
+	versions := []string{"1.201.0.20200808-120956", "1.202.0.20200908-220956", "1.203.0.20201008-120956"}
+	limit := int32(2)
+
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent", Namespace: "dynatrace"},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+			VersionHistoryLimit: &limit,
+		},
+	}
+
+	dtcMock := &dtclient.MockDynatraceClient{}
+	for _, v := range versions {
+		dtcMock.On("GetLatestAgentVersion", dtclient.OsUnix, dtclient.InstallerTypeDefault).Return(v, nil).Once()
+	}
+	dtcMock.On("GetLatestAgentVersion", dtclient.OsUnix, dtclient.InstallerTypeDefault).Return(versions[2], nil)
+
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+		now:       func() time.Time { return now },
+	}
+
+	for i := range versions {
+		now = now.Add(time.Duration(i) * time.Hour)
+		updateCR, err := reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+		require.NoError(t, err)
+		assert.True(t, updateCR)
+	}
+
+	require.Len(t, oa.Status.VersionHistory, 2, "history capped")
+	assert.Equal(t, versions[1], oa.Status.VersionHistory[0].Version, "oldest entry dropped")
+	assert.Equal(t, versions[2], oa.Status.VersionHistory[1].Version)
+	assert.True(t, oa.Status.VersionHistory[0].Timestamp.Before(&oa.Status.VersionHistory[1].Timestamp))
+
+	_, err := reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+	require.NoError(t, err)
+	assert.Len(t, oa.Status.VersionHistory, 2, "same version not recorded twice")
+	assert.Equal(t, versions[2], oa.Status.VersionHistory[1].Version)
+}
+
+func TestReconcileUpdateSettings(t *testing.T) {
+	deployed := "1.202.0.20200808-120956"
+
+	newInstance := func(spec dynatracev1alpha1.OneAgentSpec) *dynatracev1alpha1.OneAgent {
+		return &dynatracev1alpha1.OneAgent{
+			Spec:   spec,
+			Status: dynatracev1alpha1.OneAgentStatus{Version: deployed},
+		}
+	}
+
+	t.Run("no condition if updates enabled", func(t *testing.T) {
+		oa := newInstance(dynatracev1alpha1.OneAgentSpec{AgentVersion: "1.203.0.20200908-220956", AllowDowngrade: true})
+		assert.False(t, reconcileUpdateSettings(consoleLogger, oa))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdateSettingsConflictConditionType))
+	})
+
+	t.Run("consistent", func(t *testing.T) {
+		oa := newInstance(dynatracev1alpha1.OneAgentSpec{DisableAgentUpdate: true, AgentVersion: deployed})
+		assert.True(t, reconcileUpdateSettings(consoleLogger, oa))
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdateSettingsConflictConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionFalse, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonUpdateSettingsConsistent, cond.Reason)
+	})
+
+	t.Run("pinned version differs with updates disabled", func(t *testing.T) {
+		oa := newInstance(dynatracev1alpha1.OneAgentSpec{DisableAgentUpdate: true, AgentVersion: "1.203.0.20200908-220956"})
+		assert.True(t, reconcileUpdateSettings(consoleLogger, oa))
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdateSettingsConflictConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonConflictingUpdateSettings, cond.Reason)
+		assert.Equal(t, ".spec.agentVersion 1.203.0.20200908-220956 is not applied, version "+deployed+" is kept as .spec.disableAgentUpdate is set", cond.Message)
+
+		assert.False(t, reconcileUpdateSettings(consoleLogger, oa))
+	})
+
+	t.Run("downgrade allowed with updates disabled", func(t *testing.T) {
+		oa := newInstance(dynatracev1alpha1.OneAgentSpec{DisableAgentUpdate: true, AllowDowngrade: true})
+		assert.True(t, reconcileUpdateSettings(consoleLogger, oa))
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdateSettingsConflictConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, ".spec.allowDowngrade has no effect as .spec.disableAgentUpdate is set", cond.Message)
+	})
+
+	t.Run("all settings conflicting", func(t *testing.T) {
+		oa := newInstance(dynatracev1alpha1.OneAgentSpec{DisableAgentUpdate: true, AgentVersion: "1.201.0.20200708-120956", AllowDowngrade: true})
+		assert.True(t, reconcileUpdateSettings(consoleLogger, oa))
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdateSettingsConflictConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, ".spec.agentVersion 1.201.0.20200708-120956 is not applied, version "+deployed+" is kept as .spec.disableAgentUpdate is set, "+
+			".spec.allowDowngrade has no effect as .spec.disableAgentUpdate is set", cond.Message)
+
+		oa.Spec.DisableAgentUpdate = false
+		assert.True(t, reconcileUpdateSettings(consoleLogger, oa), "condition removed")
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdateSettingsConflictConditionType))
+	})
+
+	t.Run("auto-update with updates disabled", func(t *testing.T) {
+		enabled, disabled := true, false
+
+		oa := newInstance(dynatracev1alpha1.OneAgentSpec{DisableAgentUpdate: true, AutoUpdate: &enabled})
+		assert.True(t, reconcileUpdateSettings(consoleLogger, oa))
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdateSettingsConflictConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, ".spec.disableAgentUpdate doesn't stop the OneAgent from updating itself as .spec.autoUpdate is enabled", cond.Message)
+
+		oa.Spec.AutoUpdate = &disabled
+		assert.True(t, reconcileUpdateSettings(consoleLogger, oa))
+		assert.Equal(t, dynatracev1alpha1.ReasonUpdateSettingsConsistent, oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdateSettingsConflictConditionType).Reason)
+	})
+}
+
+func TestBuildAutoUpdateArgs(t *testing.T) {
+	enabled, disabled := true, false
+
+	oa := newOneAgent()
+	assert.Empty(t, buildAutoUpdateArgs(&oa.Spec, nil), "enabled by default")
+
+	oa.Spec.AutoUpdate = &enabled
+	assert.Empty(t, buildAutoUpdateArgs(&oa.Spec, nil))
+
+	oa.Spec.AutoUpdate = &disabled
+	assert.Equal(t, []string{"--set-auto-update-enabled=false"}, buildAutoUpdateArgs(&oa.Spec, nil))
+	assert.Contains(t, newPodSpecForCR(oa, false, consoleLogger).Containers[0].Args, "--set-auto-update-enabled=false")
+
+	oa.Spec.Args = []string{"--set-auto-update-enabled=true"}
+	args := newPodSpecForCR(oa, false, consoleLogger).Containers[0].Args
+	assert.Contains(t, args, "--set-auto-update-enabled=true", "spec args take precedence")
+	assert.NotContains(t, args, "--set-auto-update-enabled=false")
+}
+
+func TestReconcileVersion_VersionSource(t *testing.T) {
+	namespace := "dynatrace"
+	actual := "1.202.0.20200808-120956"
+	desired := "1.203.0.20200908-220956"
+
+	source := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: namespace},
+		Status:     dynatracev1alpha1.OneAgentStatus{Version: desired},
+	}
+	follower := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: "follower", Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+			VersionSource: "source",
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{Version: actual},
+	}
+
+	// GetLatestAgentVersion isn't expected to be called.
+	dtcMock := &dtclient.MockDynatraceClient{}
+
+	t.Run("version taken from source", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, source)
+		reconciler := &ReconcileOneAgent{client: c, apiReader: c, scheme: scheme.Scheme, logger: consoleLogger}
+		oa := follower.DeepCopy()
+
+		updateCR, err := reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+		require.NoError(t, err)
+		assert.True(t, updateCR)
+		assert.Equal(t, desired, oa.Status.Version)
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.VersionSourceConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonVersionSourceApplied, cond.Reason)
+	})
+
+	t.Run("version kept while source is absent", func(t *testing.T) {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme)
+		reconciler := &ReconcileOneAgent{client: c, apiReader: c, scheme: scheme.Scheme, logger: consoleLogger}
+		oa := follower.DeepCopy()
+
+		updateCR, err := reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+		require.NoError(t, err)
+		assert.True(t, updateCR)
+		assert.Equal(t, actual, oa.Status.Version)
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.VersionSourceConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionFalse, cond.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonVersionSourceNotFound, cond.Reason)
+	})
+
+	t.Run("version source must not be the instance itself", func(t *testing.T) {
+		oa := follower.DeepCopy()
+		oa.Spec.VersionSource = oa.Name
+		assert.EqualError(t, validate(oa), ".spec.versionSource must not reference the OneAgent itself")
+	})
+}
+
+func TestReconcileVersion_VersionBranch(t *testing.T) {
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent", Namespace: "dynatrace"},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+			VersionBranch: "1.247",
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{Version: "1.247.1.20220901-120000"},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	reconciler := &ReconcileOneAgent{client: c, apiReader: c, scheme: scheme.Scheme, logger: consoleLogger}
+
+	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetLatestAgentVersionForBranch", dtclient.OsUnix, dtclient.InstallerTypeDefault, "1.247").Return("1.247.3.20220915-101530", nil)
+
+	updateCR, err := reconciler.reconcileVersion(consoleLogger, oa, dtcMock)
+	require.NoError(t, err)
+	assert.True(t, updateCR)
+	assert.Equal(t, "1.247.3.20220915-101530", oa.Status.Version)
+	mock.AssertExpectationsForObjects(t, dtcMock)
+
+	oa.Spec.VersionBranch = "1.247.x"
+	assert.EqualError(t, validate(oa), `.spec.versionBranch must be a version prefix like 1.247, got "1.247.x"`)
+}
+
+// rolloutImage runs the rollout for the instance and returns the image of the OneAgent container on the DaemonSet
+func rolloutImage(t *testing.T, oa *dynatracev1alpha1.OneAgent) string {
+	return rolloutImageAt(t, oa, time.Now())
+}
+
+func rolloutImageAt(t *testing.T, oa *dynatracev1alpha1.OneAgent, now time.Time) string {
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	reconciler := &ReconcileOneAgent{
+		client:    c,
+		apiReader: c,
+		scheme:    scheme.Scheme,
+		logger:    consoleLogger,
+		now:       func() time.Time { return now },
+	}
+
+	_, err := reconciler.reconcileRollout(consoleLogger, oa, &dtclient.MockDynatraceClient{})
+	require.NoError(t, err)
+
+	var ds appsv1.DaemonSet
+	require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: oa.Name, Namespace: oa.Namespace}, &ds))
+	return ds.Spec.Template.Spec.Containers[0].Image
+}
+
+func TestReconcileVersion_DowngradeImmutableImage(t *testing.T) {
+	actual := "1.203.0.20200908-220956"
+	desired := "1.202.0.20200808-120956"
+
+	base := dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent", Namespace: "dynatrace"},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+			AgentVersion: desired,
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{
+			BaseOneAgentStatus: dynatracev1alpha1.BaseOneAgentStatus{UseImmutableImage: true},
+			Version:            actual,
+		},
+	}
+
+	actualImage, err := utils.BuildOneAgentImage(base.Spec.APIURL, actual)
+	require.NoError(t, err)
+	desiredImage, err := utils.BuildOneAgentImage(base.Spec.APIURL, desired)
+	require.NoError(t, err)
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+	reconciler := &ReconcileOneAgent{client: c, apiReader: c, scheme: scheme.Scheme, logger: consoleLogger}
+
+	t.Run("image of current version kept while downgrade is blocked", func(t *testing.T) {
+		oa := base.DeepCopy()
+		assert.Equal(t, actualImage, rolloutImage(t, oa))
+
+		updateCR, err := reconciler.reconcileVersionImmutableImage(oa, &dtclient.MockDynatraceClient{})
+		require.NoError(t, err)
+		assert.True(t, updateCR)
+		assert.Equal(t, actual, oa.Status.Version)
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.DowngradeBlockedConditionType)
+		require.NotNil(t, cond)
+		assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	})
+
+	t.Run("image of desired version rolled out if downgrade is allowed", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Spec.AllowDowngrade = true
+		assert.Equal(t, desiredImage, rolloutImage(t, oa))
+
+		_, err := reconciler.reconcileVersionImmutableImage(oa, &dtclient.MockDynatraceClient{})
+		require.NoError(t, err)
+		assert.Equal(t, desired, oa.Status.Version)
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.DowngradeBlockedConditionType))
+	})
+
+	t.Run("image of desired version rolled out on upgrade", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Status.Version = "1.201.0.20200708-120956"
+		assert.Equal(t, desiredImage, rolloutImage(t, oa))
+	})
+}
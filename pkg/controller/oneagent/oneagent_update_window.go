@@ -0,0 +1,184 @@
+package oneagent
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// updateWindow is the parsed form of an UpdateWindow from the spec
+type updateWindow struct {
+	days     map[time.Weekday]bool // Empty for every day
+	start    time.Duration         // Offset from midnight
+	end      time.Duration         // Offset from midnight, lower or equal than start if the window lasts until the next day
+	location *time.Location
+}
+
+// parseUpdateWindow parses an UpdateWindow from the spec
+func parseUpdateWindow(w dynatracev1alpha1.UpdateWindow) (updateWindow, error) {
+	var uw updateWindow
+	var err error
+
+	if uw.start, err = parseTimeOfDay(w.StartTime); err != nil {
+		return uw, fmt.Errorf("invalid start time %q", w.StartTime)
+	}
+	if uw.end, err = parseTimeOfDay(w.EndTime); err != nil {
+		return uw, fmt.Errorf("invalid end time %q", w.EndTime)
+	}
+
+	uw.location = time.UTC
+	if w.Timezone != "" {
+		if uw.location, err = time.LoadLocation(w.Timezone); err != nil {
+			return uw, fmt.Errorf("unknown timezone %q", w.Timezone)
+		}
+	}
+
+	uw.days = map[time.Weekday]bool{}
+	for _, d := range w.Days {
+		day, ok := parseWeekday(d)
+		if !ok {
+			return uw, fmt.Errorf("unknown day %q", d)
+		}
+		uw.days[day] = true
+	}
+
+	return uw, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// startsOn returns true if the window starts on the given day.
+func (w updateWindow) startsOn(day time.Weekday) bool {
+	return len(w.days) == 0 || w.days[day]
+}
+
+// contains returns true if t is within the window.
+func (w updateWindow) contains(t time.Time) bool {
+	t = t.In(w.location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, w.location)
+	offset := t.Sub(midnight)
+
+	if w.start < w.end {
+		return w.startsOn(t.Weekday()) && offset >= w.start && offset < w.end
+	}
+
+	// The window lasts until the next day, so it may have started yesterday.
+	yesterday := midnight.AddDate(0, 0, -1).Weekday()
+	return (w.startsOn(t.Weekday()) && offset >= w.start) || (w.startsOn(yesterday) && offset < w.end)
+}
+
+// nextStart returns the next time after t at which the window starts.
+func (w updateWindow) nextStart(t time.Time) time.Time {
+	t = t.In(w.location)
+	for i := 0; i <= 7; i++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, 0, 0, 0, 0, w.location)
+		start := day.Add(w.start)
+		if w.startsOn(day.Weekday()) && start.After(t) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// validateUpdateWindows returns the issues found on .spec.updateWindows
+func validateUpdateWindows(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	var msg []string
+	for i, w := range spec.UpdateWindows {
+		if _, err := parseUpdateWindow(w); err != nil {
+			msg = append(msg, fmt.Sprintf(".spec.updateWindows[%d] has %s", i, err))
+		}
+	}
+	return msg
+}
+
+// findUpdateWindow returns true if the given time is within one of the windows on .spec.updateWindows, or otherwise the
+// start of the next one. The start is zero if no window starts anymore.
+func findUpdateWindow(spec *dynatracev1alpha1.OneAgentSpec, now time.Time) (bool, time.Time) {
+	var next time.Time
+	for _, w := range spec.UpdateWindows {
+		uw, err := parseUpdateWindow(w)
+		if err != nil { // Already reported by validate()
+			continue
+		}
+
+		if uw.contains(now) {
+			return true, time.Time{}
+		}
+
+		if start := uw.nextStart(now); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return false, next
+}
+
+// isUpdateDeferred returns true if OneAgent updates have to wait for the next window on .spec.updateWindows at the
+// given time, in the same way as reconcileUpdateWindows.
+func isUpdateDeferred(spec *dynatracev1alpha1.OneAgentSpec, now time.Time) bool {
+	if len(spec.UpdateWindows) == 0 {
+		return false
+	}
+	inside, next := findUpdateWindow(spec, now)
+	return !inside && !next.IsZero()
+}
+
+// reconcileUpdateWindows checks whether OneAgent updates are allowed at the given time, and sets the UpdateDeferred
+// condition accordingly. The condition is only set if update windows are configured.
+//
+// Returns true if the condition has changed, and, if the time is outside all update windows, how long to wait until
+// the next one starts.
+func reconcileUpdateWindows(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet, now time.Time) (bool, time.Duration) {
+	spec := instance.GetOneAgentSpec()
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if len(spec.UpdateWindows) == 0 {
+		return conditions.RemoveCondition(dynatracev1alpha1.UpdateDeferredConditionType), 0
+	}
+
+	inside, next := findUpdateWindow(spec, now)
+	if inside {
+		return conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.UpdateDeferredConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonInsideUpdateWindow,
+			Message: "OneAgent updates are allowed within the current update window",
+		}), 0
+	}
+
+	if next.IsZero() {
+		return false, 0
+	}
+
+	upd := conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.UpdateDeferredConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonOutsideUpdateWindow,
+		Message: fmt.Sprintf("OneAgent updates are deferred until the next update window starting at %s", next.Format(time.RFC3339)),
+	})
+	if upd {
+		logger.Info("OneAgent updates deferred until the next update window", "next", next)
+	}
+	return upd, next.Sub(now)
+}
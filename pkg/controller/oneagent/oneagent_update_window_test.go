@@ -0,0 +1,180 @@
+package oneagent
+
+import (
+	"testing"
+	"time"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/utils"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/dtclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// 2020-09-05 is a Saturday.
+var saturdayMorning = time.Date(2020, 9, 5, 3, 0, 0, 0, time.UTC)
+
+func TestUpdateWindow_Contains(t *testing.T) {
+	parse := func(w dynatracev1alpha1.UpdateWindow) updateWindow {
+		uw, err := parseUpdateWindow(w)
+		require.NoError(t, err)
+		return uw
+	}
+
+	weekend := parse(dynatracev1alpha1.UpdateWindow{Days: []string{"Sat", "sunday"}, StartTime: "02:00", EndTime: "04:00"})
+	assert.True(t, weekend.contains(saturdayMorning))
+	assert.False(t, weekend.contains(saturdayMorning.Add(1*time.Hour)))
+	assert.False(t, weekend.contains(saturdayMorning.AddDate(0, 0, 2)))
+	assert.Equal(t, time.Date(2020, 9, 6, 2, 0, 0, 0, time.UTC), weekend.nextStart(saturdayMorning))
+
+	overnight := parse(dynatracev1alpha1.UpdateWindow{Days: []string{"Friday"}, StartTime: "22:00", EndTime: "04:00"})
+	assert.True(t, overnight.contains(saturdayMorning))
+	assert.False(t, overnight.contains(saturdayMorning.AddDate(0, 0, 1)))
+	assert.Equal(t, time.Date(2020, 9, 11, 22, 0, 0, 0, time.UTC), overnight.nextStart(saturdayMorning))
+
+	daily := parse(dynatracev1alpha1.UpdateWindow{StartTime: "04:00", EndTime: "05:00", Timezone: "Europe/Vienna"})
+	assert.True(t, daily.contains(saturdayMorning.Add(-1*time.Hour))) // 04:00 CEST
+	assert.False(t, daily.contains(saturdayMorning))
+	assert.Equal(t, time.Date(2020, 9, 6, 2, 0, 0, 0, time.UTC), daily.nextStart(saturdayMorning).UTC())
+}
+
+func TestValidateUpdateWindows(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.UpdateWindows = []dynatracev1alpha1.UpdateWindow{{StartTime: "02:00", EndTime: "04:00"}}
+	assert.NoError(t, validate(oa))
+
+	oa.Spec.UpdateWindows = []dynatracev1alpha1.UpdateWindow{
+		{StartTime: "2am", EndTime: "04:00"},
+		{Days: []string{"Someday"}, StartTime: "02:00", EndTime: "04:00"},
+		{StartTime: "02:00", EndTime: "04:00", Timezone: "Mars/Olympus_Mons"},
+	}
+	assert.EqualError(t, validate(oa), `.spec.updateWindows[0] has invalid start time "2am", `+
+		`.spec.updateWindows[1] has unknown day "Someday", `+
+		`.spec.updateWindows[2] has unknown timezone "Mars/Olympus_Mons"`)
+}
+
+func TestReconcileScheduledVersion(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oldVersion := "1.200.0.20200801-120000"
+	newVersion := "1.201.0.20200901-120000"
+
+	newInstance := func() *dynatracev1alpha1.OneAgent {
+		return &dynatracev1alpha1.OneAgent{
+			ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+			Spec: dynatracev1alpha1.OneAgentSpec{
+				BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+					APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				},
+				UpdateWindows: []dynatracev1alpha1.UpdateWindow{
+					{Days: []string{"Saturday"}, StartTime: "02:00", EndTime: "04:00"},
+				},
+			},
+			Status: dynatracev1alpha1.OneAgentStatus{Version: oldVersion},
+		}
+	}
+
+	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetLatestAgentVersion", "unix", "default").Return(newVersion, nil)
+
+	newReconciler := func(now time.Time) *ReconcileOneAgent {
+		c := fake.NewFakeClientWithScheme(scheme.Scheme)
+		return &ReconcileOneAgent{
+			client:    c,
+			apiReader: c,
+			scheme:    scheme.Scheme,
+			logger:    consoleLogger,
+			now:       func() time.Time { return now },
+		}
+	}
+
+	t.Run("version updated within update window", func(t *testing.T) {
+		oa := newInstance()
+
+		upd, wait, err := newReconciler(saturdayMorning).reconcileScheduledVersion(consoleLogger, oa, dtcMock)
+		require.NoError(t, err)
+		assert.True(t, upd)
+		assert.Zero(t, wait)
+		assert.Equal(t, newVersion, oa.Status.Version)
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdateDeferredConditionType)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionFalse, cond.Status)
+			assert.Equal(t, dynatracev1alpha1.ReasonInsideUpdateWindow, cond.Reason)
+		}
+	})
+
+	t.Run("version kept outside update window", func(t *testing.T) {
+		oa := newInstance()
+
+		upd, wait, err := newReconciler(saturdayMorning.Add(-2*time.Hour)).reconcileScheduledVersion(consoleLogger, oa, dtcMock)
+		require.NoError(t, err)
+		assert.True(t, upd)
+		assert.Equal(t, 1*time.Hour, wait)
+		assert.Equal(t, oldVersion, oa.Status.Version)
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdateDeferredConditionType)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionTrue, cond.Status)
+			assert.Equal(t, dynatracev1alpha1.ReasonOutsideUpdateWindow, cond.Reason)
+			assert.Contains(t, cond.Message, "2020-09-05T02:00:00Z")
+		}
+	})
+
+	t.Run("version updated without update windows", func(t *testing.T) {
+		oa := newInstance()
+		oa.Spec.UpdateWindows = nil
+
+		_, wait, err := newReconciler(saturdayMorning.Add(-2*time.Hour)).reconcileScheduledVersion(consoleLogger, oa, dtcMock)
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+		assert.Equal(t, newVersion, oa.Status.Version)
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.UpdateDeferredConditionType))
+	})
+}
+
+func TestReconcileScheduledVersion_ImmutableImage(t *testing.T) {
+	oldVersion := "1.200.0.20200801-120000"
+	newVersion := "1.201.0.20200901-120000"
+
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent", Namespace: "dynatrace"},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+			},
+			AgentVersion: newVersion,
+			UpdateWindows: []dynatracev1alpha1.UpdateWindow{
+				{Days: []string{"Saturday"}, StartTime: "02:00", EndTime: "04:00"},
+			},
+		},
+		Status: dynatracev1alpha1.OneAgentStatus{
+			BaseOneAgentStatus: dynatracev1alpha1.BaseOneAgentStatus{UseImmutableImage: true},
+			Version:            oldVersion,
+		},
+	}
+
+	oldImage, err := utils.BuildOneAgentImage(oa.Spec.APIURL, oldVersion)
+	require.NoError(t, err)
+	newImage, err := utils.BuildOneAgentImage(oa.Spec.APIURL, newVersion)
+	require.NoError(t, err)
+
+	t.Run("image kept outside update window", func(t *testing.T) {
+		assert.Equal(t, oldImage, rolloutImageAt(t, oa.DeepCopy(), saturdayMorning.Add(-2*time.Hour)))
+	})
+
+	t.Run("image updated within update window", func(t *testing.T) {
+		assert.Equal(t, newImage, rolloutImageAt(t, oa.DeepCopy(), saturdayMorning))
+	})
+
+	t.Run("image updated without update windows", func(t *testing.T) {
+		instance := oa.DeepCopy()
+		instance.Spec.UpdateWindows = nil
+		assert.Equal(t, newImage, rolloutImageAt(t, instance, saturdayMorning.Add(-2*time.Hour)))
+	})
+}
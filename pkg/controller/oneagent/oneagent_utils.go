@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
 	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -27,7 +30,8 @@ func mergeLabels(labels ...map[string]string) map[string]string {
 	return res
 }
 
-// buildLabels returns generic labels based on the name given for a Dynatrace OneAgent
+// buildLabels returns generic labels based on the name given for a Dynatrace OneAgent. They are used as the selector
+// of the DaemonSet, which is immutable, so they must not depend on the spec.
 func buildLabels(name string) map[string]string {
 	return map[string]string{
 		"dynatrace": "oneagent",
@@ -35,6 +39,12 @@ func buildLabels(name string) map[string]string {
 	}
 }
 
+// buildPodLabels returns the labels for the OneAgent pods: the custom labels from .spec.labels and the selector labels
+// from buildLabels. Custom labels cannot override the selector labels, so changing them never affects the selector.
+func buildPodLabels(instance dynatracev1alpha1.BaseOneAgentDaemonSet) map[string]string {
+	return mergeLabels(instance.GetOneAgentSpec().Labels, buildLabels(instance.GetName()))
+}
+
 // getPodReadyState determines the overall ready state of a Pod.
 // Returns true if all containers in the Pod are ready.
 func getPodReadyState(p *corev1.Pod) bool {
@@ -50,21 +60,310 @@ func getPodReadyState(p *corev1.Pod) bool {
 //
 // Return an error in the following conditions
 // - APIURL empty
+// - negative termination grace period
+// - version source referencing the instance itself, or a malformed version branch
+// - host tags, host properties, cluster name or feature flags with an invalid format
+// - installer arguments for unknown operating systems
+// - relative log monitoring paths
+// - unknown deployment type, or negative replicas
+// - canary rollout percentage out of range, or unknown promotion
+// - sidecars or init containers with a conflicting name, or mounting unknown volumes
+// - a relative storage host path, or an invalid emptyDir cache size limit
+// - readiness gates without condition type
+// - resources by node class without node class label
+// - a preStop hook without exactly one well-formed handler
+// - unknown security preset
+// - node overrides without unique name or node selector, or on a Deployment
+// - unknown preemption policy
+// - an invalid namespace selector
+// - an image repository with tag, digest or invalid format
+// - a DaemonSet name which isn't a valid object name, or on a Deployment
 func validate(cr dynatracev1alpha1.BaseOneAgentDaemonSet) error {
 	var msg []string
 	if cr.GetOneAgentSpec().APIURL == "" {
 		msg = append(msg, ".spec.apiUrl is missing")
 	}
+	msg = append(msg, validateHostTags(cr.GetOneAgentSpec())...)
+	if s := cr.GetOneAgentSpec().TerminationGracePeriodSeconds; s != nil && *s < 0 {
+		msg = append(msg, ".spec.terminationGracePeriodSeconds must not be negative")
+	}
+	if cr.GetOneAgentSpec().VersionSource == cr.GetName() && cr.GetName() != "" {
+		msg = append(msg, ".spec.versionSource must not reference the OneAgent itself")
+	}
+	if b := cr.GetOneAgentSpec().VersionBranch; b != "" && !versionBranchRegexp.MatchString(b) {
+		msg = append(msg, fmt.Sprintf(".spec.versionBranch must be a version prefix like 1.247, got %q", b))
+	}
+	msg = append(msg, validateFeatureFlags(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateInstallerArgs(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateLogMonitoring(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateUpdateWindows(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateDeploymentType(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateCanaryRollout(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateSidecars(cr)...)
+	msg = append(msg, validateStorageHostPath(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateEmptyDirCache(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateReadinessGates(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateNodeClasses(cr.GetOneAgentSpec())...)
+	msg = append(msg, validatePreStop(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateSecurityPreset(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateNodeOverrides(cr.GetOneAgentSpec())...)
+	if p := cr.GetOneAgentSpec().PreemptionPolicy; p != nil && *p != corev1.PreemptNever && *p != corev1.PreemptLowerPriority {
+		msg = append(msg, fmt.Sprintf(".spec.preemptionPolicy must be %s or %s, got %q", corev1.PreemptNever, corev1.PreemptLowerPriority, *p))
+	}
+	msg = append(msg, validateNamespaceSelector(cr.GetOneAgentSpec())...)
+	msg = append(msg, validateDaemonSetName(cr.GetOneAgentSpec())...)
+	if repo := cr.GetOneAgentSpec().ImageRepository; repo != "" && !imageRepositoryRegexp.MatchString(repo) {
+		msg = append(msg, fmt.Sprintf(".spec.imageRepository must be an image repository without tag or digest, got %q", repo))
+	}
 	if len(msg) > 0 {
 		return errors.New(strings.Join(msg, ", "))
 	}
 	return nil
 }
 
+// validateReadinessGates returns the issues found on .spec.readinessGates
+func validateReadinessGates(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	var msg []string
+	for i, gate := range spec.ReadinessGates {
+		if strings.TrimSpace(string(gate.ConditionType)) == "" {
+			msg = append(msg, fmt.Sprintf(".spec.readinessGates[%d] has no condition type", i))
+		}
+	}
+	return msg
+}
+
+// validatePreStop returns the issues found on .spec.preStop, which must set exactly one handler
+func validatePreStop(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	h := spec.PreStop
+	if h == nil {
+		return nil
+	}
+
+	handlers := 0
+	var msg []string
+	if h.Exec != nil {
+		handlers++
+		if len(h.Exec.Command) == 0 {
+			msg = append(msg, ".spec.preStop.exec.command must not be empty")
+		}
+	}
+	if h.HTTPGet != nil {
+		handlers++
+		if h.HTTPGet.Port.IntValue() == 0 && h.HTTPGet.Port.StrVal == "" {
+			msg = append(msg, ".spec.preStop.httpGet.port must be set")
+		}
+	}
+	if h.TCPSocket != nil {
+		handlers++
+		if h.TCPSocket.Port.IntValue() == 0 && h.TCPSocket.Port.StrVal == "" {
+			msg = append(msg, ".spec.preStop.tcpSocket.port must be set")
+		}
+	}
+	if handlers != 1 {
+		msg = append(msg, ".spec.preStop must set exactly one of exec, httpGet or tcpSocket")
+	}
+	return msg
+}
+
+// operatorVersionHostProperty is the host property the operator sets on its own
+const operatorVersionHostProperty = "OperatorVersion"
+
+// clusterNameHostProperty is the host property the operator sets to the name of the cluster
+const clusterNameHostProperty = "ClusterName"
+
+var (
+	hostPropertyKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+	hostTagValueRegexp    = regexp.MustCompile(`^[^\s"']+$`)
+	versionBranchRegexp   = regexp.MustCompile(`^\d+(\.\d+)*$`)
+)
+
+// validateHostTags returns the issues found on .spec.hostTags, .spec.hostProperties and .spec.clusterName
+func validateHostTags(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	var msg []string
+
+	for _, tag := range spec.HostTags {
+		if !hostTagValueRegexp.MatchString(tag) {
+			msg = append(msg, fmt.Sprintf(".spec.hostTags contains invalid tag %q", tag))
+		}
+	}
+
+	for _, key := range sortedKeys(spec.HostProperties) {
+		if !hostPropertyKeyRegexp.MatchString(key) {
+			msg = append(msg, fmt.Sprintf(".spec.hostProperties contains invalid key %q", key))
+		} else if key == operatorVersionHostProperty || key == clusterNameHostProperty {
+			msg = append(msg, fmt.Sprintf(".spec.hostProperties must not set reserved key %q", key))
+		} else if !hostTagValueRegexp.MatchString(spec.HostProperties[key]) {
+			msg = append(msg, fmt.Sprintf(".spec.hostProperties contains invalid value for key %q", key))
+		}
+	}
+
+	if name := spec.ClusterName; name != "" && !hostTagValueRegexp.MatchString(name) {
+		msg = append(msg, fmt.Sprintf(".spec.clusterName must not contain whitespace or quotes, got %q", name))
+	}
+
+	return msg
+}
+
+// validateSidecars returns the issues found on .spec.sidecars and .spec.initContainers. Their containers need names
+// unique within the pod other than the one of the OneAgent container, and can only mount volumes available on the
+// OneAgent pods.
+func validateSidecars(instance dynatracev1alpha1.BaseOneAgentDaemonSet) []string {
+	var msg []string
+
+	volumes := prepareVolumes(instance)
+	customVolumes, _, _ := prepareCustomVolumes(instance, volumes, prepareVolumeMounts(instance))
+	volumeNames := map[string]bool{}
+	for _, v := range append(volumes, customVolumes...) {
+		volumeNames[v.Name] = true
+	}
+
+	names := map[string]bool{oneAgentContainerName: true}
+	validateContainers := func(field string, containers []corev1.Container) {
+		for i, container := range containers {
+			if container.Name == "" {
+				msg = append(msg, fmt.Sprintf("%s[%d] is missing a name", field, i))
+			} else if names[container.Name] {
+				msg = append(msg, fmt.Sprintf("%s contains duplicate container name %q", field, container.Name))
+			}
+			names[container.Name] = true
+
+			for _, m := range container.VolumeMounts {
+				if !volumeNames[m.Name] {
+					msg = append(msg, fmt.Sprintf("%s[%d] mounts unknown volume %q", field, i, m.Name))
+				}
+			}
+		}
+	}
+
+	validateContainers(".spec.sidecars", instance.GetOneAgentSpec().Sidecars)
+	validateContainers(".spec.initContainers", instance.GetOneAgentSpec().InitContainers)
+
+	return msg
+}
+
+// buildHostTagArgs returns the installer arguments for .spec.hostTags and .spec.hostProperties. Arguments are
+// returned in a stable order, without duplicates, and skipping the ones already present in existingArgs.
+func buildHostTagArgs(spec *dynatracev1alpha1.OneAgentSpec, existingArgs []string) []string {
+	seen := map[string]bool{}
+	for _, arg := range existingArgs {
+		seen[arg] = true
+	}
+
+	var args []string
+	add := func(arg string) {
+		if !seen[arg] {
+			seen[arg] = true
+			args = append(args, arg)
+		}
+	}
+
+	for _, key := range sortedKeys(spec.HostProperties) {
+		add(fmt.Sprintf("--set-host-property=%s=%s", key, spec.HostProperties[key]))
+	}
+
+	for _, tag := range spec.HostTags {
+		add("--set-host-tag=" + tag)
+	}
+
+	return args
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Limits enforced by Kubernetes on a pod's DNS config
+const (
+	maxDNSNameservers   = 3
+	maxDNSSearchDomains = 6
+)
+
+// reconcileDNSConfig checks whether .spec.dnsConfig can be used in combination with .spec.dnsPolicy, and reflects the
+// outcome on the DNSConfig condition. The condition is only set if either a DNS config or the None DNS policy is used.
+//
+// Returns true if the condition has changed, and an error if Kubernetes would reject the combination.
+func reconcileDNSConfig(instance dynatracev1alpha1.BaseOneAgentDaemonSet) (bool, error) {
+	spec := instance.GetOneAgentSpec()
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if spec.DNSConfig == nil && spec.DNSPolicy != corev1.DNSNone {
+		return conditions.RemoveCondition(dynatracev1alpha1.DNSConfigConditionType), nil
+	}
+
+	var msg string
+	if spec.DNSPolicy == corev1.DNSNone && (spec.DNSConfig == nil || len(spec.DNSConfig.Nameservers) == 0) {
+		msg = fmt.Sprintf(".spec.dnsConfig must set at least one nameserver when .spec.dnsPolicy is %s", corev1.DNSNone)
+	} else if len(spec.DNSConfig.Nameservers) > maxDNSNameservers {
+		msg = fmt.Sprintf(".spec.dnsConfig must not set more than %d nameservers", maxDNSNameservers)
+	} else if len(spec.DNSConfig.Searches) > maxDNSSearchDomains {
+		msg = fmt.Sprintf(".spec.dnsConfig must not set more than %d search domains", maxDNSSearchDomains)
+	}
+
+	if msg != "" {
+		upd := conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.DNSConfigConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonDNSConfigInvalid,
+			Message: msg,
+		})
+		return upd, errors.New(msg)
+	}
+
+	msg = "DNS config is applied as is"
+	if spec.DNSPolicy != corev1.DNSNone {
+		msg = fmt.Sprintf("DNS config is merged with the settings generated from the DNS policy, set .spec.dnsPolicy to %s for full control", corev1.DNSNone)
+	}
+
+	return conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.DNSConfigConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonDNSConfigValid,
+		Message: msg,
+	}), nil
+}
+
+// reconcileTerminationGracePeriod sets the ImmediateTermination condition if .spec.terminationGracePeriodSeconds is 0,
+// which kills the OneAgent without flushing buffered data. The condition is only set if a grace period is configured.
+//
+// Returns true if the condition has changed.
+func reconcileTerminationGracePeriod(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	seconds := instance.GetOneAgentSpec().TerminationGracePeriodSeconds
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if seconds == nil {
+		return conditions.RemoveCondition(dynatracev1alpha1.ImmediateTerminationConditionType)
+	}
+
+	if *seconds > 0 {
+		return conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.ImmediateTerminationConditionType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonGracePeriodSet,
+			Message: fmt.Sprintf("OneAgent pods get %d seconds to shut down", *seconds),
+		})
+	}
+
+	if conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.ImmediateTerminationConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonNoGracePeriod,
+		Message: "OneAgent pods are killed immediately on shutdown and may lose buffered data",
+	}) {
+		logger.Info("OneAgent pods are configured without termination grace period")
+		return true
+	}
+	return false
+}
+
 func (r *ReconcileOneAgent) determineOneAgentPhase(instance dynatracev1alpha1.BaseOneAgentDaemonSet) (bool, error) {
 	var phaseChanged bool
 	dsActual := &appsv1.DaemonSet{}
-	err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.GetName(), Namespace: instance.GetNamespace()}, dsActual)
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: daemonSetName(instance), Namespace: instance.GetNamespace()}, dsActual)
 
 	if k8serrors.IsNotFound(err) {
 		return false, nil
@@ -46,6 +46,69 @@ func TestOneAgent_Validate(t *testing.T) {
 	assert.NoError(t, validate(oa))
 }
 
+func TestReconcileDNSConfig(t *testing.T) {
+	t.Run("no condition without DNS config", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
+
+		upd, err := reconcileDNSConfig(oa)
+		assert.NoError(t, err)
+		assert.False(t, upd)
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.DNSConfigConditionType))
+	})
+
+	t.Run("DNS config with None policy is valid", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.DNSPolicy = corev1.DNSNone
+		oa.Spec.DNSConfig = &corev1.PodDNSConfig{
+			Nameservers: []string{"10.0.0.10"},
+			Searches:    []string{"corp.example.com"},
+		}
+
+		upd, err := reconcileDNSConfig(oa)
+		assert.NoError(t, err)
+		assert.True(t, upd)
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.DNSConfigConditionType)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionTrue, cond.Status)
+			assert.Equal(t, dynatracev1alpha1.ReasonDNSConfigValid, cond.Reason)
+		}
+
+		upd, err = reconcileDNSConfig(oa)
+		assert.NoError(t, err)
+		assert.False(t, upd)
+
+		ps := newPodSpecForCR(oa, false, consoleLogger)
+		assert.Equal(t, corev1.DNSNone, ps.DNSPolicy)
+		assert.Equal(t, oa.Spec.DNSConfig, ps.DNSConfig)
+	})
+
+	t.Run("None policy without nameservers is incompatible", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.DNSPolicy = corev1.DNSNone
+		oa.Spec.DNSConfig = &corev1.PodDNSConfig{Searches: []string{"corp.example.com"}}
+
+		upd, err := reconcileDNSConfig(oa)
+		assert.Error(t, err)
+		assert.True(t, upd)
+
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.DNSConfigConditionType)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionFalse, cond.Status)
+			assert.Equal(t, dynatracev1alpha1.ReasonDNSConfigInvalid, cond.Reason)
+		}
+	})
+
+	t.Run("too many nameservers are incompatible", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.DNSConfig = &corev1.PodDNSConfig{Nameservers: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}}
+
+		_, err := reconcileDNSConfig(oa)
+		assert.Error(t, err)
+	})
+}
+
 func TestMigrationForDaemonSetWithoutAnnotation(t *testing.T) {
 	oaKey := metav1.ObjectMeta{Name: "my-oneagent", Namespace: "my-namespace"}
 
@@ -144,9 +207,79 @@ func TestHasSpecChanged(t *testing.T) {
 		new.Spec.PriorityClassName = "other class"
 	})
 
+	runTest("preemption policy added", true, func(old *dynatracev1alpha1.OneAgent, new *dynatracev1alpha1.OneAgent) {
+		policy := corev1.PreemptNever
+		new.Spec.PreemptionPolicy = &policy
+	})
+
 	runTest("dns policy added", true, func(old *dynatracev1alpha1.OneAgent, new *dynatracev1alpha1.OneAgent) {
 		new.Spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
 	})
+
+	runTest("dns config added", true, func(old *dynatracev1alpha1.OneAgent, new *dynatracev1alpha1.OneAgent) {
+		new.Spec.DNSConfig = &corev1.PodDNSConfig{Nameservers: []string{"10.0.0.10"}}
+	})
+
+	runTest("host tag changed", true, func(old *dynatracev1alpha1.OneAgent, new *dynatracev1alpha1.OneAgent) {
+		old.Spec.HostTags = []string{"team=frontend"}
+		new.Spec.HostTags = []string{"team=backend"}
+	})
+
+	runTest("host properties set but no change", false, func(old *dynatracev1alpha1.OneAgent, new *dynatracev1alpha1.OneAgent) {
+		old.Spec.HostProperties = map[string]string{"zone": "eu-1", "tier": "gold"}
+		new.Spec.HostProperties = map[string]string{"tier": "gold", "zone": "eu-1"}
+	})
+}
+
+func TestValidateHostTags(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.HostTags = []string{"team=frontend", "critical"}
+	oa.Spec.HostProperties = map[string]string{"Cost-Center": "4711", "zone.name": "eu-1"}
+	assert.NoError(t, validate(oa))
+
+	oa.Spec.HostTags = []string{"with space"}
+	assert.EqualError(t, validate(oa), `.spec.hostTags contains invalid tag "with space"`)
+
+	oa.Spec.HostTags = nil
+	oa.Spec.HostProperties = map[string]string{"key=value": "x"}
+	assert.EqualError(t, validate(oa), `.spec.hostProperties contains invalid key "key=value"`)
+
+	oa.Spec.HostProperties = map[string]string{"zone": ""}
+	assert.EqualError(t, validate(oa), `.spec.hostProperties contains invalid value for key "zone"`)
+
+	oa.Spec.HostProperties = map[string]string{"OperatorVersion": "1.0"}
+	assert.EqualError(t, validate(oa), `.spec.hostProperties must not set reserved key "OperatorVersion"`)
+}
+
+func TestBuildHostTagArgs(t *testing.T) {
+	spec := &dynatracev1alpha1.OneAgentSpec{
+		HostTags:       []string{"team=frontend", "critical", "team=frontend"},
+		HostProperties: map[string]string{"zone": "eu-1", "tier": "gold"},
+	}
+
+	assert.Equal(t, []string{
+		"--set-host-property=tier=gold",
+		"--set-host-property=zone=eu-1",
+		"--set-host-tag=team=frontend",
+		"--set-host-tag=critical",
+	}, buildHostTagArgs(spec, nil))
+
+	// Arguments already passed through .spec.args are not repeated
+	assert.Equal(t, []string{
+		"--set-host-property=zone=eu-1",
+		"--set-host-tag=team=frontend",
+	}, buildHostTagArgs(spec, []string{"--set-host-property=tier=gold", "--set-host-tag=critical"}))
+
+	oa := newOneAgent()
+	oa.Spec.Args = []string{"--set-host-tag=critical"}
+	oa.Spec.HostTags = spec.HostTags
+	podSpec := newPodSpecForCR(oa, false, consoleLogger)
+	assert.Equal(t, []string{
+		"--set-host-tag=critical",
+		"--set-host-tag=team=frontend",
+		"--set-host-property=OperatorVersion=snapshot",
+	}, podSpec.Containers[0].Args)
 }
 
 func TestGetPodsToRestart(t *testing.T) {
@@ -0,0 +1,56 @@
+package oneagent
+
+import (
+	"fmt"
+	"sort"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// reconcileVersionConsistency sets the VersionConsistency condition to True if every instance on .status.instances runs
+// .status.version, and to False with the number of lagging nodes otherwise. Instances whose version isn't known yet
+// aren't counted. The condition is only set once .status.version and instances are known.
+//
+// Returns true if the condition has changed.
+func reconcileVersionConsistency(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	s := instance.GetOneAgentStatus()
+	if s.Version == "" || len(s.Instances) == 0 {
+		return s.Conditions.RemoveCondition(dynatracev1alpha1.VersionConsistencyConditionType)
+	}
+
+	var lagging []string
+	running := 0
+	for node, i := range s.Instances {
+		if i.Version == "" {
+			continue
+		}
+		running++
+		if i.Version != s.Version {
+			lagging = append(lagging, node)
+		}
+	}
+
+	if len(lagging) == 0 {
+		return s.Conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.VersionConsistencyConditionType,
+			Status:  corev1.ConditionTrue,
+			Reason:  dynatracev1alpha1.ReasonVersionsConsistent,
+			Message: fmt.Sprintf("All instances run version %s", s.Version),
+		})
+	}
+
+	sort.Strings(lagging)
+	if s.Conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.VersionConsistencyConditionType,
+		Status:  corev1.ConditionFalse,
+		Reason:  dynatracev1alpha1.ReasonVersionsLagging,
+		Message: fmt.Sprintf("%d of %d nodes don't run version %s", len(lagging), running, s.Version),
+	}) {
+		logger.Info("Instances lagging behind desired version", "version", s.Version, "nodes", lagging)
+		return true
+	}
+	return false
+}
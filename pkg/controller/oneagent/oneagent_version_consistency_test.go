@@ -0,0 +1,57 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReconcileVersionConsistency(t *testing.T) {
+	t.Run("mixed versions", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Status.Version = "1.200.0.20200801-120000"
+		oa.Status.Instances = map[string]dynatracev1alpha1.OneAgentInstance{
+			"node-1": {Version: "1.200.0.20200801-120000"},
+			"node-2": {Version: "1.199.0.20200715-080000"},
+			"node-3": {Version: "1.198.0.20200701-080000"},
+			"node-4": {},
+		}
+
+		assert.True(t, reconcileVersionConsistency(consoleLogger, oa))
+		c := oa.Status.Conditions.GetCondition(dynatracev1alpha1.VersionConsistencyConditionType)
+		require.NotNil(t, c)
+		assert.Equal(t, corev1.ConditionFalse, c.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonVersionsLagging, c.Reason)
+		assert.Equal(t, "2 of 3 nodes don't run version 1.200.0.20200801-120000", c.Message)
+
+		assert.False(t, reconcileVersionConsistency(consoleLogger, oa), "unchanged on repeated reconcile")
+	})
+
+	t.Run("all instances on desired version", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Status.Version = "1.200.0.20200801-120000"
+		oa.Status.Instances = map[string]dynatracev1alpha1.OneAgentInstance{
+			"node-1": {Version: "1.200.0.20200801-120000"},
+			"node-2": {Version: "1.200.0.20200801-120000"},
+		}
+
+		assert.True(t, reconcileVersionConsistency(consoleLogger, oa))
+		c := oa.Status.Conditions.GetCondition(dynatracev1alpha1.VersionConsistencyConditionType)
+		require.NotNil(t, c)
+		assert.Equal(t, corev1.ConditionTrue, c.Status)
+		assert.Equal(t, dynatracev1alpha1.ReasonVersionsConsistent, c.Reason)
+	})
+
+	t.Run("removed without desired version", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Status.Instances = map[string]dynatracev1alpha1.OneAgentInstance{"node-1": {Version: "1.200.0.20200801-120000"}}
+		oa.Status.Conditions.SetCondition(status.Condition{Type: dynatracev1alpha1.VersionConsistencyConditionType, Status: corev1.ConditionTrue})
+
+		assert.True(t, reconcileVersionConsistency(consoleLogger, oa))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.VersionConsistencyConditionType))
+	})
+}
@@ -0,0 +1,174 @@
+package oneagent
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// prepareCustomVolumes returns the volumes and volume mounts from .spec.volumes and .spec.volumeMounts that can be
+// added to the OneAgent pods next to the given operator-managed ones, and the reasons for the rejected entries.
+//
+// Entries are rejected if
+// - a volume uses a source other than hostPath, configMap or secret
+// - a volume name is already in use
+// - a volume mount refers to a volume not defined on .spec.volumes
+// - a mount path is in use, or is nested with a path in use
+func prepareCustomVolumes(instance dynatracev1alpha1.BaseOneAgentDaemonSet, managedVolumes []corev1.Volume, managedMounts []corev1.VolumeMount) ([]corev1.Volume, []corev1.VolumeMount, []string) {
+	spec := instance.GetOneAgentSpec()
+
+	var volumes []corev1.Volume
+	var mounts []corev1.VolumeMount
+	var issues []string
+
+	names := map[string]bool{}
+	for _, v := range managedVolumes {
+		names[v.Name] = true
+	}
+
+	customNames := map[string]bool{}
+	for _, v := range spec.Volumes {
+		if names[v.Name] {
+			issues = append(issues, fmt.Sprintf("volume %q: name already in use", v.Name))
+			continue
+		}
+		if !hasSupportedVolumeSource(v) {
+			issues = append(issues, fmt.Sprintf("volume %q: only hostPath, configMap and secret sources are supported", v.Name))
+			continue
+		}
+		names[v.Name] = true
+		customNames[v.Name] = true
+		volumes = append(volumes, *v.DeepCopy())
+	}
+
+	var mountPaths []string
+	for _, m := range managedMounts {
+		mountPaths = append(mountPaths, m.MountPath)
+	}
+
+	for _, m := range spec.VolumeMounts {
+		if !customNames[m.Name] {
+			issues = append(issues, fmt.Sprintf("volume mount %q: unknown volume %q", m.MountPath, m.Name))
+			continue
+		}
+		if p := findCollidingPath(m.MountPath, mountPaths); p != "" {
+			issues = append(issues, fmt.Sprintf("volume mount %q: collides with mount path %q", m.MountPath, p))
+			continue
+		}
+		mountPaths = append(mountPaths, m.MountPath)
+		mounts = append(mounts, *m.DeepCopy())
+	}
+
+	return volumes, mounts, issues
+}
+
+func hasSupportedVolumeSource(v corev1.Volume) bool {
+	supported := corev1.VolumeSource{
+		HostPath:  v.HostPath,
+		ConfigMap: v.ConfigMap,
+		Secret:    v.Secret,
+	}
+	return (v.HostPath != nil || v.ConfigMap != nil || v.Secret != nil) && supported == v.VolumeSource
+}
+
+// findCollidingPath returns the first entry of paths that is equal to p, or is a parent or child directory of p.
+func findCollidingPath(p string, paths []string) string {
+	p = path.Clean(p)
+	for _, other := range paths {
+		o := path.Clean(other)
+		if p == o || isSubPath(p, o) || isSubPath(o, p) {
+			return other
+		}
+	}
+	return ""
+}
+
+func isSubPath(child, parent string) bool {
+	if parent == "/" {
+		return child != "/"
+	}
+	return strings.HasPrefix(child, parent+"/")
+}
+
+// validateStorageHostPath returns the issues found on .spec.storageHostPath
+func validateStorageHostPath(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	if p := spec.StorageHostPath; p != "" && !path.IsAbs(p) {
+		return []string{fmt.Sprintf(".spec.storageHostPath must be an absolute path, got %q", p)}
+	}
+	return nil
+}
+
+// validateEmptyDirCache returns the issues found on .spec.useEmptyDirCache and .spec.cacheSizeLimit
+func validateEmptyDirCache(spec *dynatracev1alpha1.OneAgentSpec) []string {
+	var msg []string
+	if spec.UseEmptyDirCache && spec.StorageHostPath != "" {
+		msg = append(msg, ".spec.useEmptyDirCache can't be combined with .spec.storageHostPath")
+	}
+	if s := spec.CacheSizeLimit; s != "" {
+		if !spec.UseEmptyDirCache {
+			msg = append(msg, ".spec.cacheSizeLimit requires .spec.useEmptyDirCache")
+		}
+		if q, err := resource.ParseQuantity(s); err != nil || q.Sign() <= 0 {
+			msg = append(msg, fmt.Sprintf(".spec.cacheSizeLimit must be a positive quantity like 2Gi, got %q", s))
+		}
+	}
+	return msg
+}
+
+// usesVolumeStorage returns true if the OneAgent stores its data on the storage volume instead of the host root
+func usesVolumeStorage(spec *dynatracev1alpha1.OneAgentSpec) bool {
+	return spec.StorageHostPath != "" || spec.UseEmptyDirCache
+}
+
+// cacheSizeLimit returns the size limit from .spec.cacheSizeLimit, or nil if unset or invalid
+func cacheSizeLimit(spec *dynatracev1alpha1.OneAgentSpec) *resource.Quantity {
+	if spec.CacheSizeLimit == "" {
+		return nil
+	}
+	q, err := resource.ParseQuantity(spec.CacheSizeLimit)
+	if err != nil {
+		return nil
+	}
+	return &q
+}
+
+// reconcileCustomVolumes reflects on the CustomVolumes condition whether all entries from .spec.volumes and
+// .spec.volumeMounts could be added to the OneAgent pods. The condition is only set if custom volumes are used.
+//
+// Returns true if the condition has changed.
+func reconcileCustomVolumes(logger logr.Logger, instance dynatracev1alpha1.BaseOneAgentDaemonSet) bool {
+	spec := instance.GetOneAgentSpec()
+	conditions := &instance.GetOneAgentStatus().Conditions
+
+	if len(spec.Volumes) == 0 && len(spec.VolumeMounts) == 0 {
+		return conditions.RemoveCondition(dynatracev1alpha1.CustomVolumesConditionType)
+	}
+
+	_, _, issues := prepareCustomVolumes(instance, prepareVolumes(instance), prepareVolumeMounts(instance))
+	if len(issues) == 0 {
+		return conditions.SetCondition(status.Condition{
+			Type:    dynatracev1alpha1.CustomVolumesConditionType,
+			Status:  corev1.ConditionTrue,
+			Reason:  dynatracev1alpha1.ReasonCustomVolumesApplied,
+			Message: "All custom volumes and volume mounts have been applied",
+		})
+	}
+
+	msg := fmt.Sprintf("Rejected custom volumes and volume mounts: %s", strings.Join(issues, ", "))
+	if conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.CustomVolumesConditionType,
+		Status:  corev1.ConditionFalse,
+		Reason:  dynatracev1alpha1.ReasonCustomVolumesRejected,
+		Message: msg,
+	}) {
+		logger.Info("custom volumes or volume mounts have been rejected", "issues", issues)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package oneagent
+
+import (
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPrepareCustomVolumes(t *testing.T) {
+	logsVolume := corev1.Volume{
+		Name:         "custom-logs",
+		VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/opt/app/logs"}},
+	}
+	configVolume := corev1.Volume{
+		Name: "custom-config",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "oneagent-config"}},
+		},
+	}
+
+	t.Run("custom volumes are merged into the pod spec", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.Volumes = []corev1.Volume{logsVolume, configVolume}
+		oa.Spec.VolumeMounts = []corev1.VolumeMount{
+			{Name: "custom-logs", MountPath: "/var/log/app"},
+			{Name: "custom-config", MountPath: "/etc/oneagent", ReadOnly: true},
+		}
+
+		podSpec := newPodSpecForCR(oa, false, consoleLogger)
+		assert.Equal(t, append(prepareVolumes(oa), logsVolume, configVolume), podSpec.Volumes)
+		assert.Equal(t, append(prepareVolumeMounts(oa), oa.Spec.VolumeMounts...), podSpec.Containers[0].VolumeMounts)
+
+		assert.True(t, reconcileCustomVolumes(consoleLogger, oa))
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.CustomVolumesConditionType)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionTrue, cond.Status)
+			assert.Equal(t, dynatracev1alpha1.ReasonCustomVolumesApplied, cond.Reason)
+		}
+	})
+
+	t.Run("colliding entries are rejected", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.TrustedCAs = "certs"
+		oa.Spec.Volumes = []corev1.Volume{
+			logsVolume,
+			{Name: "host-root", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var"}}},
+			{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		}
+		oa.Spec.VolumeMounts = []corev1.VolumeMount{
+			{Name: "custom-logs", MountPath: "/mnt/root/var/log"},
+			{Name: "custom-logs", MountPath: "/mnt/dynatrace"},
+			{Name: "scratch", MountPath: "/tmp/scratch"},
+			{Name: "custom-logs", MountPath: "/var/log/app"},
+		}
+
+		volumes, mounts, issues := prepareCustomVolumes(oa, prepareVolumes(oa), prepareVolumeMounts(oa))
+		assert.Equal(t, []corev1.Volume{logsVolume}, volumes)
+		assert.Equal(t, []corev1.VolumeMount{{Name: "custom-logs", MountPath: "/var/log/app"}}, mounts)
+		assert.Equal(t, []string{
+			`volume "host-root": name already in use`,
+			`volume "scratch": only hostPath, configMap and secret sources are supported`,
+			`volume mount "/mnt/root/var/log": collides with mount path "/mnt/root"`,
+			`volume mount "/mnt/dynatrace": collides with mount path "/mnt/dynatrace/certs"`,
+			`volume mount "/tmp/scratch": unknown volume "scratch"`,
+		}, issues)
+
+		podSpec := newPodSpecForCR(oa, false, consoleLogger)
+		assert.Equal(t, append(prepareVolumeMounts(oa), mounts...), podSpec.Containers[0].VolumeMounts)
+
+		assert.True(t, reconcileCustomVolumes(consoleLogger, oa))
+		assert.False(t, reconcileCustomVolumes(consoleLogger, oa))
+		cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.CustomVolumesConditionType)
+		if assert.NotNil(t, cond) {
+			assert.Equal(t, corev1.ConditionFalse, cond.Status)
+			assert.Equal(t, dynatracev1alpha1.ReasonCustomVolumesRejected, cond.Reason)
+		}
+	})
+
+	t.Run("no condition without custom volumes", func(t *testing.T) {
+		oa := newOneAgent()
+
+		assert.False(t, reconcileCustomVolumes(consoleLogger, oa))
+		assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.CustomVolumesConditionType))
+	})
+}
+
+func TestStorageHostPath(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+
+	podSpec := newPodSpecForCR(oa, false, consoleLogger)
+	for _, v := range podSpec.Volumes {
+		assert.NotEqual(t, storageVolumeName, v.Name, "no storage volume by default")
+	}
+	hash, err := generateHash(podSpec)
+	require.NoError(t, err)
+
+	oa.Spec.StorageHostPath = "/data/dynatrace"
+	assert.NoError(t, validate(oa))
+
+	podSpec = newPodSpecForCR(oa, false, consoleLogger)
+	assert.Contains(t, podSpec.Volumes, corev1.Volume{
+		Name: storageVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{Path: "/data/dynatrace", Type: &hostPathDirectoryOrCreate},
+		},
+	})
+	assert.Contains(t, podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: storageVolumeName, MountPath: storageMountPath})
+	assert.Contains(t, podSpec.Containers[0].Env, corev1.EnvVar{Name: "ONEAGENT_ENABLE_VOLUME_STORAGE", Value: "true"})
+
+	updatedHash, err := generateHash(podSpec)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, updatedHash, "DaemonSet rolled on change")
+
+	oa.Spec.StorageHostPath = "data/dynatrace"
+	assert.EqualError(t, validate(oa), `.spec.storageHostPath must be an absolute path, got "data/dynatrace"`)
+}
+
+func TestEmptyDirCache(t *testing.T) {
+	oa := newOneAgent()
+	oa.Spec.APIURL = "https://f.q.d.n/api"
+	oa.Spec.UseEmptyDirCache = true
+	oa.Spec.CacheSizeLimit = "2Gi"
+	require.NoError(t, validate(oa))
+
+	podSpec := newPodSpecForCR(oa, false, consoleLogger)
+	sizeLimit := resource.MustParse("2Gi")
+	assert.Contains(t, podSpec.Volumes, corev1.Volume{
+		Name:         storageVolumeName,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: &sizeLimit}},
+	})
+	assert.Contains(t, podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{Name: storageVolumeName, MountPath: storageMountPath})
+	assert.Contains(t, podSpec.Containers[0].Env, corev1.EnvVar{Name: "ONEAGENT_ENABLE_VOLUME_STORAGE", Value: "true"})
+	hash, err := generateHash(podSpec)
+	require.NoError(t, err)
+
+	oa.Spec.CacheSizeLimit = "4Gi"
+	updatedHash, err := generateHash(newPodSpecForCR(oa, false, consoleLogger))
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, updatedHash, "DaemonSet rolled on size limit change")
+
+	oa.Spec.CacheSizeLimit = ""
+	podSpec = newPodSpecForCR(oa, false, consoleLogger)
+	assert.Contains(t, podSpec.Volumes, corev1.Volume{
+		Name:         storageVolumeName,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	}, "unlimited without size limit")
+
+	oa.Spec.CacheSizeLimit = "lots"
+	oa.Spec.StorageHostPath = "/data/dynatrace"
+	assert.EqualError(t, validate(oa), `.spec.useEmptyDirCache can't be combined with .spec.storageHostPath, .spec.cacheSizeLimit must be a positive quantity like 2Gi, got "lots"`)
+
+	oa.Spec.StorageHostPath = ""
+	oa.Spec.UseEmptyDirCache = false
+	oa.Spec.CacheSizeLimit = "2Gi"
+	assert.EqualError(t, validate(oa), ".spec.cacheSizeLimit requires .spec.useEmptyDirCache")
+}
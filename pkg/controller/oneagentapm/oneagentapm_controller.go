@@ -123,12 +123,16 @@ func (r *ReconcileOneAgentAPM) Reconcile(request reconcile.Request) (reconcile.R
 	}
 
 	if instance.Spec.EnableIstio {
-		if upd, err := r.istioController.ReconcileIstio(instance, dtc); err != nil {
+		if upd, err := r.istioController.ReconcileIstio(instance, dtc); err != nil && !errors.Is(err, istio.ErrNotInstalled) {
 			// If there are errors log them, but move on.
 			logger.Info("istio: failed to reconcile objects", "error", err)
 		} else if upd {
 			return reconcile.Result{RequeueAfter: 30 * time.Second}, nil
 		}
+	} else if r.istioController != nil {
+		if _, err := r.istioController.RemoveIstio(instance); err != nil {
+			logger.Info("istio: failed to remove objects", "error", err)
+		}
 	}
 
 	return reconcile.Result{RequeueAfter: 30 * time.Minute}, nil
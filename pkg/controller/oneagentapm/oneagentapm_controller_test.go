@@ -49,7 +49,7 @@ func TestReconcileOneAgentAPM(t *testing.T) {
 	)
 
 	dtClient := &dtclient.MockDynatraceClient{}
-	dtClient.On("GetTokenScopes", "42").Return(dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}, nil)
+	dtClient.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
 	dtClient.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
 
 	reconciler := &ReconcileOneAgentAPM{
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// dryRunClient wraps a client, logging the writes instead of sending them to the API server. Reads are passed
+// through, so the desired objects are still computed from the current state of the cluster.
+type dryRunClient struct {
+	client.Client
+	logger logr.Logger
+}
+
+// NewDryRunClient returns a client reading through c, but only logging the objects to create, update or delete, and
+// the differences to the current objects for updates.
+func NewDryRunClient(c client.Client, logger logr.Logger) client.Client {
+	return &dryRunClient{Client: c, logger: logger}
+}
+
+func (c *dryRunClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	c.logger.Info("dry-run: skipping create", objectValues(obj)...)
+	return nil
+}
+
+func (c *dryRunClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	c.logger.Info("dry-run: skipping update", append(objectValues(obj), "diff", c.diff(ctx, obj))...)
+	return nil
+}
+
+func (c *dryRunClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	c.logger.Info("dry-run: skipping delete", objectValues(obj)...)
+	return nil
+}
+
+func (c *dryRunClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	c.logger.Info("dry-run: skipping delete of all", objectValues(obj)...)
+	return nil
+}
+
+func (c *dryRunClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.logger.Info("dry-run: skipping patch", append(objectValues(obj), "patch", patchData(patch, obj))...)
+	return nil
+}
+
+func (c *dryRunClient) Status() client.StatusWriter {
+	return &dryRunStatusWriter{c: c}
+}
+
+// diff returns the differences between the current object on the cluster and obj.
+func (c *dryRunClient) diff(ctx context.Context, obj runtime.Object) string {
+	key, err := client.ObjectKeyFromObject(obj)
+	if err != nil {
+		return fmt.Sprintf("unknown: %s", err)
+	}
+
+	current := obj.DeepCopyObject()
+	if err := c.Client.Get(ctx, key, current); err != nil {
+		return fmt.Sprintf("unknown: %s", err)
+	}
+	return diff.ObjectReflectDiff(current, obj)
+}
+
+// dryRunStatusWriter logs the status updates instead of sending them to the API server.
+type dryRunStatusWriter struct {
+	c *dryRunClient
+}
+
+func (w *dryRunStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	w.c.logger.Info("dry-run: skipping status update", append(objectValues(obj), "diff", w.c.diff(ctx, obj))...)
+	return nil
+}
+
+func (w *dryRunStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	w.c.logger.Info("dry-run: skipping status patch", append(objectValues(obj), "patch", patchData(patch, obj))...)
+	return nil
+}
+
+func objectValues(obj runtime.Object) []interface{} {
+	values := []interface{}{"type", fmt.Sprintf("%T", obj)}
+	if m, err := meta.Accessor(obj); err == nil {
+		values = append(values, "namespace", m.GetNamespace(), "name", m.GetName())
+	}
+	return values
+}
+
+func patchData(patch client.Patch, obj runtime.Object) string {
+	data, err := patch.Data(obj)
+	if err != nil {
+		return fmt.Sprintf("unknown: %s", err)
+	}
+	return string(data)
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"net/http"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,18 +20,37 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// tokenProbeInterval is the minimum time between verifications of a token through the Dynatrace API. Tokens are
+// verified again after it, so that scopes revoked in the meantime are detected.
+const tokenProbeInterval = 5 * time.Minute
+
+// TokenExpiryWarningWindow is the time before the expiration of a token from which the expiry conditions warn about it,
+// unless set on DynatraceClientReconciler.
+var TokenExpiryWarningWindow = 14 * 24 * time.Hour
+
+// invalidateDynatraceCaches drops the responses cached by the Dynatrace clients, overridden for testing purposes.
+var invalidateDynatraceCaches = func() {
+	dtclient.DefaultAgentVersionCache.Invalidate()
+	dtclient.DefaultCommunicationHostCache.Invalidate()
+	dtclient.DefaultETagCache.Invalidate()
+}
+
 type DynatraceClientReconciler struct {
 	Client              client.Client
 	DynatraceClientFunc DynatraceClientFunc
 	Now                 metav1.Time
 	UpdatePaaSToken     bool
 	UpdateAPIToken      bool
+
+	// TokenExpiryWarning overrides TokenExpiryWarningWindow if set.
+	TokenExpiryWarning time.Duration
 }
 
 type tokenConfig struct {
-	Type              status.ConditionType
-	Key, Value, Scope string
-	Timestamp         **metav1.Time
+	Type, ExpiryType              status.ConditionType
+	Key, Value, Scope, SecretName string
+	Timestamp                     **metav1.Time
+	Scopes                        *[]string
 }
 
 func (r *DynatraceClientReconciler) Reconcile(ctx context.Context, instance dynatracev1alpha1.BaseOneAgent) (dtclient.Client, bool, error) {
@@ -43,26 +65,31 @@ func (r *DynatraceClientReconciler) Reconcile(ctx context.Context, instance dyna
 	}
 
 	sts := instance.GetStatus()
-	ns := instance.GetNamespace()
-	secretName := GetTokensName(instance)
+	ns := GetTokensNamespace(instance)
 
 	var tokens []*tokenConfig
 
 	if r.UpdatePaaSToken {
 		tokens = append(tokens, &tokenConfig{
-			Type:      dynatracev1alpha1.PaaSTokenConditionType,
-			Key:       DynatracePaasToken,
-			Scope:     dtclient.TokenScopeInstallerDownload,
-			Timestamp: &sts.LastPaaSTokenProbeTimestamp,
+			Type:       dynatracev1alpha1.PaaSTokenConditionType,
+			ExpiryType: dynatracev1alpha1.PaaSTokenExpiryConditionType,
+			Key:        DynatracePaasToken,
+			Scope:      dtclient.TokenScopeInstallerDownload,
+			SecretName: GetPaaSTokenSecretName(instance),
+			Timestamp:  &sts.LastPaaSTokenProbeTimestamp,
+			Scopes:     &sts.TokenScopes.PaaSToken,
 		})
 	}
 
 	if r.UpdateAPIToken {
 		tokens = append(tokens, &tokenConfig{
-			Type:      dynatracev1alpha1.APITokenConditionType,
-			Key:       DynatraceApiToken,
-			Scope:     dtclient.TokenScopeDataExport,
-			Timestamp: &sts.LastAPITokenProbeTimestamp,
+			Type:       dynatracev1alpha1.APITokenConditionType,
+			ExpiryType: dynatracev1alpha1.APITokenExpiryConditionType,
+			Key:        DynatraceApiToken,
+			Scope:      dtclient.TokenScopeDataExport,
+			SecretName: GetAPITokenSecretName(instance),
+			Timestamp:  &sts.LastAPITokenProbeTimestamp,
+			Scopes:     &sts.TokenScopes.APIToken,
 		})
 	}
 
@@ -76,50 +103,92 @@ func (r *DynatraceClientReconciler) Reconcile(ctx context.Context, instance dyna
 		}
 	}
 
-	secretKey := ns + ":" + secretName
-	secret := &corev1.Secret{}
-	if err := r.Client.Get(ctx, client.ObjectKey{Name: secretName, Namespace: ns}, secret); k8serrors.IsNotFound(err) {
-		message := fmt.Sprintf("Secret '%s' not found", secretKey)
+	if reconcileAPIURL(sts, instance.GetSpec().APIURL) {
+		updateCR = true
+	}
+
+	secrets := map[string]*corev1.Secret{}
+	var secretErr error
+	var issues []string
+
+	for _, t := range tokens {
+		secretKey := ns + ":" + t.SecretName
 
-		for _, t := range tokens {
+		secret, ok := secrets[t.SecretName]
+		if !ok {
+			secret = &corev1.Secret{}
+			if err := r.Client.Get(ctx, client.ObjectKey{Name: t.SecretName, Namespace: ns}, secret); k8serrors.IsNotFound(err) {
+				secret = nil
+			} else if err != nil {
+				return nil, updateCR, err
+			}
+			secrets[t.SecretName] = secret
+		}
+
+		if secret == nil {
+			message := fmt.Sprintf("Secret '%s' not found", secretKey)
 			updateCR = sts.Conditions.SetCondition(status.Condition{
 				Type:    t.Type,
 				Status:  corev1.ConditionFalse,
 				Reason:  dynatracev1alpha1.ReasonTokenSecretNotFound,
 				Message: message,
 			}) || updateCR
-		}
 
-		return nil, updateCR, fmt.Errorf(message)
-	} else if err != nil {
-		return nil, updateCR, err
-	}
-
-	valid := true
+			if secretErr == nil {
+				secretErr = errors.New(message)
+			}
+			continue
+		}
 
-	for _, t := range tokens {
-		v := secret.Data[t.Key]
-		if len(v) == 0 {
+		v, found := LookupToken(secret, t.Key)
+		if !found {
+			message := fmt.Sprintf("Key %s missing on secret %s", t.Key, secretKey)
+			updateCR = sts.Conditions.SetCondition(status.Condition{
+				Type:    t.Type,
+				Status:  corev1.ConditionFalse,
+				Reason:  dynatracev1alpha1.ReasonTokenKeyMissing,
+				Message: message,
+			}) || updateCR
+			issues = append(issues, message)
+		} else if len(v) == 0 {
+			message := fmt.Sprintf("Token %s on secret %s missing", t.Key, secretKey)
 			updateCR = sts.Conditions.SetCondition(status.Condition{
 				Type:    t.Type,
 				Status:  corev1.ConditionFalse,
 				Reason:  dynatracev1alpha1.ReasonTokenMissing,
-				Message: fmt.Sprintf("Token %s on secret %s missing", t.Key, secretKey),
+				Message: message,
 			}) || updateCR
-			valid = false
+			issues = append(issues, message)
 		}
 		t.Value = string(v)
 	}
 
-	if !valid {
-		return nil, updateCR, fmt.Errorf("issues found with tokens, see status")
+	if secretErr != nil {
+		return nil, updateCR, secretErr
 	}
 
-	dtc, err := dtf(r.Client, instance, r.UpdateAPIToken, r.UpdatePaaSToken)
+	// Tokens present on the secret are still verified if the other one is missing, so that the conditions point out
+	// the missing token only.
+	var present []*tokenConfig
+	hasAPIToken, hasPaaSToken := false, false
+	for _, t := range tokens {
+		if t.Value == "" {
+			continue
+		}
+		present = append(present, t)
+		hasAPIToken = hasAPIToken || t.Key == DynatraceApiToken
+		hasPaaSToken = hasPaaSToken || t.Key == DynatracePaasToken
+	}
+
+	if len(present) == 0 && len(issues) > 0 {
+		return nil, updateCR, errors.New(strings.Join(issues, ", "))
+	}
+
+	dtc, err := dtf(r.Client, instance, hasAPIToken, hasPaaSToken)
 	if err != nil {
 		message := fmt.Sprintf("Failed to create Dynatrace API Client: %s", err)
 
-		for _, t := range tokens {
+		for _, t := range present {
 			updateCR = sts.Conditions.SetCondition(status.Condition{
 				Type:    t.Type,
 				Status:  corev1.ConditionFalse,
@@ -131,7 +200,9 @@ func (r *DynatraceClientReconciler) Reconcile(ctx context.Context, instance dyna
 		return nil, updateCR, err
 	}
 
-	for _, t := range tokens {
+	for _, t := range present {
+		secretKey := ns + ":" + t.SecretName
+
 		if strings.TrimSpace(t.Value) != t.Value {
 			updateCR = sts.Conditions.SetCondition(status.Condition{
 				Type:    t.Type,
@@ -143,15 +214,15 @@ func (r *DynatraceClientReconciler) Reconcile(ctx context.Context, instance dyna
 		}
 
 		// At this point, we can query the Dynatrace API to verify whether our tokens are correct. To avoid excessive requests,
-		// we wait at least 5 mins between proves.
-		if *t.Timestamp != nil && now.Time.Before((*t.Timestamp).Add(5*time.Minute)) {
+		// we wait at least tokenProbeInterval between proves.
+		if *t.Timestamp != nil && now.Time.Before((*t.Timestamp).Add(tokenProbeInterval)) {
 			continue
 		}
 
 		nowCopy := now
 		*t.Timestamp = &nowCopy
 		updateCR = true
-		ss, err := dtc.GetTokenScopes(t.Value)
+		md, err := dtc.GetTokenMetadata(t.Value)
 
 		var serr dtclient.ServerError
 		if ok := errors.As(err, &serr); ok && serr.Code == http.StatusUnauthorized {
@@ -174,6 +245,31 @@ func (r *DynatraceClientReconciler) Reconcile(ctx context.Context, instance dyna
 			continue
 		}
 
+		r.reconcileTokenExpiry(sts, t, secretKey, md.ExpiresAt, now.Time)
+
+		ss := md.Scopes
+
+		// The required scope has been revoked if the token had it on the previous probe, or the condition still reports
+		// the revocation from an earlier probe.
+		revoked := dtclient.TokenScopes(*t.Scopes).Contains(t.Scope)
+		if cond := sts.Conditions.GetCondition(t.Type); cond != nil && cond.Reason == dynatracev1alpha1.ReasonTokenScopeRevoked {
+			revoked = true
+		}
+
+		if !reflect.DeepEqual(*t.Scopes, []string(ss)) {
+			*t.Scopes = ss
+		}
+
+		if !ss.Contains(t.Scope) && revoked {
+			sts.Conditions.SetCondition(status.Condition{
+				Type:    t.Type,
+				Status:  corev1.ConditionFalse,
+				Reason:  dynatracev1alpha1.ReasonTokenScopeRevoked,
+				Message: fmt.Sprintf("Scope %s has been revoked from token on secret %s", t.Scope, secretKey),
+			})
+			continue
+		}
+
 		if !ss.Contains(t.Scope) {
 			sts.Conditions.SetCondition(status.Condition{
 				Type:    t.Type,
@@ -207,5 +303,79 @@ func (r *DynatraceClientReconciler) Reconcile(ctx context.Context, instance dyna
 		})
 	}
 
+	if len(issues) > 0 {
+		return nil, updateCR, errors.New(strings.Join(issues, ", "))
+	}
 	return dtc, updateCR, nil
 }
+
+// reconcileAPIURL detects changes of the API URL through the hash stored on the status. Once changed, the cached
+// responses of the previous tenant are dropped, and the tokens are probed again on this reconciliation, which also
+// refreshes the environment ID. Communication hosts, and so the Istio objects, are derived from the new tenant then.
+//
+// Returns true if the status has changed.
+func reconcileAPIURL(sts *dynatracev1alpha1.BaseOneAgentStatus, apiURL string) bool {
+	hasher := fnv.New32()
+	_, _ = hasher.Write([]byte(apiURL))
+	hash := strconv.FormatUint(uint64(hasher.Sum32()), 10)
+
+	previous := sts.APIURLHash
+	if previous == hash {
+		return false
+	}
+	sts.APIURLHash = hash
+
+	if previous == "" {
+		// Nothing to invalidate yet, the hash gets stored with the next status update.
+		return false
+	}
+
+	invalidateDynatraceCaches()
+	sts.LastAPITokenProbeTimestamp = nil
+	sts.LastPaaSTokenProbeTimestamp = nil
+	sts.TokenScopes = dynatracev1alpha1.TokenScopes{}
+	sts.EnvironmentID = ""
+	sts.Conditions.SetCondition(status.Condition{
+		Type:    dynatracev1alpha1.TenantChangedConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonAPIURLChanged,
+		Message: fmt.Sprintf("API URL changed to %s, tokens are validated against the new tenant", apiURL),
+	})
+	return true
+}
+
+// reconcileTokenExpiry sets the expiry condition of the token, which warns once the token expires within the warning
+// window. Neither the token nor its ID is included on the message.
+func (r *DynatraceClientReconciler) reconcileTokenExpiry(sts *dynatracev1alpha1.BaseOneAgentStatus, t *tokenConfig, secretKey string, expiresAt *time.Time, now time.Time) {
+	window := r.TokenExpiryWarning
+	if window == 0 {
+		window = TokenExpiryWarningWindow
+	}
+
+	if expiresAt == nil || expiresAt.Sub(now) > window {
+		sts.Conditions.SetCondition(status.Condition{
+			Type:    t.ExpiryType,
+			Status:  corev1.ConditionFalse,
+			Reason:  dynatracev1alpha1.ReasonTokenNotExpiring,
+			Message: fmt.Sprintf("Token %s on secret %s not expiring soon", t.Key, secretKey),
+		})
+		return
+	}
+
+	if !expiresAt.After(now) {
+		sts.Conditions.SetCondition(status.Condition{
+			Type:    t.ExpiryType,
+			Status:  corev1.ConditionTrue,
+			Reason:  dynatracev1alpha1.ReasonTokenExpired,
+			Message: fmt.Sprintf("Token %s on secret %s expired on %s", t.Key, secretKey, expiresAt.UTC().Format(time.RFC3339)),
+		})
+		return
+	}
+
+	sts.Conditions.SetCondition(status.Condition{
+		Type:    t.ExpiryType,
+		Status:  corev1.ConditionTrue,
+		Reason:  dynatracev1alpha1.ReasonTokenExpiresSoon,
+		Message: fmt.Sprintf("Token %s on secret %s expires on %s", t.Key, secretKey, expiresAt.UTC().Format(time.RFC3339)),
+	})
+}
@@ -77,8 +77,91 @@ func TestReconcileDynatraceClient_TokenValidation(t *testing.T) {
 
 		AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenConditionType, false, dynatracev1alpha1.ReasonTokenMissing,
 			"Token paasToken on secret dynatrace:oneagent missing")
+		AssertCondition(t, oa, dynatracev1alpha1.APITokenConditionType, false, dynatracev1alpha1.ReasonTokenKeyMissing,
+			"Key apiToken missing on secret dynatrace:oneagent")
+
+		mock.AssertExpectationsForObjects(t, dtcMock)
+	})
+
+	t.Run("PaaS token is valid, API token key is missing", func(t *testing.T) {
+		oa := base.DeepCopy()
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "42"}))
+
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+		dtcMock.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123"}, nil)
+
+		rec := &DynatraceClientReconciler{
+			Client:              c,
+			DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+			Now:                 metav1.Now(),
+		}
+
+		dtc, ucr, err := rec.Reconcile(context.TODO(), oa)
+		assert.Nil(t, dtc)
+		assert.True(t, ucr)
+		assert.EqualError(t, err, "Key apiToken missing on secret dynatrace:oneagent")
+
+		AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
+		AssertCondition(t, oa, dynatracev1alpha1.APITokenConditionType, false, dynatracev1alpha1.ReasonTokenKeyMissing,
+			"Key apiToken missing on secret dynatrace:oneagent")
+
+		mock.AssertExpectationsForObjects(t, dtcMock)
+	})
+
+	t.Run("API token is valid, PaaS token key is missing", func(t *testing.T) {
+		oa := base.DeepCopy()
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, map[string]string{DynatraceApiToken: "84"}))
+
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+
+		rec := &DynatraceClientReconciler{
+			Client:              c,
+			DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+			Now:                 metav1.Now(),
+		}
+
+		dtc, ucr, err := rec.Reconcile(context.TODO(), oa)
+		assert.Nil(t, dtc)
+		assert.True(t, ucr)
+		assert.EqualError(t, err, "Key paasToken missing on secret dynatrace:oneagent")
+
+		AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenConditionType, false, dynatracev1alpha1.ReasonTokenKeyMissing,
+			"Key paasToken missing on secret dynatrace:oneagent")
+		AssertCondition(t, oa, dynatracev1alpha1.APITokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
+
+		mock.AssertExpectationsForObjects(t, dtcMock)
+	})
+
+	t.Run("PaaS token is valid, API token is empty", func(t *testing.T) {
+		oa := base.DeepCopy()
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "42", DynatraceApiToken: ""}))
+
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+		dtcMock.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123"}, nil)
+
+		rec := &DynatraceClientReconciler{
+			Client:              c,
+			DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+			Now:                 metav1.Now(),
+		}
+
+		dtc, _, err := rec.Reconcile(context.TODO(), oa)
+		assert.Nil(t, dtc)
+		assert.EqualError(t, err, "Token apiToken on secret dynatrace:oneagent missing")
+
+		AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
 		AssertCondition(t, oa, dynatracev1alpha1.APITokenConditionType, false, dynatracev1alpha1.ReasonTokenMissing,
 			"Token apiToken on secret dynatrace:oneagent missing")
+		assert.Equal(t, "abc123", oa.Status.EnvironmentID)
 
 		mock.AssertExpectationsForObjects(t, dtcMock)
 	})
@@ -88,8 +171,8 @@ func TestReconcileDynatraceClient_TokenValidation(t *testing.T) {
 		c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "42", DynatraceApiToken: "84"}))
 
 		dtcMock := &dtclient.MockDynatraceClient{}
-		dtcMock.On("GetTokenScopes", "42").Return(dtclient.TokenScopes(nil), dtclient.ServerError{Code: 401, Message: "Token Authentication failed"})
-		dtcMock.On("GetTokenScopes", "84").Return(dtclient.TokenScopes(nil), fmt.Errorf("random error"))
+		dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{}, dtclient.ServerError{Code: 401, Message: "Token Authentication failed"})
+		dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{}, fmt.Errorf("random error"))
 
 		rec := &DynatraceClientReconciler{
 			Client:              c,
@@ -117,7 +200,7 @@ func TestReconcileDynatraceClient_TokenValidation(t *testing.T) {
 		c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "42", DynatraceApiToken: " \t84\n  "}))
 
 		dtcMock := &dtclient.MockDynatraceClient{}
-		dtcMock.On("GetTokenScopes", "42").Return(dtclient.TokenScopes{dtclient.TokenScopeDataExport}, nil)
+		dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
 
 		rec := &DynatraceClientReconciler{
 			Client:              c,
@@ -145,8 +228,8 @@ func TestReconcileDynatraceClient_TokenValidation(t *testing.T) {
 		c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "42", DynatraceApiToken: "84"}))
 
 		dtcMock := &dtclient.MockDynatraceClient{}
-		dtcMock.On("GetTokenScopes", "42").Return(dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}, nil)
-		dtcMock.On("GetTokenScopes", "84").Return(dtclient.TokenScopes{dtclient.TokenScopeDataExport}, nil)
+		dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+		dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
 		dtcMock.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
 
 		rec := &DynatraceClientReconciler{
@@ -297,8 +380,8 @@ func TestReconcileDynatraceClient_ProbeRequests(t *testing.T) {
 		oa.Status.LastPaaSTokenProbeTimestamp = &lastPaaSProbe
 
 		dtcMock := &dtclient.MockDynatraceClient{}
-		dtcMock.On("GetTokenScopes", "42").Return(dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}, nil)
-		dtcMock.On("GetTokenScopes", "84").Return(dtclient.TokenScopes{dtclient.TokenScopeDataExport}, nil)
+		dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+		dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
 		dtcMock.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
 
 		rec := &DynatraceClientReconciler{
@@ -323,6 +406,353 @@ func TestReconcileDynatraceClient_ProbeRequests(t *testing.T) {
 	})
 }
 
+func TestReconcileDynatraceClient_APIURLChange(t *testing.T) {
+	now := metav1.Now()
+	lastProbe := metav1.NewTime(now.Add(-1 * time.Minute))
+
+	invalidations := 0
+	defer func(f func()) { invalidateDynatraceCaches = f }(invalidateDynatraceCaches)
+	invalidateDynatraceCaches = func() { invalidations++ }
+
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+		},
+	}
+	oa.Status.EnvironmentID = "ENVIRONMENTID"
+	oa.Status.LastAPITokenProbeTimestamp = &lastProbe
+	oa.Status.LastPaaSTokenProbeTimestamp = &lastProbe
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "42", DynatraceApiToken: "84"}))
+	dtcMock := &dtclient.MockDynatraceClient{}
+
+	rec := &DynatraceClientReconciler{
+		Client:              c,
+		DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+		UpdatePaaSToken:     true,
+		UpdateAPIToken:      true,
+		Now:                 now,
+	}
+
+	_, _, err := rec.Reconcile(context.TODO(), oa)
+	require.NoError(t, err)
+	assert.NotEmpty(t, oa.Status.APIURLHash)
+	assert.Zero(t, invalidations, "nothing to invalidate on the first reconciliation")
+	assert.Nil(t, oa.Status.Conditions.GetCondition(dynatracev1alpha1.TenantChangedConditionType))
+
+	oa.Spec.APIURL = "https://OTHERENVIRONMENT.live.dynatrace.com/api"
+	dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+	dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+	dtcMock.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "OTHERENVIRONMENT"}, nil)
+
+	_, ucr, err := rec.Reconcile(context.TODO(), oa)
+	require.NoError(t, err)
+	assert.True(t, ucr)
+	assert.Equal(t, 1, invalidations)
+	assert.Equal(t, "OTHERENVIRONMENT", oa.Status.EnvironmentID)
+	assert.Equal(t, now, *oa.Status.LastAPITokenProbeTimestamp, "tokens probed again despite recent probe")
+	assert.Equal(t, now, *oa.Status.LastPaaSTokenProbeTimestamp)
+
+	cond := oa.Status.Conditions.GetCondition(dynatracev1alpha1.TenantChangedConditionType)
+	require.NotNil(t, cond)
+	assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	assert.Equal(t, dynatracev1alpha1.ReasonAPIURLChanged, cond.Reason)
+	mock.AssertExpectationsForObjects(t, dtcMock)
+
+	_, _, err = rec.Reconcile(context.TODO(), oa)
+	require.NoError(t, err)
+	assert.Equal(t, 1, invalidations, "unchanged API URL")
+}
+
+func TestReconcileDynatraceClient_TokenScopes(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "42", DynatraceApiToken: "84"}))
+
+	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+	dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport, "LogExport"}}, nil)
+	dtcMock.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+
+	rec := &DynatraceClientReconciler{
+		Client:              c,
+		DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+		UpdatePaaSToken:     true,
+		UpdateAPIToken:      true,
+		Now:                 metav1.Now(),
+	}
+
+	_, ucr, err := rec.Reconcile(context.TODO(), oa)
+	assert.True(t, ucr)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{dtclient.TokenScopeInstallerDownload}, oa.Status.TokenScopes.PaaSToken)
+	assert.Equal(t, []string{dtclient.TokenScopeDataExport, "LogExport"}, oa.Status.TokenScopes.APIToken)
+
+	mock.AssertExpectationsForObjects(t, dtcMock)
+}
+
+func TestReconcileDynatraceClient_ScopeRevoked(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, map[string]string{DynatraceApiToken: "84"}))
+	now := metav1.Now()
+
+	reconcileWithScopes := func(scopes ...string) *status.Condition {
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: scopes}, nil)
+
+		rec := &DynatraceClientReconciler{
+			Client:              c,
+			DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+			UpdateAPIToken:      true,
+			Now:                 now,
+		}
+		_, _, err := rec.Reconcile(context.TODO(), oa)
+		require.NoError(t, err)
+		mock.AssertExpectationsForObjects(t, dtcMock)
+
+		now = metav1.NewTime(now.Add(tokenProbeInterval))
+		return oa.Status.Conditions.GetCondition(dynatracev1alpha1.APITokenConditionType)
+	}
+
+	cond := reconcileWithScopes(dtclient.TokenScopeDataExport)
+	require.NotNil(t, cond)
+	assert.Equal(t, dynatracev1alpha1.ReasonTokenReady, cond.Reason)
+
+	cond = reconcileWithScopes("LogExport")
+	require.NotNil(t, cond)
+	assert.Equal(t, corev1.ConditionFalse, cond.Status)
+	assert.Equal(t, dynatracev1alpha1.ReasonTokenScopeRevoked, cond.Reason)
+	assert.Equal(t, "Scope DataExport has been revoked from token on secret dynatrace:oneagent", cond.Message)
+
+	cond = reconcileWithScopes("LogExport")
+	require.NotNil(t, cond)
+	assert.Equal(t, dynatracev1alpha1.ReasonTokenScopeRevoked, cond.Reason, "revocation still reported")
+
+	cond = reconcileWithScopes(dtclient.TokenScopeDataExport)
+	require.NotNil(t, cond)
+	assert.Equal(t, corev1.ConditionTrue, cond.Status)
+	assert.Equal(t, dynatracev1alpha1.ReasonTokenReady, cond.Reason)
+}
+
+func TestReconcileDynatraceClient_TokenExpiry(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	now := metav1.NewTime(time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC))
+
+	reconcileWithExpiry := func(window time.Duration, paasExpiry, apiExpiry *time.Time) *dynatracev1alpha1.OneAgent {
+		oa := &dynatracev1alpha1.OneAgent{
+			ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+			Spec: dynatracev1alpha1.OneAgentSpec{
+				BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+					APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+					Tokens: oaName,
+				},
+			},
+		}
+
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "42", DynatraceApiToken: "84"}))
+
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{
+			ID:        "dt0c01.PAAS",
+			Scopes:    dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload},
+			ExpiresAt: paasExpiry,
+		}, nil)
+		dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{
+			ID:        "dt0c01.API",
+			Scopes:    dtclient.TokenScopes{dtclient.TokenScopeDataExport},
+			ExpiresAt: apiExpiry,
+		}, nil)
+		dtcMock.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+
+		rec := &DynatraceClientReconciler{
+			Client:              c,
+			DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+			Now:                 now,
+			TokenExpiryWarning:  window,
+		}
+
+		_, ucr, err := rec.Reconcile(context.TODO(), oa)
+		assert.True(t, ucr)
+		require.NoError(t, err)
+		mock.AssertExpectationsForObjects(t, dtcMock)
+		return oa
+	}
+
+	at := func(d time.Duration) *time.Time {
+		ts := now.Add(d)
+		return &ts
+	}
+
+	t.Run("near expiry", func(t *testing.T) {
+		oa := reconcileWithExpiry(0, at(10*24*time.Hour), at(30*24*time.Hour))
+
+		AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenExpiryConditionType, true, dynatracev1alpha1.ReasonTokenExpiresSoon,
+			"Token paasToken on secret dynatrace:oneagent expires on 2020-10-11T12:00:00Z")
+		AssertCondition(t, oa, dynatracev1alpha1.APITokenExpiryConditionType, false, dynatracev1alpha1.ReasonTokenNotExpiring,
+			"Token apiToken on secret dynatrace:oneagent not expiring soon")
+		AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
+	})
+
+	t.Run("custom window", func(t *testing.T) {
+		oa := reconcileWithExpiry(45*24*time.Hour, nil, at(30*24*time.Hour))
+
+		AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenExpiryConditionType, false, dynatracev1alpha1.ReasonTokenNotExpiring,
+			"Token paasToken on secret dynatrace:oneagent not expiring soon")
+		AssertCondition(t, oa, dynatracev1alpha1.APITokenExpiryConditionType, true, dynatracev1alpha1.ReasonTokenExpiresSoon,
+			"Token apiToken on secret dynatrace:oneagent expires on 2020-10-31T12:00:00Z")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		oa := reconcileWithExpiry(0, nil, at(-time.Hour))
+
+		AssertCondition(t, oa, dynatracev1alpha1.APITokenExpiryConditionType, true, dynatracev1alpha1.ReasonTokenExpired,
+			"Token apiToken on secret dynatrace:oneagent expired on 2020-10-01T11:00:00Z")
+
+		for _, c := range oa.Status.Conditions {
+			assert.NotContains(t, c.Message, "dt0c01", "token ID not exposed")
+			assert.NotContains(t, c.Message, "84", "token not exposed")
+		}
+	})
+}
+
+func TestReconcileDynatraceClient_SeparateTokenSecrets(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	base := dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+		},
+	}
+
+	t.Run("PaaS and API tokens read from separate secrets", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Spec.PaaSTokenSecret = "paas-secret"
+		oa.Spec.APITokenSecret = "api-secret"
+
+		c := fake.NewFakeClientWithScheme(scheme.Scheme,
+			NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "1", DynatraceApiToken: "2"}),
+			NewSecret("paas-secret", namespace, map[string]string{DynatracePaasToken: "42"}),
+			NewSecret("api-secret", namespace, map[string]string{DynatraceApiToken: "84"}))
+
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+		dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+		dtcMock.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+
+		rec := &DynatraceClientReconciler{
+			Client:              c,
+			DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+			Now:                 metav1.Now(),
+		}
+
+		dtc, ucr, err := rec.Reconcile(context.TODO(), oa)
+		assert.Equal(t, dtcMock, dtc)
+		assert.True(t, ucr)
+		assert.NoError(t, err)
+
+		AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
+		AssertCondition(t, oa, dynatracev1alpha1.APITokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
+
+		mock.AssertExpectationsForObjects(t, dtcMock)
+	})
+
+	t.Run("API token falls back to combined secret", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Spec.PaaSTokenSecret = "paas-secret"
+
+		c := fake.NewFakeClientWithScheme(scheme.Scheme,
+			NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "1", DynatraceApiToken: "84"}),
+			NewSecret("paas-secret", namespace, map[string]string{DynatracePaasToken: "42"}))
+
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+		dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+		dtcMock.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+
+		rec := &DynatraceClientReconciler{
+			Client:              c,
+			DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+			Now:                 metav1.Now(),
+		}
+
+		dtc, ucr, err := rec.Reconcile(context.TODO(), oa)
+		assert.Equal(t, dtcMock, dtc)
+		assert.True(t, ucr)
+		assert.NoError(t, err)
+
+		AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
+		AssertCondition(t, oa, dynatracev1alpha1.APITokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
+
+		mock.AssertExpectationsForObjects(t, dtcMock)
+	})
+
+	t.Run("Dedicated PaaS token secret not found", func(t *testing.T) {
+		oa := base.DeepCopy()
+		oa.Spec.PaaSTokenSecret = "paas-secret"
+
+		c := fake.NewFakeClientWithScheme(scheme.Scheme,
+			NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "1", DynatraceApiToken: "84"}))
+		dtcMock := &dtclient.MockDynatraceClient{}
+
+		rec := &DynatraceClientReconciler{
+			Client:              c,
+			DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+			Now:                 metav1.Now(),
+		}
+
+		dtc, ucr, err := rec.Reconcile(context.TODO(), oa)
+		assert.Nil(t, dtc)
+		assert.True(t, ucr)
+		assert.EqualError(t, err, "Secret 'dynatrace:paas-secret' not found")
+
+		AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenConditionType, false, dynatracev1alpha1.ReasonTokenSecretNotFound,
+			"Secret 'dynatrace:paas-secret' not found")
+
+		mock.AssertExpectationsForObjects(t, dtcMock)
+	})
+}
+
 func AssertCondition(t *testing.T, oa *dynatracev1alpha1.OneAgent, ct status.ConditionType, status bool, reason status.ConditionReason, message string) {
 	t.Helper()
 	s := corev1.ConditionFalse
@@ -344,3 +774,103 @@ func NewSecret(name, namespace string, kv map[string]string) *corev1.Secret {
 	}
 	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}, Data: data}
 }
+
+func TestReconcileDynatraceClient_LegacyTokenKeys(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	base := dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens: oaName,
+			},
+		},
+	}
+
+	reconcileWith := func(t *testing.T, data map[string]string, paasToken, apiToken string) {
+		oa := base.DeepCopy()
+		c := fake.NewFakeClientWithScheme(scheme.Scheme, NewSecret(oaName, namespace, data))
+
+		dtcMock := &dtclient.MockDynatraceClient{}
+		dtcMock.On("GetTokenMetadata", paasToken).Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+		dtcMock.On("GetTokenMetadata", apiToken).Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+		dtcMock.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+
+		rec := &DynatraceClientReconciler{
+			Client:              c,
+			DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+			UpdatePaaSToken:     true,
+			UpdateAPIToken:      true,
+			Now:                 metav1.Now(),
+		}
+
+		dtc, _, err := rec.Reconcile(context.TODO(), oa)
+		assert.Equal(t, dtcMock, dtc)
+		assert.NoError(t, err)
+
+		AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
+		AssertCondition(t, oa, dynatracev1alpha1.APITokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
+
+		mock.AssertExpectationsForObjects(t, dtcMock)
+	}
+
+	t.Run("legacy keys used if current ones are absent", func(t *testing.T) {
+		reconcileWith(t, map[string]string{"paas-token": "42", "api_token": "84"}, "42", "84")
+	})
+
+	t.Run("current keys win over legacy ones", func(t *testing.T) {
+		reconcileWith(t, map[string]string{
+			DynatracePaasToken: "42",
+			"paas-token":       "1",
+			"api-token":        "84",
+		}, "42", "84")
+	})
+}
+
+func TestReconcileDynatraceClient_TokensNamespace(t *testing.T) {
+	namespace := "dynatrace"
+	oaName := "oneagent"
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: oaName, Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL:          "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens:          oaName,
+				TokensNamespace: "shared-secrets",
+			},
+		},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme,
+		NewSecret(oaName, namespace, map[string]string{DynatracePaasToken: "1", DynatraceApiToken: "2"}),
+		NewSecret(oaName, "shared-secrets", map[string]string{DynatracePaasToken: "42", DynatraceApiToken: "84"}))
+
+	dtcMock := &dtclient.MockDynatraceClient{}
+	dtcMock.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+	dtcMock.On("GetTokenMetadata", "84").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
+	dtcMock.On("GetConnectionInfo").Return(dtclient.ConnectionInfo{TenantUUID: "abc123456"}, nil)
+
+	rec := &DynatraceClientReconciler{
+		Client:              c,
+		DynatraceClientFunc: StaticDynatraceClient(dtcMock),
+		UpdatePaaSToken:     true,
+		UpdateAPIToken:      true,
+		Now:                 metav1.Now(),
+	}
+
+	dtc, _, err := rec.Reconcile(context.TODO(), oa)
+	assert.Equal(t, dtcMock, dtc)
+	assert.NoError(t, err)
+	AssertCondition(t, oa, dynatracev1alpha1.PaaSTokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
+	AssertCondition(t, oa, dynatracev1alpha1.APITokenConditionType, true, dynatracev1alpha1.ReasonTokenReady, "Ready")
+	mock.AssertExpectationsForObjects(t, dtcMock)
+
+	t.Run("missing secret in tokens namespace", func(t *testing.T) {
+		oa := oa.DeepCopy()
+		oa.Spec.TokensNamespace = "other-secrets"
+
+		_, _, err := rec.Reconcile(context.TODO(), oa)
+		assert.EqualError(t, err, "Secret 'other-secrets:oneagent' not found")
+	})
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// leaderGauge is 1 while this replica of the operator holds the leader lock, and 0 while it waits for it
+var leaderGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "dynatrace_oneagent_operator_leader",
+	Help: "Whether this replica holds the leader lock and reconciles the OneAgent instances.",
+}, []string{"lock"})
+
+func init() {
+	metrics.Registry.MustRegister(leaderGauge)
+}
+
+// LeadershipReporter exposes the leadership of this replica for a leader lock on the metrics of the operator, and logs
+// whenever it changes. Replicas not holding the lock don't reconcile, so it tells which replica to look at when
+// debugging.
+type LeadershipReporter struct {
+	logger logr.Logger
+	lock   string
+
+	mu       sync.Mutex
+	reported bool
+	leader   bool
+}
+
+// NewLeadershipReporter creates a reporter for the leader lock with the given name.
+func NewLeadershipReporter(logger logr.Logger, lock string) *LeadershipReporter {
+	return &LeadershipReporter{logger: logger, lock: lock}
+}
+
+// Report sets whether this replica holds the leader lock. It's safe to be called on every leadership callback, only
+// changes are logged.
+func (r *LeadershipReporter) Report(leader bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value := 0.0
+	if leader {
+		value = 1
+	}
+	leaderGauge.WithLabelValues(r.lock).Set(value)
+
+	if r.reported && r.leader == leader {
+		return
+	}
+	r.reported = true
+	r.leader = leader
+
+	if leader {
+		r.logger.Info("Acquired leadership, reconciling", "lock", r.lock)
+	} else {
+		r.logger.Info("Not the leader, waiting for leadership", "lock", r.lock)
+	}
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestLeadershipReporter(t *testing.T) {
+	const lock = "dynatrace-oneagent-test-lock"
+	r := NewLeadershipReporter(logf.Log, lock)
+
+	r.Report(false)
+	assert.Equal(t, 0.0, testutil.ToFloat64(leaderGauge.WithLabelValues(lock)))
+
+	r.Report(true)
+	assert.Equal(t, 1.0, testutil.ToFloat64(leaderGauge.WithLabelValues(lock)))
+	assert.True(t, r.leader)
+
+	r.Report(true)
+	assert.Equal(t, 1.0, testutil.ToFloat64(leaderGauge.WithLabelValues(lock)))
+
+	other := NewLeadershipReporter(logf.Log, "dynatrace-oneagent-other-lock")
+	other.Report(false)
+	assert.Equal(t, 1.0, testutil.ToFloat64(leaderGauge.WithLabelValues(lock)), "reported per lock")
+	assert.Equal(t, 0.0, testutil.ToFloat64(leaderGauge.WithLabelValues("dynatrace-oneagent-other-lock")))
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// multiNamespaceCache keeps namespaced objects in a cache per watched namespace, and cluster-scoped objects like
+// Nodes and Namespaces in a single cache for the whole cluster. The multi-namespace cache of controller-runtime fails
+// getting cluster-scoped objects and lists them once per namespace.
+type multiNamespaceCache struct {
+	namespaced cache.Cache
+	cluster    cache.Cache
+	scheme     *runtime.Scheme
+	mapper     meta.RESTMapper
+}
+
+var _ cache.Cache = &multiNamespaceCache{}
+
+// NewMultiNamespaceCache returns a cache builder watching namespaced objects in the given namespaces only, and
+// cluster-scoped objects in the whole cluster.
+func NewMultiNamespaceCache(namespaces []string) cache.NewCacheFunc {
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		if opts.Scheme == nil {
+			opts.Scheme = scheme.Scheme
+		}
+		if opts.Mapper == nil {
+			mapper, err := apiutil.NewDiscoveryRESTMapper(config)
+			if err != nil {
+				return nil, err
+			}
+			opts.Mapper = mapper
+		}
+
+		namespaced, err := cache.MultiNamespacedCacheBuilder(namespaces)(config, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.Namespace = ""
+		cluster, err := cache.New(config, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return &multiNamespaceCache{namespaced: namespaced, cluster: cluster, scheme: opts.Scheme, mapper: opts.Mapper}, nil
+	}
+}
+
+func (c *multiNamespaceCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	ca, err := c.cacheFor(obj)
+	if err != nil {
+		return err
+	}
+	return ca.Get(ctx, key, obj)
+}
+
+func (c *multiNamespaceCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	ca, err := c.cacheFor(list)
+	if err != nil {
+		return err
+	}
+	return ca.List(ctx, list, opts...)
+}
+
+func (c *multiNamespaceCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	ca, err := c.cacheFor(obj)
+	if err != nil {
+		return nil, err
+	}
+	return ca.GetInformer(obj)
+}
+
+func (c *multiNamespaceCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	ca, err := c.cacheForKind(gvk)
+	if err != nil {
+		return nil, err
+	}
+	return ca.GetInformerForKind(gvk)
+}
+
+func (c *multiNamespaceCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	ca, err := c.cacheFor(obj)
+	if err != nil {
+		return err
+	}
+	return ca.IndexField(obj, field, extractValue)
+}
+
+// Start runs both caches until stop is closed.
+func (c *multiNamespaceCache) Start(stop <-chan struct{}) error {
+	go func() {
+		if err := c.cluster.Start(stop); err != nil {
+			log.Error(err, "failed to start cluster-scoped cache")
+		}
+	}()
+	return c.namespaced.Start(stop)
+}
+
+func (c *multiNamespaceCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	namespacedSynced := c.namespaced.WaitForCacheSync(stop)
+	return c.cluster.WaitForCacheSync(stop) && namespacedSynced
+}
+
+// cacheFor returns the cache holding objects of the kind of obj, which also may be a list of them.
+func (c *multiNamespaceCache) cacheFor(obj runtime.Object) (cache.Cache, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return nil, err
+	}
+	if meta.IsListType(obj) {
+		gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	}
+	return c.cacheForKind(gvk)
+}
+
+func (c *multiNamespaceCache) cacheForKind(gvk schema.GroupVersionKind) (cache.Cache, error) {
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return c.cluster, nil
+	}
+	return c.namespaced, nil
+}
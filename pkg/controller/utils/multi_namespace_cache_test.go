@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestMultiNamespaceCache(t *testing.T) {
+	listMeta := metav1.ListMeta{ResourceVersion: "1"}
+	newPod := func(name, ns string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, ResourceVersion: "1"}}
+	}
+	lists := map[string]runtime.Object{
+		"/api/v1/nodes": &corev1.NodeList{ListMeta: listMeta, Items: []corev1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "node-1", ResourceVersion: "1"}},
+		}},
+		"/api/v1/namespaces": &corev1.NamespaceList{ListMeta: listMeta, Items: []corev1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", ResourceVersion: "1"}},
+		}},
+		"/api/v1/namespaces/dynatrace/pods":  &corev1.PodList{ListMeta: listMeta, Items: []corev1.Pod{newPod("oneagent-1", "dynatrace")}},
+		"/api/v1/namespaces/monitoring/pods": &corev1.PodList{ListMeta: listMeta, Items: []corev1.Pod{newPod("oneagent-2", "monitoring")}},
+	}
+
+	// Serves the lists, and keeps watches open without any events.
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list, ok := lists[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	}))
+	defer apiServer.Close()
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Node"), meta.RESTScopeRoot)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Pod"), meta.RESTScopeNamespace)
+
+	c, err := NewMultiNamespaceCache([]string{"dynatrace", "monitoring"})(&rest.Config{Host: apiServer.URL},
+		cache.Options{Scheme: scheme.Scheme, Mapper: mapper})
+	require.NoError(t, err)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		_ = c.Start(stop)
+	}()
+	require.True(t, c.WaitForCacheSync(stop))
+
+	t.Run("get cluster-scoped objects", func(t *testing.T) {
+		var node corev1.Node
+		require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: "node-1"}, &node))
+		assert.Equal(t, "node-1", node.Name)
+
+		var ns corev1.Namespace
+		require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: "kube-system"}, &ns))
+	})
+
+	t.Run("list cluster-scoped objects once", func(t *testing.T) {
+		var nodes corev1.NodeList
+		require.NoError(t, c.List(context.TODO(), &nodes))
+		assert.Len(t, nodes.Items, 1)
+	})
+
+	t.Run("list namespaced objects of all watched namespaces", func(t *testing.T) {
+		var pods corev1.PodList
+		require.NoError(t, c.List(context.TODO(), &pods))
+		assert.Len(t, pods.Items, 2)
+
+		require.NoError(t, c.List(context.TODO(), &pods, client.InNamespace("monitoring")))
+		if assert.Len(t, pods.Items, 1) {
+			assert.Equal(t, "oneagent-2", pods.Items[0].Name)
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
@@ -17,13 +18,25 @@ import (
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 const (
 	DynatracePaasToken = "paasToken"
 	DynatraceApiToken  = "apiToken"
+
+	// DynatraceFailoverApiTokenPrefix is the prefix for secret keys holding API tokens to fail over to on rate limits
+	DynatraceFailoverApiTokenPrefix = "apiToken-"
 )
 
+// legacyTokenKeys are the secret keys used by older installations for the tokens, in lookup order
+var legacyTokenKeys = map[string][]string{
+	DynatracePaasToken: {"paas-token", "paas_token"},
+	DynatraceApiToken:  {"api-token", "api_token"},
+}
+
+var log = logf.Log.WithName("utils")
+
 // DynatraceClientFunc defines handler func for dynatrace client
 type DynatraceClientFunc func(rtc client.Client, instance dynatracev1alpha1.BaseOneAgent, hasAPIToken, hasPaaSToken bool) (dtclient.Client, error)
 
@@ -32,12 +45,6 @@ func BuildDynatraceClient(rtc client.Client, instance dynatracev1alpha1.BaseOneA
 	ns := instance.GetNamespace()
 	spec := instance.GetSpec()
 
-	secret := &corev1.Secret{}
-	err := rtc.Get(context.TODO(), client.ObjectKey{Name: GetTokensName(instance), Namespace: ns}, secret)
-	if err != nil && !k8serrors.IsNotFound(err) {
-		return nil, err
-	}
-
 	// initialize dynatrace client
 	var opts []dtclient.Option
 	if spec.SkipCertCheck {
@@ -77,16 +84,27 @@ func BuildDynatraceClient(rtc client.Client, instance dynatracev1alpha1.BaseOneA
 		opts = append(opts, dtclient.NetworkZone(spec.NetworkZone))
 	}
 
+	var err error
+
 	var apiToken string
 	if hasAPIToken {
+		secret, err := getSecret(rtc, GetTokensNamespace(instance), GetAPITokenSecretName(instance))
+		if err != nil {
+			return nil, err
+		}
+
 		if apiToken, err = extractToken(secret, DynatraceApiToken); err != nil {
 			return nil, err
 		}
+
+		if tokens := extractFailoverAPITokens(secret); len(tokens) > 0 {
+			opts = append(opts, dtclient.FailoverAPITokens(tokens...))
+		}
 	}
 
 	var paasToken string
 	if hasPaaSToken {
-		if paasToken, err = extractToken(secret, DynatracePaasToken); err != nil {
+		if paasToken, err = getTokenFromSecret(rtc, GetTokensNamespace(instance), GetPaaSTokenSecretName(instance), DynatracePaasToken); err != nil {
 			return nil, err
 		}
 	}
@@ -94,8 +112,45 @@ func BuildDynatraceClient(rtc client.Client, instance dynatracev1alpha1.BaseOneA
 	return dtclient.NewClient(spec.APIURL, apiToken, paasToken, opts...)
 }
 
+func getTokenFromSecret(rtc client.Client, ns, name, key string) (string, error) {
+	secret, err := getSecret(rtc, ns, name)
+	if err != nil {
+		return "", err
+	}
+	return extractToken(secret, key)
+}
+
+// getSecret returns the secret with the given name, or an empty secret if it doesn't exist.
+func getSecret(rtc client.Client, ns, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	if err := rtc.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: ns}, secret); err != nil && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// extractFailoverAPITokens returns the additional API tokens on the secret, i.e., the non-empty values for keys
+// prefixed with "apiToken-", sorted by key.
+func extractFailoverAPITokens(secret *corev1.Secret) []string {
+	var keys []string
+	for key := range secret.Data {
+		if strings.HasPrefix(key, DynatraceFailoverApiTokenPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var tokens []string
+	for _, key := range keys {
+		if token := strings.TrimSpace(string(secret.Data[key])); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
 func extractToken(secret *corev1.Secret, key string) (string, error) {
-	value, ok := secret.Data[key]
+	value, ok := LookupToken(secret, key)
 	if !ok {
 		err := fmt.Errorf("missing token %s", key)
 		return "", err
@@ -104,6 +159,32 @@ func extractToken(secret *corev1.Secret, key string) (string, error) {
 	return strings.TrimSpace(string(value)), nil
 }
 
+// LookupToken returns the value for the token key on the secret, and whether it was found. If the key is absent, the
+// legacy keys for the token are checked instead, logging a deprecation notice if one of them is used.
+func LookupToken(secret *corev1.Secret, key string) ([]byte, bool) {
+	resolved := ResolveTokenKey(secret, key)
+	value, ok := secret.Data[resolved]
+	if ok && resolved != key {
+		log.Info("DEPRECATION: secret uses legacy token key, rename it to the current one",
+			"secret", secret.Namespace+":"+secret.Name, "key", resolved, "expected", key)
+	}
+	return value, ok
+}
+
+// ResolveTokenKey returns the key holding the token on the secret: key itself if present, otherwise the first legacy
+// key for the token that is present. Returns key if none of them is present.
+func ResolveTokenKey(secret *corev1.Secret, key string) string {
+	if _, ok := secret.Data[key]; ok {
+		return key
+	}
+	for _, legacy := range legacyTokenKeys[key] {
+		if _, ok := secret.Data[legacy]; ok {
+			return legacy
+		}
+	}
+	return key
+}
+
 // StaticDynatraceClient creates a DynatraceClientFunc always returning c.
 func StaticDynatraceClient(c dtclient.Client) DynatraceClientFunc {
 	return func(_ client.Client, oa dynatracev1alpha1.BaseOneAgent, _, _ bool) (dtclient.Client, error) {
@@ -111,6 +192,15 @@ func StaticDynatraceClient(c dtclient.Client) DynatraceClientFunc {
 	}
 }
 
+// GetTokensNamespace returns the namespace of the token secrets, falling back to the namespace of the instance if no
+// dedicated namespace is set.
+func GetTokensNamespace(obj dynatracev1alpha1.BaseOneAgent) string {
+	if ns := obj.GetSpec().TokensNamespace; ns != "" {
+		return ns
+	}
+	return obj.GetNamespace()
+}
+
 func GetTokensName(obj dynatracev1alpha1.BaseOneAgent) string {
 	if tkns := obj.GetSpec().Tokens; tkns != "" {
 		return tkns
@@ -118,6 +208,24 @@ func GetTokensName(obj dynatracev1alpha1.BaseOneAgent) string {
 	return obj.GetName()
 }
 
+// GetPaaSTokenSecretName returns the name of the secret holding the PaaS token, falling back to the combined tokens
+// secret if no dedicated secret is set.
+func GetPaaSTokenSecretName(obj dynatracev1alpha1.BaseOneAgent) string {
+	if s := obj.GetSpec().PaaSTokenSecret; s != "" {
+		return s
+	}
+	return GetTokensName(obj)
+}
+
+// GetAPITokenSecretName returns the name of the secret holding the API token, falling back to the combined tokens
+// secret if no dedicated secret is set.
+func GetAPITokenSecretName(obj dynatracev1alpha1.BaseOneAgent) string {
+	if s := obj.GetSpec().APITokenSecret; s != "" {
+		return s
+	}
+	return GetTokensName(obj)
+}
+
 // GetDeployment returns the Deployment object who is the owner of this pod.
 func GetDeployment(c client.Client, ns string) (*appsv1.Deployment, error) {
 	pod, err := k8sutil.GetPod(context.TODO(), c, ns)
@@ -151,6 +259,40 @@ func GetDeployment(c client.Client, ns string) (*appsv1.Deployment, error) {
 	return &d, nil
 }
 
+// GetWatchNamespaces returns the namespaces set on WATCH_NAMESPACE as a comma-separated list. The first namespace is
+// the one the Operator is deployed to. An empty WATCH_NAMESPACE is returned as a single empty namespace, meaning all
+// namespaces are watched.
+func GetWatchNamespaces() ([]string, error) {
+	ns, err := k8sutil.GetWatchNamespace()
+	if err != nil {
+		return nil, err
+	}
+	if ns == "" {
+		return []string{""}, nil
+	}
+
+	var out []string
+	for _, n := range strings.Split(ns, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			out = append(out, n)
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s must contain at least one namespace", k8sutil.WatchNamespaceEnvVar)
+	}
+	return out, nil
+}
+
+// GetOperatorNamespace returns the first namespace from WATCH_NAMESPACE, where the Operator keeps the objects it owns.
+func GetOperatorNamespace() (string, error) {
+	nss, err := GetWatchNamespaces()
+	if err != nil {
+		return "", err
+	}
+	return nss[0], nil
+}
+
 // CreateOrUpdateSecretIfNotExists creates a secret in case it does not exist or updates it if there are changes
 func CreateOrUpdateSecretIfNotExists(c client.Client, r client.Reader, secretName string, targetNS string, data map[string][]byte, secretType corev1.SecretType, log logr.Logger) error {
 	var cfg corev1.Secret
@@ -212,12 +354,13 @@ func GeneratePullSecretData(c client.Client, oa dynatracev1alpha1.BaseOneAgent,
 		return nil, err
 	}
 
-	a := fmt.Sprintf("%s:%s", ci.TenantUUID, string(tkns.Data[DynatracePaasToken]))
+	paasToken, _ := LookupToken(tkns, DynatracePaasToken)
+	a := fmt.Sprintf("%s:%s", ci.TenantUUID, string(paasToken))
 	a = b64.StdEncoding.EncodeToString([]byte(a))
 
 	auth := auths{
 		Username: ci.TenantUUID,
-		Password: string(tkns.Data[DynatracePaasToken]),
+		Password: string(paasToken),
 		Auth:     a,
 	}
 
@@ -51,6 +51,17 @@ func TestExtractToken(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, token2, "dynatrace_test_token_2")
 	}
+	{
+		secret := corev1.Secret{Data: map[string][]byte{"paas_token": []byte("legacy")}}
+		token, err := extractToken(&secret, DynatracePaasToken)
+		assert.NoError(t, err)
+		assert.Equal(t, token, "legacy")
+
+		secret.Data[DynatracePaasToken] = []byte("current")
+		token, err = extractToken(&secret, DynatracePaasToken)
+		assert.NoError(t, err)
+		assert.Equal(t, token, "current")
+	}
 }
 
 func TestBuildDynatraceClient(t *testing.T) {
@@ -103,6 +114,53 @@ func TestBuildDynatraceClient(t *testing.T) {
 	}
 }
 
+func TestBuildDynatraceClient_SeparateTokenSecrets(t *testing.T) {
+	namespace := "dynatrace"
+
+	oa := &dynatracev1alpha1.OneAgent{
+		ObjectMeta: metav1.ObjectMeta{Name: "oneagent", Namespace: namespace},
+		Spec: dynatracev1alpha1.OneAgentSpec{
+			BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{
+				APIURL:          "https://ENVIRONMENTID.live.dynatrace.com/api",
+				Tokens:          "custom-token",
+				PaaSTokenSecret: "paas-token",
+			},
+		},
+	}
+
+	{
+		fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme,
+			NewSecret("custom-token", namespace, map[string]string{DynatraceApiToken: "43"}),
+			NewSecret("paas-token", namespace, map[string]string{DynatracePaasToken: "42"}),
+		)
+
+		_, err := BuildDynatraceClient(fakeClient, oa, true, true)
+		assert.NoError(t, err)
+	}
+
+	{
+		fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme,
+			NewSecret("custom-token", namespace, map[string]string{DynatracePaasToken: "42", DynatraceApiToken: "43"}),
+		)
+
+		_, err := BuildDynatraceClient(fakeClient, oa, true, true)
+		assert.EqualError(t, err, "missing token paasToken")
+	}
+}
+
+func TestExtractFailoverAPITokens(t *testing.T) {
+	secret := NewSecret("dynakube", "dynatrace", map[string]string{
+		DynatracePaasToken:                    "42",
+		DynatraceApiToken:                     "84",
+		DynatraceFailoverApiTokenPrefix + "b": "86",
+		DynatraceFailoverApiTokenPrefix + "a": " 85 ",
+		DynatraceFailoverApiTokenPrefix + "c": "",
+	})
+
+	assert.Equal(t, []string{"85", "86"}, extractFailoverAPITokens(secret))
+	assert.Empty(t, extractFailoverAPITokens(NewSecret("dynakube", "dynatrace", map[string]string{DynatraceApiToken: "84"})))
+}
+
 // GetDeployment returns the Deployment object who is the owner of this pod.
 func TestGetDeployment(t *testing.T) {
 	const ns = "dynatrace"
@@ -143,6 +201,32 @@ func TestGetDeployment(t *testing.T) {
 	assert.Equal(t, "dynatrace", deploy.Namespace)
 }
 
+func TestGetWatchNamespaces(t *testing.T) {
+	defer os.Unsetenv(k8sutil.WatchNamespaceEnvVar)
+
+	os.Setenv(k8sutil.WatchNamespaceEnvVar, "dynatrace")
+	nss, err := GetWatchNamespaces()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dynatrace"}, nss)
+
+	os.Setenv(k8sutil.WatchNamespaceEnvVar, "dynatrace, team-a,,team-b ")
+	nss, err = GetWatchNamespaces()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dynatrace", "team-a", "team-b"}, nss)
+
+	ns, err := GetOperatorNamespace()
+	require.NoError(t, err)
+	assert.Equal(t, "dynatrace", ns)
+
+	os.Setenv(k8sutil.WatchNamespaceEnvVar, " , ")
+	_, err = GetWatchNamespaces()
+	assert.Error(t, err)
+
+	os.Unsetenv(k8sutil.WatchNamespaceEnvVar)
+	_, err = GetWatchNamespaces()
+	assert.Error(t, err)
+}
+
 func TestBuildOneAgentAPMImage(t *testing.T) {
 	var tag string
 	var err error
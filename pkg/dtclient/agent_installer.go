@@ -0,0 +1,105 @@
+package dtclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ChecksumMismatchError is returned if the SHA-256 checksum of a downloaded installer differs from the one announced by
+// the Dynatrace API, e.g. because a mirror served a corrupted file.
+type ChecksumMismatchError struct {
+	Expected string
+	Actual   string
+}
+
+func (e ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("installer checksum mismatch: expected sha256 %s, got %s", e.Expected, e.Actual)
+}
+
+// GetAgentInstaller returns the stream of the installer for the given OS, installer type and version. The response is
+// streamed as is, without the limit on the response size, and must be closed by the caller.
+func (dc *dynatraceClient) GetAgentInstaller(os, installerType, version string) (io.ReadCloser, error) {
+	if len(os) == 0 || len(installerType) == 0 || len(version) == 0 {
+		return nil, errors.New("os, installerType or version is empty")
+	}
+
+	url := dc.getURL(fmt.Sprintf("/v1/deployment/installer/agent/%s/%s/version/%s", os, installerType, version))
+	resp, err := dc.makeRequest(url, dynatracePaaSToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, err := dc.readResponseBody(resp)
+		if err != nil {
+			return nil, err
+		}
+		return nil, dc.handleErrorResponseFromAPI(data, resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+// GetAgentInstallerChecksum returns the hex encoded SHA-256 checksum of the installer for the given OS, installer type
+// and version.
+func (dc *dynatraceClient) GetAgentInstallerChecksum(os, installerType, version string) (string, error) {
+	if len(os) == 0 || len(installerType) == 0 || len(version) == 0 {
+		return "", errors.New("os, installerType or version is empty")
+	}
+
+	url := dc.getURL(fmt.Sprintf("/v1/deployment/installer/agent/%s/%s/version/%s/checksum", os, installerType, version))
+	resp, err := dc.makeRequest(url, dynatracePaaSToken)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := dc.getServerResponseData(resp)
+	if err != nil {
+		return "", err
+	}
+
+	var jr struct {
+		SHA256 string `json:"sha256"`
+	}
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return "", fmt.Errorf("error unmarshalling json response: %w", err)
+	}
+	if jr.SHA256 == "" {
+		return "", errors.New("installer checksum not set")
+	}
+	return strings.ToLower(jr.SHA256), nil
+}
+
+// DownloadAgentInstaller writes the installer for the given OS, installer type and version to w, and verifies it
+// against the checksum from GetAgentInstallerChecksum. Returns a ChecksumMismatchError if they differ, in which case
+// the bytes written to w must be discarded.
+func DownloadAgentInstaller(dtc Client, os, installerType, version string, w io.Writer) error {
+	expected, err := dtc.GetAgentInstallerChecksum(os, installerType, version)
+	if err != nil {
+		return fmt.Errorf("failed to get installer checksum: %w", err)
+	}
+
+	installer, err := dtc.GetAgentInstaller(os, installerType, version)
+	if err != nil {
+		return fmt.Errorf("failed to download installer: %w", err)
+	}
+	defer installer.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), installer); err != nil {
+		return fmt.Errorf("failed to download installer: %w", err)
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		return ChecksumMismatchError{Expected: expected, Actual: actual}
+	}
+	return nil
+}
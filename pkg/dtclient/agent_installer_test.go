@@ -0,0 +1,76 @@
+package dtclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadAgentInstaller(t *testing.T) {
+	const version = "1.203.0.20200908-220956"
+	installer := bytes.Repeat([]byte("installer"), 1024)
+	sum := sha256.Sum256(installer)
+	checksum := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Api-Token "+paasToken {
+			writeError(w, http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/deployment/installer/agent/unix/default/version/" + version:
+			_, _ = w.Write(installer)
+		case "/v1/deployment/installer/agent/unix/default/version/" + version + "/checksum":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"sha256": "` + strings.ToUpper(checksum) + `"}`))
+		default:
+			writeError(w, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// The installer is larger than the response size limit, but streamed.
+	dtc, err := NewClient(server.URL, apiToken, paasToken, MaxResponseSize(1024))
+	require.NoError(t, err)
+
+	t.Run("matching checksum", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, DownloadAgentInstaller(dtc, OsUnix, InstallerTypeDefault, version, &buf))
+		assert.Equal(t, installer, buf.Bytes())
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		_, err := dtc.GetAgentInstaller(OsUnix, InstallerTypeDefault, "1.0.0")
+		var serr ServerError
+		require.True(t, errors.As(err, &serr))
+		assert.Equal(t, http.StatusNotFound, serr.Code)
+
+		_, err = dtc.GetAgentInstallerChecksum(OsUnix, InstallerTypeDefault, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("mismatching checksum", func(t *testing.T) {
+		dtcMock := &MockDynatraceClient{}
+		dtcMock.On("GetAgentInstallerChecksum", OsUnix, InstallerTypeDefault, version).Return(checksum, nil)
+		dtcMock.On("GetAgentInstaller", OsUnix, InstallerTypeDefault, version).
+			Return(ioutil.NopCloser(bytes.NewReader(installer[1:])), nil)
+
+		err := DownloadAgentInstaller(dtcMock, OsUnix, InstallerTypeDefault, version, ioutil.Discard)
+		var cerr ChecksumMismatchError
+		require.True(t, errors.As(err, &cerr))
+		assert.Equal(t, checksum, cerr.Expected)
+		assert.NotEqual(t, checksum, cerr.Actual)
+		mock.AssertExpectationsForObjects(t, dtcMock)
+	})
+}
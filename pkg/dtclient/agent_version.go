@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/version"
 )
 
 func (dc *dynatraceClient) GetAgentVersionForIP(ip string) (string, error) {
@@ -22,25 +26,148 @@ func (dc *dynatraceClient) GetAgentVersionForIP(ip string) (string, error) {
 	return hostInfo.version, nil
 }
 
-// GetVersionForLatest gets the latest agent version for the given OS and installer type.
+func (dc *dynatraceClient) GetMonitoringModeForIP(ip string) (string, error) {
+	if len(ip) == 0 {
+		return "", errors.New("ip is invalid")
+	}
+
+	hostInfo, err := dc.getHostInfoForIP(ip)
+	if err != nil {
+		return "", err
+	}
+	if hostInfo.monitoringMode == "" {
+		return "", errors.New("monitoring mode not set for host")
+	}
+
+	return hostInfo.monitoringMode, nil
+}
+
+func (dc *dynatraceClient) GetLastSeenForIP(ip string) (time.Time, error) {
+	if len(ip) == 0 {
+		return time.Time{}, errors.New("ip is invalid")
+	}
+
+	hostInfo, err := dc.getHostInfoForIP(ip)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return hostInfo.lastSeen, nil
+}
+
+// GetVersionForLatest gets the latest agent version for the given OS and installer type. Successful results are
+// served from the agent version cache until they expire. Once expired, the request is sent conditionally with the ETag
+// of the last response, if the server provided one.
 func (dc *dynatraceClient) GetLatestAgentVersion(os, installerType string) (string, error) {
 	if len(os) == 0 || len(installerType) == 0 {
 		return "", errors.New("os or installerType is empty")
 	}
 
-	url := fmt.Sprintf("%s/v1/deployment/installer/agent/%s/%s/latest/metainfo", dc.url, os, installerType)
-	resp, err := dc.makeRequest(url, dynatracePaaSToken)
+	key := agentVersionCacheKey{url: dc.url, paasToken: dc.paasToken, os: os, installerType: installerType}
+	if dc.agentVersionCache != nil {
+		if v, ok := dc.agentVersionCache.get(key); ok {
+			return v, nil
+		}
+	}
+
+	info, err := dc.getLatestAgentVersionInfo(os, installerType)
+	if err != nil {
+		return "", err
+	}
+
+	if dc.agentVersionCache != nil {
+		dc.agentVersionCache.set(key, info.Version)
+	}
+	return info.Version, nil
+}
+
+// AgentVersionInfo is an agent version with the release metadata reported by the server.
+type AgentVersionInfo struct {
+	Version string
+
+	// ReleaseDate is zero if not reported by the server.
+	ReleaseDate time.Time
+
+	// LTS is true if the version is a supported long-term support release.
+	LTS bool
+}
+
+// GetLatestAgentVersionInfo gets the latest agent version for the given OS and installer type together with its
+// release metadata. Responses aren't served from the agent version cache, but update it.
+func (dc *dynatraceClient) GetLatestAgentVersionInfo(os, installerType string) (AgentVersionInfo, error) {
+	if len(os) == 0 || len(installerType) == 0 {
+		return AgentVersionInfo{}, errors.New("os or installerType is empty")
+	}
+
+	info, err := dc.getLatestAgentVersionInfo(os, installerType)
+	if err != nil {
+		return AgentVersionInfo{}, err
+	}
+
+	if dc.agentVersionCache != nil {
+		key := agentVersionCacheKey{url: dc.url, paasToken: dc.paasToken, os: os, installerType: installerType}
+		dc.agentVersionCache.set(key, info.Version)
+	}
+	return info, nil
+}
+
+// getLatestAgentVersionInfo requests the metadata of the latest agent version, conditionally with the ETag of the last
+// response if the server provided one.
+func (dc *dynatraceClient) getLatestAgentVersionInfo(os, installerType string) (AgentVersionInfo, error) {
+	url := dc.getURL(fmt.Sprintf("/v1/deployment/installer/agent/%s/%s/latest/metainfo", os, installerType))
+	result, err := dc.getWithETag(url, dynatracePaaSToken, func(data []byte) (interface{}, error) {
+		return dc.readResponseForLatestVersionInfo(data)
+	})
+	if err != nil {
+		return AgentVersionInfo{}, err
+	}
+	return result.(AgentVersionInfo), nil
+}
+
+// GetLatestAgentVersionForBranch gets the newest agent version available for the given OS and installer type within
+// the branch, e.g. 1.247 for 1.247.x. Versions not in Dynatrace's format are ignored.
+func (dc *dynatraceClient) GetLatestAgentVersionForBranch(os, installerType, branch string) (string, error) {
+	if len(os) == 0 || len(installerType) == 0 {
+		return "", errors.New("os or installerType is empty")
+	}
+	if len(branch) == 0 {
+		return "", errors.New("branch is empty")
+	}
+
+	resp, err := dc.makeRequest(dc.getURL(fmt.Sprintf("/v1/deployment/installer/agent/versions/%s/%s", os, installerType)), dynatracePaaSToken)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	responseData, err := dc.getServerResponseData(resp)
+	data, err := dc.getServerResponseData(resp)
+	if err != nil {
+		return "", err
+	}
+
+	versions, err := dc.readResponseForAvailableVersions(data)
 	if err != nil {
 		return "", err
 	}
 
-	return dc.readResponseForLatestVersion(responseData)
+	latest := ""
+	for _, v := range versions {
+		if v != branch && !strings.HasPrefix(v, branch+".") {
+			continue
+		}
+		other := latest
+		if other == "" {
+			other = v
+		}
+		if cmp, err := version.CompareAgentVersions(v, other); err == nil && (latest == "" || cmp > 0) {
+			latest = v
+		}
+	}
+
+	if latest == "" {
+		return "", fmt.Errorf("no agent version available for branch %s", branch)
+	}
+	return latest, nil
 }
 
 func (dc *dynatraceClient) GetEntityIDForIP(ip string) (string, error) {
@@ -61,21 +188,49 @@ func (dc *dynatraceClient) GetEntityIDForIP(ip string) (string, error) {
 
 // readLatestVersion reads the agent version from the given server response reader.
 func (dc *dynatraceClient) readResponseForLatestVersion(response []byte) (string, error) {
+	info, err := dc.readResponseForLatestVersionInfo(response)
+	return info.Version, err
+}
+
+// readResponseForLatestVersionInfo reads the latest agent version with its release metadata from the given server
+// response. A malformed release date is ignored, so that the version can still be used.
+func (dc *dynatraceClient) readResponseForLatestVersionInfo(response []byte) (AgentVersionInfo, error) {
 	type jsonResponse struct {
 		LatestAgentVersion string
+		ReleaseDate        string `json:"releaseDate"`
+		LTS                bool   `json:"lts"`
 	}
 
 	jr := &jsonResponse{}
 	err := json.Unmarshal(response, jr)
 	if err != nil {
 		dc.logger.Error(err, "error unmarshalling json response")
-		return "", err
+		return AgentVersionInfo{}, err
 	}
 
 	v := jr.LatestAgentVersion
 	if len(v) == 0 {
-		return "", errors.New("agent version not set")
+		return AgentVersionInfo{}, errors.New("agent version not set")
+	}
+
+	info := AgentVersionInfo{Version: v, LTS: jr.LTS}
+	if jr.ReleaseDate != "" {
+		if info.ReleaseDate, err = time.Parse(time.RFC3339, jr.ReleaseDate); err != nil {
+			dc.logger.Info("ignoring malformed release date", "version", v, "releaseDate", jr.ReleaseDate)
+			info.ReleaseDate = time.Time{}
+		}
+	}
+	return info, nil
+}
+
+// readResponseForAvailableVersions reads the list of agent versions from the given server response.
+func (dc *dynatraceClient) readResponseForAvailableVersions(response []byte) ([]string, error) {
+	var jr struct {
+		AvailableVersions []string `json:"availableVersions"`
 	}
 
-	return v, nil
+	if err := json.Unmarshal(response, &jr); err != nil {
+		return nil, fmt.Errorf("error unmarshalling json response: %w", err)
+	}
+	return jr.AvailableVersions, nil
 }
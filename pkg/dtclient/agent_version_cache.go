@@ -0,0 +1,80 @@
+package dtclient
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultAgentVersionCacheTTL is the time the latest agent versions are kept in the DefaultAgentVersionCache.
+const DefaultAgentVersionCacheTTL = 1 * time.Hour
+
+// DefaultAgentVersionCache is the cache used by clients created by NewClient unless the LatestAgentVersionCache option
+// is given. Since it's shared, cached versions survive the clients, which are usually created on every reconciliation.
+var DefaultAgentVersionCache = NewAgentVersionCache(DefaultAgentVersionCacheTTL)
+
+// AgentVersionCache keeps the latest agent versions received from the Dynatrace API per environment, OS and installer
+// type for a fixed time. It's safe for concurrent use.
+type AgentVersionCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[agentVersionCacheKey]agentVersionCacheEntry
+
+	// Set for testing purposes, leave nil to use the current time.
+	now func() time.Time
+}
+
+type agentVersionCacheKey struct {
+	url           string
+	paasToken     string
+	os            string
+	installerType string
+}
+
+type agentVersionCacheEntry struct {
+	version string
+	expires time.Time
+}
+
+// NewAgentVersionCache creates a cache that keeps entries for the given time.
+func NewAgentVersionCache(ttl time.Duration) *AgentVersionCache {
+	return &AgentVersionCache{
+		ttl:     ttl,
+		entries: map[agentVersionCacheKey]agentVersionCacheEntry{},
+	}
+}
+
+// Invalidate drops all cached entries, so that the next queries go to the Dynatrace API.
+func (c *AgentVersionCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[agentVersionCacheKey]agentVersionCacheEntry{}
+}
+
+func (c *AgentVersionCache) get(key agentVersionCacheKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !c.currentTime().Before(e.expires) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return e.version, true
+}
+
+func (c *AgentVersionCache) set(key agentVersionCacheKey, version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = agentVersionCacheEntry{version: version, expires: c.currentTime().Add(c.ttl)}
+}
+
+func (c *AgentVersionCache) currentTime() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
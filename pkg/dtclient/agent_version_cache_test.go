@@ -0,0 +1,100 @@
+package dtclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLatestAgentVersionCached(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	latest := "1.203.0.20200908-220956"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		_ = json.NewEncoder(w).Encode(map[string]string{"latestAgentVersion": latest})
+	}))
+	defer ts.Close()
+
+	now := time.Date(2020, 9, 10, 12, 0, 0, 0, time.UTC)
+	cache := NewAgentVersionCache(DefaultAgentVersionCacheTTL)
+	cache.now = func() time.Time { return now }
+
+	dtc, err := NewClient(ts.URL, apiToken, paasToken, LatestAgentVersionCache(cache))
+	require.NoError(t, err)
+
+	callCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+
+	t.Run("single request within TTL", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := dtc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+				assert.NoError(t, err)
+				assert.Equal(t, "1.203.0.20200908-220956", v)
+			}()
+		}
+		wg.Wait()
+
+		// Concurrent misses may query the server more than once, later calls must be served from the cache
+		requests := callCount()
+		assert.GreaterOrEqual(t, requests, 1)
+
+		now = now.Add(59 * time.Minute)
+		v, err := dtc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.203.0.20200908-220956", v)
+		assert.Equal(t, requests, callCount())
+	})
+
+	t.Run("refresh after expiry", func(t *testing.T) {
+		mu.Lock()
+		calls = 0
+		latest = "1.205.0.20201010-120000"
+		mu.Unlock()
+
+		now = now.Add(2 * time.Minute)
+		v, err := dtc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.205.0.20201010-120000", v)
+		assert.Equal(t, 1, callCount())
+	})
+
+	t.Run("refresh after invalidation", func(t *testing.T) {
+		mu.Lock()
+		calls = 0
+		latest = "1.207.0.20201110-120000"
+		mu.Unlock()
+
+		cache.Invalidate()
+		v, err := dtc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.207.0.20201110-120000", v)
+		assert.Equal(t, 1, callCount())
+	})
+
+	t.Run("entries are kept per installer type", func(t *testing.T) {
+		mu.Lock()
+		calls = 0
+		mu.Unlock()
+
+		_, err := dtc.GetLatestAgentVersion(OsUnix, InstallerTypePaasSh)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, callCount())
+	})
+}
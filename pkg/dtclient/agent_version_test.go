@@ -2,8 +2,10 @@ package dtclient
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -26,6 +28,7 @@ const hostsResponse = `[
       "10.11.12.13",
       "192.168.0.1"
     ],
+    "monitoringMode": "FULL_STACK",
     "agentVersion": {
       "major": 1,
       "minor": 142,
@@ -74,6 +77,64 @@ func TestResponseForLatestVersion(t *testing.T) {
 
 }
 
+func TestResponseForLatestVersionInfo(t *testing.T) {
+	dc := &dynatraceClient{
+		logger: consoleLogger,
+	}
+	readFromString := func(json string) (AgentVersionInfo, error) {
+		return dc.readResponseForLatestVersionInfo([]byte(json))
+	}
+
+	{
+		info, err := readFromString(`{"latestAgentVersion": "1.195.0.20200504-100000", "releaseDate": "2020-05-04T10:00:00Z", "lts": true}`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, AgentVersionInfo{
+				Version:     "1.195.0.20200504-100000",
+				ReleaseDate: time.Date(2020, 5, 4, 10, 0, 0, 0, time.UTC),
+				LTS:         true,
+			}, info)
+		}
+	}
+	{
+		info, err := readFromString(`{"latestAgentVersion": "17"}`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, AgentVersionInfo{Version: "17"}, info, "metadata not reported")
+		}
+	}
+	{
+		info, err := readFromString(`{"latestAgentVersion": "17", "releaseDate": "last tuesday"}`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, AgentVersionInfo{Version: "17"}, info, "malformed release date ignored")
+		}
+	}
+	{
+		_, err := readFromString(`{"releaseDate": "2020-05-04T10:00:00Z", "lts": true}`)
+		assert.EqualError(t, err, "agent version not set")
+	}
+	{
+		_, err := readFromString(`{"latestAgentVersion": "17", "lts": "yes"}`)
+		assert.Error(t, err, "invalid LTS flag")
+	}
+}
+
+func testAgentVersionGetLatestAgentVersionInfo(t *testing.T, dynatraceClient Client) {
+	{
+		_, err := dynatraceClient.GetLatestAgentVersionInfo("", InstallerTypeDefault)
+
+		assert.Error(t, err, "empty OS")
+	}
+	{
+		info, err := dynatraceClient.GetLatestAgentVersionInfo(OsUnix, InstallerTypeDefault)
+
+		assert.NoError(t, err)
+		assert.Equal(t, AgentVersionInfo{
+			Version:     "17",
+			ReleaseDate: time.Date(2020, 5, 4, 10, 0, 0, 0, time.UTC),
+			LTS:         true,
+		}, info)
+	}
+}
+
 func testAgentVersionGetLatestAgentVersion(t *testing.T, dynatraceClient Client) {
 	{
 		_, err := dynatraceClient.GetLatestAgentVersion("", InstallerTypeDefault)
@@ -93,6 +154,31 @@ func testAgentVersionGetLatestAgentVersion(t *testing.T, dynatraceClient Client)
 	}
 }
 
+func testAgentVersionGetLatestAgentVersionForBranch(t *testing.T, dynatraceClient Client) {
+	{
+		_, err := dynatraceClient.GetLatestAgentVersionForBranch(OsUnix, InstallerTypeDefault, "")
+
+		assert.Error(t, err, "empty branch")
+	}
+	{
+		v, err := dynatraceClient.GetLatestAgentVersionForBranch(OsUnix, InstallerTypeDefault, "1.247")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1.247.3.20220915-101530", v, "newest version of the branch")
+	}
+	{
+		v, err := dynatraceClient.GetLatestAgentVersionForBranch(OsUnix, InstallerTypeDefault, "1.24")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1.24.0.20210101-000000", v, "prefix of a longer minor version doesn't match")
+	}
+	{
+		_, err := dynatraceClient.GetLatestAgentVersionForBranch(OsUnix, InstallerTypeDefault, "1.249")
+
+		assert.EqualError(t, err, "no agent version available for branch 1.249")
+	}
+}
+
 func testAgentVersionGetAgentVersionForIP(t *testing.T, dynatraceClient Client) {
 	{
 		_, err := dynatraceClient.GetAgentVersionForIP("")
@@ -117,6 +203,49 @@ func testAgentVersionGetAgentVersionForIP(t *testing.T, dynatraceClient Client)
 	}
 }
 
+func testAgentVersionGetMonitoringModeForIP(t *testing.T, dynatraceClient Client) {
+	{
+		_, err := dynatraceClient.GetMonitoringModeForIP("")
+
+		assert.Error(t, err, "lookup empty ip")
+	}
+	{
+		_, err := dynatraceClient.GetMonitoringModeForIP(unknownIP)
+
+		assert.Error(t, err, "lookup unknown ip")
+	}
+	{
+		_, err := dynatraceClient.GetMonitoringModeForIP(unsetIP)
+
+		assert.Error(t, err, "lookup unset ip")
+	}
+	{
+		mode, err := dynatraceClient.GetMonitoringModeForIP(goodIP)
+
+		assert.NoError(t, err, "lookup good ip")
+		assert.Equal(t, MonitoringModeFullStack, mode, "monitoring mode matches for lookup good ip")
+	}
+}
+
+func testAgentVersionGetLastSeenForIP(t *testing.T, dynatraceClient Client) {
+	{
+		_, err := dynatraceClient.GetLastSeenForIP("")
+
+		assert.Error(t, err, "lookup empty ip")
+	}
+	{
+		_, err := dynatraceClient.GetLastSeenForIP(unknownIP)
+
+		assert.True(t, errors.Is(err, ErrHostNotFound), "lookup unknown ip")
+	}
+	{
+		lastSeen, err := dynatraceClient.GetLastSeenForIP(goodIP)
+
+		assert.NoError(t, err, "lookup good ip")
+		assert.Equal(t, time.Unix(1521540000, 0).UTC(), lastSeen, "last seen matches for lookup good ip")
+	}
+}
+
 func handleVersionForIP(request *http.Request, writer http.ResponseWriter) {
 	switch request.Method {
 	case "GET":
@@ -127,11 +256,28 @@ func handleVersionForIP(request *http.Request, writer http.ResponseWriter) {
 	}
 }
 
+func handleAvailableVersions(request *http.Request, writer http.ResponseWriter) {
+	switch request.Method {
+	case "GET":
+		writer.WriteHeader(http.StatusOK)
+		out, _ := json.Marshal(map[string][]string{"availableVersions": {
+			"1.24.0.20210101-000000",
+			"1.247.3.20220915-101530",
+			"1.248.0.20221001-093000",
+			"1.247.latest",
+			"1.247.1.20220901-120000",
+		}})
+		_, _ = writer.Write(out)
+	default:
+		writeError(writer, http.StatusMethodNotAllowed)
+	}
+}
+
 func handleLatestAgentVersion(request *http.Request, writer http.ResponseWriter) {
 	switch request.Method {
 	case "GET":
 		writer.WriteHeader(http.StatusOK)
-		out, _ := json.Marshal(map[string]string{"latestAgentVersion": "17"})
+		out, _ := json.Marshal(map[string]interface{}{"latestAgentVersion": "17", "releaseDate": "2020-05-04T10:00:00Z", "lts": true})
 		_, _ = writer.Write(out)
 	default:
 		writeError(writer, http.StatusMethodNotAllowed)
@@ -0,0 +1,114 @@
+package dtclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oauthTokenExpiryMargin is subtracted from the lifetime of OAuth access tokens, so that they are refreshed before
+// requests start getting rejected.
+const oauthTokenExpiryMargin = 30 * time.Second
+
+// authStrategy sets the credentials on the requests sent to the Dynatrace API.
+type authStrategy interface {
+	// authorize sets the Authorization header on the request.
+	authorize(req *http.Request) error
+}
+
+// setAPITokenHeader authenticates the request with an API or PaaS token, used if no authStrategy is set on the client.
+func setAPITokenHeader(req *http.Request, token string) {
+	req.Header.Set("Authorization", fmt.Sprintf("Api-Token %s", token))
+}
+
+// oauthClientCredentials authenticates requests with bearer tokens acquired from an OAuth token endpoint through the
+// client credentials grant. Tokens are cached until they expire.
+type oauthClientCredentials struct {
+	dc *dynatraceClient
+
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	mu          sync.Mutex
+	accessToken string
+	expires     time.Time
+
+	// Set for testing purposes, leave nil to use the current time.
+	now func() time.Time
+}
+
+func (o *oauthClientCredentials) authorize(req *http.Request) error {
+	token, err := o.token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	return nil
+}
+
+// token returns the cached access token, or acquires a new one if it's missing or expired.
+func (o *oauthClientCredentials) token() (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.accessToken != "" && o.currentTime().Before(o.expires) {
+		return o.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", o.clientID)
+	form.Set("client_secret", o.clientSecret)
+	if len(o.scopes) > 0 {
+		form.Set("scope", strings.Join(o.scopes, " "))
+	}
+
+	req, err := http.NewRequest("POST", o.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error initializing http request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := o.dc.do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting OAuth access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := o.dc.readResponseBody(resp)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error requesting OAuth access token: server returned status code %d", resp.StatusCode)
+	}
+
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(data, &tr); err != nil {
+		return "", fmt.Errorf("error unmarshalling json response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return "", errors.New("no access token in OAuth token response")
+	}
+
+	o.accessToken = tr.AccessToken
+	o.expires = o.currentTime().Add(time.Duration(tr.ExpiresIn)*time.Second - oauthTokenExpiryMargin)
+	return o.accessToken, nil
+}
+
+func (o *oauthClientCredentials) currentTime() time.Time {
+	if o.now != nil {
+		return o.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,78 @@
+package dtclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthClientCredentials(t *testing.T) {
+	const tokenPath = "/sso/oauth2/token"
+
+	tokenRequests := 0
+	var authHeaders []string
+
+	dynatraceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path == tokenPath {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "POST", r.Method)
+			assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			assert.Equal(t, "my-client", r.PostForm.Get("client_id"))
+			assert.Equal(t, "my-secret", r.PostForm.Get("client_secret"))
+			assert.Equal(t, "environment:read environment:write", r.PostForm.Get("scope"))
+
+			tokenRequests++
+			_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 300}`, tokenRequests)
+			return
+		}
+
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"version": "1.200.0"}`))
+	}))
+	defer dynatraceServer.Close()
+
+	dtc, err := NewClient(dynatraceServer.URL, "", "",
+		OAuthClientCredentials(dynatraceServer.URL+tokenPath, "my-client", "my-secret", "environment:read", "environment:write"))
+	require.NoError(t, err)
+
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	dtc.(*dynatraceClient).auth.(*oauthClientCredentials).now = func() time.Time { return now }
+
+	_, err = dtc.GetClusterInfo()
+	require.NoError(t, err)
+	_, err = dtc.GetClusterInfo()
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, tokenRequests, "access token cached")
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-1"}, authHeaders)
+
+	now = now.Add(5 * time.Minute)
+
+	_, err = dtc.GetClusterInfo()
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, tokenRequests, "access token refreshed on expiry")
+	assert.Equal(t, "Bearer token-2", authHeaders[2])
+}
+
+func TestOAuthClientCredentials_TokenError(t *testing.T) {
+	dynatraceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": "invalid_client"}`))
+	}))
+	defer dynatraceServer.Close()
+
+	dtc, err := NewClient(dynatraceServer.URL, "", "",
+		OAuthClientCredentials(dynatraceServer.URL+"/sso/oauth2/token", "my-client", "wrong-secret"))
+	require.NoError(t, err)
+
+	_, err = dtc.GetClusterInfo()
+	assert.EqualError(t, err, "error requesting OAuth access token: server returned status code 401")
+}
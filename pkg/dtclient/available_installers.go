@@ -0,0 +1,75 @@
+package dtclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// AgentInstaller identifies an installer by OS, installer type and architecture.
+type AgentInstaller struct {
+	OS            string
+	InstallerType string
+	Arch          string
+}
+
+// installerMatrix lists the installers looked up by GetAvailableInstallers.
+var installerMatrix = []AgentInstaller{
+	{OS: OsUnix, InstallerType: InstallerTypeDefault, Arch: ArchX86},
+	{OS: OsUnix, InstallerType: InstallerTypeDefault, Arch: ArchARM},
+	{OS: OsUnix, InstallerType: InstallerTypeDefault, Arch: ArchPPCLE},
+	{OS: OsUnix, InstallerType: InstallerTypeDefault, Arch: ArchS390},
+	{OS: OsUnix, InstallerType: InstallerTypePaasZip, Arch: ArchX86},
+	{OS: OsUnix, InstallerType: InstallerTypePaasZip, Arch: ArchARM},
+	{OS: OsUnix, InstallerType: InstallerTypePaasZip, Arch: ArchPPCLE},
+	{OS: OsUnix, InstallerType: InstallerTypePaasZip, Arch: ArchS390},
+	{OS: OsWindows, InstallerType: InstallerTypeDefault, Arch: ArchX86},
+}
+
+// GetAvailableInstallers returns the installers available for the given version, in the order of the installer
+// matrix. Installers the environment doesn't know, i.e., answered with 400 Bad Request or 404 Not Found, are skipped.
+func (dc *dynatraceClient) GetAvailableInstallers(version string) ([]AgentInstaller, error) {
+	if len(version) == 0 {
+		return nil, errors.New("version is empty")
+	}
+
+	var available []AgentInstaller
+	for _, installer := range installerMatrix {
+		versions, err := dc.getAvailableVersions(installer)
+
+		var serr ServerError
+		if errors.As(err, &serr) && (serr.Code == http.StatusBadRequest || serr.Code == http.StatusNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		for _, v := range versions {
+			if v == version {
+				available = append(available, installer)
+				break
+			}
+		}
+	}
+	return available, nil
+}
+
+// getAvailableVersions returns the versions available for the OS, installer type and architecture of the installer.
+func (dc *dynatraceClient) getAvailableVersions(installer AgentInstaller) ([]string, error) {
+	u := dc.getURL(fmt.Sprintf("/v1/deployment/installer/agent/versions/%s/%s", installer.OS, installer.InstallerType))
+	u += "?arch=" + url.QueryEscape(installer.Arch)
+
+	resp, err := dc.makeRequest(u, dynatracePaaSToken)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := dc.getServerResponseData(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return dc.readResponseForAvailableVersions(data)
+}
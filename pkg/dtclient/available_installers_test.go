@@ -0,0 +1,59 @@
+package dtclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAvailableInstallers(t *testing.T) {
+	const version = "1.203.0.20200908-220956"
+
+	failing := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			writeError(w, http.StatusInternalServerError)
+			return
+		}
+
+		arch := r.URL.Query().Get("arch")
+		switch {
+		case r.URL.Path == "/v1/deployment/installer/agent/versions/windows/default":
+			writeError(w, http.StatusNotFound)
+		case arch == ArchS390:
+			writeError(w, http.StatusBadRequest)
+		case arch == ArchPPCLE:
+			_ = json.NewEncoder(w).Encode(map[string][]string{"availableVersions": {"1.201.0.20200708-120956"}})
+		default:
+			_ = json.NewEncoder(w).Encode(map[string][]string{"availableVersions": {"1.201.0.20200708-120956", version}})
+		}
+	}))
+	defer ts.Close()
+
+	dtc, err := NewClient(ts.URL, apiToken, paasToken)
+	require.NoError(t, err)
+
+	installers, err := dtc.GetAvailableInstallers(version)
+	require.NoError(t, err)
+	assert.Equal(t, []AgentInstaller{
+		{OS: OsUnix, InstallerType: InstallerTypeDefault, Arch: ArchX86},
+		{OS: OsUnix, InstallerType: InstallerTypeDefault, Arch: ArchARM},
+		{OS: OsUnix, InstallerType: InstallerTypePaasZip, Arch: ArchX86},
+		{OS: OsUnix, InstallerType: InstallerTypePaasZip, Arch: ArchARM},
+	}, installers)
+
+	installers, err = dtc.GetAvailableInstallers("1.199.0.20200508-120956")
+	require.NoError(t, err)
+	assert.Empty(t, installers)
+
+	_, err = dtc.GetAvailableInstallers("")
+	assert.EqualError(t, err, "version is empty")
+
+	failing = true
+	_, err = dtc.GetAvailableInstallers(version)
+	assert.Error(t, err)
+}
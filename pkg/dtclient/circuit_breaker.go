@@ -0,0 +1,132 @@
+package dtclient
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultCircuitBreaker is used by clients created by NewClient unless the CircuitBreaking option is given. It's nil by
+// default, which never stops requests.
+var DefaultCircuitBreaker *CircuitBreaker
+
+// CircuitOpenError is returned without sending the request while the circuit breaker for the Dynatrace API is open.
+type CircuitOpenError struct {
+	// RetryAfter is the time until requests are let through again.
+	RetryAfter time.Duration
+}
+
+func (e CircuitOpenError) Error() string {
+	return fmt.Sprintf("too many failed requests to the Dynatrace API, retrying in %s", e.RetryAfter)
+}
+
+// CircuitBreaker stops sending requests to a Dynatrace environment after a number of consecutive failures, i.e.,
+// connection errors or 5xx responses, so that a clearly unavailable API isn't hammered by reconciliations. Requests
+// fail fast with a CircuitOpenError until the cooldown has passed. After that, the circuit is half-open: a single probe
+// request is let through, while other requests keep failing fast until it completes. A successful probe closes the
+// circuit again, while a failed one reopens it.
+//
+// The state is kept per API URL. It can be shared between clients, and is safe for concurrent use.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+
+	// Set for testing purposes, leave nil to use the current time.
+	now func() time.Time
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+
+	// Set while the probe request of the half-open circuit is in flight.
+	probing bool
+}
+
+// NewCircuitBreaker creates a breaker that opens after threshold consecutive failures, for the duration of cooldown.
+// Returns nil if threshold is not positive, which never stops requests.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		circuits:  map[string]*circuit{},
+	}
+}
+
+// allow returns a CircuitOpenError if the circuit for the API URL is open, or if it's half-open and the probe request
+// is in flight. Returns true if the request is the probe of the half-open circuit.
+func (b *CircuitBreaker) allow(url string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[url]
+	if !ok || c.openUntil.IsZero() {
+		return false, nil
+	}
+	if wait := c.openUntil.Sub(b.currentTime()); wait > 0 {
+		return false, CircuitOpenError{RetryAfter: wait}
+	}
+	if c.probing {
+		// The result of the probe is unknown yet, so retry as if it failed.
+		return false, CircuitOpenError{RetryAfter: b.cooldown}
+	}
+
+	c.probing = true
+	return true, nil
+}
+
+// record counts the result of a request to the API URL, opening the circuit once the threshold is reached, or
+// reopening it if the request was the failed probe of the half-open circuit.
+func (b *CircuitBreaker) record(url string, probe bool, resp *http.Response, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil && resp.StatusCode < http.StatusInternalServerError {
+		delete(b.circuits, url)
+		return
+	}
+
+	c, ok := b.circuits[url]
+	if !ok {
+		c = &circuit{}
+		b.circuits[url] = c
+	}
+	if probe {
+		c.probing = false
+	}
+	c.failures++
+	if probe || c.failures >= b.threshold {
+		c.openUntil = b.currentTime().Add(b.cooldown)
+	}
+}
+
+func (b *CircuitBreaker) currentTime() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// breakerDo sends the request through the circuit breaker of the client if there is one.
+func (dc *dynatraceClient) breakerDo(req *http.Request) (*http.Response, error) {
+	b := dc.circuitBreaker
+	if b == nil {
+		return dc.timedDo(req)
+	}
+
+	probe, err := b.allow(dc.url)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := dc.timedDo(req)
+	b.record(dc.url, probe, resp, err)
+	return resp, err
+}
@@ -0,0 +1,139 @@
+package dtclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	requests := 0
+	down := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error": {"code": 503, "message": "unavailable"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"version": "1.200.0"}`))
+	}))
+	defer server.Close()
+
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	dtc, err := NewClient(server.URL, apiToken, paasToken, CircuitBreaking(breaker))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = dtc.GetClusterInfo()
+		require.Error(t, err)
+		assert.False(t, errors.As(err, &CircuitOpenError{}), "circuit closed below threshold")
+	}
+	assert.Equal(t, 2, requests)
+
+	t.Run("fails fast while open", func(t *testing.T) {
+		now = now.Add(20 * time.Second)
+
+		// Shared with other clients of the same environment.
+		other, err := NewClient(server.URL, apiToken, paasToken, CircuitBreaking(breaker))
+		require.NoError(t, err)
+
+		_, err = other.GetClusterInfo()
+		var cerr CircuitOpenError
+		require.True(t, errors.As(err, &cerr))
+		assert.Equal(t, 40*time.Second, cerr.RetryAfter)
+		assert.Equal(t, 2, requests, "request not sent")
+	})
+
+	t.Run("reopens on failure after cooldown", func(t *testing.T) {
+		now = now.Add(time.Minute)
+
+		_, err = dtc.GetClusterInfo()
+		assert.False(t, errors.As(err, &CircuitOpenError{}))
+		assert.Equal(t, 3, requests)
+
+		_, err = dtc.GetClusterInfo()
+		assert.True(t, errors.As(err, &CircuitOpenError{}))
+		assert.Equal(t, 3, requests)
+	})
+
+	t.Run("closes on success after cooldown", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		down = false
+
+		ci, err := dtc.GetClusterInfo()
+		require.NoError(t, err)
+		assert.Equal(t, "1.200.0", ci.Version)
+
+		down = true
+		_, err = dtc.GetClusterInfo()
+		assert.False(t, errors.As(err, &CircuitOpenError{}), "failures counted from zero again")
+		assert.Equal(t, 5, requests)
+	})
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	var requests int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		select {
+		case started <- struct{}{}: // Blocks the probe until released.
+			<-release
+		default:
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error": {"code": 503, "message": "unavailable"}}`))
+	}))
+	defer server.Close()
+
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(1, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	dtc, err := NewClient(server.URL, apiToken, paasToken, CircuitBreaking(breaker))
+	require.NoError(t, err)
+
+	_, err = dtc.GetClusterInfo()
+	require.Error(t, err)
+	now = now.Add(time.Minute)
+
+	probeErr := make(chan error)
+	go func() {
+		_, err := dtc.GetClusterInfo()
+		probeErr <- err
+	}()
+	<-started
+
+	_, err = dtc.GetClusterInfo()
+	var cerr CircuitOpenError
+	require.True(t, errors.As(err, &cerr), "fails fast while probing")
+	assert.Equal(t, time.Minute, cerr.RetryAfter)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	close(release)
+	err = <-probeErr
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &CircuitOpenError{}), "probe sent")
+
+	_, err = dtc.GetClusterInfo()
+	require.True(t, errors.As(err, &cerr), "reopened after failed probe")
+	assert.Equal(t, time.Minute, cerr.RetryAfter)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestNewCircuitBreaker_Disabled(t *testing.T) {
+	assert.Nil(t, NewCircuitBreaker(0, time.Minute))
+}
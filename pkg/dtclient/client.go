@@ -4,17 +4,23 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"io"
 	"net/http"
 	"net/url"
+	"path"
 	"strings"
+	"time"
 
+	"github.com/Dynatrace/dynatrace-oneagent-operator/version"
+	"github.com/go-logr/logr"
+	"golang.org/x/net/http/httpproxy"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // Client is the interface for the Dynatrace REST API client.
 type Client interface {
 	// GetLatestAgentVersion gets the latest agent version for the given OS and installer type.
-	// Returns the version as received from the server on success.
+	// Returns the version as received from the server on success. Versions are cached, see AgentVersionCache.
 	//
 	// Returns an error for the following conditions:
 	//  - os or installerType is empty
@@ -23,6 +29,56 @@ type Client interface {
 	//  - the agent version is not set or empty
 	GetLatestAgentVersion(os, installerType string) (string, error)
 
+	// GetLatestAgentVersionInfo gets the latest agent version for the given OS and installer type, with its release
+	// date and whether it is a long-term support release, if reported by the server.
+	//
+	// Returns an error for the following conditions:
+	//  - os or installerType is empty
+	//  - IO error or unexpected response
+	//  - error response from the server (e.g. authentication failure)
+	//  - the agent version is not set or empty
+	GetLatestAgentVersionInfo(os, installerType string) (AgentVersionInfo, error)
+
+	// GetLatestAgentVersionForBranch gets the newest agent version for the given OS and installer type among the
+	// versions available on the environment that belong to the branch, i.e., that equal it or start with it followed
+	// by a dot. E.g., branch 1.247 matches 1.247.0.20220901-123456, but not 1.24.0.20220901-123456.
+	//
+	// Returns an error for the following conditions:
+	//  - os, installerType or branch is empty
+	//  - IO error or unexpected response
+	//  - error response from the server (e.g. authentication failure)
+	//  - no available version matches the branch
+	GetLatestAgentVersionForBranch(os, installerType, branch string) (string, error)
+
+	// GetAvailableInstallers returns the combinations of OS, installer type and architecture the given agent version
+	// is available for on the environment, among the installers for Unix and Windows hosts.
+	//
+	// Returns an error for the following conditions:
+	//  - version is empty
+	//  - IO error or unexpected response
+	//  - error response from the server (e.g. authentication failure)
+	GetAvailableInstallers(version string) ([]AgentInstaller, error)
+
+	// GetAgentInstaller returns the installer for the given OS, installer type and version as a stream, which must be
+	// closed by the caller. The limit on the response size doesn't apply. Use DownloadAgentInstaller to verify the
+	// installer against its checksum.
+	//
+	// Returns an error for the following conditions:
+	//  - os, installerType or version is empty
+	//  - IO error
+	//  - error response from the server (e.g. authentication failure or unknown version)
+	GetAgentInstaller(os, installerType, version string) (io.ReadCloser, error)
+
+	// GetAgentInstallerChecksum returns the hex encoded SHA-256 checksum of the installer for the given OS, installer
+	// type and version.
+	//
+	// Returns an error for the following conditions:
+	//  - os, installerType or version is empty
+	//  - IO error or unexpected response
+	//  - error response from the server (e.g. authentication failure or unknown version)
+	//  - the checksum is not set
+	GetAgentInstallerChecksum(os, installerType, version string) (string, error)
+
 	// GetAgentVersionForIP returns the agent version running on the host with the given IP address.
 	// Returns the version string formatted as "Major.Minor.Revision.Timestamp" on success.
 	//
@@ -37,18 +93,55 @@ type Client interface {
 	// client instance to fetch a new list from the server.
 	GetAgentVersionForIP(ip string) (string, error)
 
-	// GetCommunicationHosts returns, on success, the list of communication hosts used for available
+	// GetMonitoringModeForIP returns the monitoring mode Dynatrace reports for the host with the given IP address,
+	// e.g. FULL_STACK, INFRASTRUCTURE or DISCOVERY.
+	//
+	// Returns an error for the following conditions:
+	//  - the IP is empty
+	//  - IO error or unexpected response
+	//  - error response from the server (e.g. authentication failure)
+	//  - a host with the given IP cannot be found
+	//  - the monitoring mode for the host is not set
+	//
+	// Uses the same cached list of hosts as GetAgentVersionForIP.
+	GetMonitoringModeForIP(ip string) (string, error)
+
+	// GetLastSeenForIP returns when Dynatrace last received data from the host with the given IP address.
+	//
+	// Returns an error for the following conditions:
+	//  - the IP is empty
+	//  - IO error or unexpected response
+	//  - error response from the server (e.g. authentication failure)
+	//  - a host with the given IP cannot be found, or hasn't been seen within the last 30 minutes (ErrHostNotFound)
+	//
+	// Uses the same cached list of hosts as GetAgentVersionForIP.
+	GetLastSeenForIP(ip string) (time.Time, error)
+
+	// GetConnectionInfo returns, on success, the tenant UUID and the list of communication hosts used for available
 	// communication endpoints that the Dynatrace OneAgent can use to connect to.
 	//
 	// Returns an error if there was also an error response from the server.
 	GetConnectionInfo() (ConnectionInfo, error)
 
+	// GetCommunicationHosts returns, on success, the ActiveGate and cluster communication endpoints discovered for the
+	// network zone of the client, parsed into protocol, host and port. Falls back to the endpoints from the connection
+	// info if discovery isn't available. Duplicated endpoints are only returned once, and results are cached.
+	//
+	// Returns an error if there was an error response from the server, or if none of the endpoints could be parsed.
+	GetCommunicationHosts() ([]CommunicationHost, error)
+
 	// GetCommunicationHostForClient returns a CommunicationHost for the client's API URL. Or error, if failed to be parsed.
 	GetCommunicationHostForClient() (CommunicationHost, error)
 
 	// SendEvent posts events to dynatrace API
 	SendEvent(eventData *EventData) error
 
+	// SendDeploymentEvent posts a CUSTOM_DEPLOYMENT event for the given OneAgent version to the Dynatrace API,
+	// attached to the hosts matching the event's IP addresses.
+	//
+	// Returns an error if the event is incomplete, none of the hosts could be found, or the request failed.
+	SendDeploymentEvent(event *DeploymentEvent) error
+
 	// GetEntityIDForIP returns the entity id for a given IP address.
 	//
 	// Returns an error in case the lookup failed.
@@ -57,9 +150,37 @@ type Client interface {
 	// GetTokenScopes returns the list of scopes assigned to a token if successful.
 	GetTokenScopes(token string) (TokenScopes, error)
 
+	// GetTokenMetadata returns the scopes and the expiration date assigned to a token if successful.
+	GetTokenMetadata(token string) (TokenMetadata, error)
+
 	// GetClusterInfo returns the following information about the cluster:
 	// * Version
 	GetClusterInfo() (*ClusterInfo, error)
+
+	// GetClusterVersion returns the version of the Dynatrace Managed cluster hosting the environment, e.g., to avoid
+	// endpoints not available on older clusters.
+	//
+	// Returns an error for the following conditions:
+	//  - ErrNotManaged for SaaS environments
+	//  - IO error or unexpected response
+	//  - error response from the server (e.g. authentication failure)
+	//  - the cluster version is not set
+	GetClusterVersion() (string, error)
+
+	// GetTenantStatus returns whether the Dynatrace environment is under maintenance, as reported by the server with a
+	// 503 response mentioning maintenance.
+	//
+	// Returns an error for other error responses from the server, or on IO errors.
+	GetTenantStatus() (TenantStatus, error)
+
+	// GetEntities returns the monitored entities of the given type, e.g. PROCESS_GROUP, matching the entity selector.
+	// The selector is added to the type criterion, e.g. `entityName("my-app")`, and can be empty.
+	//
+	// Returns an error for the following conditions:
+	//  - entityType is empty
+	//  - IO error or unexpected response on any of the pages
+	//  - error response from the server (e.g. authentication failure or invalid selector)
+	GetEntities(entityType, selector string) ([]Entity, error)
 }
 
 // Known OS values.
@@ -78,6 +199,21 @@ const (
 	InstallerTypePaasSh     = "paas-sh"
 )
 
+// Known architectures.
+const (
+	ArchX86   = "x86"
+	ArchARM   = "arm"
+	ArchPPCLE = "ppcle"
+	ArchS390  = "s390"
+)
+
+// Known monitoring modes.
+const (
+	MonitoringModeFullStack      = "FULL_STACK"
+	MonitoringModeInfrastructure = "INFRASTRUCTURE"
+	MonitoringModeDiscovery      = "DISCOVERY"
+)
+
 // Known token scopes
 const (
 	TokenScopeInstallerDownload = "InstallerDownload"
@@ -85,7 +221,7 @@ const (
 )
 
 // NewClient creates a REST client for the given API base URL and authentication tokens.
-// Returns an error if a token or the URL is empty.
+// Returns an error if the URL is empty, or if both tokens are empty and no OAuthClientCredentials option is given.
 //
 // The API base URL is different for managed and SaaS environments:
 //  - SaaS: https://{environment-id}.live.dynatrace.com/api
@@ -96,32 +232,54 @@ func NewClient(url, apiToken, paasToken string, opts ...Option) (Client, error)
 	if len(url) == 0 {
 		return nil, errors.New("url is empty")
 	}
-	if len(apiToken) == 0 && len(paasToken) == 0 {
-		return nil, errors.New("tokens are empty")
-	}
-
-	if strings.HasSuffix(url, "/") {
-		url = url[:len(url)-1]
-	}
-
 	dc := &dynatraceClient{
-		url:       url,
+		url:       normalizeAPIURL(url),
 		apiToken:  apiToken,
 		paasToken: paasToken,
 		logger:    log.Log.WithName("dynatrace.client"),
+		userAgent: defaultUserAgent(),
 
-		hostCache: make(map[string]hostInfo),
+		hostCache:              make(map[string]hostInfo),
+		etags:                  DefaultETagCache,
+		agentVersionCache:      DefaultAgentVersionCache,
+		communicationHostCache: DefaultCommunicationHostCache,
+		requestLimiter:         DefaultRequestLimiter,
+		circuitBreaker:         DefaultCircuitBreaker,
+		maxResponseSize:        DefaultMaxResponseSize,
 		httpClient: &http.Client{
 			Transport: http.DefaultTransport.(*http.Transport).Clone(),
 		},
 	}
 
+	// Use the proxy settings from the environment unless the Proxy option overrides them.
+	dc.httpClient.Transport.(*http.Transport).Proxy = proxyFromEnvironment()
+
 	for _, opt := range opts {
 		opt(dc)
 	}
+
+	if len(apiToken) == 0 && len(paasToken) == 0 && dc.auth == nil {
+		return nil, errors.New("tokens are empty")
+	}
 	return dc, nil
 }
 
+// normalizeAPIURL removes trailing and duplicate slashes from the path of the API URL, so that endpoint paths can be
+// appended to it both for SaaS (/api) and Managed (/e/{environment-id}/api) layouts.
+func normalizeAPIURL(apiURL string) string {
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return strings.TrimRight(apiURL, "/")
+	}
+
+	u.Path = path.Clean("/" + u.Path)
+	if u.Path == "/" {
+		u.Path = ""
+	}
+	u.RawPath = ""
+	return u.String()
+}
+
 // Option can be passed to NewClient and customizes the created client instance.
 type Option func(*dynatraceClient)
 
@@ -139,18 +297,64 @@ func SkipCertificateValidation(skip bool) Option {
 	}
 }
 
+// BasePath creates an Option that prepends the given path prefix to the path of the API base URL, for gateways
+// exposing the Dynatrace API under a prefix, e.g., https://{domain}/{prefix}/e/{environment-id}/api. Leading, trailing
+// and duplicate slashes on the prefix are ignored.
+func BasePath(prefix string) Option {
+	return func(c *dynatraceClient) {
+		prefix = strings.Trim(prefix, "/")
+		if prefix == "" {
+			return
+		}
+
+		u, err := url.Parse(c.url)
+		if err != nil || u.Host == "" {
+			c.logger.Info("Could not parse API URL, ignoring base path", "basePath", prefix)
+			return
+		}
+		u.Path = "/" + prefix + "/" + u.Path
+		u.RawPath = ""
+		c.url = normalizeAPIURL(u.String())
+	}
+}
+
+// supportedProxySchemes are the schemes of the proxy URLs the transport of the client can connect through
+var supportedProxySchemes = map[string]bool{"http": true, "https": true, "socks5": true}
+
+// Proxy creates an Option that routes requests through the given proxy URL, either an HTTP(S) proxy or a SOCKS5 one
+// with a socks5:// URL. An explicit proxy takes precedence over the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+// variables, which are used otherwise, also if the URL has an unsupported scheme.
 func Proxy(proxyURL string) Option {
 	return func(c *dynatraceClient) {
+		if proxyURL == "" {
+			return
+		}
+
 		p, err := url.Parse(proxyURL)
 		if err != nil {
 			c.logger.Info("Could not parse proxy URL!")
 			return
 		}
+		if !supportedProxySchemes[p.Scheme] {
+			c.logger.Info("Unsupported proxy URL scheme, falling back to proxy from environment", "scheme", p.Scheme)
+			return
+		}
 		t := c.httpClient.Transport.(*http.Transport)
 		t.Proxy = http.ProxyURL(p)
 	}
 }
 
+// proxyFromEnvironment returns a proxy function configured from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+// variables, or their lowercase versions. The variables are read once when the proxy function is created, which
+// happens for every new client, instead of being cached for the lifetime of the process as by
+// http.ProxyFromEnvironment.
+func proxyFromEnvironment() func(*http.Request) (*url.URL, error) {
+	proxyFunc := httpproxy.FromEnvironment().ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
 func Certs(certs []byte) Option {
 	return func(c *dynatraceClient) {
 		rootCAs := x509.NewCertPool()
@@ -166,6 +370,174 @@ func Certs(certs []byte) Option {
 	}
 }
 
+// Logger creates an Option that sets the logger used by the client. Timings of the API calls are logged at debug
+// level (V(1)).
+func Logger(logger logr.Logger) Option {
+	return func(c *dynatraceClient) {
+		c.logger = logger
+	}
+}
+
+// OAuthClientCredentials creates an Option that authenticates the requests with bearer tokens acquired from the
+// given OAuth token endpoint through the client credentials grant, instead of the API and PaaS tokens. Access tokens
+// are refreshed once they expire.
+func OAuthClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) Option {
+	return func(c *dynatraceClient) {
+		c.auth = &oauthClientCredentials{
+			dc:           c,
+			tokenURL:     tokenURL,
+			clientID:     clientID,
+			clientSecret: clientSecret,
+			scopes:       scopes,
+		}
+	}
+}
+
+// FailoverAPITokens creates an Option that adds API tokens to fail over to, in the given order, when the current API
+// token exceeds a rate limit applied per token by the Dynatrace API. Tokens missing the DataExport scope are ignored.
+func FailoverAPITokens(tokens ...string) Option {
+	return func(c *dynatraceClient) {
+		if c.apiToken == "" {
+			return
+		}
+
+		// The initial API token is verified by the caller.
+		c.apiTokens = []string{c.apiToken}
+		c.validAPITokens = map[string]bool{c.apiToken: true}
+
+		for _, token := range tokens {
+			if token != "" && token != c.apiToken {
+				c.apiTokens = append(c.apiTokens, token)
+			}
+		}
+	}
+}
+
+// LatestAgentVersionCache creates an Option that replaces the DefaultAgentVersionCache used for the latest agent
+// versions. Caching is disabled if cache is nil.
+func LatestAgentVersionCache(cache *AgentVersionCache) Option {
+	return func(c *dynatraceClient) {
+		c.agentVersionCache = cache
+	}
+}
+
+// ETagCaching creates an Option that replaces the DefaultETagCache used for conditional requests. Conditional requests
+// aren't sent if cache is nil.
+func ETagCaching(cache *ETagCache) Option {
+	return func(c *dynatraceClient) {
+		c.etags = cache
+	}
+}
+
+// CommunicationHostCaching creates an Option that replaces the DefaultCommunicationHostCache used for the
+// communication hosts. Caching is disabled if cache is nil.
+func CommunicationHostCaching(cache *CommunicationHostCache) Option {
+	return func(c *dynatraceClient) {
+		c.communicationHostCache = cache
+	}
+}
+
+// RequestLimit creates an Option that replaces the DefaultRequestLimiter bounding the requests in flight. Requests
+// aren't limited if limiter is nil.
+func RequestLimit(limiter *RequestLimiter) Option {
+	return func(c *dynatraceClient) {
+		c.requestLimiter = limiter
+	}
+}
+
+// CircuitBreaking creates an Option that replaces the DefaultCircuitBreaker stopping requests after repeated failures.
+// Requests are never stopped if breaker is nil.
+func CircuitBreaking(breaker *CircuitBreaker) Option {
+	return func(c *dynatraceClient) {
+		c.circuitBreaker = breaker
+	}
+}
+
+// MaxResponseSize creates an Option that replaces the DefaultMaxResponseSize for the responses read by the client.
+// Response sizes aren't limited if size is not positive.
+func MaxResponseSize(size int64) Option {
+	return func(c *dynatraceClient) {
+		c.maxResponseSize = size
+	}
+}
+
+// defaultUserAgent returns the User-Agent sent unless overridden with the UserAgent option, identifying the operator
+// and its version, e.g., "dynatrace-oneagent-operator/v0.9.0".
+func defaultUserAgent() string {
+	return "dynatrace-oneagent-operator/" + version.Version
+}
+
+// UserAgent creates an Option that replaces the User-Agent sent with all requests to the Dynatrace API. The default
+// User-Agent is kept if userAgent is empty.
+func UserAgent(userAgent string) Option {
+	return func(c *dynatraceClient) {
+		if userAgent != "" {
+			c.userAgent = userAgent
+		}
+	}
+}
+
+// reservedHeaders are set by the client itself and can't be overridden with CustomHeaders.
+var reservedHeaders = map[string]bool{
+	"Accept-Encoding": true,
+	"Authorization":   true,
+	"Content-Length":  true,
+	"Content-Type":    true,
+	"Host":            true,
+	"If-None-Match":   true,
+	"User-Agent":      true,
+}
+
+// CustomHeaders creates an Option that adds the given headers to all requests sent to the Dynatrace API, e.g., for
+// routing through proxies or gateways. Reserved headers like Authorization or
+// User-Agent are ignored with a warning.
+func CustomHeaders(headers map[string]string) Option {
+	return func(c *dynatraceClient) {
+		for name, value := range headers {
+			name = http.CanonicalHeaderKey(name)
+			if reservedHeaders[name] {
+				// Only the name is logged, since the value may contain credentials.
+				c.logger.Info("Ignoring custom header reserved by the client", "header", name)
+				continue
+			}
+
+			if c.customHeaders == nil {
+				c.customHeaders = http.Header{}
+			}
+			c.customHeaders.Set(name, value)
+		}
+	}
+}
+
+// InterceptRequests creates an Option that runs interceptor on every request to the Dynatrace API right before it's
+// sent, after all options given before it. The Authorization header can't be removed unless AllowAuthorizationRemoval
+// is given too.
+func InterceptRequests(interceptor RequestInterceptor) Option {
+	return func(c *dynatraceClient) {
+		if interceptor != nil {
+			c.requestInterceptors = append(c.requestInterceptors, interceptor)
+		}
+	}
+}
+
+// InterceptResponses creates an Option that runs interceptor on the response or error of every request to the
+// Dynatrace API, after all options given before it.
+func InterceptResponses(interceptor ResponseInterceptor) Option {
+	return func(c *dynatraceClient) {
+		if interceptor != nil {
+			c.responseInterceptors = append(c.responseInterceptors, interceptor)
+		}
+	}
+}
+
+// AllowAuthorizationRemoval creates an Option that lets the interceptors from InterceptRequests remove the
+// Authorization header, e.g., if authentication is done by a gateway in between.
+func AllowAuthorizationRemoval() Option {
+	return func(c *dynatraceClient) {
+		c.allowAuthorizationRemoval = true
+	}
+}
+
 func NetworkZone(networkZone string) Option {
 	return func(c *dynatraceClient) {
 		c.networkZone = networkZone
@@ -1,9 +1,18 @@
 package dtclient
 
 import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewClient(t *testing.T) {
@@ -35,3 +44,246 @@ func TestNewClient(t *testing.T) {
 		assert.Error(t, err, "empty URL")
 	}
 }
+
+func TestProxy(t *testing.T) {
+	const apiURL = "https://aabb.live.dynatrace.com/api"
+
+	proxyFor := func(t *testing.T, opts ...Option) string {
+		c, err := NewClient(apiURL, "foo", "bar", opts...)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodGet, apiURL+"/v1/deployment/installer/agent/connectioninfo", nil)
+		require.NoError(t, err)
+
+		proxyURL, err := c.(*dynatraceClient).httpClient.Transport.(*http.Transport).Proxy(req)
+		require.NoError(t, err)
+		if proxyURL == nil {
+			return ""
+		}
+		return proxyURL.String()
+	}
+
+	setEnv := func(t *testing.T, key, value string) {
+		old, ok := os.LookupEnv(key)
+		require.NoError(t, os.Setenv(key, value))
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, old)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+
+	for _, key := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy"} {
+		setEnv(t, key, "")
+	}
+
+	t.Run("no proxy configured", func(t *testing.T) {
+		assert.Equal(t, "", proxyFor(t))
+	})
+
+	t.Run("proxy from environment", func(t *testing.T) {
+		setEnv(t, "HTTPS_PROXY", "http://env-proxy:3128")
+		assert.Equal(t, "http://env-proxy:3128", proxyFor(t))
+	})
+
+	t.Run("no proxy from environment excludes host", func(t *testing.T) {
+		setEnv(t, "HTTPS_PROXY", "http://env-proxy:3128")
+		setEnv(t, "NO_PROXY", ".dynatrace.com")
+		assert.Equal(t, "", proxyFor(t))
+	})
+
+	t.Run("explicit proxy overrides environment", func(t *testing.T) {
+		setEnv(t, "HTTPS_PROXY", "http://env-proxy:3128")
+		setEnv(t, "NO_PROXY", ".dynatrace.com")
+		assert.Equal(t, "http://explicit-proxy:8080", proxyFor(t, Proxy("http://explicit-proxy:8080")))
+	})
+
+	t.Run("empty explicit proxy keeps environment", func(t *testing.T) {
+		setEnv(t, "HTTPS_PROXY", "http://env-proxy:3128")
+		assert.Equal(t, "http://env-proxy:3128", proxyFor(t, Proxy("")))
+	})
+
+	t.Run("explicit SOCKS5 proxy", func(t *testing.T) {
+		assert.Equal(t, "socks5://socks-proxy:1080", proxyFor(t, Proxy("socks5://socks-proxy:1080")))
+	})
+
+	t.Run("unsupported scheme keeps environment", func(t *testing.T) {
+		setEnv(t, "HTTPS_PROXY", "http://env-proxy:3128")
+		assert.Equal(t, "http://env-proxy:3128", proxyFor(t, Proxy("socks4://socks-proxy:1080")))
+	})
+}
+
+func TestProxy_SOCKS5(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"version": "1.200.0"}`))
+	}))
+	defer server.Close()
+
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	var mu sync.Mutex
+	var targets []string
+	go func() {
+		for {
+			conn, err := proxy.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(conn, func(target string) {
+				mu.Lock()
+				targets = append(targets, target)
+				mu.Unlock()
+			})
+		}
+	}()
+
+	dtc, err := NewClient(server.URL, apiToken, paasToken, Proxy("socks5://"+proxy.Addr().String()))
+	require.NoError(t, err)
+
+	ci, err := dtc.GetClusterInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "1.200.0", ci.Version)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{server.Listener.Addr().String()}, targets)
+}
+
+// serveSOCKS5 handles a SOCKS5 CONNECT without authentication to an IPv4 address, as sent by the client for the test
+// server, and relays the connection once the address connected to has been passed to connected.
+func serveSOCKS5(conn net.Conn, connected func(target string)) {
+	defer conn.Close()
+
+	// Greeting: version, number of methods, methods. Accept "no authentication".
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, greeting[1])); err != nil {
+		return
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return
+	}
+
+	// Request: version, command, reserved, address type, IPv4 address, port.
+	req := make([]byte, 10)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return
+	}
+	if req[1] != 1 || req[3] != 1 {
+		return
+	}
+	target := net.JoinHostPort(net.IP(req[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(req[8:10]))))
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+	connected(target)
+
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+}
+
+func TestNormalizeAPIURL(t *testing.T) {
+	for input, expected := range map[string]string{
+		"https://aabb.live.dynatrace.com/api":            "https://aabb.live.dynatrace.com/api",
+		"https://aabb.live.dynatrace.com/api/":           "https://aabb.live.dynatrace.com/api",
+		"https://aabb.live.dynatrace.com/api//":          "https://aabb.live.dynatrace.com/api",
+		"https://managed.example.com/e/tenant/api":       "https://managed.example.com/e/tenant/api",
+		"https://managed.example.com//e/tenant//api/":    "https://managed.example.com/e/tenant/api",
+		"https://managed.example.com:9999/e/tenant/api/": "https://managed.example.com:9999/e/tenant/api",
+		"http://127.0.0.1:8080/":                         "http://127.0.0.1:8080",
+	} {
+		assert.Equal(t, expected, normalizeAPIURL(input), "input: %s", input)
+	}
+}
+
+func TestClientEndpointLayouts(t *testing.T) {
+	for _, layout := range []struct {
+		prefix string
+		paths  []string
+	}{
+		{prefix: "/api", paths: []string{"/api", "/api/", "/api//"}},
+		{prefix: "/e/tenant/api", paths: []string{"/e/tenant/api", "/e/tenant/api/", "//e/tenant//api//"}},
+	} {
+		var requested []string
+		mux := http.NewServeMux()
+		mux.Handle(layout.prefix+"/", http.StripPrefix(layout.prefix, dynatraceServerHandlerWith(func(r *http.Request, w http.ResponseWriter) {
+			requested = append(requested, r.URL.Path)
+			handleRequest(r, w)
+		})))
+
+		ts := httptest.NewServer(mux)
+
+		for _, p := range layout.paths {
+			requested = nil
+
+			dtc, err := NewClient(ts.URL+p, apiToken, paasToken, LatestAgentVersionCache(nil))
+			require.NoError(t, err)
+
+			_, err = dtc.GetConnectionInfo()
+			assert.NoError(t, err, "api path: %s", p)
+
+			_, err = dtc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+			assert.NoError(t, err, "api path: %s", p)
+
+			_, err = dtc.GetTokenScopes("good-token")
+			assert.NoError(t, err, "api path: %s", p)
+
+			assert.Equal(t, []string{
+				"/v1/deployment/installer/agent/connectioninfo",
+				"/v1/deployment/installer/agent/unix/default/latest/metainfo",
+				"/v1/tokens/lookup",
+			}, requested, "api path: %s", p)
+		}
+
+		ts.Close()
+	}
+}
+
+func TestClientBasePath(t *testing.T) {
+	var requested []string
+	mux := http.NewServeMux()
+	mux.Handle("/gateway/dynatrace/e/tenant/api/", http.StripPrefix("/gateway/dynatrace/e/tenant/api", dynatraceServerHandlerWith(func(r *http.Request, w http.ResponseWriter) {
+		requested = append(requested, r.URL.Path)
+		handleRequest(r, w)
+	})))
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	for _, prefix := range []string{"gateway/dynatrace", "/gateway/dynatrace/", "//gateway//dynatrace//"} {
+		requested = nil
+
+		dtc, err := NewClient(ts.URL+"/e/tenant/api/", apiToken, paasToken, BasePath(prefix), LatestAgentVersionCache(nil))
+		require.NoError(t, err)
+		assert.Equal(t, ts.URL+"/gateway/dynatrace/e/tenant/api", dtc.(*dynatraceClient).url, "base path: %s", prefix)
+
+		_, err = dtc.GetConnectionInfo()
+		assert.NoError(t, err, "base path: %s", prefix)
+
+		_, err = dtc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+		assert.NoError(t, err, "base path: %s", prefix)
+
+		assert.Equal(t, []string{
+			"/v1/deployment/installer/agent/connectioninfo",
+			"/v1/deployment/installer/agent/unix/default/latest/metainfo",
+		}, requested, "base path: %s", prefix)
+	}
+
+	dtc, err := NewClient(ts.URL+"/api", apiToken, paasToken, BasePath("/"))
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"/api", dtc.(*dynatraceClient).url, "empty base path is ignored")
+}
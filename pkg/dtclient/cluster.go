@@ -2,20 +2,26 @@ package dtclient
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"net/url"
+	"strings"
 )
 
 const (
 	clusterVersionEndpoint = "/v1/config/clusterversion"
 )
 
+// ErrNotManaged is returned by GetClusterVersion for SaaS environments, as their cluster version isn't relevant for
+// the compatibility of the API.
+var ErrNotManaged = errors.New("not a managed environment")
+
 type ClusterInfo struct {
 	Version string `json:"version"`
 }
 
 func (dc *dynatraceClient) GetClusterInfo() (*ClusterInfo, error) {
 	result := ClusterInfo{}
-	url := fmt.Sprintf("%s%s", dc.url, clusterVersionEndpoint)
+	url := dc.getURL(clusterVersionEndpoint)
 	resp, err := dc.makeRequest(url, dynatraceApiToken)
 	if err != nil {
 		return nil, err
@@ -36,3 +42,28 @@ func (dc *dynatraceClient) GetClusterInfo() (*ClusterInfo, error) {
 
 	return &result, nil
 }
+
+func (dc *dynatraceClient) GetClusterVersion() (string, error) {
+	if !dc.isManaged() {
+		return "", ErrNotManaged
+	}
+
+	info, err := dc.GetClusterInfo()
+	if err != nil {
+		return "", err
+	} else if info.Version == "" {
+		return "", errors.New("cluster version is not set")
+	}
+	return info.Version, nil
+}
+
+// isManaged returns true if the API URL of the client has the layout of a Dynatrace Managed environment, i.e.,
+// https://{domain}/e/{environment-id}/api.
+func (dc *dynatraceClient) isManaged() bool {
+	u, err := url.Parse(dc.url)
+	if err != nil {
+		return false
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return len(segments) >= 3 && segments[len(segments)-3] == "e" && segments[len(segments)-1] == "api"
+}
@@ -2,11 +2,13 @@ package dtclient
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -73,3 +75,36 @@ func handleClusterVersionRequest(request *http.Request, writer http.ResponseWrit
 		_, _ = writer.Write([]byte("\n"))
 	}
 }
+
+func TestDynatraceClient_GetClusterVersion(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/e/tenant/api"+clusterVersionEndpoint {
+			writeError(w, http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version":"` + clusterVersion + `"}`))
+	}))
+	defer ts.Close()
+
+	t.Run("managed", func(t *testing.T) {
+		dtc, err := NewClient(ts.URL+"/e/tenant/api", apiToken, paasToken)
+		require.NoError(t, err)
+
+		v, err := dtc.GetClusterVersion()
+		assert.NoError(t, err)
+		assert.Equal(t, clusterVersion, v)
+	})
+
+	t.Run("saas", func(t *testing.T) {
+		requests = 0
+		dtc, err := NewClient(ts.URL+"/api", apiToken, paasToken)
+		require.NoError(t, err)
+
+		v, err := dtc.GetClusterVersion()
+		assert.True(t, errors.Is(err, ErrNotManaged))
+		assert.Empty(t, v)
+		assert.Zero(t, requests)
+	})
+}
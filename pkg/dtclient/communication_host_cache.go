@@ -0,0 +1,82 @@
+package dtclient
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCommunicationHostCacheTTL is the time the communication hosts are kept in the DefaultCommunicationHostCache.
+const DefaultCommunicationHostCacheTTL = 15 * time.Minute
+
+// DefaultCommunicationHostCache is the cache used by clients created by NewClient unless the CommunicationHostCaching
+// option is given. Like the DefaultAgentVersionCache, it's shared so that hosts survive the clients.
+var DefaultCommunicationHostCache = NewCommunicationHostCache(DefaultCommunicationHostCacheTTL)
+
+// CommunicationHostCache keeps the communication hosts resolved for a Dynatrace environment and network zone for a
+// fixed time. It's safe for concurrent use.
+type CommunicationHostCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[communicationHostCacheKey]communicationHostCacheEntry
+
+	// Set for testing purposes, leave nil to use the current time.
+	now func() time.Time
+}
+
+type communicationHostCacheKey struct {
+	url         string
+	paasToken   string
+	networkZone string
+}
+
+type communicationHostCacheEntry struct {
+	hosts   []CommunicationHost
+	expires time.Time
+}
+
+// NewCommunicationHostCache creates a cache that keeps entries for the given time.
+func NewCommunicationHostCache(ttl time.Duration) *CommunicationHostCache {
+	return &CommunicationHostCache{
+		ttl:     ttl,
+		entries: map[communicationHostCacheKey]communicationHostCacheEntry{},
+	}
+}
+
+// Invalidate drops all cached entries, so that the next queries go to the Dynatrace API.
+func (c *CommunicationHostCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[communicationHostCacheKey]communicationHostCacheEntry{}
+}
+
+func (c *CommunicationHostCache) get(key communicationHostCacheKey) ([]CommunicationHost, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.currentTime().Before(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return append([]CommunicationHost(nil), e.hosts...), true
+}
+
+func (c *CommunicationHostCache) set(key communicationHostCacheKey, hosts []CommunicationHost) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = communicationHostCacheEntry{
+		hosts:   append([]CommunicationHost(nil), hosts...),
+		expires: c.currentTime().Add(c.ttl),
+	}
+}
+
+func (c *CommunicationHostCache) currentTime() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
@@ -3,11 +3,17 @@ package dtclient
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
+
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/version"
 )
 
+// minEndpointDiscoveryClusterVersion is the oldest Managed cluster version providing the communication endpoints
+// discovery API
+const minEndpointDiscoveryClusterVersion = "1.204.0"
+
 // ConnectionInfo => struct of TenantUUID and CommunicationHosts
 type ConnectionInfo struct {
 	CommunicationHosts []CommunicationHost
@@ -26,7 +32,7 @@ func (dc *dynatraceClient) GetCommunicationHostForClient() (CommunicationHost, e
 }
 
 func (dc *dynatraceClient) GetConnectionInfo() (ConnectionInfo, error) {
-	url := fmt.Sprintf("%s/v1/deployment/installer/agent/connectioninfo", dc.url)
+	url := dc.getURL("/v1/deployment/installer/agent/connectioninfo")
 	resp, err := dc.makeRequest(url, dynatracePaaSToken)
 	if err != nil {
 		return ConnectionInfo{}, err
@@ -41,10 +47,77 @@ func (dc *dynatraceClient) GetConnectionInfo() (ConnectionInfo, error) {
 	return dc.readResponseForConnectionInfo(responseData)
 }
 
+// GetCommunicationHosts resolves the communication hosts from the endpoints discovered for the network zone of the
+// client, ordered by priority, and falls back to the ones from the connection info if discovery isn't available.
+// Successful results are served from the communication host cache until they expire.
+func (dc *dynatraceClient) GetCommunicationHosts() ([]CommunicationHost, error) {
+	key := communicationHostCacheKey{url: dc.url, paasToken: dc.paasToken, networkZone: dc.networkZone}
+	if dc.communicationHostCache != nil {
+		if hosts, ok := dc.communicationHostCache.get(key); ok {
+			return hosts, nil
+		}
+	}
+
+	var hosts []CommunicationHost
+	err := errors.New("cluster version doesn't support endpoint discovery")
+	if dc.supportsEndpointDiscovery() {
+		hosts, err = dc.discoverCommunicationHosts()
+	}
+	if err != nil {
+		dc.logger.Info("communication endpoint discovery not available, using connection info", "error", err.Error())
+
+		ci, err := dc.GetConnectionInfo()
+		if err != nil {
+			return nil, err
+		}
+		hosts = ci.CommunicationHosts
+	}
+
+	if dc.communicationHostCache != nil {
+		dc.communicationHostCache.set(key, hosts)
+	}
+	return hosts, nil
+}
+
+// supportsEndpointDiscovery returns false for Managed clusters known to be older than the communication endpoints
+// discovery API. SaaS environments, and clusters whose version can't be determined, are assumed to support it.
+func (dc *dynatraceClient) supportsEndpointDiscovery() bool {
+	clusterVersion, err := dc.GetClusterVersion()
+	if err != nil {
+		return true
+	}
+
+	cmp, err := version.CompareAgentVersions(clusterVersion, minEndpointDiscoveryClusterVersion)
+	return err != nil || cmp >= 0
+}
+
+// discoverCommunicationHosts queries the communication endpoints the Dynatrace API recommends for the network zone of
+// the client. SaaS environments return the endpoints of their region there, ordered by priority.
+func (dc *dynatraceClient) discoverCommunicationHosts() ([]CommunicationHost, error) {
+	u := dc.getURL("/v1/deployment/installer/agent/connectioninfo/endpoints")
+	if dc.networkZone != "" {
+		u += "?networkZone=" + url.QueryEscape(dc.networkZone)
+	}
+
+	resp, err := dc.makeRequest(u, dynatracePaaSToken)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseData, err := dc.getServerResponseData(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return dc.parseEndpoints(splitEndpoints(string(responseData)))
+}
+
 func (dc *dynatraceClient) readResponseForConnectionInfo(response []byte) (ConnectionInfo, error) {
 	type jsonResponse struct {
-		TenantUUID             string   `json:"tenantUUID"`
-		CommunicationEndpoints []string `json:"communicationEndpoints"`
+		TenantUUID                      string   `json:"tenantUUID"`
+		CommunicationEndpoints          []string `json:"communicationEndpoints"`
+		FormattedCommunicationEndpoints string   `json:"formattedCommunicationEndpoints"`
 	}
 
 	resp := jsonResponse{}
@@ -55,9 +128,35 @@ func (dc *dynatraceClient) readResponseForConnectionInfo(response []byte) (Conne
 	}
 
 	t := resp.TenantUUID
-	ch := make([]CommunicationHost, 0, len(resp.CommunicationEndpoints))
 
+	var endpoints []string
 	for _, s := range resp.CommunicationEndpoints {
+		endpoints = append(endpoints, splitEndpoints(s)...)
+	}
+	if len(endpoints) == 0 {
+		endpoints = splitEndpoints(resp.FormattedCommunicationEndpoints)
+	}
+
+	ch, err := dc.parseEndpoints(endpoints)
+	if err != nil {
+		return ConnectionInfo{}, err
+	}
+
+	ci := ConnectionInfo{
+		CommunicationHosts: ch,
+		TenantUUID:         t,
+	}
+
+	return ci, nil
+}
+
+// parseEndpoints parses the endpoints into communication hosts in their original order, skipping duplicates and the
+// ones that can't be parsed. Returns an error if no communication host is left.
+func (dc *dynatraceClient) parseEndpoints(endpoints []string) ([]CommunicationHost, error) {
+	ch := make([]CommunicationHost, 0, len(endpoints))
+	seen := map[CommunicationHost]bool{}
+
+	for _, s := range endpoints {
 		logger := dc.logger.WithValues("url", s)
 
 		e, err := dc.parseEndpoint(s)
@@ -65,19 +164,28 @@ func (dc *dynatraceClient) readResponseForConnectionInfo(response []byte) (Conne
 			logger.Info("failed to parse communication endpoint")
 			continue
 		}
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
 		ch = append(ch, e)
 	}
 
 	if len(ch) == 0 {
-		return ConnectionInfo{}, errors.New("no communication hosts available")
+		return nil, errors.New("no communication hosts available")
 	}
+	return ch, nil
+}
 
-	ci := ConnectionInfo{
-		CommunicationHosts: ch,
-		TenantUUID:         t,
+// splitEndpoints splits a list of endpoints separated by commas or semicolons, as returned by some tenant versions.
+func splitEndpoints(s string) []string {
+	var out []string
+	for _, e := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if e = strings.TrimSpace(e); e != "" {
+			out = append(out, e)
+		}
 	}
-
-	return ci, nil
+	return out
 }
 
 func (dc *dynatraceClient) parseEndpoint(s string) (CommunicationHost, error) {
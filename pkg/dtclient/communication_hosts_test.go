@@ -2,9 +2,11 @@ package dtclient
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const goodCommunicationEndpointsResponse = `{
@@ -72,6 +74,34 @@ func TestReadCommunicationHosts(t *testing.T) {
 	}
 }
 
+func TestReadCommunicationHosts_SeparatedEndpoints(t *testing.T) {
+	dc := &dynatraceClient{
+		logger: consoleLogger,
+	}
+
+	expected := []CommunicationHost{
+		{Protocol: "https", Host: "ag.example.com", Port: 9999},
+		{Protocol: "https", Host: "example.live.dynatrace.com", Port: 443},
+	}
+
+	for _, tc := range []struct {
+		name string
+		json string
+	}{
+		{"comma-separated entry", `{"communicationEndpoints": ["https://ag.example.com:9999/communication,https://example.live.dynatrace.com/communication"]}`},
+		{"semicolon-separated entry", `{"communicationEndpoints": ["https://ag.example.com:9999/communication; https://example.live.dynatrace.com/communication"]}`},
+		{"duplicated entries", `{"communicationEndpoints": ["https://ag.example.com:9999/communication", "https://example.live.dynatrace.com/communication;https://ag.example.com:9999/communication"]}`},
+		{"formatted endpoints", `{"formattedCommunicationEndpoints": "https://ag.example.com:9999/communication;https://example.live.dynatrace.com/communication;"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ci, err := dc.readResponseForConnectionInfo([]byte(tc.json))
+			if assert.NoError(t, err) {
+				assert.Equal(t, expected, ci.CommunicationHosts)
+			}
+		})
+	}
+}
+
 func TestParseEndpoints(t *testing.T) {
 	var err error
 	var ch CommunicationHost
@@ -137,6 +167,62 @@ func TestParseEndpoints(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestGetCommunicationHosts_Discovery(t *testing.T) {
+	requests := map[string]int{}
+	discovery := true
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/v1/deployment/installer/agent/connectioninfo/endpoints":
+			if !discovery {
+				writeError(w, http.StatusNotFound)
+				return
+			}
+			assert.Equal(t, "zone-a", r.URL.Query().Get("networkZone"))
+			_, _ = w.Write([]byte("https://abc123.eu-1.live.dynatrace.com/communication;https://sg-eu-1.dynatrace.com/communication;" +
+				"https://abc123.eu-1.live.dynatrace.com/communication"))
+		case "/v1/deployment/installer/agent/connectioninfo":
+			handleCommunicationHosts(r, w)
+		default:
+			writeError(w, http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	t.Run("discovered endpoints are cached", func(t *testing.T) {
+		dtc, err := NewClient(ts.URL, apiToken, paasToken, NetworkZone("zone-a"),
+			CommunicationHostCaching(NewCommunicationHostCache(DefaultCommunicationHostCacheTTL)))
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			hosts, err := dtc.GetCommunicationHosts()
+			require.NoError(t, err)
+			assert.Equal(t, []CommunicationHost{
+				{Protocol: "https", Host: "abc123.eu-1.live.dynatrace.com", Port: 443},
+				{Protocol: "https", Host: "sg-eu-1.dynatrace.com", Port: 443},
+			}, hosts)
+		}
+		assert.Equal(t, 1, requests["/v1/deployment/installer/agent/connectioninfo/endpoints"])
+		assert.Zero(t, requests["/v1/deployment/installer/agent/connectioninfo"])
+	})
+
+	t.Run("falls back to connection info", func(t *testing.T) {
+		discovery = false
+		requests = map[string]int{}
+
+		dtc, err := NewClient(ts.URL, apiToken, paasToken, NetworkZone("zone-a"), CommunicationHostCaching(nil))
+		require.NoError(t, err)
+
+		hosts, err := dtc.GetCommunicationHosts()
+		require.NoError(t, err)
+		assert.Len(t, hosts, 5)
+		assert.Equal(t, CommunicationHost{Protocol: "http", Host: "host1.domain.com", Port: 80}, hosts[0])
+		assert.Equal(t, 1, requests["/v1/deployment/installer/agent/connectioninfo/endpoints"])
+		assert.Equal(t, 1, requests["/v1/deployment/installer/agent/connectioninfo"])
+	})
+}
+
 func testCommunicationHostsGetCommunicationHosts(t *testing.T, dynatraceClient Client) {
 	res, err := dynatraceClient.GetConnectionInfo()
 
@@ -146,6 +232,11 @@ func testCommunicationHostsGetCommunicationHosts(t *testing.T, dynatraceClient C
 		{Host: "host2.dynatracelabs.com", Port: 443, Protocol: "https"},
 		{Host: "12.0.9.1", Port: 80, Protocol: "http"},
 	})
+
+	hosts, err := dynatraceClient.GetCommunicationHosts()
+
+	assert.NoError(t, err)
+	assert.Equal(t, res.CommunicationHosts, hosts)
 }
 
 func handleCommunicationHosts(request *http.Request, writer http.ResponseWriter) {
@@ -169,3 +260,28 @@ func handleCommunicationHosts(request *http.Request, writer http.ResponseWriter)
 		writeError(writer, http.StatusMethodNotAllowed)
 	}
 }
+
+func TestGetCommunicationHosts_OldManagedCluster(t *testing.T) {
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/e/tenant/api/v1/config/clusterversion":
+			_, _ = w.Write([]byte(`{"version":"1.200.0.20200720-145209"}`))
+		case "/e/tenant/api/v1/deployment/installer/agent/connectioninfo":
+			handleCommunicationHosts(r, w)
+		default:
+			writeError(w, http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	dtc, err := NewClient(ts.URL+"/e/tenant/api", apiToken, paasToken, CommunicationHostCaching(nil))
+	require.NoError(t, err)
+
+	hosts, err := dtc.GetCommunicationHosts()
+	require.NoError(t, err)
+	assert.Len(t, hosts, 5)
+	assert.Zero(t, requests["/e/tenant/api/v1/deployment/installer/agent/connectioninfo/endpoints"])
+	assert.Equal(t, 1, requests["/e/tenant/api/v1/deployment/installer/agent/connectioninfo"])
+}
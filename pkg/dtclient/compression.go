@@ -0,0 +1,45 @@
+package dtclient
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decompressResponse replaces the body of a gzip-encoded response with its decompressed content. Since requests set
+// Accept-Encoding themselves, the transport leaves responses as sent by the server. The limit on the response size
+// applies to the decompressed body read from the response.
+func decompressResponse(resp *http.Response) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return
+	}
+
+	resp.Body = &gzipReadCloser{body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// gzipReadCloser decompresses the body on the first read, so that empty bodies, e.g., of HEAD requests, don't fail
+// unless read.
+type gzipReadCloser struct {
+	body   io.ReadCloser
+	reader *gzip.Reader
+	err    error
+}
+
+func (g *gzipReadCloser) Read(p []byte) (int, error) {
+	if g.reader == nil && g.err == nil {
+		g.reader, g.err = gzip.NewReader(g.body)
+	}
+	if g.err != nil {
+		return 0, g.err
+	}
+	return g.reader.Read(p)
+}
+
+func (g *gzipReadCloser) Close() error {
+	return g.body.Close()
+}
@@ -0,0 +1,55 @@
+package dtclient
+
+import (
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedResponses(t *testing.T) {
+	var acceptEncodings []string
+	body := `{"version": "1.200.0"}`
+
+	dynatraceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncodings = append(acceptEncodings, r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		_, _ = gz.Write([]byte(body))
+	}))
+	defer dynatraceServer.Close()
+
+	dtc, err := NewClient(dynatraceServer.URL, apiToken, paasToken, CustomHeaders(map[string]string{"Accept-Encoding": "br"}))
+	require.NoError(t, err)
+
+	ci, err := dtc.GetClusterInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "1.200.0", ci.Version)
+	assert.Equal(t, []string{"gzip"}, acceptEncodings)
+
+	t.Run("size limit applies to decompressed body", func(t *testing.T) {
+		// Highly compressible, so the compressed response is far below the limit.
+		body = `{"version": "` + strings.Repeat("1", 64*1024) + `"}`
+
+		dtc, err := NewClient(dynatraceServer.URL, apiToken, paasToken, MaxResponseSize(1024))
+		require.NoError(t, err)
+
+		_, err = dtc.GetClusterInfo()
+		assert.True(t, errors.Is(err, ErrResponseTooLarge))
+	})
+}
+
+func TestDecompressResponse_Uncompressed(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}, Body: http.NoBody, ContentLength: 0}
+	decompressResponse(resp)
+	assert.Equal(t, http.NoBody, resp.Body)
+	assert.False(t, resp.Uncompressed)
+}
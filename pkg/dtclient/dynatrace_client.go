@@ -1,21 +1,39 @@
 package dtclient
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 )
 
 type hostInfo struct {
-	version  string
-	entityID string
+	version        string
+	entityID       string
+	monitoringMode string
+	lastSeen       time.Time
 }
 
+// DefaultMaxResponseSize is the maximum size in bytes of the responses read by clients, unless overridden with the
+// MaxResponseSize option.
+const DefaultMaxResponseSize = int64(4 << 20)
+
+// ErrResponseTooLarge is returned when a response exceeds the maximum size of the client.
+var ErrResponseTooLarge = errors.New("response exceeds maximum size")
+
+// ErrHostNotFound is returned for hosts unknown to Dynatrace, or which haven't been seen recently.
+var ErrHostNotFound = errors.New("host not found")
+
 // client implements the Client interface.
 type dynatraceClient struct {
 	url       string
@@ -23,12 +41,59 @@ type dynatraceClient struct {
 	paasToken string
 	logger    logr.Logger
 
+	// Authenticates the requests instead of the API and PaaS tokens if set, e.g., with OAuth client credentials.
+	auth authStrategy
+
+	// API tokens to rotate through when the current API token gets rate limited, including the initial apiToken.
+	apiTokens []string
+
+	// Caches whether the API tokens have the scopes required to be used.
+	validAPITokens map[string]bool
+
+	// Guards apiToken and validAPITokens, which change while failing over between API tokens.
+	tokenMu sync.Mutex
+
 	networkZone string
 
+	// Sent as User-Agent with all requests.
+	userAgent string
+
+	// Headers added to all requests, never containing reserved headers.
+	customHeaders http.Header
+
 	httpClient *http.Client
 
 	hostCache map[string]hostInfo
 
+	// Guards hostCache, which is built lazily by the first of possibly concurrent host lookups.
+	hostCacheMu sync.Mutex
+
+	// ETags of the responses of endpoints supporting conditional requests, with the values read from them, nil to not
+	// send conditional requests.
+	etags *ETagCache
+
+	// Caches the latest agent versions, nil to disable caching.
+	agentVersionCache *AgentVersionCache
+
+	// Caches the communication hosts, nil to disable caching.
+	communicationHostCache *CommunicationHostCache
+
+	// Bounds the requests in flight, nil to not limit requests.
+	requestLimiter *RequestLimiter
+
+	// Stops requests after repeated failures, nil to never stop requests.
+	circuitBreaker *CircuitBreaker
+
+	// Maximum size in bytes of the responses read into memory, not positive to not limit sizes.
+	maxResponseSize int64
+
+	// Run around every HTTP call, in the order they have been added.
+	requestInterceptors  []RequestInterceptor
+	responseInterceptors []ResponseInterceptor
+
+	// Lets request interceptors remove the Authorization header.
+	allowAuthorizationRemoval bool
+
 	// Set for testing purposes, leave the default zero value to use the current time.
 	now time.Time
 }
@@ -40,6 +105,11 @@ const (
 	dynatracePaaSToken
 )
 
+// getURL returns the URL of the given endpoint, relative to the API URL of the client.
+func (dc *dynatraceClient) getURL(endpoint string) string {
+	return dc.url + "/" + strings.TrimLeft(endpoint, "/")
+}
+
 // makeRequest does an HTTP request by formatting the URL from the given arguments and returns the response.
 // The response body must be closed by the caller when no longer used.
 func (dc *dynatraceClient) makeRequest(url string, tokenType tokenType) (*http.Response, error) {
@@ -48,32 +118,236 @@ func (dc *dynatraceClient) makeRequest(url string, tokenType tokenType) (*http.R
 		return nil, fmt.Errorf("error initializing http request: %s", err.Error())
 	}
 
-	var authHeader string
+	return dc.sendRequest(req, tokenType)
+}
+
+// sendRequest sends the request authenticated with the given token type, or the auth strategy if set.
+// The response body must be closed by the caller when no longer used.
+func (dc *dynatraceClient) sendRequest(req *http.Request, tokenType tokenType) (*http.Response, error) {
+	url := req.URL.String()
+
+	if dc.auth != nil {
+		if err := dc.auth.authorize(req); err != nil {
+			return nil, err
+		}
+		return dc.do(req)
+	}
 
 	switch tokenType {
 	case dynatraceApiToken:
-		if dc.apiToken == "" {
+		if dc.currentAPIToken() == "" {
 			return nil, fmt.Errorf("not able to set token since api token is empty for request: %s", url)
 		}
-		authHeader = fmt.Sprintf("Api-Token %s", dc.apiToken)
+		return dc.doWithAPIToken(req)
 	case dynatracePaaSToken:
 		if dc.paasToken == "" {
 			return nil, fmt.Errorf("not able to set token since paas token is empty for request: %s", url)
 		}
-		authHeader = fmt.Sprintf("Api-Token %s", dc.paasToken)
+		setAPITokenHeader(req, dc.paasToken)
+		return dc.do(req)
 	default:
 		return nil, errors.New("unable to determine token to set in headers")
 	}
+}
+
+// Headers of rate limited responses of the Dynatrace API, describing the limit that has been exceeded.
+const (
+	rateLimitLimitHeader = "X-RateLimit-Limit"
+	rateLimitResetHeader = "X-RateLimit-Reset"
+	rateLimitScopeHeader = "X-RateLimit-Scope"
+)
+
+// rateLimitScopeToken is the scope of rate limits applied per API token, rather than to the whole environment.
+const rateLimitScopeToken = "token"
+
+// rateLimit describes the rate limit exceeded by a request.
+type rateLimit struct {
+	scope string
+	limit int
+	reset time.Time
+}
+
+// parseRateLimit reads the details of the rate limit from the headers of the rate limited response. The scope is
+// read from the details of the error in the response body if the header isn't set.
+func parseRateLimit(resp *http.Response, body []byte) rateLimit {
+	rl := rateLimit{scope: resp.Header.Get(rateLimitScopeHeader)}
+	if rl.scope == "" {
+		var se struct {
+			Error struct {
+				Details struct {
+					LimitScope string `json:"limitScope"`
+				} `json:"details"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(body, &se); err == nil {
+			rl.scope = se.Error.Details.LimitScope
+		}
+	}
+
+	if limit, err := strconv.Atoi(resp.Header.Get(rateLimitLimitHeader)); err == nil {
+		rl.limit = limit
+	}
+
+	// The reset time is given in microseconds since the epoch.
+	if reset, err := strconv.ParseInt(resp.Header.Get(rateLimitResetHeader), 10, 64); err == nil {
+		rl.reset = time.Unix(0, reset*int64(time.Microsecond))
+	}
+	return rl
+}
+
+// isTokenScoped returns true if the rate limit applies only to the API token used for the request.
+func (rl rateLimit) isTokenScoped() bool {
+	return strings.EqualFold(rl.scope, rateLimitScopeToken)
+}
+
+// doWithAPIToken sends the request authenticated with the current API token. If the token has exceeded a rate limit
+// applied per token, the request is retried with the next failover API token until all of them have been tried. The
+// last token used is kept for subsequent requests. Other rate limits apply to all tokens of the environment, so the
+// rate limited response is returned as is.
+//
+// The response body must be closed by the caller when no longer used.
+func (dc *dynatraceClient) doWithAPIToken(req *http.Request) (*http.Response, error) {
+	tried := map[string]bool{}
+	token := dc.currentAPIToken()
+
+	for {
+		tried[token] = true
+		setAPITokenHeader(req, token)
+
+		resp, err := dc.do(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+
+		// Buffer the error response to release the request slot, since looking up the next token sends requests.
+		data, err := dc.readResponseBody(resp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+		rl := parseRateLimit(resp, data)
+		if !rl.isTokenScoped() {
+			return resp, nil
+		}
+
+		next := dc.nextAPIToken(tried)
+		if next == "" {
+			return resp, nil
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return resp, nil
+			}
+			req.Body = body
+		}
+
+		dc.logger.Info("API token rate limited, failing over to next API token", "url", req.URL.Path, "limit", rl.limit, "reset", rl.reset)
+		dc.swapAPIToken(token, next)
+		token = next
+	}
+}
+
+// currentAPIToken returns the API token used for requests.
+func (dc *dynatraceClient) currentAPIToken() string {
+	dc.tokenMu.Lock()
+	defer dc.tokenMu.Unlock()
+	return dc.apiToken
+}
+
+// swapAPIToken replaces the given API token with the next one for subsequent requests, unless a concurrent request
+// has replaced it already.
+func (dc *dynatraceClient) swapAPIToken(token, next string) {
+	dc.tokenMu.Lock()
+	defer dc.tokenMu.Unlock()
+	if dc.apiToken == token {
+		dc.apiToken = next
+	}
+}
+
+// nextAPIToken returns the first API token not yet tried having the scopes required, or an empty string if there is
+// none left.
+func (dc *dynatraceClient) nextAPIToken(tried map[string]bool) string {
+	for _, token := range dc.apiTokens {
+		if !tried[token] && dc.isValidAPIToken(token) {
+			return token
+		}
+	}
+	return ""
+}
+
+// isValidAPIToken verifies, once per token, that it has the DataExport scope. Tokens that couldn't be verified are
+// not used.
+func (dc *dynatraceClient) isValidAPIToken(token string) bool {
+	dc.tokenMu.Lock()
+	valid, ok := dc.validAPITokens[token]
+	dc.tokenMu.Unlock()
+	if ok {
+		return valid
+	}
+
+	scopes, err := dc.GetTokenScopes(token)
+	if err != nil {
+		dc.logger.Info("failed to verify scopes of failover API token", "error", err.Error())
+		return false
+	}
+
+	valid = scopes.Contains(TokenScopeDataExport)
+	if !valid {
+		dc.logger.Info("failover API token ignored, missing scope", "scope", TokenScopeDataExport)
+	}
+
+	dc.tokenMu.Lock()
+	defer dc.tokenMu.Unlock()
+	if dc.validAPITokens == nil {
+		dc.validAPITokens = map[string]bool{}
+	}
+	dc.validAPITokens[token] = valid
+
+	return valid
+}
 
-	req.Header.Add("Authorization", authHeader)
+// timedDo sends the request with the custom headers of the client through its interceptors, and logs its duration at
+// debug level. Only the method and the URL path are logged, since the query and the headers may contain secrets.
+func (dc *dynatraceClient) timedDo(req *http.Request) (*http.Response, error) {
+	for name, values := range dc.customHeaders {
+		req.Header[name] = values
+	}
+	req.Header.Set("User-Agent", dc.userAgent)
+	req.Header.Set("Accept-Encoding", "gzip")
+	dc.interceptRequest(req)
+
+	start := time.Now()
+	resp, err := dc.httpClient.Do(req)
+	duration := time.Since(start)
+
+	if err != nil {
+		// Errors returned by the HTTP client include the full URL, only log the cause.
+		cause := err
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			cause = urlErr.Err
+		}
+		dc.logger.V(1).Info("Dynatrace API call failed", "method", req.Method, "endpoint", req.URL.Path,
+			"duration", duration.String(), "error", cause.Error())
+		dc.interceptResponse(req, nil, err)
+		return nil, err
+	}
 
-	return dc.httpClient.Do(req)
+	dc.logger.V(1).Info("Dynatrace API call", "method", req.Method, "endpoint", req.URL.Path,
+		"status", resp.StatusCode, "duration", duration.String())
+	decompressResponse(resp)
+	dc.interceptResponse(req, resp, nil)
+	return resp, nil
 }
 
 func (dc *dynatraceClient) getServerResponseData(response *http.Response) ([]byte, error) {
-	responseData, err := ioutil.ReadAll(response.Body)
+	responseData, err := dc.readResponseBody(response)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %w", err)
+		return nil, err
 	}
 
 	if response.StatusCode != http.StatusOK {
@@ -83,6 +357,27 @@ func (dc *dynatraceClient) getServerResponseData(response *http.Response) ([]byt
 	return responseData, nil
 }
 
+// readResponseBody reads the whole response body into memory, failing with ErrResponseTooLarge if it exceeds the
+// maximum response size. Endpoints streaming large downloads must read the response body directly instead.
+func (dc *dynatraceClient) readResponseBody(response *http.Response) ([]byte, error) {
+	if dc.maxResponseSize <= 0 {
+		data, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error reading response: %w", err)
+		}
+		return data, nil
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, dc.maxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if int64(len(data)) > dc.maxResponseSize {
+		return nil, fmt.Errorf("error reading response: %w of %d bytes", ErrResponseTooLarge, dc.maxResponseSize)
+	}
+	return data, nil
+}
+
 func (dc *dynatraceClient) handleErrorResponseFromAPI(response []byte, statusCode int) error {
 	se := serverErrorResponse{}
 	if err := json.Unmarshal(response, &se); err != nil {
@@ -93,6 +388,9 @@ func (dc *dynatraceClient) handleErrorResponseFromAPI(response []byte, statusCod
 }
 
 func (dc *dynatraceClient) getHostInfoForIP(ip string) (*hostInfo, error) {
+	dc.hostCacheMu.Lock()
+	defer dc.hostCacheMu.Unlock()
+
 	if len(dc.hostCache) == 0 {
 		err := dc.buildHostCache()
 		if err != nil {
@@ -102,14 +400,14 @@ func (dc *dynatraceClient) getHostInfoForIP(ip string) (*hostInfo, error) {
 
 	switch hostInfo, ok := dc.hostCache[ip]; {
 	case !ok:
-		return nil, errors.New("host not found")
+		return nil, ErrHostNotFound
 	default:
 		return &hostInfo, nil
 	}
 }
 
 func (dc *dynatraceClient) buildHostCache() error {
-	url := fmt.Sprintf("%s/v1/entity/infrastructure/hosts?includeDetails=false", dc.url)
+	url := dc.getURL("/v1/entity/infrastructure/hosts?includeDetails=false")
 	resp, err := dc.makeRequest(url, dynatraceApiToken)
 	if err != nil {
 		return err
@@ -140,6 +438,7 @@ func (dc *dynatraceClient) setHostCacheFromResponse(response []byte) error {
 		}
 		EntityID          string
 		NetworkZoneID     string
+		MonitoringMode    string
 		LastSeenTimestamp int64
 	}
 
@@ -161,7 +460,8 @@ func (dc *dynatraceClient) setHostCacheFromResponse(response []byte) error {
 
 	for _, info := range hostInfoResponses {
 		// If we haven't seen this host in the last 30 minutes, ignore it.
-		if tm := time.Unix(info.LastSeenTimestamp/1000, 0).UTC(); tm.Before(now.Add(-30 * time.Minute)) {
+		lastSeen := time.Unix(info.LastSeenTimestamp/1000, 0).UTC()
+		if lastSeen.Before(now.Add(-30 * time.Minute)) {
 			inactive = append(inactive, info.EntityID)
 			continue
 		}
@@ -169,7 +469,7 @@ func (dc *dynatraceClient) setHostCacheFromResponse(response []byte) error {
 		nz := info.NetworkZoneID
 
 		if (dc.networkZone != "" && nz == dc.networkZone) || (dc.networkZone == "" && (nz == "default" || nz == "")) {
-			hostInfo := hostInfo{entityID: info.EntityID}
+			hostInfo := hostInfo{entityID: info.EntityID, monitoringMode: info.MonitoringMode, lastSeen: lastSeen}
 
 			if v := info.AgentVersion; v != nil {
 				hostInfo.version = fmt.Sprintf("%d.%d.%d.%s", v.Major, v.Minor, v.Revision, v.Timestamp)
@@ -2,13 +2,19 @@ package dtclient
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -73,6 +79,187 @@ func TestGetResponseOrServerError(t *testing.T) {
 	}
 }
 
+func TestMaxResponseSize(t *testing.T) {
+	const size = 1024
+
+	dynatraceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/v1/entity/infrastructure/hosts" {
+			// A valid JSON response, just too large for the limit
+			_, _ = w.Write([]byte(`[{"entityId": "` + strings.Repeat("x", size) + `"}]`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"version": "1.200.0"}`))
+	}))
+	defer dynatraceServer.Close()
+
+	dtc, err := NewClient(dynatraceServer.URL, apiToken, paasToken, MaxResponseSize(size))
+	require.NoError(t, err)
+
+	_, err = dtc.GetAgentVersionForIP(goodIP)
+	assert.True(t, errors.Is(err, ErrResponseTooLarge), "oversized response rejected")
+
+	ci, err := dtc.GetClusterInfo()
+	assert.NoError(t, err, "small response accepted")
+	assert.Equal(t, "1.200.0", ci.Version)
+
+	dtc, err = NewClient(dynatraceServer.URL, apiToken, paasToken, MaxResponseSize(0))
+	require.NoError(t, err)
+
+	_, err = dtc.GetAgentVersionForIP(goodIP)
+	assert.False(t, errors.Is(err, ErrResponseTooLarge), "no limit if disabled")
+}
+
+func TestAPICallTiming(t *testing.T) {
+	dynatraceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"version": "1.200.0"}`))
+	}))
+	defer dynatraceServer.Close()
+
+	logger := &capturingLogger{}
+	dtc, err := NewClient(dynatraceServer.URL, apiToken, paasToken, Logger(logger))
+	require.NoError(t, err)
+
+	_, err = dtc.GetClusterInfo()
+	require.NoError(t, err)
+
+	require.Len(t, logger.entries, 1)
+	entry := logger.entries[0]
+	assert.Equal(t, 1, entry.level)
+	assert.Equal(t, "Dynatrace API call", entry.msg)
+	assert.Equal(t, "GET", entry.values["method"])
+	assert.Equal(t, "/v1/config/clusterversion", entry.values["endpoint"])
+	assert.Equal(t, http.StatusOK, entry.values["status"])
+
+	duration, err := time.ParseDuration(entry.values["duration"].(string))
+	require.NoError(t, err)
+	assert.True(t, duration >= 50*time.Millisecond, "duration includes server latency")
+
+	for k, v := range entry.values {
+		assert.NotContains(t, fmt.Sprint(v), apiToken, "token not logged in %s", k)
+		assert.NotContains(t, fmt.Sprint(v), dynatraceServer.URL, "full URL not logged in %s", k)
+	}
+}
+
+func TestCustomHeaders(t *testing.T) {
+	var received http.Header
+	dynatraceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"version": "1.200.0"}`))
+	}))
+	defer dynatraceServer.Close()
+
+	logger := &capturingLogger{}
+	dtc, err := NewClient(dynatraceServer.URL, apiToken, paasToken, Logger(logger), CustomHeaders(map[string]string{
+		"x-route-to":    "dynatrace",
+		"Authorization": "Bearer secret-value",
+	}))
+	require.NoError(t, err)
+
+	_, err = dtc.GetClusterInfo()
+	require.NoError(t, err)
+
+	assert.Equal(t, "dynatrace", received.Get("X-Route-To"))
+	assert.Equal(t, "Api-Token "+apiToken, received.Get("Authorization"), "reserved header not overridden")
+
+	require.NotEmpty(t, logger.entries)
+	warning := logger.entries[0]
+	assert.Equal(t, "Ignoring custom header reserved by the client", warning.msg)
+	assert.Equal(t, "Authorization", warning.values["header"])
+
+	for _, entry := range logger.entries {
+		for k, v := range entry.values {
+			assert.NotContains(t, fmt.Sprint(v), "secret-value", "header value not logged in %s", k)
+		}
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var userAgents []string
+	dynatraceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.Header.Get("User-Agent"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"version": "1.200.0"}`))
+	}))
+	defer dynatraceServer.Close()
+
+	t.Run("default", func(t *testing.T) {
+		userAgents = nil
+		dtc, err := NewClient(dynatraceServer.URL, apiToken, paasToken, CustomHeaders(map[string]string{"user-agent": "curl/7.68.0"}))
+		require.NoError(t, err)
+
+		_, err = dtc.GetClusterInfo()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dynatrace-oneagent-operator/snapshot"}, userAgents)
+	})
+
+	t.Run("overridden", func(t *testing.T) {
+		userAgents = nil
+		dtc, err := NewClient(dynatraceServer.URL, apiToken, paasToken, UserAgent("dynatrace-oneagent-operator/v0.9.0 (support-case-1234)"))
+		require.NoError(t, err)
+
+		_, err = dtc.GetClusterInfo()
+		require.NoError(t, err)
+		_, _ = dtc.GetTokenScopes(apiToken) // Response isn't a token, only the request matters.
+		assert.Equal(t, []string{
+			"dynatrace-oneagent-operator/v0.9.0 (support-case-1234)",
+			"dynatrace-oneagent-operator/v0.9.0 (support-case-1234)",
+		}, userAgents)
+	})
+}
+
+type capturedLogEntry struct {
+	level  int
+	msg    string
+	values map[string]interface{}
+}
+
+// capturingLogger is a logr.Logger recording the entries logged through it.
+type capturingLogger struct {
+	level   int
+	entries []capturedLogEntry
+}
+
+func (l *capturingLogger) Info(msg string, keysAndValues ...interface{}) {
+	values := map[string]interface{}{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		values[fmt.Sprint(keysAndValues[i])] = keysAndValues[i+1]
+	}
+	l.entries = append(l.entries, capturedLogEntry{level: l.level, msg: msg, values: values})
+}
+
+func (l *capturingLogger) Enabled() bool { return true }
+
+func (l *capturingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.Info(msg, append(keysAndValues, "error", err)...)
+}
+
+func (l *capturingLogger) V(level int) logr.InfoLogger {
+	return &capturingLevelLogger{parent: l, level: level}
+}
+
+func (l *capturingLogger) WithValues(keysAndValues ...interface{}) logr.Logger { return l }
+
+func (l *capturingLogger) WithName(name string) logr.Logger { return l }
+
+// capturingLevelLogger records entries in the parent capturingLogger with the given verbosity level.
+type capturingLevelLogger struct {
+	parent *capturingLogger
+	level  int
+}
+
+func (l *capturingLevelLogger) Info(msg string, keysAndValues ...interface{}) {
+	level := l.parent.level
+	l.parent.level = l.level
+	l.parent.Info(msg, keysAndValues...)
+	l.parent.level = level
+}
+
+func (l *capturingLevelLogger) Enabled() bool { return true }
+
 func TestBuildHostCache(t *testing.T) {
 	dynatraceServer := httptest.NewServer(dynatraceServerHandler())
 	defer dynatraceServer.Close()
@@ -106,6 +293,42 @@ func TestBuildHostCache(t *testing.T) {
 	}
 }
 
+func TestBuildHostCache_Concurrent(t *testing.T) {
+	var hostRequests int32
+	dynatraceServer := httptest.NewServer(dynatraceServerHandlerWith(func(request *http.Request, writer http.ResponseWriter) {
+		if request.URL.Path == "/v1/entity/infrastructure/hosts" {
+			atomic.AddInt32(&hostRequests, 1)
+		}
+		handleRequest(request, writer)
+	}))
+	defer dynatraceServer.Close()
+
+	dc := &dynatraceClient{
+		url:       dynatraceServer.URL,
+		apiToken:  apiToken,
+		paasToken: paasToken,
+		now:       time.Unix(1521540000, 0),
+		logger:    consoleLogger,
+
+		hostCache:  make(map[string]hostInfo),
+		httpClient: http.DefaultClient,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			version, err := dc.GetAgentVersionForIP("10.11.12.13")
+			assert.NoError(t, err)
+			assert.Equal(t, "1.142.0.20180313-173634", version)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hostRequests), "host list requested once")
+}
+
 func TestServerError(t *testing.T) {
 	{
 		se := &ServerError{Code: 401, Message: "Unauthorized"}
@@ -137,30 +360,42 @@ func TestDynatraceClientWithServer(t *testing.T) {
 	require.NotNil(t, dtc)
 
 	testAgentVersionGetLatestAgentVersion(t, dtc)
+	testAgentVersionGetLatestAgentVersionInfo(t, dtc)
+	testAgentVersionGetLatestAgentVersionForBranch(t, dtc)
 	testAgentVersionGetAgentVersionForIP(t, dtc)
+	testAgentVersionGetMonitoringModeForIP(t, dtc)
+	testAgentVersionGetLastSeenForIP(t, dtc)
 	testCommunicationHostsGetCommunicationHosts(t, dtc)
 	testSendEvent(t, dtc)
 	testGetTokenScopes(t, dtc)
+	testGetTokenMetadata(t, dtc)
 }
 
 func dynatraceServerHandler() http.HandlerFunc {
+	return dynatraceServerHandlerWith(handleRequest)
+}
+
+func dynatraceServerHandlerWith(handlerFunc func(request *http.Request, writer http.ResponseWriter)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
 		if r.FormValue("Api-Token") == "" && r.Header.Get("Authorization") == "" {
 			writeError(w, http.StatusUnauthorized)
 		} else {
-			handleRequest(r, w)
+			handlerFunc(r, w)
 		}
 	}
 }
 
 func handleRequest(request *http.Request, writer http.ResponseWriter) {
 	latestAgentVersion := fmt.Sprintf("/v1/deployment/installer/agent/%s/%s/latest/metainfo", OsUnix, InstallerTypeDefault)
+	availableVersions := fmt.Sprintf("/v1/deployment/installer/agent/versions/%s/%s", OsUnix, InstallerTypeDefault)
 
 	switch request.URL.Path {
 	case latestAgentVersion:
 		handleLatestAgentVersion(request, writer)
+	case availableVersions:
+		handleAvailableVersions(request, writer)
 	case "/v1/entity/infrastructure/hosts":
 		handleVersionForIP(request, writer)
 	case "/v1/deployment/installer/agent/connectioninfo":
@@ -187,6 +422,146 @@ func writeError(w http.ResponseWriter, status int) {
 	_, _ = w.Write(result)
 }
 
+func TestAPITokenFailover(t *testing.T) {
+	const (
+		limitedToken            = "limited-token"
+		environmentLimitedToken = "environment-limited-token"
+		noScopeToken            = "no-scope-token"
+		backupToken             = "backup-token"
+	)
+
+	requestsByToken := map[string]int{}
+	var requestsMu sync.Mutex
+	var eventBody []byte
+
+	dynatraceServer := httptest.NewServer(dynatraceServerHandlerWith(func(request *http.Request, writer http.ResponseWriter) {
+		if request.URL.Path == "/v1/tokens/lookup" {
+			var model struct {
+				Token string `json:"token"`
+			}
+			d, _ := ioutil.ReadAll(request.Body)
+			_ = json.Unmarshal(d, &model)
+
+			scopes := map[string]string{noScopeToken: `["InstallerDownload"]`, backupToken: `["DataExport"]`}[model.Token]
+			if scopes == "" {
+				writeError(writer, http.StatusUnauthorized)
+				return
+			}
+			writer.WriteHeader(http.StatusOK)
+			_, _ = writer.Write([]byte(fmt.Sprintf(`{"scopes": %s}`, scopes)))
+			return
+		}
+
+		token := strings.TrimPrefix(request.Header.Get("Authorization"), "Api-Token ")
+		requestsMu.Lock()
+		requestsByToken[token]++
+		requestsMu.Unlock()
+
+		switch token {
+		case limitedToken:
+			writer.Header().Set("X-RateLimit-Scope", "token")
+			writer.Header().Set("X-RateLimit-Limit", "50")
+			writeError(writer, http.StatusTooManyRequests)
+			return
+		case environmentLimitedToken:
+			writer.WriteHeader(http.StatusTooManyRequests)
+			_, _ = writer.Write([]byte(`{"error": {"code": 429, "message": "rate limited", "details": {"limitScope": "environment"}}}`))
+			return
+		}
+
+		if request.URL.Path == "/v1/events" {
+			d, _ := ioutil.ReadAll(request.Body)
+			requestsMu.Lock()
+			eventBody = d
+			requestsMu.Unlock()
+		}
+		handleRequest(request, writer)
+	}))
+	defer dynatraceServer.Close()
+
+	t.Run("fails over to next token with required scopes", func(t *testing.T) {
+		dtc, err := NewClient(dynatraceServer.URL, limitedToken, paasToken, FailoverAPITokens(noScopeToken, backupToken))
+		require.NoError(t, err)
+		dtc.(*dynatraceClient).now = time.Unix(1521540000, 0)
+
+		v, err := dtc.GetAgentVersionForIP(goodIP)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.142.0.20180313-173634", v)
+
+		assert.Equal(t, backupToken, dtc.(*dynatraceClient).apiToken)
+		assert.Equal(t, 1, requestsByToken[limitedToken])
+		assert.Equal(t, 0, requestsByToken[noScopeToken])
+		assert.Equal(t, 1, requestsByToken[backupToken])
+
+		// Request body is sent again with the next token.
+		err = dtc.SendEvent(&EventData{EventType: MarkedForTerminationEvent})
+		assert.NoError(t, err)
+		assert.Contains(t, string(eventBody), MarkedForTerminationEvent)
+		assert.Equal(t, 1, requestsByToken[limitedToken])
+	})
+
+	t.Run("returns rate limit error when all tokens are exhausted", func(t *testing.T) {
+		dtc, err := NewClient(dynatraceServer.URL, limitedToken, paasToken, FailoverAPITokens(noScopeToken))
+		require.NoError(t, err)
+
+		_, err = dtc.GetAgentVersionForIP(goodIP)
+
+		var serr ServerError
+		if assert.True(t, errors.As(err, &serr)) {
+			assert.Equal(t, http.StatusTooManyRequests, serr.Code)
+		}
+		assert.Equal(t, limitedToken, dtc.(*dynatraceClient).apiToken)
+	})
+
+	t.Run("keeps token when rate limit applies to environment", func(t *testing.T) {
+		backupRequests := requestsByToken[backupToken]
+
+		dtc, err := NewClient(dynatraceServer.URL, environmentLimitedToken, paasToken, FailoverAPITokens(backupToken))
+		require.NoError(t, err)
+
+		_, err = dtc.GetAgentVersionForIP(goodIP)
+
+		var serr ServerError
+		if assert.True(t, errors.As(err, &serr)) {
+			assert.Equal(t, http.StatusTooManyRequests, serr.Code)
+		}
+		assert.Equal(t, environmentLimitedToken, dtc.(*dynatraceClient).apiToken)
+		assert.Equal(t, backupRequests, requestsByToken[backupToken])
+	})
+
+	t.Run("fails over concurrent requests", func(t *testing.T) {
+		dtc, err := NewClient(dynatraceServer.URL, limitedToken, paasToken, FailoverAPITokens(noScopeToken, backupToken))
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, dtc.SendEvent(&EventData{EventType: MarkedForTerminationEvent}))
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, backupToken, dtc.(*dynatraceClient).currentAPIToken())
+	})
+}
+
+func TestParseRateLimit(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("X-RateLimit-Limit", "50")
+	resp.Header.Set("X-RateLimit-Reset", "1521540000000000")
+
+	rl := parseRateLimit(resp, []byte(`{"error": {"code": 429, "details": {"limitScope": "Token"}}}`))
+	assert.True(t, rl.isTokenScoped())
+	assert.Equal(t, 50, rl.limit)
+	assert.True(t, time.Unix(1521540000, 0).Equal(rl.reset))
+
+	resp.Header.Set("X-RateLimit-Scope", "environment")
+	assert.False(t, parseRateLimit(resp, nil).isTokenScoped())
+	assert.False(t, parseRateLimit(&http.Response{Header: http.Header{}}, []byte("rate limited")).isTokenScoped())
+}
+
 func TestIgnoreNonCurrentlySeenHosts(t *testing.T) {
 	// now:                         20/05/2020 10:10 AM UTC
 	// HOST-42 - lastSeenTimestamp: 20/05/2020 10:04 AM UTC
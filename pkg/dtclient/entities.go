@@ -0,0 +1,71 @@
+package dtclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+const entitiesEndpoint = "/v2/entities"
+
+// Entity is a monitored entity as returned by the entities API, e.g., a process group or host.
+type Entity struct {
+	EntityID    string `json:"entityId"`
+	Type        string `json:"type"`
+	DisplayName string `json:"displayName"`
+}
+
+type entitiesResponse struct {
+	Entities    []Entity `json:"entities"`
+	NextPageKey string   `json:"nextPageKey"`
+}
+
+// GetEntities returns the entities of the given type, e.g. PROCESS_GROUP, matching the selector. The selector is
+// combined with the type into the entity selector, e.g. `entityName.startsWith("kube")`, and can be empty to return
+// all entities of the type. All pages of the result are fetched.
+func (dc *dynatraceClient) GetEntities(entityType, selector string) ([]Entity, error) {
+	if entityType == "" {
+		return nil, errors.New("entity type is empty")
+	}
+
+	entitySelector := fmt.Sprintf("type(%q)", entityType)
+	if selector != "" {
+		entitySelector += "," + selector
+	}
+
+	query := url.Values{"entitySelector": {entitySelector}}
+	var entities []Entity
+	for {
+		page, err := dc.getEntitiesPage(query)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, page.Entities...)
+
+		if page.NextPageKey == "" {
+			return entities, nil
+		}
+		// Following pages are only selected by their key, other parameters must not be repeated.
+		query = url.Values{"nextPageKey": {page.NextPageKey}}
+	}
+}
+
+func (dc *dynatraceClient) getEntitiesPage(query url.Values) (*entitiesResponse, error) {
+	resp, err := dc.makeRequest(dc.getURL(entitiesEndpoint)+"?"+query.Encode(), dynatraceApiToken)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := dc.getServerResponseData(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var page entitiesResponse
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, fmt.Errorf("error parsing entities response: %w", err)
+	}
+	return &page, nil
+}
@@ -0,0 +1,76 @@
+package dtclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEntities(t *testing.T) {
+	var queries []string
+	dynatraceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.URL.Path != entitiesEndpoint:
+			writeError(w, http.StatusNotFound)
+		case r.Header.Get("Authorization") != "Api-Token "+apiToken:
+			writeError(w, http.StatusUnauthorized)
+		case r.URL.Query().Get("nextPageKey") == "":
+			_, _ = w.Write([]byte(`{"totalCount": 3, "nextPageKey": "page-2", "entities": [
+				{"entityId": "PROCESS_GROUP-1", "type": "PROCESS_GROUP", "displayName": "kube-proxy"},
+				{"entityId": "PROCESS_GROUP-2", "type": "PROCESS_GROUP", "displayName": "kube-dns"}
+			]}`))
+		default:
+			_, _ = w.Write([]byte(`{"totalCount": 3, "entities": [
+				{"entityId": "PROCESS_GROUP-3", "type": "PROCESS_GROUP", "displayName": "kube-scheduler"}
+			]}`))
+		}
+	}))
+	defer dynatraceServer.Close()
+
+	dtc, err := NewClient(dynatraceServer.URL, apiToken, paasToken)
+	require.NoError(t, err)
+
+	t.Run("selector encoded and all pages fetched", func(t *testing.T) {
+		queries = nil
+
+		entities, err := dtc.GetEntities("PROCESS_GROUP", `entityName.startsWith("kube"),tag("env:prod")`)
+		require.NoError(t, err)
+		assert.Equal(t, []Entity{
+			{EntityID: "PROCESS_GROUP-1", Type: "PROCESS_GROUP", DisplayName: "kube-proxy"},
+			{EntityID: "PROCESS_GROUP-2", Type: "PROCESS_GROUP", DisplayName: "kube-dns"},
+			{EntityID: "PROCESS_GROUP-3", Type: "PROCESS_GROUP", DisplayName: "kube-scheduler"},
+		}, entities)
+
+		assert.Equal(t, []string{
+			"entitySelector=type%28%22PROCESS_GROUP%22%29%2CentityName.startsWith%28%22kube%22%29%2Ctag%28%22env%3Aprod%22%29",
+			"nextPageKey=page-2",
+		}, queries)
+	})
+
+	t.Run("only type without selector", func(t *testing.T) {
+		queries = nil
+
+		_, err := dtc.GetEntities("HOST", "")
+		require.NoError(t, err)
+		assert.Equal(t, "entitySelector=type%28%22HOST%22%29", queries[0])
+	})
+
+	t.Run("entity type is required", func(t *testing.T) {
+		_, err := dtc.GetEntities("", `entityName("kube-dns")`)
+		assert.EqualError(t, err, "entity type is empty")
+	})
+
+	t.Run("error on failed page", func(t *testing.T) {
+		failing, err := NewClient(dynatraceServer.URL, "invalid", paasToken)
+		require.NoError(t, err)
+
+		_, err = failing.GetEntities("PROCESS_GROUP", "")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,105 @@
+package dtclient
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// DefaultETagCache is the cache used by clients created by NewClient unless the ETagCaching option is given. Like the
+// DefaultAgentVersionCache, it's shared so that ETags survive the clients, which are usually created on every
+// reconciliation.
+var DefaultETagCache = NewETagCache()
+
+// etagEntry is the ETag of a response, with the value read from it.
+type etagEntry struct {
+	etag  string
+	value interface{}
+}
+
+type etagCacheKey struct {
+	url   string
+	token string
+}
+
+// ETagCache stores the ETags of responses per URL and token, with the values read from them. It's safe for concurrent
+// use.
+type ETagCache struct {
+	mu      sync.Mutex
+	entries map[etagCacheKey]etagEntry
+}
+
+// NewETagCache creates an empty cache.
+func NewETagCache() *ETagCache {
+	return &ETagCache{entries: map[etagCacheKey]etagEntry{}}
+}
+
+// Invalidate drops all cached entries, so that the next requests are sent without ETags.
+func (c *ETagCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[etagCacheKey]etagEntry{}
+}
+
+func (c *ETagCache) get(key etagCacheKey) (etagEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	return e, ok
+}
+
+func (c *ETagCache) set(key etagCacheKey, e etagEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = e
+}
+
+// getWithETag sends a conditional GET request for the URL, with the ETag stored for it on If-None-Match. If the
+// server responds with 304 Not Modified, the value stored with the ETag is returned. Otherwise, the response is
+// read with parse, and the result stored with the ETag of the response, if any. Without an ETag cache, a plain GET
+// request is sent.
+func (dc *dynatraceClient) getWithETag(url string, tokenType tokenType, parse func([]byte) (interface{}, error)) (interface{}, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing http request: %s", err.Error())
+	}
+
+	key := etagCacheKey{url: url, token: dc.paasToken}
+	if tokenType == dynatraceApiToken {
+		key.token = dc.currentAPIToken()
+	}
+
+	var cached etagEntry
+	var hasCached bool
+	if dc.etags != nil {
+		cached, hasCached = dc.etags.get(key)
+	}
+	if hasCached {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := dc.sendRequest(req, tokenType)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if hasCached && resp.StatusCode == http.StatusNotModified {
+		return cached.value, nil
+	}
+
+	data, err := dc.getServerResponseData(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" && dc.etags != nil {
+		dc.etags.set(key, etagEntry{etag: etag, value: value})
+	}
+	return value, nil
+}
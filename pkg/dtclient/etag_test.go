@@ -0,0 +1,103 @@
+package dtclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetWithETag(t *testing.T) {
+	const etag = `"version-1"`
+
+	requests := 0
+	dynatraceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(`{"latestAgentVersion": "1.200.0.20200801-120000"}`))
+	}))
+	defer dynatraceServer.Close()
+
+	client, err := NewClient(dynatraceServer.URL, apiToken, paasToken, LatestAgentVersionCache(nil), ETagCaching(NewETagCache()))
+	require.NoError(t, err)
+	dc := client.(*dynatraceClient)
+
+	t.Run("GetLatestAgentVersion uses cached value on 304", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			v, err := dc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+			require.NoError(t, err)
+			assert.Equal(t, "1.200.0.20200801-120000", v)
+		}
+		assert.Equal(t, 2, requests, "conditional request still sent")
+	})
+
+	t.Run("response not parsed again on 304", func(t *testing.T) {
+		parsed := 0
+		parse := func(data []byte) (interface{}, error) {
+			parsed++
+			return string(data), nil
+		}
+
+		url := dc.getURL("/v1/deployment/installer/agent/windows/default/latest/metainfo")
+		first, err := dc.getWithETag(url, dynatracePaaSToken, parse)
+		require.NoError(t, err)
+		second, err := dc.getWithETag(url, dynatracePaaSToken, parse)
+		require.NoError(t, err)
+
+		assert.Equal(t, first, second)
+		assert.Equal(t, 1, parsed)
+	})
+}
+
+func TestGetWithETag_SharedCache(t *testing.T) {
+	const etag = `"version-1"`
+
+	notModified := 0
+	dynatraceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(`{"latestAgentVersion": "1.200.0.20200801-120000"}`))
+	}))
+	defer dynatraceServer.Close()
+
+	cache := NewETagCache()
+	newClient := func(paasToken string) Client {
+		client, err := NewClient(dynatraceServer.URL, apiToken, paasToken, LatestAgentVersionCache(nil), ETagCaching(cache))
+		require.NoError(t, err)
+		return client
+	}
+
+	t.Run("ETag reused by another client", func(t *testing.T) {
+		for _, client := range []Client{newClient(paasToken), newClient(paasToken)} {
+			v, err := client.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+			require.NoError(t, err)
+			assert.Equal(t, "1.200.0.20200801-120000", v)
+		}
+		assert.Equal(t, 1, notModified)
+	})
+
+	t.Run("ETag not reused for another token", func(t *testing.T) {
+		_, err := newClient("other-paas-token").GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+		require.NoError(t, err)
+		assert.Equal(t, 1, notModified)
+	})
+
+	t.Run("ETag dropped on invalidation", func(t *testing.T) {
+		cache.Invalidate()
+		_, err := newClient(paasToken).GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+		require.NoError(t, err)
+		assert.Equal(t, 1, notModified)
+	})
+}
@@ -0,0 +1,40 @@
+package dtclient
+
+import (
+	"net/http"
+)
+
+// RequestInterceptor is called with every request to the Dynatrace API right before it's sent, including retries,
+// e.g., for custom logging or to add headers. The request is already authenticated, so the interceptor must not log
+// the Authorization header or the query, which may contain tokens.
+type RequestInterceptor func(req *http.Request)
+
+// ResponseInterceptor is called with every request to the Dynatrace API together with its response, or the error if
+// no response has been received, e.g., for custom logging or metrics. The response body is already decompressed, and
+// must be left readable for the client.
+type ResponseInterceptor func(req *http.Request, resp *http.Response, err error)
+
+// interceptRequest runs the request interceptors of the client. The Authorization header is restored if an
+// interceptor removes it, unless the client allows that with AllowAuthorizationRemoval.
+func (dc *dynatraceClient) interceptRequest(req *http.Request) {
+	if len(dc.requestInterceptors) == 0 {
+		return
+	}
+
+	auth := req.Header.Get("Authorization")
+	for _, intercept := range dc.requestInterceptors {
+		intercept(req)
+	}
+
+	if auth != "" && req.Header.Get("Authorization") == "" && !dc.allowAuthorizationRemoval {
+		dc.logger.Info("Request interceptor removed the Authorization header, restoring it", "endpoint", req.URL.Path)
+		req.Header.Set("Authorization", auth)
+	}
+}
+
+// interceptResponse runs the response interceptors of the client.
+func (dc *dynatraceClient) interceptResponse(req *http.Request, resp *http.Response, err error) {
+	for _, intercept := range dc.responseInterceptors {
+		intercept(req, resp, err)
+	}
+}
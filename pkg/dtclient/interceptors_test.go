@@ -0,0 +1,101 @@
+package dtclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptors(t *testing.T) {
+	var received http.Header
+	ts := httptest.NewServer(dynatraceServerHandlerWith(func(r *http.Request, w http.ResponseWriter) {
+		received = r.Header.Clone()
+		handleRequest(r, w)
+	}))
+	defer ts.Close()
+
+	t.Run("request and response interceptors run around each call", func(t *testing.T) {
+		var requests []string
+		var statuses []int
+
+		dtc, err := NewClient(ts.URL, apiToken, paasToken,
+			LatestAgentVersionCache(nil),
+			InterceptRequests(func(req *http.Request) {
+				requests = append(requests, req.URL.Path)
+				req.Header.Set("X-Request-Id", "4711")
+			}),
+			InterceptResponses(func(req *http.Request, resp *http.Response, err error) {
+				assert.NoError(t, err)
+				statuses = append(statuses, resp.StatusCode)
+			}),
+		)
+		require.NoError(t, err)
+
+		v, err := dtc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+		require.NoError(t, err)
+		assert.Equal(t, "17", v, "response body left readable")
+
+		assert.Equal(t, []string{"/v1/deployment/installer/agent/unix/default/latest/metainfo"}, requests)
+		assert.Equal(t, []int{http.StatusOK}, statuses)
+		assert.Equal(t, "4711", received.Get("X-Request-Id"))
+		assert.Equal(t, "Api-Token "+paasToken, received.Get("Authorization"))
+	})
+
+	t.Run("Authorization header restored when removed", func(t *testing.T) {
+		dtc, err := NewClient(ts.URL, apiToken, paasToken,
+			LatestAgentVersionCache(nil),
+			InterceptRequests(func(req *http.Request) { req.Header.Del("Authorization") }),
+		)
+		require.NoError(t, err)
+
+		_, err = dtc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+		require.NoError(t, err)
+		assert.Equal(t, "Api-Token "+paasToken, received.Get("Authorization"))
+	})
+
+	t.Run("Authorization header replaced", func(t *testing.T) {
+		dtc, err := NewClient(ts.URL, apiToken, paasToken,
+			LatestAgentVersionCache(nil),
+			InterceptRequests(func(req *http.Request) { req.Header.Set("Authorization", "Bearer gateway-token") }),
+		)
+		require.NoError(t, err)
+
+		_, err = dtc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer gateway-token", received.Get("Authorization"))
+	})
+
+	t.Run("Authorization header removed if allowed", func(t *testing.T) {
+		dtc, err := NewClient(ts.URL, apiToken, paasToken,
+			LatestAgentVersionCache(nil),
+			InterceptRequests(func(req *http.Request) { req.Header.Del("Authorization") }),
+			AllowAuthorizationRemoval(),
+		)
+		require.NoError(t, err)
+
+		_, err = dtc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+		assert.Error(t, err, "unauthorized")
+	})
+
+	t.Run("response interceptor gets errors", func(t *testing.T) {
+		unreachable := httptest.NewServer(http.NotFoundHandler())
+		unreachable.Close()
+
+		var intercepted error
+		dtc, err := NewClient(unreachable.URL, apiToken, paasToken,
+			LatestAgentVersionCache(nil),
+			InterceptResponses(func(req *http.Request, resp *http.Response, err error) {
+				assert.Nil(t, resp)
+				intercepted = err
+			}),
+		)
+		require.NoError(t, err)
+
+		_, err = dtc.GetLatestAgentVersion(OsUnix, InstallerTypeDefault)
+		require.Error(t, err)
+		assert.Error(t, intercepted, "transport error passed to interceptor")
+	})
+}
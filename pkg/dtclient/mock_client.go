@@ -1,6 +1,11 @@
 package dtclient
 
-import "github.com/stretchr/testify/mock"
+import (
+	"io"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
 
 // MockDynatraceClient implements a Dynatrace REST API Client mock
 type MockDynatraceClient struct {
@@ -12,16 +17,59 @@ func (o *MockDynatraceClient) GetAgentVersionForIP(ip string) (string, error) {
 	return args.String(0), args.Error(1)
 }
 
+func (o *MockDynatraceClient) GetMonitoringModeForIP(ip string) (string, error) {
+	args := o.Called(ip)
+	return args.String(0), args.Error(1)
+}
+
+func (o *MockDynatraceClient) GetLastSeenForIP(ip string) (time.Time, error) {
+	args := o.Called(ip)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
 func (o *MockDynatraceClient) GetLatestAgentVersion(os, installerType string) (string, error) {
 	args := o.Called(os, installerType)
 	return args.String(0), args.Error(1)
 }
 
+func (o *MockDynatraceClient) GetLatestAgentVersionInfo(os, installerType string) (AgentVersionInfo, error) {
+	args := o.Called(os, installerType)
+	return args.Get(0).(AgentVersionInfo), args.Error(1)
+}
+
+func (o *MockDynatraceClient) GetLatestAgentVersionForBranch(os, installerType, branch string) (string, error) {
+	args := o.Called(os, installerType, branch)
+	return args.String(0), args.Error(1)
+}
+
+func (o *MockDynatraceClient) GetAvailableInstallers(version string) ([]AgentInstaller, error) {
+	args := o.Called(version)
+	return args.Get(0).([]AgentInstaller), args.Error(1)
+}
+
+func (o *MockDynatraceClient) GetAgentInstaller(os, installerType, version string) (io.ReadCloser, error) {
+	args := o.Called(os, installerType, version)
+	if r := args.Get(0); r != nil {
+		return r.(io.ReadCloser), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (o *MockDynatraceClient) GetAgentInstallerChecksum(os, installerType, version string) (string, error) {
+	args := o.Called(os, installerType, version)
+	return args.String(0), args.Error(1)
+}
+
 func (o *MockDynatraceClient) GetConnectionInfo() (ConnectionInfo, error) {
 	args := o.Called()
 	return args.Get(0).(ConnectionInfo), args.Error(1)
 }
 
+func (o *MockDynatraceClient) GetCommunicationHosts() ([]CommunicationHost, error) {
+	args := o.Called()
+	return args.Get(0).([]CommunicationHost), args.Error(1)
+}
+
 func (o *MockDynatraceClient) GetCommunicationHostForClient() (CommunicationHost, error) {
 	args := o.Called()
 	return args.Get(0).(CommunicationHost), args.Error(1)
@@ -32,6 +80,11 @@ func (o *MockDynatraceClient) SendEvent(event *EventData) error {
 	return args.Error(0)
 }
 
+func (o *MockDynatraceClient) SendDeploymentEvent(event *DeploymentEvent) error {
+	args := o.Called(event)
+	return args.Error(0)
+}
+
 func (o *MockDynatraceClient) GetEntityIDForIP(ip string) (string, error) {
 	args := o.Called(ip)
 	return args.String(0), args.Error(1)
@@ -42,7 +95,27 @@ func (o *MockDynatraceClient) GetTokenScopes(token string) (TokenScopes, error)
 	return args.Get(0).(TokenScopes), args.Error(1)
 }
 
+func (o *MockDynatraceClient) GetTokenMetadata(token string) (TokenMetadata, error) {
+	args := o.Called(token)
+	return args.Get(0).(TokenMetadata), args.Error(1)
+}
+
 func (o *MockDynatraceClient) GetClusterInfo() (*ClusterInfo, error) {
 	args := o.Called()
 	return args.Get(0).(*ClusterInfo), args.Error(1)
 }
+
+func (o *MockDynatraceClient) GetClusterVersion() (string, error) {
+	args := o.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (o *MockDynatraceClient) GetTenantStatus() (TenantStatus, error) {
+	args := o.Called()
+	return args.Get(0).(TenantStatus), args.Error(1)
+}
+
+func (o *MockDynatraceClient) GetEntities(entityType, selector string) ([]Entity, error) {
+	args := o.Called(entityType, selector)
+	return args.Get(0).([]Entity), args.Error(1)
+}
@@ -0,0 +1,78 @@
+package dtclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// DefaultRequestLimiter is used by clients created by NewClient unless the RequestLimit option is given. It's nil by
+// default, which doesn't limit requests.
+var DefaultRequestLimiter *RequestLimiter
+
+// RequestLimiter bounds the number of requests in flight to the Dynatrace API. It can be shared between clients, and
+// is safe for concurrent use. A request keeps its slot until the response body is closed.
+type RequestLimiter struct {
+	slots chan struct{}
+}
+
+// NewRequestLimiter creates a limiter that allows up to n requests in flight. Returns nil if n is not positive, which
+// doesn't limit requests.
+func NewRequestLimiter(n int) *RequestLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return &RequestLimiter{slots: make(chan struct{}, n)}
+}
+
+// acquire waits for a free slot. Returns the context's error if it's done before a slot is available.
+func (l *RequestLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *RequestLimiter) release() {
+	<-l.slots
+}
+
+// releasingBody releases the request slot once the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// do sends the request, waiting for a free slot on the request limiter of the client if there is one. Fails fast with
+// a CircuitOpenError while the circuit breaker of the client is open.
+//
+// The response body must be closed by the caller when no longer used.
+func (dc *dynatraceClient) do(req *http.Request) (*http.Response, error) {
+	l := dc.requestLimiter
+	if l == nil {
+		return dc.breakerDo(req)
+	}
+
+	if err := l.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := dc.breakerDo(req)
+	if err != nil {
+		l.release()
+		return nil, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: l.release}
+	return resp, nil
+}
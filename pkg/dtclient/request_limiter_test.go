@@ -0,0 +1,68 @@
+package dtclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLimiter(t *testing.T) {
+	t.Run("caps concurrent requests", func(t *testing.T) {
+		var inFlight, maxInFlight int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"version": "1.200.0"}`))
+		}))
+		defer server.Close()
+
+		limiter := NewRequestLimiter(2)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			dtc, err := NewClient(server.URL, "api", "paas", RequestLimit(limiter))
+			require.NoError(t, err)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := dtc.GetClusterInfo()
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+		assert.Equal(t, 0, len(limiter.slots))
+	})
+
+	t.Run("cancelled context releases waiting request", func(t *testing.T) {
+		limiter := NewRequestLimiter(1)
+		require.NoError(t, limiter.acquire(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, limiter.acquire(ctx))
+
+		limiter.release()
+		assert.NoError(t, limiter.acquire(context.Background()))
+	})
+
+	t.Run("unlimited if not positive", func(t *testing.T) {
+		assert.Nil(t, NewRequestLimiter(0))
+	})
+}
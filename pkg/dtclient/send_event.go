@@ -6,20 +6,40 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 const (
 	MarkedForTerminationEvent = "MARKED_FOR_TERMINATION"
+	CustomDeploymentEvent     = "CUSTOM_DEPLOYMENT"
 )
 
 // EventData struct which defines what event payload should contain
 type EventData struct {
-	EventType     string               `json:"eventType"`
-	StartInMillis uint64               `json:"start"`
-	EndInMillis   uint64               `json:"end"`
-	Description   string               `json:"description"`
-	AttachRules   EventDataAttachRules `json:"attachRules"`
-	Source        string               `json:"source"`
+	EventType         string               `json:"eventType"`
+	StartInMillis     uint64               `json:"start"`
+	EndInMillis       uint64               `json:"end"`
+	Description       string               `json:"description"`
+	AttachRules       EventDataAttachRules `json:"attachRules"`
+	Source            string               `json:"source"`
+	DeploymentName    string               `json:"deploymentName,omitempty"`
+	DeploymentVersion string               `json:"deploymentVersion,omitempty"`
+	CustomProperties  map[string]string    `json:"customProperties,omitempty"`
+}
+
+// DeploymentEvent describes a OneAgent version rolled out by the Operator
+type DeploymentEvent struct {
+	// Version is the OneAgent version being deployed
+	Version string
+
+	// ClusterID identifies the Kubernetes cluster where the OneAgent is deployed
+	ClusterID string
+
+	// ClusterName is the stable name of the Kubernetes cluster, sent as custom property if set
+	ClusterName string
+
+	// HostIPs contains the IP addresses of the hosts the event gets attached to
+	HostIPs []string
 }
 
 type EventDataAttachRules struct {
@@ -40,19 +60,74 @@ func (dc *dynatraceClient) SendEvent(eventData *EventData) error {
 		return err
 	}
 
-	url := fmt.Sprintf("%s/v1/events", dc.url)
+	url := dc.getURL("/v1/events")
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonStr))
 	if err != nil {
 		return fmt.Errorf("error initializing http request: %s", err.Error())
 	}
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", fmt.Sprintf("Api-Token %s", dc.apiToken))
 
-	response, err := dc.httpClient.Do(req)
+	response, err := dc.doWithAPIToken(req)
 	if err != nil {
 		return fmt.Errorf("error making post request to dynatrace api: %s", err.Error())
 	}
+	defer response.Body.Close()
 
 	_, err = dc.getServerResponseData(response)
 	return err
 }
+
+func (dc *dynatraceClient) SendDeploymentEvent(event *DeploymentEvent) error {
+	if event == nil {
+		return errors.New("no deployment event given")
+	}
+
+	if event.Version == "" {
+		return errors.New("no version set on deployment event")
+	}
+
+	seen := map[string]bool{}
+	var entityIDs []string
+
+	for _, ip := range event.HostIPs {
+		id, err := dc.GetEntityIDForIP(ip)
+		if err != nil {
+			dc.logger.Info("Deployment event: ignoring host", "ip", ip, "error", err)
+			continue
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			entityIDs = append(entityIDs, id)
+		}
+	}
+
+	if len(entityIDs) == 0 {
+		return errors.New("no hosts found to attach the deployment event to")
+	}
+
+	now := dc.now
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+	ts := uint64(now.UnixNano() / int64(time.Millisecond))
+
+	properties := map[string]string{"ClusterID": event.ClusterID}
+	if event.ClusterName != "" {
+		properties["ClusterName"] = event.ClusterName
+	}
+
+	return dc.SendEvent(&EventData{
+		EventType:         CustomDeploymentEvent,
+		Source:            "OneAgent Operator",
+		Description:       fmt.Sprintf("OneAgent version %s deployed", event.Version),
+		StartInMillis:     ts,
+		EndInMillis:       ts,
+		DeploymentName:    "OneAgent",
+		DeploymentVersion: event.Version,
+		CustomProperties:  properties,
+		AttachRules: EventDataAttachRules{
+			EntityIDs: entityIDs,
+		},
+	})
+}
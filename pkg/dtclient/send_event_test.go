@@ -2,10 +2,14 @@ package dtclient
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEventDataMarshal(t *testing.T) {
@@ -89,6 +93,67 @@ func testSendEvent(t *testing.T, dynatraceClient Client) {
 	}
 }
 
+func TestSendDeploymentEvent(t *testing.T) {
+	var received []byte
+
+	dynatraceServer := httptest.NewServer(dynatraceServerHandlerWith(func(request *http.Request, writer http.ResponseWriter) {
+		if request.URL.Path == "/v1/events" {
+			received, _ = ioutil.ReadAll(request.Body)
+		}
+		handleRequest(request, writer)
+	}))
+	defer dynatraceServer.Close()
+
+	dtc, err := NewClient(dynatraceServer.URL, apiToken, paasToken)
+	require.NoError(t, err)
+	dtc.(*dynatraceClient).now = time.Unix(1521540000, 0)
+
+	t.Run("SendDeploymentEvent posts deployment payload", func(t *testing.T) {
+		received = nil
+
+		err := dtc.SendDeploymentEvent(&DeploymentEvent{
+			Version:     "1.203.0.20200908-220956",
+			ClusterID:   "a0f9c3e4-7b14-4e5b-9d53-4a1f2c3b4d5e",
+			ClusterName: "production",
+			HostIPs:     []string{"10.11.12.13", "192.168.0.1", "127.0.0.1"},
+		})
+		require.NoError(t, err)
+
+		assert.JSONEq(t, `{
+			"eventType": "CUSTOM_DEPLOYMENT",
+			"start": 1521540000000,
+			"end": 1521540000000,
+			"description": "OneAgent version 1.203.0.20200908-220956 deployed",
+			"attachRules": {
+				"entityIds": [ "dynatraceSampleEntityId" ]
+			},
+			"source": "OneAgent Operator",
+			"deploymentName": "OneAgent",
+			"deploymentVersion": "1.203.0.20200908-220956",
+			"customProperties": {
+				"ClusterID": "a0f9c3e4-7b14-4e5b-9d53-4a1f2c3b4d5e",
+				"ClusterName": "production"
+			}
+		}`, string(received))
+	})
+
+	t.Run("SendDeploymentEvent fails without known hosts", func(t *testing.T) {
+		received = nil
+
+		err := dtc.SendDeploymentEvent(&DeploymentEvent{
+			Version: "1.203.0.20200908-220956",
+			HostIPs: []string{"127.0.0.1"},
+		})
+		assert.Error(t, err)
+		assert.Nil(t, received)
+	})
+
+	t.Run("SendDeploymentEvent fails without version", func(t *testing.T) {
+		err := dtc.SendDeploymentEvent(&DeploymentEvent{HostIPs: []string{"10.11.12.13"}})
+		assert.Error(t, err)
+	})
+}
+
 func handleSendEvent(request *http.Request, writer http.ResponseWriter) {
 	eventPostResponse := []byte(`{
 		"storedEventIds": [1],
@@ -0,0 +1,42 @@
+package dtclient
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// TenantStatus describes whether the Dynatrace environment is available for API requests
+type TenantStatus struct {
+	// Maintenance is true while the environment is under maintenance
+	Maintenance bool
+
+	// Message is the message returned by the server during maintenance
+	Message string
+}
+
+func (dc *dynatraceClient) GetTenantStatus() (TenantStatus, error) {
+	resp, err := dc.makeRequest(dc.getURL(clusterVersionEndpoint), dynatraceApiToken)
+	if err != nil {
+		return TenantStatus{}, err
+	}
+	defer func() {
+		// Unable to do anything, swallow error
+		_ = resp.Body.Close()
+	}()
+
+	_, err = dc.getServerResponseData(resp)
+	var serr ServerError
+	if errors.As(err, &serr) && isMaintenanceError(serr) {
+		return TenantStatus{Maintenance: true, Message: serr.Message}, nil
+	} else if err != nil {
+		return TenantStatus{}, err
+	}
+
+	return TenantStatus{}, nil
+}
+
+// isMaintenanceError returns true if the server error reports the environment to be under maintenance
+func isMaintenanceError(err ServerError) bool {
+	return err.Code == http.StatusServiceUnavailable && strings.Contains(strings.ToLower(err.Message), "maintenance")
+}
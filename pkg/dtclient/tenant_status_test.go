@@ -0,0 +1,43 @@
+package dtclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynatraceClient_GetTenantStatus(t *testing.T) {
+	newClient := func(t *testing.T, handler func(request *http.Request, writer http.ResponseWriter)) Client {
+		server := httptest.NewServer(clusterVersionRequestHandler(handler))
+		t.Cleanup(server.Close)
+
+		dtc, err := NewClient(server.URL, apiToken, paasToken)
+		require.NoError(t, err)
+		return dtc
+	}
+
+	t.Run("available tenant", func(t *testing.T) {
+		tenant, err := newClient(t, handleClusterVersionRequest).GetTenantStatus()
+		assert.NoError(t, err)
+		assert.Equal(t, TenantStatus{}, tenant)
+	})
+
+	t.Run("tenant under maintenance", func(t *testing.T) {
+		tenant, err := newClient(t, func(request *http.Request, writer http.ResponseWriter) {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = writer.Write([]byte(`{"error": {"code": 503, "message": "Cluster is in maintenance mode"}}`))
+		}).GetTenantStatus()
+		assert.NoError(t, err)
+		assert.Equal(t, TenantStatus{Maintenance: true, Message: "Cluster is in maintenance mode"}, tenant)
+	})
+
+	t.Run("unavailable without maintenance", func(t *testing.T) {
+		_, err := newClient(t, func(request *http.Request, writer http.ResponseWriter) {
+			writeError(writer, http.StatusServiceUnavailable)
+		}).GetTenantStatus()
+		assert.EqualError(t, err, "dynatrace server error 503: error received from server")
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // TokenScopes is a list of scopes assigned to a token
@@ -20,7 +21,26 @@ func (s TokenScopes) Contains(scope string) bool {
 	return false
 }
 
+// TokenMetadata contains the details of a token as returned by the token lookup API
+type TokenMetadata struct {
+	// ID is the public part of the token, which doesn't grant access on its own, but shouldn't be logged either.
+	ID string
+
+	Scopes TokenScopes
+
+	// ExpiresAt is nil if the token never expires.
+	ExpiresAt *time.Time
+}
+
 func (dc *dynatraceClient) GetTokenScopes(token string) (TokenScopes, error) {
+	md, err := dc.GetTokenMetadata(token)
+	if err != nil {
+		return nil, err
+	}
+	return md.Scopes, nil
+}
+
+func (dc *dynatraceClient) GetTokenMetadata(token string) (TokenMetadata, error) {
 	var model struct {
 		Token string `json:"token"`
 	}
@@ -28,38 +48,45 @@ func (dc *dynatraceClient) GetTokenScopes(token string) (TokenScopes, error) {
 
 	jsonStr, err := json.Marshal(model)
 	if err != nil {
-		return nil, err
+		return TokenMetadata{}, err
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/v1/tokens/lookup", dc.url), bytes.NewBuffer(jsonStr))
+	req, err := http.NewRequest("POST", dc.getURL("/v1/tokens/lookup"), bytes.NewBuffer(jsonStr))
 	if err != nil {
-		return nil, fmt.Errorf("error initializing http request: %w", err)
+		return TokenMetadata{}, fmt.Errorf("error initializing http request: %w", err)
 	}
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", fmt.Sprintf("Api-Token %s", token))
+	setAPITokenHeader(req, token)
 
-	resp, err := dc.httpClient.Do(req)
+	resp, err := dc.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error making post request to dynatrace api: %w", err)
+		return TokenMetadata{}, fmt.Errorf("error making post request to dynatrace api: %w", err)
 	}
 	defer resp.Body.Close()
 
 	data, err := dc.getServerResponseData(resp)
 	if err != nil {
-		return nil, err
+		return TokenMetadata{}, err
 	}
 
-	return dc.readResponseForTokenScopes(data)
+	return dc.readResponseForTokenMetadata(data)
 }
 
-func (dc *dynatraceClient) readResponseForTokenScopes(response []byte) (TokenScopes, error) {
+func (dc *dynatraceClient) readResponseForTokenMetadata(response []byte) (TokenMetadata, error) {
 	var jr struct {
-		Scopes []string `json:"scopes"`
+		ID      string   `json:"id"`
+		Scopes  []string `json:"scopes"`
+		Expires *int64   `json:"expires"`
 	}
 
 	if err := json.Unmarshal(response, &jr); err != nil {
-		return nil, fmt.Errorf("error unmarshalling json response: %w", err)
+		return TokenMetadata{}, fmt.Errorf("error unmarshalling json response: %w", err)
 	}
 
-	return jr.Scopes, nil
+	md := TokenMetadata{ID: jr.ID, Scopes: jr.Scopes}
+	if jr.Expires != nil {
+		expiresAt := time.Unix(0, *jr.Expires*int64(time.Millisecond)).UTC()
+		md.ExpiresAt = &expiresAt
+	}
+	return md, nil
 }
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -23,6 +24,27 @@ func testGetTokenScopes(t *testing.T, dynatraceClient Client) {
 	}
 }
 
+func testGetTokenMetadata(t *testing.T, dynatraceClient Client) {
+	{
+		md, err := dynatraceClient.GetTokenMetadata("good-token")
+		assert.NoError(t, err)
+		assert.Equal(t, "f7060574-e8cf-4bc2-a9e0-307517ca9957", md.ID)
+		assert.Nil(t, md.ExpiresAt, "never expires")
+	}
+	{
+		md, err := dynatraceClient.GetTokenMetadata("expiring-token")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"InstallerDownload"}, md.Scopes)
+		if assert.NotNil(t, md.ExpiresAt) {
+			assert.Equal(t, time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC), *md.ExpiresAt)
+		}
+	}
+	{
+		_, err := dynatraceClient.GetTokenMetadata("bad-token")
+		assert.Exactly(t, ServerError{Code: 401, Message: "error received from server"}, err)
+	}
+}
+
 func handleTokenScopes(request *http.Request, writer http.ResponseWriter) {
 	var model struct {
 		Token string `json:"token"`
@@ -49,6 +71,17 @@ func handleTokenScopes(request *http.Request, writer http.ResponseWriter) {
 				"LogExport"
 			]
 		}`))
+	case "expiring-token":
+		writer.WriteHeader(http.StatusOK)
+		writer.Write([]byte(`{
+			"id": "0b6b4a5f-3ac5-4b44-bd5c-5c0e0f5b3a21",
+			"name": "the-expiring-token",
+			"userId": "the-user",
+			"expires": 1601553600000,
+			"scopes": [
+				"InstallerDownload"
+			]
+		}`))
 	default:
 		writeError(writer, http.StatusUnauthorized)
 	}
@@ -185,13 +185,14 @@ func mockDynatraceClientFunc(communicationHosts *[]string) utils.DynatraceClient
 		dtc := new(dtclient.MockDynatraceClient)
 		dtc.On("GetLatestAgentVersion", "unix", "default").Return("17", nil)
 		dtc.On("GetConnectionInfo").Return(connInfo, nil)
+		dtc.On("GetCommunicationHosts").Return(commHosts, nil)
 		dtc.On("GetCommunicationHostForClient").Return(dtclient.CommunicationHost{
 			Protocol: "https",
 			Host:     DefaultTestAPIURL,
 			Port:     443,
 		}, nil)
-		dtc.On("GetTokenScopes", "42").Return(dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}, nil)
-		dtc.On("GetTokenScopes", "43").Return(dtclient.TokenScopes{dtclient.TokenScopeDataExport}, nil)
+		dtc.On("GetTokenMetadata", "42").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeInstallerDownload}}, nil)
+		dtc.On("GetTokenMetadata", "43").Return(dtclient.TokenMetadata{Scopes: dtclient.TokenScopes{dtclient.TokenScopeDataExport}}, nil)
 
 		return dtc, nil
 	}
@@ -30,3 +30,21 @@ func IsSupportedAgentVersion(agentVersion versionInfo) bool {
 	comparison := compareVersionInfo(agentVersion, minSupportedAgentVersion)
 	return comparison >= 0
 }
+
+// CompareAgentVersions compares two OneAgent versions in Dynatrace's format, e.g. 1.203.0.20200908-220956. The build
+// timestamp is only taken into account if it is given on both versions.
+//
+// Returns 0 if a == b, n > 0 if a > b, and n < 0 if a < b.
+func CompareAgentVersions(a string, b string) (int, error) {
+	va, err := extractVersion(a)
+	if err != nil {
+		return 0, err
+	}
+
+	vb, err := extractVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	return compareVersionInfo(va, vb), nil
+}
@@ -51,3 +51,34 @@ func TestIsSupportedAgentVersion(t *testing.T) {
 		assert.False(t, isSupported)
 	})
 }
+
+func TestCompareAgentVersions(t *testing.T) {
+	t.Run("CompareAgentVersions", func(t *testing.T) {
+		result, err := CompareAgentVersions("1.203.0.20200908-220956", "1.203.0.20200908-220956")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, result)
+
+		result, err = CompareAgentVersions("1.202.0.20200808-120956", "1.203.0.20200908-220956")
+		assert.NoError(t, err)
+		assert.True(t, result < 0)
+
+		result, err = CompareAgentVersions("1.203.0.20200908-220956", "1.203.0.20200901-220956")
+		assert.NoError(t, err)
+		assert.True(t, result > 0)
+
+		result, err = CompareAgentVersions("1.200.1.12345", "1.200.1.123456")
+		assert.NoError(t, err)
+		assert.True(t, result < 0)
+
+		result, err = CompareAgentVersions("1.203.0", "1.203.0.20200908-220956")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, result)
+	})
+	t.Run("CompareAgentVersions fails on malformed version", func(t *testing.T) {
+		_, err := CompareAgentVersions("1.203", "1.203.0.20200908-220956")
+		assert.Error(t, err)
+
+		_, err = CompareAgentVersions("1.203.0.20200908-220956", "")
+		assert.Error(t, err)
+	})
+}
@@ -4,15 +4,17 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 type versionInfo struct {
-	major   int
-	minor   int
-	release int
+	major     int
+	minor     int
+	release   int
+	timestamp string
 }
 
-var versionRegex = regexp.MustCompile(`^([\d]+)\.([\d]+)\.([\d]+)`)
+var versionRegex = regexp.MustCompile(`^([\d]+)\.([\d]+)\.([\d]+)(?:\.([\d]+(?:-[\d]+)?))?`)
 
 // compareVersionInfo returns:
 // 	0: if a == b
@@ -34,7 +36,18 @@ func compareVersionInfo(a versionInfo, b versionInfo) int {
 
 	// Major and minor is equal, check release
 	result = a.release - b.release
-	return result
+	if result != 0 {
+		return result
+	}
+
+	// Major, minor and release is equal, check timestamp if both are given
+	if a.timestamp == "" || b.timestamp == "" {
+		return 0
+	}
+	if len(a.timestamp) != len(b.timestamp) {
+		return len(a.timestamp) - len(b.timestamp)
+	}
+	return strings.Compare(a.timestamp, b.timestamp)
 }
 
 func extractVersion(versionString string) (versionInfo, error) {
@@ -59,5 +72,5 @@ func extractVersion(versionString string) (versionInfo, error) {
 		return versionInfo{}, err
 	}
 
-	return versionInfo{major, minor, release}, nil
+	return versionInfo{major, minor, release, version[4]}, nil
 }
@@ -10,9 +10,10 @@ import (
 	"reflect"
 	"time"
 
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/controller/utils"
 	"github.com/Dynatrace/dynatrace-oneagent-operator/pkg/webhook"
 	"github.com/go-logr/logr"
-	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -38,7 +39,7 @@ const (
 // AddToManager creates a new OneAgent Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func AddToManager(mgr manager.Manager) error {
-	ns, err := k8sutil.GetWatchNamespace()
+	ns, err := utils.GetOperatorNamespace()
 	if err != nil {
 		return err
 	}
@@ -227,6 +228,8 @@ func (r *ReconcileWebhook) reconcileWebhookConfig(ctx context.Context, log logr.
 
 	scope := admissionregistrationv1beta1.NamespacedScope
 	path := "/inject"
+	defaultingPath := webhook.DefaultingPath
+	ignore := admissionregistrationv1beta1.Ignore
 	webhookConfiguration := &admissionregistrationv1beta1.MutatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: webhookName,
@@ -261,6 +264,31 @@ func (r *ReconcileWebhook) reconcileWebhookConfig(ctx context.Context, log logr.
 				},
 				CABundle: rootCerts,
 			},
+		}, {
+			// Failures are ignored, the operator applies the same defaults to unset fields.
+			Name:                    "defaulting.oneagent.dynatrace.com",
+			AdmissionReviewVersions: []string{"v1beta1"},
+			Rules: []admissionregistrationv1beta1.RuleWithOperations{{
+				Operations: []admissionregistrationv1beta1.OperationType{
+					admissionregistrationv1beta1.Create,
+					admissionregistrationv1beta1.Update,
+				},
+				Rule: admissionregistrationv1beta1.Rule{
+					APIGroups:   []string{dynatracev1alpha1.SchemeGroupVersion.Group},
+					APIVersions: []string{dynatracev1alpha1.SchemeGroupVersion.Version},
+					Resources:   []string{"oneagents"},
+					Scope:       &scope,
+				},
+			}},
+			FailurePolicy: &ignore,
+			ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+				Service: &admissionregistrationv1beta1.ServiceReference{
+					Name:      webhookName,
+					Namespace: r.namespace,
+					Path:      &defaultingPath,
+				},
+				CABundle: rootCerts,
+			},
 		}},
 	}
 
@@ -279,7 +307,7 @@ func (r *ReconcileWebhook) reconcileWebhookConfig(ctx context.Context, log logr.
 		return err
 	}
 
-	if len(cfg.Webhooks) == 1 && bytes.Equal(cfg.Webhooks[0].ClientConfig.CABundle, rootCerts) {
+	if isWebhookConfigUpToDate(&cfg, webhookConfiguration) {
 		return nil
 	}
 
@@ -287,3 +315,18 @@ func (r *ReconcileWebhook) reconcileWebhookConfig(ctx context.Context, log logr.
 	cfg.Webhooks = webhookConfiguration.Webhooks
 	return r.client.Update(ctx, &cfg)
 }
+
+// isWebhookConfigUpToDate returns true if the configuration has the expected webhooks, all with the given CA bundle
+func isWebhookConfigUpToDate(cfg, expected *admissionregistrationv1beta1.MutatingWebhookConfiguration) bool {
+	if len(cfg.Webhooks) != len(expected.Webhooks) {
+		return false
+	}
+
+	for i := range cfg.Webhooks {
+		if cfg.Webhooks[i].Name != expected.Webhooks[i].Name ||
+			!bytes.Equal(cfg.Webhooks[i].ClientConfig.CABundle, expected.Webhooks[i].ClientConfig.CABundle) {
+			return false
+		}
+	}
+	return true
+}
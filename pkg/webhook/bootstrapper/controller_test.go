@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/require"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -103,3 +104,31 @@ func TestReconcileWebhook(t *testing.T) {
 	assert.Equal(t, secret400, secret401)
 	assert.Equal(t, secret401["ca.crt"], getWebhookCA())
 }
+
+func TestReconcileWebhookConfig_AddsDefaultingWebhook(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true), zap.WriteTo(os.Stdout))
+	rootCerts := []byte("ca")
+
+	outdated := &admissionregistrationv1beta1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: webhook.ServiceName},
+		Webhooks: []admissionregistrationv1beta1.MutatingWebhook{{
+			Name:         "webhook.oneagent.dynatrace.com",
+			ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{CABundle: rootCerts},
+		}},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, outdated)
+	r := ReconcileWebhook{client: c, logger: logger, namespace: "dynatrace", scheme: scheme.Scheme}
+	require.NoError(t, r.reconcileWebhookConfig(context.TODO(), logger, rootCerts))
+
+	var cfg admissionregistrationv1beta1.MutatingWebhookConfiguration
+	require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: webhook.ServiceName}, &cfg))
+	require.Len(t, cfg.Webhooks, 2)
+
+	defaulting := cfg.Webhooks[1]
+	assert.Equal(t, "defaulting.oneagent.dynatrace.com", defaulting.Name)
+	assert.Equal(t, webhook.DefaultingPath, *defaulting.ClientConfig.Service.Path)
+	assert.Equal(t, rootCerts, defaulting.ClientConfig.CABundle)
+	assert.Equal(t, admissionregistrationv1beta1.Ignore, *defaulting.FailurePolicy)
+	assert.Equal(t, []string{"oneagents"}, defaulting.Rules[0].Resources)
+}
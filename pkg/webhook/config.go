@@ -49,4 +49,7 @@ const (
 
 	// ServiceName is the name used for the webhook's corresponding Service and MutatingWebhookConfiguration objects.
 	ServiceName = "dynatrace-oneagent-webhook"
+
+	// DefaultingPath is the path of the webhook filling the defaults onto OneAgent objects.
+	DefaultingPath = "/default-oneagent"
 )
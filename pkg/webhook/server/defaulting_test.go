@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	dynatracev1alpha1 "github.com/Dynatrace/dynatrace-oneagent-operator/pkg/apis/dynatrace/v1alpha1"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestOneAgentDefaulting(t *testing.T) {
+	wh := admission.DefaultingWebhookFor(&dynatracev1alpha1.OneAgent{})
+	require.NoError(t, wh.InjectScheme(scheme.Scheme))
+
+	defaulted := func(t *testing.T, oa *dynatracev1alpha1.OneAgent) dynatracev1alpha1.OneAgentSpec {
+		raw, err := json.Marshal(oa)
+		require.NoError(t, err)
+
+		req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Operation: admissionv1beta1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+			Namespace: "dynatrace",
+		}}
+		resp := wh.Handle(context.TODO(), req)
+		require.NoError(t, resp.Complete(req))
+		require.True(t, resp.Allowed, resp.Result)
+
+		patched := raw
+		if len(resp.Patch) > 0 {
+			patch, err := jsonpatch.DecodePatch(resp.Patch)
+			require.NoError(t, err)
+			patched, err = patch.Apply(raw)
+			require.NoError(t, err)
+		}
+
+		var actual dynatracev1alpha1.OneAgent
+		require.NoError(t, json.Unmarshal(patched, &actual))
+		return actual.Spec
+	}
+
+	newOneAgent := func() *dynatracev1alpha1.OneAgent {
+		return &dynatracev1alpha1.OneAgent{
+			TypeMeta:   metav1.TypeMeta{Kind: "OneAgent", APIVersion: "dynatrace.com/v1alpha1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "oneagent", Namespace: "dynatrace"},
+			Spec: dynatracev1alpha1.OneAgentSpec{
+				BaseOneAgentSpec: dynatracev1alpha1.BaseOneAgentSpec{APIURL: "https://ENVIRONMENTID.live.dynatrace.com/api"},
+			},
+		}
+	}
+
+	t.Run("unset fields", func(t *testing.T) {
+		spec := defaulted(t, newOneAgent())
+		assert.Equal(t, corev1.DNSClusterFirst, spec.DNSPolicy)
+		if assert.NotNil(t, spec.WaitReadySeconds) {
+			assert.Equal(t, uint16(300), *spec.WaitReadySeconds)
+		}
+		assert.Equal(t, dynatracev1alpha1.DeploymentTypeDaemonSet, spec.DeploymentType)
+		assert.Nil(t, spec.Replicas, "replicas only defaulted for deployments")
+		assert.Nil(t, spec.CanaryRollout)
+	})
+
+	t.Run("deployment with canary rollout", func(t *testing.T) {
+		oa := newOneAgent()
+		oa.Spec.DeploymentType = dynatracev1alpha1.DeploymentTypeDeployment
+		oa.Spec.CanaryRollout = &dynatracev1alpha1.CanaryRollout{Percentage: 10}
+
+		spec := defaulted(t, oa)
+		if assert.NotNil(t, spec.Replicas) {
+			assert.Equal(t, int32(1), *spec.Replicas)
+		}
+		assert.Equal(t, dynatracev1alpha1.CanaryPromotionManual, spec.CanaryRollout.Promotion)
+	})
+
+	t.Run("set fields are kept", func(t *testing.T) {
+		waitSecs := uint16(60)
+		oa := newOneAgent()
+		oa.Spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
+		oa.Spec.WaitReadySeconds = &waitSecs
+		oa.Spec.CanaryRollout = &dynatracev1alpha1.CanaryRollout{Percentage: 10, Promotion: dynatracev1alpha1.CanaryPromotionAutomatic}
+
+		spec := defaulted(t, oa)
+		assert.Equal(t, corev1.DNSClusterFirstWithHostNet, spec.DNSPolicy)
+		assert.Equal(t, uint16(60), *spec.WaitReadySeconds)
+		assert.Equal(t, dynatracev1alpha1.CanaryPromotionAutomatic, spec.CanaryRollout.Promotion)
+	})
+}
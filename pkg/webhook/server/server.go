@@ -27,7 +27,7 @@ var logger = log.Log.WithName("oneagent.webhook")
 
 // AddToManager adds the Webhook server to the Manager
 func AddToManager(mgr manager.Manager) error {
-	ns, err := k8sutil.GetWatchNamespace()
+	ns, err := utils.GetOperatorNamespace()
 	if err != nil {
 		return err
 	}
@@ -50,6 +50,8 @@ func AddToManager(mgr manager.Manager) error {
 		image:     pod.Spec.Containers[0].Image,
 	}})
 
+	mgr.GetWebhookServer().Register(dtwebhook.DefaultingPath, admission.DefaultingWebhookFor(&dynatracev1alpha1.OneAgent{}))
+
 	mgr.GetWebhookServer().Register("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))